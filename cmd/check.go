@@ -0,0 +1,77 @@
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var checkCmd = &cobra.Command{
+	Use:   "check [directory]",
+	Short: "Check markdown files for broken links",
+	Long: `Render all markdown files in a directory and check that relative links,
+image paths and heading anchors resolve. With --prose, possible spelling
+mistakes and repeated words are reported too.
+
+Every broken link is printed with its file and line. The command exits with a
+non-zero status if any link is broken, so it can be used in CI.`,
+	Args: cobra.MaximumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		dir := "."
+		if len(args) > 0 {
+			dir = args[0]
+		}
+
+		var parserOpts []pkg.ParserOption
+		if wikiLinks {
+			parserOpts = append(parserOpts, pkg.WithWikiLinks(dir))
+		}
+		parser := pkg.NewParser(theme, parserOpts...)
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, false, parser,
+			pkg.WithMarkdownExtensions(markdownExtensions),
+			pkg.WithIncludes(includes),
+			pkg.WithVars(substituteVars || varsFile != "", varsFile),
+			pkg.WithHidden(hidden),
+			proseOption(),
+		)
+
+		report, err := srv.CheckLinks(dir)
+		if err != nil {
+			return err
+		}
+		for _, b := range report.Broken {
+			fmt.Println(b)
+		}
+		for _, p := range report.Prose {
+			fmt.Println(p)
+		}
+		if prose {
+			fmt.Printf("Checked %d files, found %d broken links and %d prose issues\n", report.Files, len(report.Broken), len(report.Prose))
+		} else {
+			fmt.Printf("Checked %d files, found %d broken links\n", report.Files, len(report.Broken))
+		}
+
+		if len(report.Broken) > 0 {
+			return fmt.Errorf("found %d broken links", len(report.Broken))
+		}
+		if len(report.Prose) > 0 {
+			return fmt.Errorf("found %d prose issues", len(report.Prose))
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(checkCmd)
+
+	checkCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions rendered as markdown")
+	checkCmd.Flags().BoolVar(&hidden, "hidden", false, "Check dotfiles and dot-directories")
+	checkCmd.Flags().BoolVar(&includes, "includes", false, "Expand <!-- include: file.md --> directives in markdown files")
+	checkCmd.Flags().BoolVar(&substituteVars, "substitute-vars", false, "Substitute {{ .name }} placeholders in markdown files with the fields of their front matter")
+	checkCmd.Flags().StringVar(&varsFile, "vars", "", "YAML file of variables for {{ .name }} placeholders, implies --substitute-vars; front matter fields take precedence")
+	checkCmd.Flags().BoolVar(&wikiLinks, "wiki-links", false, "Resolve [[Page Name]] wiki links against the checked directory")
+	checkCmd.Flags().BoolVar(&prose, "prose", false, "Report possible spelling mistakes and repeated words")
+	checkCmd.Flags().StringVar(&spellChecker, "spell-checker", pkg.DefaultSpellChecker, "Command listing the misspelled words of its standard input for --prose, empty to only find repeated words")
+	checkCmd.Flags().StringSliceVar(&dictionaries, "dictionary", nil, "Files of words --prose accepts, one per line, in addition to the .grip-words file of the directory")
+}
@@ -0,0 +1,108 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"net/url"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var diffRef string
+
+var diffCmd = &cobra.Command{
+	Use:   "diff FILE [NEW_FILE]",
+	Short: "Preview the rendered changes of a markdown file",
+	Long: `Preview a markdown file with the blocks that changed highlighted, like
+GitHub's rich diff.
+
+Basic usage:
+  go-grip diff README.md				# changes since the last commit
+  go-grip diff README.md --ref v1.0		# changes since a tag or commit
+  go-grip diff README.md --ref v1.0..main	# changes between two revisions
+  go-grip diff old.md new.md			# changes between two files
+
+The preview reloads whenever a file in the directory changes.`,
+	Args: cobra.RangeArgs(1, 2),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) == 1 {
+			if old, new, ok := strings.Cut(args[0], ".."); ok && !fileExists(args[0]) {
+				args = []string{old, new}
+			}
+		}
+
+		q := url.Values{}
+		var serveFile string
+		if len(args) == 2 {
+			if diffRef != "" {
+				return fmt.Errorf("--ref can't be used when comparing two files")
+			}
+			// both files are served from the working directory
+			for i, name := range args {
+				rel, err := filepath.Rel(".", filepath.Clean(name))
+				if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+					return fmt.Errorf("%s is outside of the working directory", name)
+				}
+				args[i] = filepath.ToSlash(rel)
+			}
+			q.Set("old", args[0])
+			q.Set("file", args[1])
+		} else {
+			serveFile = args[0]
+			q.Set("file", filepath.Base(args[0]))
+			from, to, _ := strings.Cut(diffRef, "..")
+			if from != "" {
+				q.Set("from", from)
+			}
+			if to != "" {
+				q.Set("to", to)
+			}
+		}
+		for _, name := range args {
+			if !fileExists(name) && diffRef == "" {
+				return fmt.Errorf("file not found: %s", name)
+			}
+		}
+
+		parser := pkg.NewParser(theme, graphvizOption(cmd))
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, browser, parser,
+			pkg.WithPortScan(10),
+			pkg.WithMarkdownExtensions(markdownExtensions),
+			pkg.WithStartPage("/diff?"+q.Encode()),
+			pkg.WithBrowserCommand(browserCmd),
+		)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if err := srv.Serve(ctx, serveFile); err != nil {
+			return fmt.Errorf("server error: %v", err)
+		}
+		return nil
+	},
+}
+
+func fileExists(name string) bool {
+	info, err := os.Stat(name)
+	return err == nil && !info.IsDir()
+}
+
+func init() {
+	rootCmd.AddCommand(diffCmd)
+
+	diffCmd.Flags().StringVar(&diffRef, "ref", "", "Git revision to compare against, or A..B to compare two revisions (default HEAD)")
+	diffCmd.Flags().StringVar(&theme, "theme", "auto", "Select CSS theme [light/dark/dark_dimmed/dark_high_contrast/light_high_contrast/auto]")
+	diffCmd.Flags().BoolVar(&boundingBox, "bounding-box", true, "Add bounding box to HTML output")
+	diffCmd.Flags().BoolVarP(&browser, "browser", "b", true, "Open browser tab automatically")
+	diffCmd.Flags().StringVar(&browserCmd, "browser-cmd", "", "Command opening the browser, e.g. \"firefox --new-window %s\" (default: $BROWSER or the system default)")
+	diffCmd.Flags().StringSliceVarP(&hosts, "host", "H", []string{"localhost"}, "Host(s) to listen on, repeat for multiple addresses")
+	diffCmd.Flags().IntVarP(&port, "port", "p", 6419, "Port to listen on")
+	diffCmd.Flags().StringVar(&graphvizDot, "graphviz-dot", "", "Graphviz dot binary used to render dot code blocks, empty to show them as code (default: dot if it is installed)")
+	diffCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions rendered as markdown")
+}
@@ -0,0 +1,223 @@
+package cmd
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"strings"
+	"syscall"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var (
+	exportPDF    bool
+	exportEPUB   bool
+	exportWatch  bool
+	exportOutput string
+	exportTitle  string
+	chromePath   string
+)
+
+var exportCmd = &cobra.Command{
+	Use:   "export FILE...",
+	Short: "export md document to another format",
+	Long: `Export a markdown file to another format.
+
+Basic usage:
+  go-grip export --pdf FILE			# print FILE to FILE.pdf with headless Chromium
+  go-grip export --pdf FILE -o OUT.pdf	# specify output file
+  go-grip export --epub FILE...		# package files as chapters of FILE.epub
+  go-grip export --epub DIR -o BOOK.epub	# package the markdown files of DIR
+  go-grip export --watch DIR -o SITE	# keep the HTML pages of DIR in SITE up to date`,
+	Args: cobra.MinimumNArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if exportWatch {
+			if exportPDF || exportEPUB {
+				return fmt.Errorf("--watch exports static HTML, not --pdf or --epub")
+			}
+			if len(args) > 1 {
+				return fmt.Errorf("--watch exports a single file or directory")
+			}
+			if exportOutput == "" {
+				return fmt.Errorf("--watch needs an output directory, -o DIR")
+			}
+		} else if exportPDF == exportEPUB {
+			return fmt.Errorf("select one export format, --pdf or --epub")
+		}
+
+		refOpts, err := referencesOption()
+		if err != nil {
+			return err
+		}
+		footnoteOpts, err := footnoteOptions()
+		if err != nil {
+			return err
+		}
+		refOpts = append(refOpts, footnoteOpts...)
+		parser := pkg.NewParser(theme, append([]pkg.ParserOption{
+			pkg.WithMapTiles(mapTiles),
+			pkg.WithPlantUML(plantumlServer, plantumlJar),
+			pkg.WithDiagramCache(cachePath("diagrams"), int64(diagramCacheSize)<<20),
+			graphvizOption(cmd),
+			pkg.WithSmartypants(smartypants),
+			pkg.WithHardWraps(hardWraps),
+			pkg.WithDefinitionLists(definitionLists),
+			pkg.WithAttributes(attributeLists),
+			pkg.WithAbbreviations(abbreviations),
+			pkg.WithEmbeds(embeds),
+			pkg.WithLanguageDetection(detectLanguage),
+			pkg.WithLexerAliases(lexerAliases),
+		}, refOpts...)...)
+		enc, err := pkg.ParseEncoding(encoding)
+		if err != nil {
+			return err
+		}
+		opts := []pkg.Option{
+			pkg.WithEncoding(enc),
+			pkg.WithIncludes(includes),
+			pkg.WithVars(substituteVars || varsFile != "", varsFile),
+			pkg.WithMarkdownExtensions(markdownExtensions),
+			pkg.WithReloadExclude(reloadExclude),
+		}
+		opts = append(opts, pkg.WithTypography(typography))
+		opts = append(opts, pkg.WithStrictOffline(strictOffline))
+		layoutOpts, err := layoutOptions()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, layoutOpts...)
+		langOpt, err := langOption()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, langOpt)
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, false, parser, opts...)
+
+		if exportWatch {
+			ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+			defer stop()
+			return srv.WatchExport(ctx, args[0], exportOutput)
+		}
+
+		if exportEPUB {
+			files, err := epubFiles(srv, args)
+			if err != nil {
+				return err
+			}
+			return srv.GenerateEPUB(files, exportOutputFile(args[0], ".epub"), exportTitle)
+		}
+
+		if len(args) > 1 {
+			return fmt.Errorf("--pdf exports a single file")
+		}
+		input := args[0]
+		info, err := os.Stat(input)
+		if err != nil {
+			return fmt.Errorf("file not found: %s - %v", input, err)
+		}
+		if info.IsDir() {
+			return fmt.Errorf("expected a file but got a directory '%s'", input)
+		}
+		return srv.GeneratePDF(input, exportOutputFile(input, ".pdf"), chromePath)
+	},
+}
+
+// exportOutputFile returns the --output flag, or input with the extension of
+// the export format in the working directory.
+func exportOutputFile(input string, ext string) string {
+	if exportOutput != "" {
+		return exportOutput
+	}
+	base := filepath.Base(filepath.Clean(input))
+	return strings.TrimSuffix(base, filepath.Ext(base)) + ext
+}
+
+// epubFiles returns the markdown files of the arguments in chapter order.
+// Directories contribute their markdown files, README.md first and the rest
+// sorted by name.
+func epubFiles(srv *pkg.Server, args []string) ([]string, error) {
+	var files []string
+	for _, arg := range args {
+		info, err := os.Stat(arg)
+		if err != nil {
+			return nil, fmt.Errorf("file not found: %s - %v", arg, err)
+		}
+		if !info.IsDir() {
+			files = append(files, arg)
+			continue
+		}
+
+		entries, err := os.ReadDir(arg)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read directory: %v", err)
+		}
+		var readme []string
+		var rest []string
+		for _, entry := range entries {
+			if entry.IsDir() || !srv.IsMarkdown(entry.Name()) {
+				continue
+			}
+			if strings.EqualFold(entry.Name(), "README.md") {
+				readme = append(readme, filepath.Join(arg, entry.Name()))
+			} else {
+				rest = append(rest, filepath.Join(arg, entry.Name()))
+			}
+		}
+		if len(readme)+len(rest) == 0 {
+			return nil, fmt.Errorf("no markdown files found in directory %s", arg)
+		}
+		files = append(files, readme...)
+		files = append(files, rest...)
+	}
+	return files, nil
+}
+
+func init() {
+	rootCmd.AddCommand(exportCmd)
+
+	exportCmd.Flags().StringVar(&theme, "theme", "light", "Select CSS theme [light/dark/dark_dimmed/dark_high_contrast/light_high_contrast/auto]")
+	exportCmd.Flags().BoolVar(&boundingBox, "bounding-box", false, "Add bounding box to the output")
+	exportCmd.Flags().StringVar(&plantumlServer, "plantuml-server", "", "PlantUML server URL used to render plantuml code blocks, e.g. https://www.plantuml.com/plantuml")
+	exportCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory rendered diagrams and remote images are cached in (default: no diagram cache, remote images in the user cache directory)")
+	exportCmd.Flags().IntVar(&diagramCacheSize, "diagram-cache-size", pkg.DefaultDiagramCacheSize>>20, "Disk space in MB used to cache rendered diagrams (0 disables the cache)")
+	exportCmd.Flags().StringVar(&plantumlJar, "plantuml-jar", "", "plantuml.jar used to render plantuml code blocks if no server is set")
+	exportCmd.Flags().StringVar(&graphvizDot, "graphviz-dot", "", "Graphviz dot binary used to render dot code blocks, empty to show them as code (default: dot if it is installed)")
+	exportCmd.Flags().BoolVar(&detectLanguage, "detect-language", true, "Highlight code blocks without language in the language guessed from their content")
+	exportCmd.Flags().StringToStringVar(&lexerAliases, "lexer-alias", nil, "Highlight code blocks in a language like another one, e.g. tf=terraform,jsonc=json")
+	exportCmd.Flags().BoolVar(&embeds, "embeds", false, "Embed YouTube videos and GitHub Gists linked on their own line, loaded from the internet")
+	exportCmd.Flags().StringVar(&repo, "repo", "", "Link #123, GH-123, owner/repo#123, @user and commit SHA references like GitHub does in the READMEs of this owner/name repository")
+	exportCmd.Flags().BoolVar(&abbreviations, "abbreviations", false, "Show the definitions of *[ABBR]: definition abbreviations as tooltips")
+	exportCmd.Flags().StringVar(&footnoteMarkers, "footnote-markers", pkg.FootnoteNumeric, "Mark footnotes with numbers or with symbols like * and †, numeric or symbols")
+	exportCmd.Flags().StringVar(&footnoteBacklink, "footnote-backlink", pkg.DefaultFootnoteBacklink, "Text of the links from footnotes back to their references")
+	exportCmd.Flags().StringVar(&bibliographyFile, "bibliography", "", "BibTeX or CSL-JSON file resolving [@key] citations, listed in a References section")
+	exportCmd.Flags().StringVar(&citationStyle, "citation-style", pkg.CitationNumeric, "Format citations of --bibliography as numeric or author-date")
+	exportCmd.Flags().BoolVar(&attributeLists, "attributes", false, "Attach ids, classes and attributes to blocks with {#id .class} attribute lists")
+	exportCmd.Flags().BoolVar(&definitionLists, "definition-lists", false, "Render \"Term\" lines followed by \": definition\" lines as definition lists")
+	exportCmd.Flags().BoolVar(&hardWraps, "hard-wraps", false, "Render single newlines as line breaks like GitHub comments instead of joining lines like GitHub files")
+	exportCmd.Flags().BoolVar(&smartypants, "smartypants", false, "Convert straight quotes, dashes and ellipses to typographic ones")
+	exportCmd.Flags().BoolVar(&includes, "includes", false, "Expand <!-- include: file.md --> directives in markdown files")
+	exportCmd.Flags().BoolVar(&substituteVars, "substitute-vars", false, "Substitute {{ .name }} placeholders in markdown files with the fields of their front matter")
+	exportCmd.Flags().StringVar(&varsFile, "vars", "", "YAML file of variables for {{ .name }} placeholders, implies --substitute-vars; front matter fields take precedence")
+	exportCmd.Flags().StringVar(&mapTiles, "map-tiles", "", "Tile URL template for GeoJSON and TopoJSON maps, e.g. "+pkg.OpenStreetMapTiles+" (default: maps without tiles)")
+	exportCmd.Flags().BoolVar(&strictOffline, "strict-offline", false, "Fail rendering pages that load scripts, stylesheets, fonts, frames or map tiles from other hosts, so they work without network access")
+	exportCmd.Flags().StringVar(&typography.MaxWidth, "max-width", "", "Maximum width of the content, e.g. 980px like GitHub or none for the full window width")
+	exportCmd.Flags().StringVar(&typography.FontFamily, "font-family", "", "CSS font family of the text")
+	exportCmd.Flags().StringVar(&typography.FontSize, "font-size", "", "Font size of the text, e.g. 18px")
+	exportCmd.Flags().StringVar(&typography.LineHeight, "line-height", "", "Line height of the text, e.g. 1.7")
+	exportCmd.Flags().StringVar(&templateFile, "template", "", "Use a custom page layout template instead of the embedded one")
+	exportCmd.Flags().StringToStringVar(&partials, "partial", nil, "Fill a block of the page layout (head, header, after-content or footer) with a template file, as name=file")
+	exportCmd.Flags().StringVar(&lang, "lang", pkg.DefaultLang, "Language of the user interface around the documents (de, en, es, fr, ja or zh)")
+	exportCmd.Flags().BoolVar(&exportPDF, "pdf", false, "Export a PDF using a headless Chromium based browser")
+	exportCmd.Flags().BoolVar(&exportEPUB, "epub", false, "Export an EPUB book with one chapter per file")
+	exportCmd.Flags().BoolVar(&exportWatch, "watch", false, "Export static HTML pages to the --output directory and regenerate the pages of changed files until interrupted")
+	exportCmd.Flags().StringSliceVar(&reloadExclude, "reload-exclude", nil, "Ignore changes of files matching a glob like *.log, build/*.html or an absolute path with --watch")
+	exportCmd.Flags().StringVar(&exportTitle, "title", "", "Title of the EPUB book (default: title of the first chapter)")
+	exportCmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the source files, e.g. utf-8, utf-16le or latin1, detected from a byte order mark or the content with auto")
+	exportCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions exported as markdown from directories")
+	exportCmd.Flags().StringVarP(&exportOutput, "output", "o", "", "Output file (default: FILE with the extension of the export format)")
+	exportCmd.Flags().StringVar(&chromePath, "chrome", "", "Path of the Chromium based browser used for --pdf (default: search PATH)")
+}
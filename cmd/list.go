@@ -0,0 +1,41 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"strings"
+	"text/tabwriter"
+	"time"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var listCmd = &cobra.Command{
+	Use:   "list",
+	Short: "List running preview servers",
+	Args:  cobra.NoArgs,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		instances, err := pkg.ListInstances()
+		if err != nil {
+			return fmt.Errorf("failed to list instances: %v", err)
+		}
+
+		if len(instances) == 0 {
+			fmt.Println("No running instances")
+			return nil
+		}
+
+		w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+		fmt.Fprintln(w, "ID\tPORT\tFILE\tUPTIME\tURL")
+		for _, inst := range instances {
+			uptime := time.Since(inst.Started).Round(time.Second)
+			fmt.Fprintf(w, "%d\t%d\t%s\t%s\t%s\n", inst.ID, inst.Port, inst.File, uptime, strings.Join(inst.URLs, " "))
+		}
+		return w.Flush()
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(listCmd)
+}
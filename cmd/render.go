@@ -2,14 +2,22 @@ package cmd
 
 import (
 	"fmt"
+	"io"
 	"os"
 	"path/filepath"
+	"strconv"
+	"strings"
 
 	"github.com/chrishrb/go-grip/pkg"
 	"github.com/spf13/cobra"
 )
 
-var directoryMode bool
+var (
+	directoryMode bool
+	splitLevel    int
+	drafts        bool
+	termMode      bool
+)
 
 var renderCmd = &cobra.Command{
 	Use:   "render [file|directory]",
@@ -19,13 +27,84 @@ var renderCmd = &cobra.Command{
 Basic usage:
   go-grip render FILE				# generate static HTML for a single file
   go-grip render FILE --output DIR	# specify output directory
-  go-grip render --directory DIR	# render all markdown files in directory`,
+  go-grip render --directory DIR	# render all markdown files in directory
+  go-grip render FILE --split-level 2	# split file into one page per H1/H2 section
+  cat FILE | go-grip render -		# print HTML for markdown read from stdin
+  go-grip render FILE --term		# print FILE styled for reading in the terminal`,
 	Args: cobra.ExactArgs(1),
 	RunE: func(cmd *cobra.Command, args []string) error {
 		input := args[0]
 
-		parser := pkg.NewParser(theme)
-		srv := pkg.NewServer(host, port, theme, boundingBox, browser, parser)
+		parserOpts := []pkg.ParserOption{
+			pkg.WithMapTiles(mapTiles),
+			pkg.WithPlantUML(plantumlServer, plantumlJar),
+			pkg.WithDiagramCache(cachePath("diagrams"), int64(diagramCacheSize)<<20),
+			graphvizOption(cmd),
+			pkg.WithSmartypants(smartypants),
+			pkg.WithHardWraps(hardWraps),
+			pkg.WithDefinitionLists(definitionLists),
+			pkg.WithAttributes(attributeLists),
+			pkg.WithAbbreviations(abbreviations),
+			pkg.WithEmbeds(embeds),
+			pkg.WithLanguageDetection(detectLanguage),
+			pkg.WithLexerAliases(lexerAliases),
+		}
+		if wikiLinks {
+			// wiki links resolve against the rendered directory
+			root := input
+			if !directoryMode {
+				root = filepath.Dir(filepath.Clean(input))
+			}
+			parserOpts = append(parserOpts, pkg.WithWikiLinks(root))
+		}
+		refOpts, err := referencesOption()
+		if err != nil {
+			return err
+		}
+		footnoteOpts, err := footnoteOptions()
+		if err != nil {
+			return err
+		}
+		refOpts = append(refOpts, footnoteOpts...)
+		parserOpts = append(parserOpts, refOpts...)
+		enc, err := pkg.ParseEncoding(encoding)
+		if err != nil {
+			return err
+		}
+		parser := pkg.NewParser(theme, parserOpts...)
+		if termMode {
+			return renderTerm(parser, input, enc)
+		}
+		opts := []pkg.Option{
+			pkg.WithEncoding(enc),
+			pkg.WithMarkdownExtensions(markdownExtensions),
+			pkg.WithIncludes(includes),
+			pkg.WithVars(substituteVars || varsFile != "", varsFile),
+			pkg.WithDrafts(drafts),
+			pkg.WithLightbox(lightbox),
+		}
+		opts = append(opts, pkg.WithTypography(typography))
+		opts = append(opts, pkg.WithStrictOffline(strictOffline))
+		layoutOpts, err := layoutOptions()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, layoutOpts...)
+		langOpt, err := langOption()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, langOpt)
+
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, browser, parser, opts...)
+
+		if input == "-" {
+			content, err := io.ReadAll(os.Stdin)
+			if err != nil {
+				return fmt.Errorf("failed to read stdin: %v", err)
+			}
+			return srv.WriteHTML(os.Stdout, content)
+		}
 
 		if outputDir == "" {
 			cacheDir, err := os.UserCacheDir()
@@ -47,6 +126,32 @@ Basic usage:
 	},
 }
 
+// renderTerm prints a markdown file, or stdin for "-", styled for the
+// terminal. Colors are left out if stdout isn't a terminal or NO_COLOR is set.
+func renderTerm(parser *pkg.Parser, input string, enc pkg.Encoding) error {
+	var content []byte
+	var err error
+	if input == "-" {
+		content, err = io.ReadAll(os.Stdin)
+	} else {
+		content, err = os.ReadFile(input)
+	}
+	if err != nil {
+		return fmt.Errorf("failed to read %s: %v", input, err)
+	}
+	content = enc.Decode(content)
+	if strings.EqualFold(filepath.Ext(input), ".mdx") {
+		content = pkg.MDXToMarkdown(content)
+	}
+
+	width, _ := strconv.Atoi(os.Getenv("COLUMNS"))
+	info, err := os.Stdout.Stat()
+	color := err == nil && info.Mode()&os.ModeCharDevice != 0 && os.Getenv("NO_COLOR") == ""
+
+	_, err = os.Stdout.Write(parser.MdToTerm(content, width, color))
+	return err
+}
+
 func renderSingleFile(srv *pkg.Server, filePath string, outputDir string) error {
 	info, err := os.Stat(filePath)
 	if err != nil {
@@ -57,8 +162,15 @@ func renderSingleFile(srv *pkg.Server, filePath string, outputDir string) error
 		return fmt.Errorf("expected a file but got a directory '%s'. Use --directory flag for directories", filePath)
 	}
 
-	if filepath.Ext(filePath) != ".md" {
-		return fmt.Errorf("file '%s' must be a markdown file with .md extension", filePath)
+	if !srv.IsMarkdown(filePath) {
+		return fmt.Errorf("file '%s' must be a markdown file with one of the extensions %s", filePath, strings.Join(markdownExtensions, ", "))
+	}
+
+	if splitLevel > 0 {
+		if err := srv.GenerateSplitFile(filePath, outputDir, splitLevel); err != nil {
+			return fmt.Errorf("failed to generate HTML: %v", err)
+		}
+		return nil
 	}
 
 	if err := srv.GenerateSingleFile(filePath, outputDir); err != nil {
@@ -88,8 +200,45 @@ func renderDirectory(srv *pkg.Server, dirPath string, outputDir string) error {
 func init() {
 	rootCmd.AddCommand(renderCmd)
 
-	renderCmd.Flags().StringVar(&theme, "theme", "auto", "Select CSS theme [light/dark/auto]")
+	renderCmd.Flags().StringVar(&theme, "theme", "auto", "Select CSS theme [light/dark/dark_dimmed/dark_high_contrast/light_high_contrast/auto]")
 	renderCmd.Flags().BoolVar(&boundingBox, "bounding-box", true, "Add bounding box to HTML output")
+	renderCmd.Flags().StringVar(&plantumlServer, "plantuml-server", "", "PlantUML server URL used to render plantuml code blocks, e.g. https://www.plantuml.com/plantuml")
+	renderCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory rendered diagrams and remote images are cached in (default: no diagram cache, remote images in the user cache directory)")
+	renderCmd.Flags().IntVar(&diagramCacheSize, "diagram-cache-size", pkg.DefaultDiagramCacheSize>>20, "Disk space in MB used to cache rendered diagrams (0 disables the cache)")
+	renderCmd.Flags().StringVar(&plantumlJar, "plantuml-jar", "", "plantuml.jar used to render plantuml code blocks if no server is set")
+	renderCmd.Flags().StringVar(&graphvizDot, "graphviz-dot", "", "Graphviz dot binary used to render dot code blocks, empty to show them as code (default: dot if it is installed)")
+	renderCmd.Flags().BoolVar(&detectLanguage, "detect-language", true, "Highlight code blocks without language in the language guessed from their content")
+	renderCmd.Flags().StringToStringVar(&lexerAliases, "lexer-alias", nil, "Highlight code blocks in a language like another one, e.g. tf=terraform,jsonc=json")
+	renderCmd.Flags().BoolVar(&embeds, "embeds", false, "Embed YouTube videos and GitHub Gists linked on their own line, loaded from the internet")
+	renderCmd.Flags().StringVar(&repo, "repo", "", "Link #123, GH-123, owner/repo#123, @user and commit SHA references like GitHub does in the READMEs of this owner/name repository")
+	renderCmd.Flags().BoolVar(&abbreviations, "abbreviations", false, "Show the definitions of *[ABBR]: definition abbreviations as tooltips")
+	renderCmd.Flags().StringVar(&footnoteMarkers, "footnote-markers", pkg.FootnoteNumeric, "Mark footnotes with numbers or with symbols like * and †, numeric or symbols")
+	renderCmd.Flags().StringVar(&footnoteBacklink, "footnote-backlink", pkg.DefaultFootnoteBacklink, "Text of the links from footnotes back to their references")
+	renderCmd.Flags().StringVar(&bibliographyFile, "bibliography", "", "BibTeX or CSL-JSON file resolving [@key] citations, listed in a References section")
+	renderCmd.Flags().StringVar(&citationStyle, "citation-style", pkg.CitationNumeric, "Format citations of --bibliography as numeric or author-date")
+	renderCmd.Flags().BoolVar(&attributeLists, "attributes", false, "Attach ids, classes and attributes to blocks with {#id .class} attribute lists")
+	renderCmd.Flags().BoolVar(&definitionLists, "definition-lists", false, "Render \"Term\" lines followed by \": definition\" lines as definition lists")
+	renderCmd.Flags().BoolVar(&hardWraps, "hard-wraps", false, "Render single newlines as line breaks like GitHub comments instead of joining lines like GitHub files")
+	renderCmd.Flags().BoolVar(&smartypants, "smartypants", false, "Convert straight quotes, dashes and ellipses to typographic ones")
+	renderCmd.Flags().BoolVar(&wikiLinks, "wiki-links", false, "Resolve [[Page Name]] wiki links against the rendered directory")
+	renderCmd.Flags().BoolVar(&includes, "includes", false, "Expand <!-- include: file.md --> directives in markdown files")
+	renderCmd.Flags().BoolVar(&substituteVars, "substitute-vars", false, "Substitute {{ .name }} placeholders in markdown files with the fields of their front matter")
+	renderCmd.Flags().StringVar(&varsFile, "vars", "", "YAML file of variables for {{ .name }} placeholders, implies --substitute-vars; front matter fields take precedence")
+	renderCmd.Flags().StringVar(&mapTiles, "map-tiles", "", "Tile URL template for GeoJSON and TopoJSON maps, e.g. "+pkg.OpenStreetMapTiles+" (default: maps without tiles)")
+	renderCmd.Flags().BoolVar(&strictOffline, "strict-offline", false, "Fail rendering pages that load scripts, stylesheets, fonts, frames or map tiles from other hosts, so they work without network access")
+	renderCmd.Flags().StringVar(&typography.MaxWidth, "max-width", "", "Maximum width of the content, e.g. 980px like GitHub or none for the full window width")
+	renderCmd.Flags().StringVar(&typography.FontFamily, "font-family", "", "CSS font family of the text")
+	renderCmd.Flags().StringVar(&typography.FontSize, "font-size", "", "Font size of the text, e.g. 18px")
+	renderCmd.Flags().StringVar(&typography.LineHeight, "line-height", "", "Line height of the text, e.g. 1.7")
+	renderCmd.Flags().StringVar(&templateFile, "template", "", "Use a custom page layout template instead of the embedded one")
+	renderCmd.Flags().StringToStringVar(&partials, "partial", nil, "Fill a block of the page layout (head, header, after-content or footer) with a template file, as name=file")
+	renderCmd.Flags().StringVar(&lang, "lang", pkg.DefaultLang, "Language of the user interface around the documents (de, en, es, fr, ja or zh)")
 	renderCmd.Flags().StringVarP(&outputDir, "output", "o", "", "Output directory for static files")
 	renderCmd.Flags().BoolVarP(&directoryMode, "directory", "d", false, "Render all markdown files in directory")
+	renderCmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the source files, e.g. utf-8, utf-16le or latin1, detected from a byte order mark or the content with auto")
+	renderCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions rendered as markdown")
+	renderCmd.Flags().BoolVar(&lightbox, "lightbox", false, "Show images enlarged in an overlay when they are clicked")
+	renderCmd.Flags().BoolVar(&drafts, "drafts", false, "Render pages marked draft: true in their front matter with --directory")
+	renderCmd.Flags().BoolVar(&termMode, "term", false, "Print the markdown styled for the terminal instead of rendering HTML")
+	renderCmd.Flags().IntVar(&splitLevel, "split-level", 0, "Split a single file into multiple pages at headings up to this level (0 disables)")
 }
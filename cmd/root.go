@@ -1,32 +1,282 @@
 package cmd
 
 import (
+	"fmt"
+	"io"
+	"log/slog"
 	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
 
+	"github.com/chrishrb/go-grip/pkg"
 	"github.com/spf13/cobra"
 )
 
 var (
-	theme       string
-	boundingBox bool
+	theme              string
+	boundingBox        bool
+	templateFile       string
+	partials           map[string]string
+	lang               string
+	markdownExtensions []string
+	mapTiles           string
+	plantumlServer     string
+	plantumlJar        string
+	graphvizDot        string
+	cacheDir           string
+	diagramCacheSize   int
+	wikiLinks          bool
+	includes           bool
+	substituteVars     bool
+	varsFile           string
+	prose              bool
+	spellChecker       string
+	dictionaries       []string
+	smartypants        bool
+	hardWraps          bool
+	definitionLists    bool
+	attributeLists     bool
+	abbreviations      bool
+	embeds             bool
+	typography         pkg.Typography
+	detectLanguage     bool
+	lexerAliases       map[string]string
+	footnoteMarkers    string
+	footnoteBacklink   string
+	bibliographyFile   string
+	citationStyle      string
 
-	browser bool
-	host    string
-	port    int
+	browser     bool
+	browserCmd  string
+	hosts       []string
+	port        int
+	portScan    int
+	listenAddrs []string
+
+	tlsCert       string
+	tlsKey        string
+	tlsSelfSigned bool
+	http2         bool
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	auth      string
+	authToken string
+
+	corsOrigins []string
+	basePath    string
+	corsMethods []string
+
+	githubURL   string
+	repo        string
+	githubToken string
+
+	maxRenders      int
+	renderTimeout   time.Duration
+	cacheSize       int
+	warmup          bool
+	qrCode          bool
+	rateLimit       float64
+	rateBurst       int
+	maxRequestKB    int
+	maxPreviewMB    int
+	debounce        time.Duration
+	reloadExclude   []string
+	reloadTransport string
+	noReload        bool
+	compress        bool
+	hidden          bool
+	followSymlinks  bool
 
 	outputDir string
+
+	verbose   bool
+	quiet     bool
+	logFormat string
+	logFile   string
+	accessLog bool
+	timings   bool
+	metrics   bool
+
+	daemon      bool
+	pidFile     string
+	exportStdin bool
+
+	gitRef      string
+	gitInfo     bool
+	slides      bool
+	renderCode  bool
+	lightbox    bool
+	wordCount   bool
+	breadcrumbs bool
+	anchor      string
+	encoding    string
+	pager       bool
+
+	imageProxy    bool
+	imageCacheTTL time.Duration
+	offline       bool
+	strictOffline bool
+	clipboard     bool
+	commentMode   bool
+	commentAPI    bool
 )
 
 var rootCmd = &cobra.Command{
 	Use:   "go-grip [command] <args>",
+	Args:  cobra.ArbitraryArgs,
 	Short: "Render markdown document as html",
 	Long: `go-grip is a tool for rendering markdown documents as HTML.
 
 Available commands:
   go-grip render FILE   - Generate static HTML from markdown
-  go-grip serve FILE    - Serve markdown via local HTTP server `,
+  go-grip serve FILE    - Serve markdown via local HTTP server
+  go-grip export FILE   - Export markdown to PDF
+  go-grip check DIR     - Check markdown files for broken links
+  go-grip FILE|-        - Shorthand for serve, "-" reads from stdin
+  go-grip --export -    - Print the HTML of markdown read from stdin
+  go-grip --clipboard   - Preview the clipboard as markdown
+  go-grip list          - List running preview servers
+  go-grip stop ID|PORT  - Stop a running preview server`,
 
 	SilenceUsage: true,
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return setupLogging()
+	},
+}
+
+// setupLogging configures the default slog logger from the logging flags.
+// Logs go to stderr, so they don't mix with HTML printed to stdout, or to
+// --log-file.
+func setupLogging() error {
+	level := slog.LevelInfo
+	if verbose {
+		level = slog.LevelDebug
+	} else if quiet {
+		level = slog.LevelWarn
+	}
+	opts := &slog.HandlerOptions{Level: level}
+
+	var out io.Writer = os.Stderr
+	if logFile != "" {
+		f, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+		if err != nil {
+			return fmt.Errorf("failed to open log file: %v", err)
+		}
+		out = f
+	}
+
+	var handler slog.Handler
+	switch logFormat {
+	case "text":
+		// timestamps only clutter the output of an interactive tool
+		if logFile == "" {
+			opts.ReplaceAttr = func(groups []string, a slog.Attr) slog.Attr {
+				if a.Key == slog.TimeKey && len(groups) == 0 {
+					return slog.Attr{}
+				}
+				return a
+			}
+		}
+		handler = slog.NewTextHandler(out, opts)
+	case "json":
+		handler = slog.NewJSONHandler(out, opts)
+	default:
+		return fmt.Errorf("invalid --log-format %q, expected text or json", logFormat)
+	}
+	slog.SetDefault(slog.New(handler))
+	return nil
+}
+
+// referencesOption returns the parser options linking the references of
+// --repo and --comment-mode.
+func referencesOption() ([]pkg.ParserOption, error) {
+	if repo == "" {
+		if commentMode {
+			// comments link users and references naming their repository
+			return []pkg.ParserOption{pkg.WithReferences(githubURL, "")}, nil
+		}
+		return nil, nil
+	}
+	if !pkg.ValidRepository(repo) {
+		return nil, fmt.Errorf("invalid --repo %q, expected owner/name", repo)
+	}
+	return []pkg.ParserOption{pkg.WithReferences(githubURL, repo)}, nil
+}
+
+// graphvizOption returns the parser option of --graphviz-dot. Without it,
+// dot code blocks are rendered if Graphviz is installed, see pkg.NewParser.
+func graphvizOption(cmd *cobra.Command) pkg.ParserOption {
+	if !cmd.Flags().Changed("graphviz-dot") {
+		return func(*pkg.Parser) {}
+	}
+	return pkg.WithGraphviz(graphvizDot)
+}
+
+// footnoteOptions returns the parser options of --footnote-markers,
+// --footnote-backlink, --bibliography and --citation-style.
+func footnoteOptions() ([]pkg.ParserOption, error) {
+	if footnoteMarkers != pkg.FootnoteNumeric && footnoteMarkers != pkg.FootnoteSymbols {
+		return nil, fmt.Errorf("invalid --footnote-markers %q, expected %s or %s", footnoteMarkers, pkg.FootnoteNumeric, pkg.FootnoteSymbols)
+	}
+	opts := []pkg.ParserOption{pkg.WithFootnoteStyle(footnoteMarkers, footnoteBacklink)}
+	if citationStyle != pkg.CitationNumeric && citationStyle != pkg.CitationAuthorDate {
+		return nil, fmt.Errorf("invalid --citation-style %q, expected %s or %s", citationStyle, pkg.CitationNumeric, pkg.CitationAuthorDate)
+	}
+	if bibliographyFile != "" {
+		if _, err := os.Stat(bibliographyFile); err != nil {
+			return nil, fmt.Errorf("failed to read bibliography: %v", err)
+		}
+		opts = append(opts, pkg.WithBibliography(bibliographyFile, citationStyle))
+	}
+	return opts, nil
+}
+
+// layoutOptions returns the server options of --template and --partial.
+func layoutOptions() ([]pkg.Option, error) {
+	var opts []pkg.Option
+	if templateFile != "" {
+		opts = append(opts, pkg.WithTemplate(templateFile))
+	}
+	if len(partials) == 0 {
+		return opts, nil
+	}
+	for name := range partials {
+		if !slices.Contains(pkg.PartialNames, name) {
+			return nil, fmt.Errorf("invalid --partial %q, expected %s", name, strings.Join(pkg.PartialNames, ", "))
+		}
+	}
+	return append(opts, pkg.WithPartials(partials)), nil
+}
+
+// proseOption returns the server option of --prose.
+func proseOption() pkg.Option {
+	if !prose {
+		return pkg.WithProseLint(nil)
+	}
+	return pkg.WithProseLint(&pkg.ProseLint{SpellChecker: spellChecker, Dictionaries: dictionaries})
+}
+
+// langOption returns the server option of --lang.
+func langOption() (pkg.Option, error) {
+	if !pkg.ValidLang(lang) {
+		return nil, fmt.Errorf("invalid --lang %q, expected one of %s", lang, strings.Join(pkg.Languages(), ", "))
+	}
+	return pkg.WithLang(lang), nil
+}
+
+// cachePath returns the directory of the named cache in --cache-dir, or an
+// empty string if it isn't set, which disables the diagram cache and keeps
+// remote images in the user cache directory.
+func cachePath(name string) string {
+	if cacheDir == "" {
+		return ""
+	}
+	return filepath.Join(cacheDir, name)
 }
 
 func Execute() {
@@ -37,6 +287,10 @@ func Execute() {
 }
 
 func init() {
-	// no flag is used as root,
-	//TODO: potential for backwards compatibility
+	// without a command, the root command behaves like serve, see serve.go
+	rootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "Log debug messages")
+	rootCmd.PersistentFlags().BoolVarP(&quiet, "quiet", "q", false, "Only log warnings and errors")
+	rootCmd.PersistentFlags().StringVar(&logFormat, "log-format", "text", "Log format [text/json]")
+	rootCmd.PersistentFlags().StringVar(&logFile, "log-file", "", "Append logs with timestamps to this file instead of writing them to stderr")
+	rootCmd.MarkFlagsMutuallyExclusive("verbose", "quiet")
 }
@@ -1,27 +1,285 @@
 package cmd
 
 import (
+	"context"
 	"fmt"
+	"log/slog"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"slices"
+	"strings"
+	"syscall"
 
 	"github.com/chrishrb/go-grip/pkg"
 	"github.com/spf13/cobra"
 )
 
 var serveCmd = &cobra.Command{
-	Use:   "serve FILE",
+	Use:   "serve FILE|URL|owner/repo|-|DIR...",
 	Short: "Run as a server and serve the markdown file",
 	Long: `Start a local server to render and serve the markdown file.
 
 The server will watch for changes to the file and automatically refresh the browser.
-This is useful for live previewing markdown as you edit it.`,
-	Args: cobra.ExactArgs(1),
+This is useful for live previewing markdown as you edit it.
+
+Pass "-" to serve markdown read from stdin, the page is re-rendered whenever
+new input arrives. With --export it is printed as an HTML page instead. A URL or an owner/repo shorthand serves a remote document
+or the README of a GitHub repository.
+
+Several directories, e.g. the doc trees of a monorepo, are served at their
+paths below their common parent directory, with an index of them at /.
+
+With --clipboard the contents of the clipboard are served instead of a file
+and re-rendered whenever they change.`,
+	Args: func(cmd *cobra.Command, args []string) error {
+		if clipboard {
+			return cobra.NoArgs(cmd, args)
+		}
+		return cobra.MinimumNArgs(1)(cmd, args)
+	},
 	RunE: func(cmd *cobra.Command, args []string) error {
-		file := args[0]
+		var file string
+		if len(args) > 0 {
+			file = args[0]
+		}
+		if len(args) > 1 {
+			for _, arg := range args {
+				if info, err := os.Stat(arg); err != nil || !info.IsDir() {
+					return fmt.Errorf("%s is not a directory, only directories can be served together", arg)
+				}
+			}
+		}
+		if exportStdin {
+			if file != "-" || len(args) > 1 {
+				return fmt.Errorf("--export prints the page of stdin, pass \"-\" or use render FILE")
+			}
+			return renderCmd.RunE(cmd, args)
+		}
+		if daemon && !pkg.IsDaemon() {
+			return startDaemon(file)
+		}
+		if pidFile != "" {
+			remove, err := pkg.WritePIDFile(pidFile)
+			if err != nil {
+				return err
+			}
+			defer remove()
+		}
+		if f, a, ok := splitAnchor(file); ok {
+			file = f
+			if anchor == "" {
+				anchor = a
+			}
+		}
+
+		if commentAPI {
+			commentMode = true
+		}
+		if commentMode {
+			// single newlines break lines in comments
+			hardWraps = true
+		}
 
-		parser := pkg.NewParser(theme)
-		srv := pkg.NewServer(host, port, theme, boundingBox, browser, parser)
+		var opts []pkg.Option
+		parserOpts := []pkg.ParserOption{
+			pkg.WithMapTiles(mapTiles),
+			pkg.WithPlantUML(plantumlServer, plantumlJar),
+			pkg.WithDiagramCache(cachePath("diagrams"), int64(diagramCacheSize)<<20),
+			graphvizOption(cmd),
+			pkg.WithSourceLines(),
+			pkg.WithSmartypants(smartypants),
+			pkg.WithHardWraps(hardWraps),
+			pkg.WithDefinitionLists(definitionLists),
+			pkg.WithAttributes(attributeLists),
+			pkg.WithAbbreviations(abbreviations),
+			pkg.WithEmbeds(embeds),
+			pkg.WithLanguageDetection(detectLanguage),
+			pkg.WithLexerAliases(lexerAliases),
+		}
 
-		if err := srv.Serve(file); err != nil {
+		if githubToken == "" {
+			githubToken = os.Getenv("GITHUB_TOKEN")
+		}
+		gh := pkg.NewGitHub(githubURL, githubToken)
+
+		remote, isRemote, err := pkg.ResolveRemote(file, gh)
+		if err != nil {
+			return err
+		}
+		if isRemote {
+			opts = append(opts, pkg.WithRemoteSource(remote))
+			parserOpts = append(parserOpts, pkg.WithBaseURL(remote.LinkBase, remote.ImageBase))
+		} else {
+			root := "."
+			if file != "-" {
+				root = filepath.Dir(filepath.Clean(file))
+			}
+			parserOpts = append(parserOpts, pkg.WithRepoLinks(root))
+			// wiki links resolve against the served directory
+			if wikiLinks {
+				parserOpts = append(parserOpts, pkg.WithWikiLinks(root))
+			}
+		}
+
+		refOpts, err := referencesOption()
+		if err != nil {
+			return err
+		}
+		footnoteOpts, err := footnoteOptions()
+		if err != nil {
+			return err
+		}
+		refOpts = append(refOpts, footnoteOpts...)
+		parserOpts = append(parserOpts, refOpts...)
+
+		parser := pkg.NewParser(theme, parserOpts...)
+		if tlsCert != "" || tlsKey != "" {
+			opts = append(opts, pkg.WithTLS(tlsCert, tlsKey))
+		} else if tlsSelfSigned {
+			opts = append(opts, pkg.WithSelfSignedTLS())
+		}
+		opts = append(opts, pkg.WithHTTP2(http2))
+		opts = append(opts, pkg.WithTimeouts(readTimeout, writeTimeout, idleTimeout))
+
+		if auth != "" {
+			user, pass, ok := strings.Cut(auth, ":")
+			if !ok || user == "" {
+				return fmt.Errorf("invalid --auth value, expected user:pass")
+			}
+			opts = append(opts, pkg.WithBasicAuth(user, pass))
+		}
+		if authToken != "" {
+			opts = append(opts, pkg.WithTokenAuth(authToken))
+		}
+		if basePath != "" {
+			opts = append(opts, pkg.WithBasePath(basePath))
+		}
+		if len(corsOrigins) > 0 {
+			opts = append(opts, pkg.WithCORS(corsOrigins, corsMethods))
+		}
+
+		if portScan > 0 {
+			opts = append(opts, pkg.WithPortScan(portScan))
+		}
+		if maxRenders > 0 {
+			opts = append(opts, pkg.WithMaxRenders(maxRenders))
+		}
+		if renderTimeout > 0 {
+			opts = append(opts, pkg.WithRenderTimeout(renderTimeout))
+		}
+
+		opts = append(opts, pkg.WithRateLimit(rateLimit, rateBurst))
+		opts = append(opts, pkg.WithMaxRequestSize(int64(maxRequestKB)<<10))
+		opts = append(opts, pkg.WithMaxPreviewSize(int64(maxPreviewMB)<<20))
+		opts = append(opts, pkg.WithCacheSize(int64(cacheSize)<<20))
+		opts = append(opts, pkg.WithBrowserCommand(browserCmd))
+		if len(listenAddrs) > 0 {
+			opts = append(opts, pkg.WithListen(listenAddrs))
+		}
+		opts = append(opts, pkg.WithWarmup(warmup))
+		opts = append(opts, pkg.WithReload(!noReload))
+		opts = append(opts, pkg.WithReloadDebounce(debounce))
+		// the log and pid files of the server may be inside the served
+		// directory, logging a reload would reload again
+		excludes := slices.Clone(reloadExclude)
+		for _, f := range []string{logFile, pidFile} {
+			if f == "" {
+				continue
+			}
+			if abs, err := filepath.Abs(f); err == nil {
+				excludes = append(excludes, abs)
+			}
+		}
+		opts = append(opts, pkg.WithReloadExclude(excludes))
+		switch reloadTransport {
+		case pkg.ReloadAuto, pkg.ReloadWebSocket, pkg.ReloadSSE:
+			opts = append(opts, pkg.WithReloadTransport(reloadTransport))
+		default:
+			return fmt.Errorf("invalid --reload-transport %q, expected auto, websocket or sse", reloadTransport)
+		}
+		opts = append(opts, pkg.WithCompression(compress))
+		opts = append(opts, pkg.WithHidden(hidden))
+		opts = append(opts, pkg.WithFollowSymlinks(followSymlinks))
+		opts = append(opts, pkg.WithMarkdownExtensions(markdownExtensions))
+		enc, err := pkg.ParseEncoding(encoding)
+		if err != nil {
+			return err
+		}
+		opts = append(opts, pkg.WithEncoding(enc))
+		opts = append(opts, pkg.WithIncludes(includes))
+		opts = append(opts, pkg.WithVars(substituteVars || varsFile != "", varsFile))
+		opts = append(opts, pkg.WithLightbox(lightbox))
+		opts = append(opts, pkg.WithWordCount(wordCount))
+		opts = append(opts, pkg.WithBreadcrumbs(breadcrumbs))
+		opts = append(opts, pkg.WithPager(pager))
+		opts = append(opts, pkg.WithClipboard(clipboard))
+		if commentMode {
+			var api *pkg.GitHub
+			if commentAPI {
+				api = gh
+			}
+			opts = append(opts, pkg.WithCommentMode(true, api, repo))
+		}
+		if gitRef != "" {
+			if isRemote || file == "-" || clipboard {
+				return fmt.Errorf("--ref only works with files of a git repository")
+			}
+			opts = append(opts, pkg.WithGitRef(gitRef))
+		}
+		if slides {
+			opts = append(opts, pkg.WithSlides(true))
+		}
+		if gitInfo {
+			opts = append(opts, pkg.WithGitInfo(true))
+		}
+		opts = append(opts, proseOption())
+		if imageProxy || offline {
+			opts = append(opts, pkg.WithImageProxy(cachePath("images"), imageCacheTTL, offline))
+		}
+		if metrics {
+			opts = append(opts, pkg.WithMetrics(true))
+		}
+		if accessLog {
+			opts = append(opts, pkg.WithAccessLog(slog.Default()))
+		}
+		if timings {
+			opts = append(opts, pkg.WithRenderTimings(true))
+		}
+		if renderCode {
+			opts = append(opts, pkg.WithRenderCode(true))
+		}
+		// the QR code is only useful to humans looking at the terminal
+		if info, err := os.Stderr.Stat(); qrCode && !quiet && err == nil && info.Mode()&os.ModeCharDevice != 0 {
+			opts = append(opts, pkg.WithQRCode(os.Stderr))
+		}
+		if anchor != "" {
+			opts = append(opts, pkg.WithAnchor(anchor))
+		}
+		opts = append(opts, pkg.WithTypography(typography))
+		opts = append(opts, pkg.WithStrictOffline(strictOffline))
+		layoutOpts, err := layoutOptions()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, layoutOpts...)
+		langOpt, err := langOption()
+		if err != nil {
+			return err
+		}
+		opts = append(opts, langOpt)
+
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, browser, parser, opts...)
+
+		ctx, stop := signal.NotifyContext(context.Background(), os.Interrupt, syscall.SIGTERM)
+		defer stop()
+
+		if len(args) > 1 {
+			err = srv.ServeRoots(ctx, args)
+		} else {
+			err = srv.Serve(ctx, file)
+		}
+		if err != nil {
 			return fmt.Errorf("server error: %v", err)
 		}
 
@@ -29,12 +287,153 @@ This is useful for live previewing markdown as you edit it.`,
 	},
 }
 
+// startDaemon runs the server with the arguments of the process in the
+// background, logging to --log-file or a file in the user cache directory.
+func startDaemon(file string) error {
+	if file == "-" {
+		return fmt.Errorf("--daemon can't serve stdin")
+	}
+	args := os.Args[1:]
+	if logFile == "" {
+		var err error
+		if logFile, err = pkg.DaemonLogFile(port); err != nil {
+			return err
+		}
+		args = append(args, "--log-file", logFile)
+	}
+	inst, err := pkg.StartDaemon(args, logFile)
+	if err != nil {
+		return err
+	}
+	fmt.Printf("Started instance %d (port %d) in the background, logging to %s\n", inst.ID, inst.Port, logFile)
+	for _, u := range inst.URLs {
+		fmt.Println(u)
+	}
+	fmt.Printf("Stop it with \"go-grip stop %d\"\n", inst.ID)
+	return nil
+}
+
+// splitAnchor splits a file argument like README.md#installation into the
+// file and the anchor, unless a file with the whole name exists.
+func splitAnchor(arg string) (string, string, bool) {
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		return arg, "", false
+	}
+	file, anchor, ok := strings.Cut(arg, "#")
+	if !ok || file == "" {
+		return arg, "", false
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return arg, "", false
+	}
+	return file, anchor, true
+}
+
 func init() {
 	rootCmd.AddCommand(serveCmd)
+	rootCmd.RunE = func(cmd *cobra.Command, args []string) error {
+		if len(args) == 0 && !clipboard {
+			return cmd.Help()
+		}
+		return serveCmd.RunE(cmd, args)
+	}
+	defer rootCmd.Flags().AddFlagSet(serveCmd.Flags())
 
-	serveCmd.Flags().StringVar(&theme, "theme", "auto", "Select CSS theme [light/dark/auto]")
+	serveCmd.Flags().StringVar(&theme, "theme", "auto", "Select CSS theme [light/dark/dark_dimmed/dark_high_contrast/light_high_contrast/auto]")
 	serveCmd.Flags().BoolVar(&boundingBox, "bounding-box", true, "Add bounding box to HTML output")
+	serveCmd.Flags().StringVar(&typography.MaxWidth, "max-width", "", "Maximum width of the content, e.g. 980px like GitHub or none for the full window width")
+	serveCmd.Flags().StringVar(&typography.FontFamily, "font-family", "", "CSS font family of the text")
+	serveCmd.Flags().StringVar(&typography.FontSize, "font-size", "", "Font size of the text, e.g. 18px")
+	serveCmd.Flags().StringVar(&typography.LineHeight, "line-height", "", "Line height of the text, e.g. 1.7")
+	serveCmd.Flags().StringVar(&templateFile, "template", "", "Use a custom page layout template instead of the embedded one")
+	serveCmd.Flags().StringToStringVar(&partials, "partial", nil, "Fill a block of the page layout (head, header, after-content or footer) with a template file, as name=file")
+	serveCmd.Flags().StringVar(&lang, "lang", pkg.DefaultLang, "Language of the user interface around the documents (de, en, es, fr, ja or zh)")
 	serveCmd.Flags().BoolVarP(&browser, "browser", "b", true, "Open browser tab automatically")
-	serveCmd.Flags().StringVarP(&host, "host", "H", "localhost", "Host to listen on")
+	serveCmd.Flags().StringVar(&browserCmd, "browser-cmd", "", "Command opening the browser, e.g. \"firefox --new-window %s\" (default: $BROWSER or the system default)")
+	serveCmd.Flags().StringSliceVarP(&hosts, "host", "H", []string{"localhost"}, "Host(s) to listen on, repeat for multiple addresses")
+	serveCmd.Flags().StringSliceVar(&listenAddrs, "listen", nil, "Listen on a unix domain socket, e.g. unix:/tmp/go-grip.sock, instead of --host and --port")
 	serveCmd.Flags().IntVarP(&port, "port", "p", 6419, "Port to listen on")
+	serveCmd.Flags().StringVar(&basePath, "base-path", "", "Path prefix go-grip is served below behind a reverse proxy, e.g. /preview/")
+	serveCmd.Flags().IntVar(&portScan, "port-scan", 0, "Try up to N following ports if the port is already in use")
+	serveCmd.Flags().StringVar(&tlsCert, "tls-cert", "", "TLS certificate file for serving over HTTPS")
+	serveCmd.Flags().StringVar(&tlsKey, "tls-key", "", "TLS key file for serving over HTTPS")
+	serveCmd.Flags().StringVar(&repo, "repo", "", "Link #123, GH-123, owner/repo#123, @user and commit SHA references like GitHub does in the READMEs of this owner/name repository")
+	serveCmd.Flags().StringVar(&githubURL, "github-url", "", "Base URL of a GitHub Enterprise Server instance (default https://github.com)")
+	serveCmd.Flags().StringVar(&githubToken, "github-token", "", "Token for the GitHub API, defaults to $GITHUB_TOKEN")
+	serveCmd.Flags().StringVar(&auth, "auth", "", "Require HTTP basic authentication (user:pass)")
+	serveCmd.Flags().StringVar(&authToken, "auth-token", "", "Require a bearer token, also accepted as ?token= query parameter")
+	serveCmd.Flags().StringSliceVar(&corsOrigins, "cors-origin", nil, "Origin allowed to fetch pages, the API and assets from the browser, e.g. https://editor.example.com, or * for any")
+	serveCmd.Flags().StringSliceVar(&corsMethods, "cors-methods", pkg.DefaultCORSMethods, "Methods allowed for --cors-origin")
+	serveCmd.Flags().Float64Var(&rateLimit, "rate-limit", 0, "Maximum requests per second of every client IP on average, excess requests get 429 (0 is unlimited)")
+	serveCmd.Flags().IntVar(&rateBurst, "rate-burst", 100, "Requests a client may make at once with --rate-limit, e.g. for a page with many images")
+	serveCmd.Flags().IntVar(&maxRequestKB, "max-request-size", pkg.DefaultMaxRequestSize>>10, "Maximum size in KB of request headers, bodies and websocket messages")
+	serveCmd.Flags().IntVar(&maxPreviewMB, "max-preview-size", pkg.DefaultMaxPreviewSize>>20, "Maximum size in MB of files rendered as pages, larger files link the raw file instead (0 is unlimited)")
+	serveCmd.Flags().IntVar(&maxRenders, "max-renders", 0, "Maximum number of concurrent renders, excess requests get 503 (0 is unlimited)")
+	serveCmd.Flags().DurationVar(&renderTimeout, "render-timeout", 0, "Maximum time a single render may take, e.g. 5s (0 is unlimited)")
+	serveCmd.Flags().IntVar(&cacheSize, "cache-size", 64, "Memory in MB used to cache rendered pages (0 disables the cache)")
+	serveCmd.Flags().DurationVar(&debounce, "debounce", pkg.DefaultReloadDebounce, "Time without further file changes before the page reloads, so bursts of saves reload once")
+	serveCmd.Flags().StringSliceVar(&reloadExclude, "reload-exclude", nil, "Ignore changes of files matching a glob like *.log, build/*.html or an absolute path, e.g. output written while rendering")
+	serveCmd.Flags().BoolVar(&noReload, "no-reload", false, "Don't watch files or reload pages, for read-only serving where file watches are limited")
+	serveCmd.Flags().StringVar(&reloadTransport, "reload-transport", pkg.ReloadAuto, "How pages are told to reload: websocket, sse for server-sent events, or auto to fall back to sse when websockets are blocked")
+	serveCmd.Flags().BoolVar(&qrCode, "qr", true, "Print a QR code of the preview URL when it can be opened from other devices")
+	serveCmd.Flags().BoolVar(&warmup, "warmup", false, "Render all markdown files in parallel at startup to fill the render cache")
+	serveCmd.Flags().StringVar(&plantumlServer, "plantuml-server", "", "PlantUML server URL used to render plantuml code blocks, e.g. https://www.plantuml.com/plantuml")
+	serveCmd.Flags().StringVar(&cacheDir, "cache-dir", "", "Directory rendered diagrams and remote images are cached in (default: no diagram cache, remote images in the user cache directory)")
+	serveCmd.Flags().IntVar(&diagramCacheSize, "diagram-cache-size", pkg.DefaultDiagramCacheSize>>20, "Disk space in MB used to cache rendered diagrams (0 disables the cache)")
+	serveCmd.Flags().StringVar(&plantumlJar, "plantuml-jar", "", "plantuml.jar used to render plantuml code blocks if no server is set")
+	serveCmd.Flags().StringVar(&graphvizDot, "graphviz-dot", "", "Graphviz dot binary used to render dot code blocks, empty to show them as code (default: dot if it is installed)")
+	serveCmd.Flags().BoolVar(&detectLanguage, "detect-language", true, "Highlight code blocks without language in the language guessed from their content")
+	serveCmd.Flags().StringToStringVar(&lexerAliases, "lexer-alias", nil, "Highlight code blocks in a language like another one, e.g. tf=terraform,jsonc=json")
+	serveCmd.Flags().BoolVar(&embeds, "embeds", false, "Embed YouTube videos and GitHub Gists linked on their own line, loaded from the internet")
+	serveCmd.Flags().BoolVar(&abbreviations, "abbreviations", false, "Show the definitions of *[ABBR]: definition abbreviations as tooltips")
+	serveCmd.Flags().StringVar(&footnoteMarkers, "footnote-markers", pkg.FootnoteNumeric, "Mark footnotes with numbers or with symbols like * and †, numeric or symbols")
+	serveCmd.Flags().StringVar(&footnoteBacklink, "footnote-backlink", pkg.DefaultFootnoteBacklink, "Text of the links from footnotes back to their references")
+	serveCmd.Flags().StringVar(&bibliographyFile, "bibliography", "", "BibTeX or CSL-JSON file resolving [@key] citations, listed in a References section")
+	serveCmd.Flags().StringVar(&citationStyle, "citation-style", pkg.CitationNumeric, "Format citations of --bibliography as numeric or author-date")
+	serveCmd.Flags().BoolVar(&attributeLists, "attributes", false, "Attach ids, classes and attributes to blocks with {#id .class} attribute lists")
+	serveCmd.Flags().BoolVar(&definitionLists, "definition-lists", false, "Render \"Term\" lines followed by \": definition\" lines as definition lists")
+	serveCmd.Flags().BoolVar(&hardWraps, "hard-wraps", false, "Render single newlines as line breaks like GitHub comments instead of joining lines like GitHub files")
+	serveCmd.Flags().BoolVar(&smartypants, "smartypants", false, "Convert straight quotes, dashes and ellipses to typographic ones")
+	serveCmd.Flags().BoolVar(&wikiLinks, "wiki-links", false, "Resolve [[Page Name]] wiki links against the served directory")
+	serveCmd.Flags().BoolVar(&includes, "includes", false, "Expand <!-- include: file.md --> directives in markdown files")
+	serveCmd.Flags().BoolVar(&substituteVars, "substitute-vars", false, "Substitute {{ .name }} placeholders in markdown files with the fields of their front matter")
+	serveCmd.Flags().StringVar(&varsFile, "vars", "", "YAML file of variables for {{ .name }} placeholders, implies --substitute-vars; front matter fields take precedence")
+	serveCmd.Flags().StringVar(&mapTiles, "map-tiles", "", "Tile URL template for GeoJSON and TopoJSON maps, e.g. "+pkg.OpenStreetMapTiles+" (default: maps without tiles)")
+	serveCmd.Flags().BoolVar(&strictOffline, "strict-offline", false, "Fail rendering pages that load scripts, stylesheets, fonts, frames or map tiles from other hosts, so they work without network access")
+	serveCmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the source files, e.g. utf-8, utf-16le or latin1, detected from a byte order mark or the content with auto")
+	serveCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions rendered as markdown")
+	serveCmd.Flags().BoolVar(&renderCode, "render-code", false, "Show source files highlighted with linkable line numbers like GitHub's file view instead of as text")
+	serveCmd.Flags().BoolVar(&hidden, "hidden", false, "Serve dotfiles and dot-directories")
+	serveCmd.Flags().BoolVar(&followSymlinks, "follow-symlinks", true, "Serve symlinked files and directories, skipping links that loop; links to files outside the served directory are served as well, reject paths through links with --follow-symlinks=false")
+	serveCmd.Flags().StringVar(&gitRef, "ref", "", "Serve the files of a git commit, branch or tag instead of the working tree")
+	serveCmd.Flags().BoolVar(&commentMode, "comment-mode", false, "Preview like GitHub renders issue and pull request comments: single newlines break lines, users and references are linked and there is no table of contents")
+	serveCmd.Flags().BoolVar(&commentAPI, "comment-api", false, "Render comments with the GitHub markdown API, exactly as they will post, in the context of --repo (implies --comment-mode, sends the drafts to GitHub)")
+	serveCmd.Flags().BoolVar(&clipboard, "clipboard", false, "Preview the clipboard as markdown instead of a file, re-rendered when it changes")
+	serveCmd.Flags().StringVar(&anchor, "anchor", "", "Open the page at this heading, e.g. installation, also given as README.md#installation")
+	serveCmd.Flags().BoolVar(&breadcrumbs, "breadcrumbs", false, "Show the path of pages above them with links to the directories")
+	serveCmd.Flags().BoolVar(&pager, "pager", false, "Link the previous and next page below pages, ordered by directory, front matter order and name, unless SUMMARY.md or mkdocs.yml define the order")
+	serveCmd.Flags().BoolVar(&wordCount, "word-count", false, "Show the word count, reading time and last modification above pages")
+	serveCmd.Flags().BoolVar(&lightbox, "lightbox", false, "Show images enlarged in an overlay when they are clicked")
+	serveCmd.Flags().BoolVar(&slides, "slides", false, "Present markdown files as slides, separated by --- or <!-- slide -->")
+	serveCmd.Flags().BoolVar(&exportStdin, "export", false, "Print the HTML page of markdown read from \"-\" to stdout instead of serving it, like render -")
+	serveCmd.Flags().BoolVar(&daemon, "daemon", false, "Run the server in the background, logging to --log-file or a file in the user cache directory")
+	serveCmd.Flags().StringVar(&pidFile, "pid-file", "", "Write the process ID of the server to this file while it runs")
+	serveCmd.Flags().BoolVar(&gitInfo, "git-info", false, "Show the branch and last commit of each page below it")
+	serveCmd.Flags().BoolVar(&prose, "prose", false, "Underline possible spelling mistakes and repeated words")
+	serveCmd.Flags().StringVar(&spellChecker, "spell-checker", pkg.DefaultSpellChecker, "Command listing the misspelled words of its standard input for --prose, empty to only find repeated words")
+	serveCmd.Flags().StringSliceVar(&dictionaries, "dictionary", nil, "Files of words --prose accepts, one per line, in addition to the .grip-words file of the directory")
+	serveCmd.Flags().BoolVar(&imageProxy, "image-proxy", false, "Fetch remote images through the server and cache them on disk")
+	serveCmd.Flags().DurationVar(&imageCacheTTL, "image-cache-ttl", pkg.DefaultImageCacheTTL, "How long proxied images are cached before they are fetched again")
+	serveCmd.Flags().BoolVar(&offline, "offline", false, "Serve remote images only from the image cache, with placeholders for missing ones (implies --image-proxy)")
+	serveCmd.Flags().BoolVar(&metrics, "metrics", false, "Serve Prometheus metrics at /metrics and a health check at /healthz")
+	serveCmd.Flags().BoolVar(&accessLog, "access-log", false, "Log every request with method, path, status and duration")
+	serveCmd.Flags().BoolVar(&timings, "timings", false, "Log every render with the time spent parsing, highlighting code and executing the template, and the page size")
+	serveCmd.Flags().BoolVar(&compress, "compress", true, "Compress HTML, CSS and JS responses with gzip or brotli")
+	serveCmd.Flags().BoolVar(&tlsSelfSigned, "tls-self-signed", false, "Serve over HTTPS with a generated self-signed certificate")
+	serveCmd.Flags().BoolVar(&http2, "http2", true, "Serve HTTP/2 over HTTPS, loading pages with many images over a single connection")
+	serveCmd.Flags().DurationVar(&readTimeout, "read-timeout", pkg.DefaultReadTimeout, "Maximum time to read a request (0 is unlimited)")
+	serveCmd.Flags().DurationVar(&writeTimeout, "write-timeout", 0, "Maximum time to write a response, live reload excepted (0 is unlimited)")
+	serveCmd.Flags().DurationVar(&idleTimeout, "idle-timeout", pkg.DefaultIdleTimeout, "How long idle connections are kept open for further requests (0 is unlimited)")
+	serveCmd.MarkFlagsMutuallyExclusive("tls-cert", "tls-self-signed")
+	serveCmd.MarkFlagsMutuallyExclusive("tls-key", "tls-self-signed")
 }
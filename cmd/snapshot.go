@@ -0,0 +1,72 @@
+package cmd
+
+import (
+	"fmt"
+	"os"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var snapshotOutput string
+
+var snapshotCmd = &cobra.Command{
+	Use:   "snapshot FILE",
+	Short: "Print the rendered HTML of a file in a canonical form for golden tests",
+	Long: `Render a file like the preview, without the page layout, and print the HTML
+with one tag per line, sorted attributes and collapsed whitespace.
+
+The output only changes when the rendering of the document changes, so it can
+be committed as a golden file and compared in CI:
+
+  go-grip snapshot docs/guide.md -o testdata/guide.html
+  go-grip snapshot docs/guide.md | diff testdata/guide.html -`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		var parserOpts []pkg.ParserOption
+		parserOpts = append(parserOpts, pkg.WithLanguageDetection(detectLanguage))
+		refOpts, err := referencesOption()
+		if err != nil {
+			return err
+		}
+		parserOpts = append(parserOpts, refOpts...)
+		enc, err := pkg.ParseEncoding(encoding)
+		if err != nil {
+			return err
+		}
+		parser := pkg.NewParser(theme, parserOpts...)
+		srv := pkg.NewServer(hosts, port, theme, boundingBox, false, parser,
+			pkg.WithEncoding(enc),
+			pkg.WithMarkdownExtensions(markdownExtensions),
+			pkg.WithIncludes(includes),
+			pkg.WithVars(substituteVars || varsFile != "", varsFile),
+		)
+
+		out, err := srv.Snapshot(args[0])
+		if err != nil {
+			return err
+		}
+		if snapshotOutput == "" {
+			_, err = os.Stdout.Write(out)
+			return err
+		}
+		if err := os.WriteFile(snapshotOutput, out, 0644); err != nil {
+			return fmt.Errorf("failed to write snapshot: %v", err)
+		}
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(snapshotCmd)
+
+	snapshotCmd.Flags().StringVar(&theme, "theme", "light", "Select CSS theme [light/dark/dark_dimmed/dark_high_contrast/light_high_contrast/auto]")
+	snapshotCmd.Flags().StringSliceVar(&markdownExtensions, "extensions", pkg.DefaultMarkdownExtensions, "File extensions rendered as markdown")
+	snapshotCmd.Flags().BoolVar(&includes, "includes", false, "Expand <!-- include: file.md --> directives in markdown files")
+	snapshotCmd.Flags().BoolVar(&substituteVars, "substitute-vars", false, "Substitute {{ .name }} placeholders in markdown files with the fields of their front matter")
+	snapshotCmd.Flags().StringVar(&varsFile, "vars", "", "YAML file of variables for {{ .name }} placeholders, implies --substitute-vars; front matter fields take precedence")
+	snapshotCmd.Flags().BoolVar(&detectLanguage, "detect-language", true, "Highlight code blocks without language in the language guessed from their content")
+	snapshotCmd.Flags().StringVar(&repo, "repo", "", "Link #123, GH-123, owner/repo#123, @user and commit SHA references like GitHub does in the READMEs of this owner/name repository")
+	snapshotCmd.Flags().StringVar(&encoding, "encoding", "auto", "Encoding of the source files, e.g. utf-8, utf-16le or latin1, detected from a byte order mark or the content with auto")
+	snapshotCmd.Flags().StringVarP(&snapshotOutput, "output", "o", "", "Write the snapshot to a file instead of stdout")
+}
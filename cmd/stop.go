@@ -0,0 +1,40 @@
+package cmd
+
+import (
+	"fmt"
+	"strconv"
+
+	"github.com/chrishrb/go-grip/pkg"
+	"github.com/spf13/cobra"
+)
+
+var stopCmd = &cobra.Command{
+	Use:   "stop <id|port>",
+	Short: "Stop a running preview server",
+	Long: `Stop a running preview server by its id or port.
+
+Use "go-grip list" to show the running servers.`,
+	Args: cobra.ExactArgs(1),
+	RunE: func(cmd *cobra.Command, args []string) error {
+		idOrPort, err := strconv.Atoi(args[0])
+		if err != nil {
+			return fmt.Errorf("invalid id or port '%s'", args[0])
+		}
+
+		inst, err := pkg.FindInstance(idOrPort)
+		if err != nil {
+			return err
+		}
+
+		if err := inst.Stop(); err != nil {
+			return fmt.Errorf("failed to stop instance %d: %v", inst.ID, err)
+		}
+
+		fmt.Printf("Stopped instance %d (port %d)\n", inst.ID, inst.Port)
+		return nil
+	},
+}
+
+func init() {
+	rootCmd.AddCommand(stopCmd)
+}
@@ -7,3 +7,6 @@ var Templates embed.FS
 
 //go:embed static
 var StaticFiles embed.FS
+
+//go:embed locales
+var Locales embed.FS
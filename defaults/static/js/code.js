@@ -0,0 +1,18 @@
+// Copies the code of a block with a title bar when its copy button is
+// clicked. Clicks are handled on the document, so code blocks of live
+// reloaded content work too.
+(function () {
+  document.addEventListener("click", function (e) {
+    var button = e.target.closest(".grip-code-copy");
+    if (!button || !navigator.clipboard) {
+      return;
+    }
+    var pre = button.closest(".grip-code").querySelector("pre");
+    navigator.clipboard.writeText(pre.textContent).then(function () {
+      button.textContent = gripMessage("Copied");
+      setTimeout(function () {
+        button.textContent = gripMessage("Copy");
+      }, 1500);
+    });
+  });
+})();
@@ -0,0 +1,64 @@
+// Highlights the lines of a source file selected in the URL, #L10 or
+// #L10-L20. Shift-clicking a line number extends the selection to a range,
+// like GitHub's file view.
+(function () {
+  function parse(hash) {
+    var m = /^#L(\d+)(?:-L(\d+))?$/.exec(hash);
+    if (!m) {
+      return null;
+    }
+    var start = parseInt(m[1], 10);
+    var end = m[2] ? parseInt(m[2], 10) : start;
+    return { start: Math.min(start, end), end: Math.max(start, end) };
+  }
+
+  function line(n) {
+    var number = document.getElementById("L" + n);
+    return number && number.closest(".line");
+  }
+
+  function highlight() {
+    document.querySelectorAll(".grip-code-file .line.hl").forEach(function (el) {
+      el.classList.remove("hl");
+    });
+    var range = parse(location.hash);
+    if (!range) {
+      return;
+    }
+    for (var n = range.start; n <= range.end; n++) {
+      var el = line(n);
+      if (!el) {
+        break;
+      }
+      el.classList.add("hl");
+    }
+    var first = line(range.start);
+    if (first) {
+      first.scrollIntoView({ block: "center" });
+    }
+  }
+
+  document.addEventListener("click", function (e) {
+    var link = e.target.closest(".grip-code-file .lnlinks");
+    if (!link) {
+      return;
+    }
+    e.preventDefault();
+    var n = parseInt(link.textContent, 10);
+    var range = parse(location.hash);
+    var hash = "#L" + n;
+    if (e.shiftKey && range) {
+      hash = "#L" + Math.min(range.start, n) + "-L" + Math.max(range.start, n);
+    }
+    // replace the hash without jumping to the line
+    history.replaceState(null, "", hash);
+    highlight();
+  });
+
+  window.addEventListener("hashchange", highlight);
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", highlight);
+  } else {
+    highlight();
+  }
+})();
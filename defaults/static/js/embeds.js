@@ -0,0 +1,15 @@
+// Sizes the frames of embedded gists to their content. The frames are
+// sandboxed, so they post their height instead of the page reading it.
+(function () {
+  window.addEventListener("message", function (e) {
+    var height = e.data && e.data.gripEmbedHeight;
+    if (typeof height !== "number") {
+      return;
+    }
+    document.querySelectorAll("iframe.grip-gist").forEach(function (frame) {
+      if (frame.contentWindow === e.source) {
+        frame.style.height = height + "px";
+      }
+    });
+  });
+})();
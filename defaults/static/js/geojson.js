@@ -0,0 +1,352 @@
+// Renders .grip-map elements holding GeoJSON or TopoJSON as interactive SVG
+// maps in Web Mercator projection, drawn over raster tiles if the element has
+// a data-tiles URL template. Drag to pan, use the wheel or double click to zoom.
+(function () {
+  var TILE_SIZE = 256;
+  var MAX_LAT = 85.05112878;
+  var SVG_NS = "http://www.w3.org/2000/svg";
+
+  // project returns the position of a coordinate in pixels at zoom level 0.
+  function project(coord) {
+    var lat = Math.max(-MAX_LAT, Math.min(MAX_LAT, coord[1]));
+    var sin = Math.sin((lat * Math.PI) / 180);
+    return [
+      ((coord[0] + 180) / 360) * TILE_SIZE,
+      (0.5 - Math.log((1 + sin) / (1 - sin)) / (4 * Math.PI)) * TILE_SIZE,
+    ];
+  }
+
+  function topoToGeo(topo) {
+    var t = topo.transform;
+
+    function point(p) {
+      return t ? [p[0] * t.scale[0] + t.translate[0], p[1] * t.scale[1] + t.translate[1]] : p;
+    }
+
+    function arc(i) {
+      var points = [];
+      var x = 0;
+      var y = 0;
+      topo.arcs[i < 0 ? ~i : i].forEach(function (p) {
+        if (t) {
+          // quantized arcs are delta encoded
+          x += p[0];
+          y += p[1];
+          points.push(point([x, y]));
+        } else {
+          points.push(p.slice());
+        }
+      });
+      if (i < 0) {
+        points.reverse();
+      }
+      return points;
+    }
+
+    function line(arcs) {
+      var points = [];
+      arcs.forEach(function (i, k) {
+        var a = arc(i);
+        if (k > 0) {
+          a.shift();
+        }
+        points = points.concat(a);
+      });
+      return points;
+    }
+
+    function geometry(g) {
+      switch (g.type) {
+        case "GeometryCollection":
+          return { type: g.type, geometries: g.geometries.map(geometry) };
+        case "Point":
+          return { type: g.type, coordinates: point(g.coordinates) };
+        case "MultiPoint":
+          return { type: g.type, coordinates: g.coordinates.map(point) };
+        case "LineString":
+          return { type: g.type, coordinates: line(g.arcs) };
+        case "MultiLineString":
+        case "Polygon":
+          return { type: g.type, coordinates: g.arcs.map(line) };
+        case "MultiPolygon":
+          return {
+            type: g.type,
+            coordinates: g.arcs.map(function (polygon) {
+              return polygon.map(line);
+            }),
+          };
+      }
+      return null;
+    }
+
+    var features = [];
+    Object.keys(topo.objects).forEach(function (name) {
+      var object = topo.objects[name];
+      var objects = object.type === "GeometryCollection" ? object.geometries : [object];
+      objects.forEach(function (o) {
+        features.push({ type: "Feature", properties: o.properties || {}, geometry: geometry(o) });
+      });
+    });
+    return { type: "FeatureCollection", features: features };
+  }
+
+  // features flattens any GeoJSON object into a list of features.
+  function features(geo) {
+    switch (geo.type) {
+      case "FeatureCollection":
+        return geo.features;
+      case "Feature":
+        return [geo];
+      default:
+        return [{ type: "Feature", properties: {}, geometry: geo }];
+    }
+  }
+
+  // shapes splits a geometry into points, lines and polygons of projected
+  // coordinates.
+  function shapes(g, out) {
+    if (!g) {
+      return out;
+    }
+    switch (g.type) {
+      case "GeometryCollection":
+        g.geometries.forEach(function (child) {
+          shapes(child, out);
+        });
+        break;
+      case "Point":
+        out.points.push(project(g.coordinates));
+        break;
+      case "MultiPoint":
+        g.coordinates.forEach(function (c) {
+          out.points.push(project(c));
+        });
+        break;
+      case "LineString":
+        out.lines.push(g.coordinates.map(project));
+        break;
+      case "MultiLineString":
+        g.coordinates.forEach(function (l) {
+          out.lines.push(l.map(project));
+        });
+        break;
+      case "Polygon":
+        out.polygons.push(g.coordinates.map(function (ring) {
+          return ring.map(project);
+        }));
+        break;
+      case "MultiPolygon":
+        g.coordinates.forEach(function (polygon) {
+          out.polygons.push(polygon.map(function (ring) {
+            return ring.map(project);
+          }));
+        });
+        break;
+    }
+    return out;
+  }
+
+  function describe(properties) {
+    return Object.keys(properties || {})
+      .map(function (key) {
+        return key + ": " + JSON.stringify(properties[key]);
+      })
+      .join("\n");
+  }
+
+  function render(el) {
+    var data = JSON.parse(el.querySelector("script").textContent);
+    if (el.dataset.format === "topojson" || data.type === "Topology") {
+      data = topoToGeo(data);
+    }
+
+    var items = features(data).map(function (f) {
+      return { title: describe(f.properties), shapes: shapes(f.geometry, { points: [], lines: [], polygons: [] }) };
+    });
+
+    var minX = Infinity;
+    var minY = Infinity;
+    var maxX = -Infinity;
+    var maxY = -Infinity;
+    function extend(p) {
+      minX = Math.min(minX, p[0]);
+      minY = Math.min(minY, p[1]);
+      maxX = Math.max(maxX, p[0]);
+      maxY = Math.max(maxY, p[1]);
+    }
+    items.forEach(function (item) {
+      item.shapes.points.forEach(extend);
+      item.shapes.lines.forEach(function (l) {
+        l.forEach(extend);
+      });
+      item.shapes.polygons.forEach(function (polygon) {
+        polygon.forEach(function (ring) {
+          ring.forEach(extend);
+        });
+      });
+    });
+    if (minX === Infinity) {
+      throw new Error("no geometries");
+    }
+
+    var tiles = document.createElement("div");
+    tiles.className = "grip-map-tiles";
+    var svg = document.createElementNS(SVG_NS, "svg");
+    el.appendChild(tiles);
+    el.appendChild(svg);
+    if (el.dataset.tiles) {
+      var attribution = document.createElement("div");
+      attribution.className = "grip-map-attribution";
+      attribution.innerHTML = '&copy; <a href="https://www.openstreetmap.org/copyright">OpenStreetMap</a> contributors';
+      el.appendChild(attribution);
+    }
+
+    var width = el.clientWidth;
+    var height = el.clientHeight;
+    var center = [(minX + maxX) / 2, (minY + maxY) / 2];
+    var spanX = Math.max(maxX - minX, 1e-9);
+    var spanY = Math.max(maxY - minY, 1e-9);
+    var zoom = Math.log2(Math.min((width * 0.9) / spanX, (height * 0.9) / spanY));
+    zoom = Math.max(0, Math.min(zoom, 18));
+
+    function toScreen(p) {
+      var scale = Math.pow(2, zoom);
+      return [(p[0] - center[0]) * scale + width / 2, (p[1] - center[1]) * scale + height / 2];
+    }
+
+    function pathData(points, close) {
+      return points
+        .map(function (p, i) {
+          var s = toScreen(p);
+          return (i === 0 ? "M" : "L") + s[0].toFixed(1) + " " + s[1].toFixed(1);
+        })
+        .join("") + (close ? "Z" : "");
+    }
+
+    function drawTiles() {
+      tiles.textContent = "";
+      if (!el.dataset.tiles) {
+        return;
+      }
+      var z = Math.max(0, Math.min(19, Math.round(zoom)));
+      var size = TILE_SIZE * Math.pow(2, zoom - z);
+      var count = Math.pow(2, z);
+      var scale = Math.pow(2, zoom);
+      var left = center[0] * scale - width / 2;
+      var top = center[1] * scale - height / 2;
+      for (var ty = Math.floor(top / size); ty * size < top + height; ty++) {
+        if (ty < 0 || ty >= count) {
+          continue;
+        }
+        for (var tx = Math.floor(left / size); tx * size < left + width; tx++) {
+          var img = document.createElement("img");
+          img.alt = "";
+          img.src = el.dataset.tiles
+            .replace("{z}", z)
+            .replace("{x}", ((tx % count) + count) % count)
+            .replace("{y}", ty);
+          img.style.left = tx * size - left + "px";
+          img.style.top = ty * size - top + "px";
+          img.style.width = img.style.height = size + "px";
+          tiles.appendChild(img);
+        }
+      }
+    }
+
+    function draw() {
+      svg.textContent = "";
+      svg.setAttribute("width", width);
+      svg.setAttribute("height", height);
+      items.forEach(function (item) {
+        var group = document.createElementNS(SVG_NS, "g");
+        if (item.title) {
+          var title = document.createElementNS(SVG_NS, "title");
+          title.textContent = item.title;
+          group.appendChild(title);
+        }
+        item.shapes.polygons.forEach(function (polygon) {
+          var path = document.createElementNS(SVG_NS, "path");
+          path.setAttribute("class", "grip-map-polygon");
+          path.setAttribute("fill-rule", "evenodd");
+          path.setAttribute("d", polygon.map(function (ring) {
+            return pathData(ring, true);
+          }).join(""));
+          group.appendChild(path);
+        });
+        item.shapes.lines.forEach(function (line) {
+          var path = document.createElementNS(SVG_NS, "path");
+          path.setAttribute("class", "grip-map-line");
+          path.setAttribute("d", pathData(line, false));
+          group.appendChild(path);
+        });
+        item.shapes.points.forEach(function (p) {
+          var s = toScreen(p);
+          var circle = document.createElementNS(SVG_NS, "circle");
+          circle.setAttribute("class", "grip-map-point");
+          circle.setAttribute("cx", s[0]);
+          circle.setAttribute("cy", s[1]);
+          circle.setAttribute("r", 6);
+          group.appendChild(circle);
+        });
+        svg.appendChild(group);
+      });
+      drawTiles();
+    }
+
+    function zoomAt(delta, x, y) {
+      var scale = Math.pow(2, zoom);
+      var world = [center[0] + (x - width / 2) / scale, center[1] + (y - height / 2) / scale];
+      zoom = Math.max(0, Math.min(19, zoom + delta));
+      scale = Math.pow(2, zoom);
+      center = [world[0] - (x - width / 2) / scale, world[1] - (y - height / 2) / scale];
+      draw();
+    }
+
+    el.addEventListener("wheel", function (e) {
+      e.preventDefault();
+      var rect = el.getBoundingClientRect();
+      zoomAt(e.deltaY < 0 ? 0.5 : -0.5, e.clientX - rect.left, e.clientY - rect.top);
+    });
+    el.addEventListener("dblclick", function (e) {
+      var rect = el.getBoundingClientRect();
+      zoomAt(e.shiftKey ? -1 : 1, e.clientX - rect.left, e.clientY - rect.top);
+    });
+
+    var drag = null;
+    el.addEventListener("pointerdown", function (e) {
+      drag = { x: e.clientX, y: e.clientY, center: center.slice() };
+      el.setPointerCapture(e.pointerId);
+    });
+    el.addEventListener("pointermove", function (e) {
+      if (!drag) {
+        return;
+      }
+      var scale = Math.pow(2, zoom);
+      center = [drag.center[0] - (e.clientX - drag.x) / scale, drag.center[1] - (e.clientY - drag.y) / scale];
+      draw();
+    });
+    el.addEventListener("pointerup", function () {
+      drag = null;
+    });
+
+    draw();
+  }
+
+  function renderAll() {
+    document.querySelectorAll(".grip-map:not([data-rendered])").forEach(function (el) {
+      el.dataset.rendered = "true";
+      try {
+        render(el);
+      } catch (err) {
+        el.textContent = "Failed to render map: " + err.message;
+        el.classList.add("grip-map-error");
+      }
+    });
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", renderAll);
+  } else {
+    renderAll();
+  }
+})();
@@ -0,0 +1,51 @@
+// Shows the branch and the last commit of the page in a bar below it, loaded
+// from /api/git.
+(function () {
+  document.addEventListener("DOMContentLoaded", function () {
+    var container = document.querySelector(".container");
+    if (!container) {
+      return;
+    }
+    var base = document.documentElement.dataset.basePath || "";
+    var file = decodeURIComponent(location.pathname);
+    if (base && file.indexOf(base + "/") === 0) {
+      file = file.slice(base.length);
+    }
+    fetch(base + "/api/git?file=" + encodeURIComponent(file))
+      .then(function (res) {
+        return res.ok ? res.json() : null;
+      })
+      .then(function (info) {
+        if (!info) {
+          return;
+        }
+        var bar = document.createElement("div");
+        bar.className = "container grip-gitinfo";
+
+        function add(text, className, title) {
+          var span = document.createElement("span");
+          span.textContent = text;
+          if (className) {
+            span.className = className;
+          }
+          if (title) {
+            span.title = title;
+          }
+          bar.appendChild(span);
+        }
+
+        add(info.branch, "grip-gitinfo-branch");
+        if (info.commit) {
+          add(info.commit.slice(0, 7), "grip-gitinfo-commit", info.subject);
+          add(info.author + " on " + new Date(info.date).toLocaleString());
+        } else {
+          add(gripMessage("not committed"));
+        }
+        if (info.modified) {
+          add(gripMessage("modified"), "grip-gitinfo-modified", gripMessage("The file has uncommitted changes"));
+        }
+        container.insertAdjacentElement("afterend", bar);
+      })
+      .catch(function () {});
+  });
+})();
@@ -0,0 +1,40 @@
+// Translates the user interface strings of the scripts into the language
+// selected with --lang. The server embeds the catalog of the language in the
+// page, keyed by the English strings, which are used when a string has no
+// translation. Strings rendered with the document, like the copy buttons of
+// code blocks, are translated when the page has loaded.
+(function () {
+  var messages = {};
+  var el = document.getElementById("grip-messages");
+  if (el) {
+    try {
+      messages = JSON.parse(el.textContent);
+    } catch (e) {}
+  }
+
+  // gripMessage returns the translation of key with each %s or %d replaced
+  // by the next argument.
+  window.gripMessage = function (key) {
+    var args = Array.prototype.slice.call(arguments, 1);
+    return (messages[key] || key).replace(/%[sd]/g, function () {
+      return args.length ? String(args.shift()) : "";
+    });
+  };
+
+  document.addEventListener("DOMContentLoaded", function () {
+    if (!Object.keys(messages).length) {
+      return;
+    }
+    document.querySelectorAll(".grip-code-copy").forEach(function (button) {
+      button.textContent = gripMessage("Copy");
+      button.title = button.textContent;
+    });
+    document.querySelectorAll(".grip-link-outside").forEach(function (a) {
+      a.title = gripMessage("Outside of the previewed directory");
+    });
+    document.querySelectorAll(".grip-csv-search").forEach(function (input) {
+      input.placeholder = gripMessage("Search this file…");
+      input.setAttribute("aria-label", input.placeholder);
+    });
+  });
+})();
@@ -0,0 +1,54 @@
+// Shows images of the page enlarged in an overlay when they are clicked. A
+// click anywhere or Escape closes the overlay. Clicks are handled on the
+// document, so images of live reloaded content work too.
+(function () {
+  var overlay = null;
+
+  function close() {
+    if (overlay) {
+      overlay.remove();
+      overlay = null;
+    }
+  }
+
+  function open(img) {
+    overlay = document.createElement("div");
+    overlay.className = "grip-lightbox";
+    var large = document.createElement("img");
+    large.src = img.currentSrc || img.src;
+    large.alt = img.alt;
+    overlay.appendChild(large);
+    if (img.alt) {
+      var caption = document.createElement("div");
+      caption.className = "grip-lightbox-caption";
+      caption.textContent = img.alt;
+      overlay.appendChild(caption);
+    }
+    overlay.addEventListener("click", close);
+    document.body.appendChild(overlay);
+  }
+
+  document.addEventListener("click", function (e) {
+    var img = e.target.closest(".container img");
+    if (!img || img.classList.contains("emoji")) {
+      return;
+    }
+    // images linking somewhere else keep their link
+    var link = img.closest("a");
+    if (link && link.href !== img.src) {
+      return;
+    }
+    e.preventDefault();
+    open(img);
+  });
+
+  document.addEventListener("DOMContentLoaded", function () {
+    document.body.classList.add("grip-lightbox-enabled");
+  });
+
+  document.addEventListener("keydown", function (e) {
+    if (e.key === "Escape") {
+      close();
+    }
+  });
+})();
@@ -0,0 +1,104 @@
+// Underlines links of the preview that don't resolve: links to files the
+// server doesn't have, anchors missing on this page and images that fail to
+// load. Each target is only requested once.
+(function () {
+  var checked = {};
+
+  function mark(el, reason, className) {
+    el.classList.add(className || "grip-broken-link");
+    el.title = reason;
+  }
+
+  // distance is the edit distance of two slugs, to suggest the heading a
+  // broken anchor was meant to point to.
+  function distance(a, b) {
+    var prev = [];
+    for (var j = 0; j <= b.length; j++) {
+      prev.push(j);
+    }
+    for (var i = 1; i <= a.length; i++) {
+      var cur = [i];
+      for (var k = 1; k <= b.length; k++) {
+        cur.push(Math.min(prev[k] + 1, cur[k - 1] + 1, prev[k - 1] + (a[i - 1] === b[k - 1] ? 0 : 1)));
+      }
+      prev = cur;
+    }
+    return prev[b.length];
+  }
+
+  function closestHeading(id) {
+    var best, bestDistance = Infinity;
+    document.querySelectorAll(".markdown-body :is(h1, h2, h3, h4, h5, h6)[id]").forEach(function (h) {
+      var d = distance(id, h.id);
+      if (d < bestDistance) {
+        best = h.id;
+        bestDistance = d;
+      }
+    });
+    // only suggest headings that look like a typo of the anchor
+    return bestDistance <= Math.max(2, id.length / 3) ? best : "";
+  }
+
+  function markAnchor(a, hash) {
+    var id = decodeURIComponent(hash.slice(1));
+    var reason = gripMessage("Broken anchor: %s doesn't match any heading", hash);
+    var suggestion = closestHeading(id);
+    if (suggestion) {
+      reason = gripMessage("Broken anchor: %s doesn't match any heading, did you mean %s?", hash, "#" + suggestion);
+    }
+    mark(a, reason, "grip-broken-anchor");
+  }
+
+  function exists(path) {
+    if (!checked[path]) {
+      checked[path] = fetch(path, { method: "HEAD" }).then(
+        function (res) {
+          return res.status !== 404;
+        },
+        function () {
+          return true;
+        }
+      );
+    }
+    return checked[path];
+  }
+
+  function hasAnchor(hash) {
+    var id = decodeURIComponent(hash.slice(1));
+    return !id || document.getElementById(id) || document.getElementsByName(id).length > 0;
+  }
+
+  document.querySelectorAll(".container a[href]").forEach(function (a) {
+    if (a.dataset.rendered || a.classList.contains("grip-link-outside")) {
+      return;
+    }
+    a.dataset.rendered = "true";
+
+    var url = new URL(a.getAttribute("href"), location.href);
+    if (url.origin !== location.origin) {
+      return;
+    }
+    if (url.pathname === location.pathname) {
+      if (url.hash && !hasAnchor(url.hash)) {
+        markAnchor(a, url.hash);
+      }
+      return;
+    }
+    exists(url.pathname).then(function (ok) {
+      if (!ok) {
+        mark(a, gripMessage("Broken link: %s not found", url.pathname));
+      }
+    });
+  });
+
+  document.querySelectorAll(".container img[src]").forEach(function (img) {
+    function broken() {
+      mark(img, gripMessage("Broken image: %s not found", img.getAttribute("src")));
+    }
+    if (img.complete && img.naturalWidth === 0) {
+      broken();
+    } else {
+      img.addEventListener("error", broken);
+    }
+  });
+})();
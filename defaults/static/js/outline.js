@@ -0,0 +1,147 @@
+// Adds an "Outline" button listing the headings of the page in a popover,
+// like the headings menu of GitHub. Typing filters the headings, the arrow
+// keys select one and Enter jumps to it. Headings are read when the popover
+// opens, so live reloaded content is listed too.
+(function () {
+  var popover = null;
+  var button;
+
+  function headings() {
+    return Array.prototype.filter.call(
+      document.querySelectorAll(".container :is(h1, h2, h3, h4, h5, h6)[id]"),
+      function (h) {
+        return h.textContent.trim() !== "";
+      },
+    );
+  }
+
+  function close() {
+    if (popover) {
+      popover.remove();
+      popover = null;
+      button.setAttribute("aria-expanded", "false");
+    }
+  }
+
+  function jump(id) {
+    close();
+    location.hash = "#" + encodeURIComponent(id);
+  }
+
+  function open() {
+    var items = headings().map(function (h) {
+      var link = document.createElement("a");
+      link.className = "grip-outline-item";
+      link.href = "#" + encodeURIComponent(h.id);
+      link.textContent = h.textContent.trim();
+      link.style.paddingLeft = 8 + (Number(h.tagName[1]) - 1) * 12 + "px";
+      link.setAttribute("role", "option");
+      link.addEventListener("click", function (e) {
+        e.preventDefault();
+        jump(h.id);
+      });
+      return { el: link, id: h.id, text: link.textContent.toLowerCase() };
+    });
+
+    popover = document.createElement("div");
+    popover.className = "grip-outline";
+    var filter = document.createElement("input");
+    filter.type = "search";
+    filter.placeholder = gripMessage("Filter headings");
+    filter.setAttribute("aria-label", filter.placeholder);
+    var list = document.createElement("div");
+    list.className = "grip-outline-list";
+    list.setAttribute("role", "listbox");
+    items.forEach(function (item) {
+      list.appendChild(item.el);
+    });
+    var empty = document.createElement("div");
+    empty.className = "grip-outline-empty";
+    empty.textContent = gripMessage("No matching headings");
+    list.appendChild(empty);
+    popover.appendChild(filter);
+    popover.appendChild(list);
+    document.body.appendChild(popover);
+    button.setAttribute("aria-expanded", "true");
+
+    var visible = items;
+    var selected = 0;
+
+    function select(i) {
+      visible.forEach(function (item, j) {
+        item.el.classList.toggle("grip-outline-selected", j === i);
+        item.el.setAttribute("aria-selected", j === i ? "true" : "false");
+      });
+      selected = i;
+      if (visible[i]) {
+        visible[i].el.scrollIntoView({ block: "nearest" });
+      }
+    }
+
+    filter.addEventListener("input", function () {
+      var query = filter.value.trim().toLowerCase();
+      visible = items.filter(function (item) {
+        var match = item.text.indexOf(query) >= 0;
+        item.el.style.display = match ? "" : "none";
+        return match;
+      });
+      empty.hidden = visible.length > 0;
+      select(0);
+    });
+
+    filter.addEventListener("keydown", function (e) {
+      switch (e.key) {
+        case "ArrowDown":
+          e.preventDefault();
+          select(Math.min(selected + 1, visible.length - 1));
+          break;
+        case "ArrowUp":
+          e.preventDefault();
+          select(Math.max(selected - 1, 0));
+          break;
+        case "Enter":
+          e.preventDefault();
+          if (visible[selected]) {
+            jump(visible[selected].id);
+          }
+          break;
+        case "Escape":
+          close();
+          button.focus();
+          break;
+      }
+    });
+
+    empty.hidden = items.length > 0;
+    select(0);
+    filter.focus();
+  }
+
+  document.addEventListener("click", function (e) {
+    if (popover && !popover.contains(e.target) && e.target !== button) {
+      close();
+    }
+  });
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var toolbar = document.querySelector(".grip-toolbar");
+    if (!toolbar || headings().length === 0) {
+      return;
+    }
+    button = document.createElement("button");
+    button.className = "grip-button";
+    button.type = "button";
+    button.textContent = gripMessage("Outline");
+    button.title = gripMessage("List the headings of the page");
+    button.setAttribute("aria-haspopup", "listbox");
+    button.setAttribute("aria-expanded", "false");
+    button.addEventListener("click", function () {
+      if (popover) {
+        close();
+      } else {
+        open();
+      }
+    });
+    toolbar.insertBefore(button, toolbar.firstChild);
+  });
+})();
@@ -0,0 +1,76 @@
+// Underlines the possible spelling mistakes and repeated words of the page,
+// loaded from /api/prose, with the issue as tooltip. Code is left alone.
+(function () {
+  var skip = "pre, code, kbd, samp, script, style, svg, .grip-prose-issue";
+
+  function escape(text) {
+    return text.replace(/[.*+?^${}()|[\]\\]/g, "\\$&");
+  }
+
+  function underline(root, issue) {
+    // whole words only, the repeated words may be separated by any space
+    var words = issue.text.split(/\s+/).map(escape).join("\\s+");
+    var re = new RegExp("(^|[^\\p{L}'’])(" + words + ")(?![\\p{L}'’])", "gu");
+    var title =
+      issue.kind === "repetition"
+        ? gripMessage("Repeated word: %s", issue.text)
+        : gripMessage("Possible spelling mistake: %s", issue.text);
+
+    var walker = document.createTreeWalker(root, NodeFilter.SHOW_TEXT, {
+      acceptNode: function (node) {
+        return node.parentElement.closest(skip) ? NodeFilter.FILTER_REJECT : NodeFilter.FILTER_ACCEPT;
+      },
+    });
+    var nodes = [];
+    while (walker.nextNode()) {
+      nodes.push(walker.currentNode);
+    }
+    nodes.forEach(function (node) {
+      var text = node.nodeValue;
+      var m, last = 0;
+      var fragment = document.createDocumentFragment();
+      re.lastIndex = 0;
+      while ((m = re.exec(text))) {
+        var start = m.index + m[1].length;
+        fragment.appendChild(document.createTextNode(text.slice(last, start)));
+        var span = document.createElement("span");
+        span.className = "grip-prose-issue grip-prose-" + issue.kind;
+        span.title = title;
+        span.textContent = m[2];
+        fragment.appendChild(span);
+        last = start + m[2].length;
+      }
+      if (last > 0) {
+        fragment.appendChild(document.createTextNode(text.slice(last)));
+        node.parentNode.replaceChild(fragment, node);
+      }
+    });
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var root = document.querySelector(".container");
+    if (!root) {
+      return;
+    }
+    var base = document.documentElement.dataset.basePath || "";
+    var file = decodeURIComponent(location.pathname);
+    if (base && file.indexOf(base + "/") === 0) {
+      file = file.slice(base.length);
+    }
+    fetch(base + "/api/prose?file=" + encodeURIComponent(file))
+      .then(function (res) {
+        return res.ok ? res.json() : [];
+      })
+      .then(function (issues) {
+        var seen = {};
+        issues.forEach(function (issue) {
+          var key = issue.kind + ":" + issue.text;
+          if (!seen[key]) {
+            seen[key] = true;
+            underline(root, issue);
+          }
+        });
+      })
+      .catch(function () {});
+  });
+})();
@@ -0,0 +1,92 @@
+(function () {
+  var banner;
+  var delay = 1000;
+  var script = document.currentScript;
+  var transport = (script && script.getAttribute("data-transport")) || "auto";
+  var base = document.documentElement.dataset.basePath || "";
+
+  function showBanner(text) {
+    if (!banner) {
+      banner = document.createElement("div");
+      banner.className = "reload-banner";
+      banner.setAttribute("role", "status");
+      banner.style.cssText =
+        "position:fixed;top:0;left:0;right:0;z-index:1000;padding:6px 12px;" +
+        "text-align:center;font-size:14px;color:#fff;background:#cf222e;";
+      document.body.appendChild(banner);
+    }
+    banner.textContent = text;
+  }
+
+  function onMessage(data) {
+    if (data === "reload") {
+      location.reload();
+    } else if (data === "stale") {
+      showBanner(gripMessage("Live reload stopped watching files, this page may be out of date. Retrying…"));
+    }
+  }
+
+  function onOpen(isRetry) {
+    delay = 1000;
+    // changes may have happened while disconnected
+    if (isRetry) {
+      location.reload();
+    }
+  }
+
+  function retry() {
+    showBanner(gripMessage("Live reload disconnected. Reconnecting…"));
+    setTimeout(function () {
+      connect(true);
+    }, delay);
+    delay = Math.min(delay * 2, 10000);
+  }
+
+  function connectEvents(isRetry) {
+    var events = new EventSource(base + "/reload_events");
+    events.onopen = function () {
+      onOpen(isRetry);
+    };
+    events.onmessage = function (msg) {
+      onMessage(msg.data);
+    };
+    events.onerror = function () {
+      events.close();
+      retry();
+    };
+  }
+
+  function connectWS(isRetry) {
+    var protocol = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(protocol + location.host + base + "/reload_ws");
+    var opened = false;
+
+    ws.onopen = function () {
+      opened = true;
+      onOpen(isRetry);
+    };
+    ws.onmessage = function (msg) {
+      onMessage(msg.data);
+    };
+    ws.onclose = function () {
+      // websockets may be blocked by a proxy, server-sent events are plain
+      // HTTP responses
+      if (!opened && transport === "auto" && window.EventSource) {
+        transport = "sse";
+        connectEvents(isRetry);
+        return;
+      }
+      retry();
+    };
+  }
+
+  function connect(isRetry) {
+    if (transport === "sse") {
+      connectEvents(isRetry);
+    } else {
+      connectWS(isRetry);
+    }
+  }
+
+  connect(false);
+})();
@@ -0,0 +1,73 @@
+// Adds a "share" button copying a link to the page pinned to the revision it
+// shows as ?rev=. Pages opened from a link to an older revision show a banner
+// telling that the file changed since the link was shared.
+(function () {
+  var revision = document.documentElement.dataset.revision;
+
+  function shareURL() {
+    var url = new URL(location.href);
+    url.searchParams.set("rev", revision);
+    return url.toString();
+  }
+
+  function copy(text) {
+    // the clipboard API is only available on localhost and HTTPS, not on
+    // plain HTTP LAN addresses
+    if (navigator.clipboard && window.isSecureContext) {
+      return navigator.clipboard.writeText(text);
+    }
+    window.prompt(gripMessage("Copy this link to share the page:"), text);
+    return Promise.reject();
+  }
+
+  function showBanner() {
+    var banner = document.createElement("div");
+    banner.className = "grip-share-banner";
+    banner.setAttribute("role", "status");
+    var text = document.createElement("span");
+    text.textContent = gripMessage("This file has changed since the link was shared, you are seeing a newer version.");
+    var dismiss = document.createElement("button");
+    dismiss.className = "grip-button";
+    dismiss.type = "button";
+    dismiss.textContent = gripMessage("Dismiss");
+    dismiss.addEventListener("click", function () {
+      var url = new URL(location.href);
+      url.searchParams.delete("rev");
+      history.replaceState(history.state, "", url.toString());
+      banner.remove();
+    });
+    banner.appendChild(text);
+    banner.appendChild(dismiss);
+    var container = document.querySelector(".container");
+    container.parentNode.insertBefore(banner, container);
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var shared = new URL(location.href).searchParams.get("rev");
+    if (shared && shared !== revision) {
+      showBanner();
+    }
+
+    var toolbar = document.querySelector(".grip-toolbar");
+    if (!toolbar) {
+      return;
+    }
+    var button = document.createElement("button");
+    button.className = "grip-button";
+    button.type = "button";
+    button.textContent = gripMessage("Share");
+    button.title = gripMessage("Copy a link to this revision of the page");
+    button.addEventListener("click", function () {
+      copy(shareURL()).then(
+        function () {
+          button.textContent = gripMessage("Copied");
+          setTimeout(function () {
+            button.textContent = gripMessage("Share");
+          }, 1500);
+        },
+        function () {}
+      );
+    });
+    toolbar.appendChild(button);
+  });
+})();
@@ -0,0 +1,69 @@
+// Presents the slides of the page one at a time. The arrow, page and space
+// keys navigate, Home and End jump to the first and last slide and f toggles
+// fullscreen. The slide number is kept in the URL hash, so live reloads stay
+// on the current slide.
+(function () {
+  document.addEventListener("DOMContentLoaded", function () {
+    var slides = document.querySelectorAll(".grip-slide");
+    if (slides.length === 0) {
+      return;
+    }
+    document.body.classList.add("grip-presenting");
+
+    var counter = document.createElement("div");
+    counter.className = "grip-slide-counter";
+    document.body.appendChild(counter);
+
+    var current = 0;
+    function show(i) {
+      current = Math.max(0, Math.min(slides.length - 1, i));
+      slides.forEach(function (slide, j) {
+        slide.classList.toggle("active", j === current);
+      });
+      counter.textContent = current + 1 + " / " + slides.length;
+      history.replaceState(null, "", "#" + (current + 1));
+    }
+
+    function toggleFullscreen() {
+      if (document.fullscreenElement) {
+        document.exitFullscreen();
+      } else {
+        document.documentElement.requestFullscreen();
+      }
+    }
+
+    document.addEventListener("keydown", function (e) {
+      if (e.altKey || e.ctrlKey || e.metaKey || e.target.closest("input, textarea, select")) {
+        return;
+      }
+      switch (e.key) {
+        case "ArrowRight":
+        case "ArrowDown":
+        case "PageDown":
+        case " ":
+          show(current + 1);
+          break;
+        case "ArrowLeft":
+        case "ArrowUp":
+        case "PageUp":
+          show(current - 1);
+          break;
+        case "Home":
+          show(0);
+          break;
+        case "End":
+          show(slides.length - 1);
+          break;
+        case "f":
+          toggleFullscreen();
+          break;
+        default:
+          return;
+      }
+      e.preventDefault();
+    });
+
+    var n = parseInt(location.hash.slice(1), 10);
+    show(isNaN(n) ? 0 : n - 1);
+  });
+})();
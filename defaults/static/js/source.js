@@ -0,0 +1,73 @@
+// Adds a "view source" toggle showing the syntax highlighted markdown next to
+// the rendered page. The source is loaded from the page URL with ?source=1;
+// the toggle state survives live reloads.
+(function () {
+  var storageKey = "go-grip-source";
+  var pane;
+
+  function sourceURL() {
+    var url = new URL(location.href);
+    url.hash = "";
+    url.searchParams.set("source", "1");
+    return url.toString();
+  }
+
+  function show(button) {
+    document.body.classList.add("grip-source-view");
+    button.textContent = gripMessage("Hide source");
+    if (!pane) {
+      pane = document.createElement("div");
+      pane.className = "grip-source";
+      document.body.appendChild(pane);
+    }
+    fetch(sourceURL())
+      .then(function (res) {
+        if (!res.ok) {
+          throw new Error(res.status + " " + res.statusText);
+        }
+        return res.text();
+      })
+      .then(function (html) {
+        pane.innerHTML = html;
+      })
+      .catch(function (err) {
+        pane.textContent = gripMessage("Failed to load source: %s", err.message);
+      });
+  }
+
+  function hide(button) {
+    document.body.classList.remove("grip-source-view");
+    button.textContent = gripMessage("View source");
+    if (pane) {
+      pane.remove();
+      pane = null;
+    }
+  }
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var toolbar = document.querySelector(".grip-toolbar");
+    if (!toolbar) {
+      return;
+    }
+    var button = document.createElement("button");
+    button.className = "grip-button";
+    button.type = "button";
+    button.title = gripMessage("Show the markdown source next to the page");
+    button.addEventListener("click", function () {
+      if (pane) {
+        sessionStorage.removeItem(storageKey);
+        hide(button);
+      } else {
+        sessionStorage.setItem(storageKey, "1");
+        show(button);
+      }
+    });
+    toolbar.appendChild(button);
+
+    if (sessionStorage.getItem(storageKey)) {
+      show(button);
+    } else {
+      hide(button);
+    }
+  });
+})();
@@ -0,0 +1,263 @@
+// Renders .grip-stl elements as interactive 3D previews of the ASCII or
+// binary STL file at their data-src URL, using plain WebGL. Drag to rotate,
+// use the wheel to zoom. Colors follow the page's color mode.
+(function () {
+  var VERTEX_SHADER =
+    "attribute vec3 position;" +
+    "attribute vec3 normal;" +
+    "uniform mat4 model;" +
+    "uniform mat4 projection;" +
+    "varying vec3 vNormal;" +
+    "void main() {" +
+    "  vNormal = mat3(model) * normal;" +
+    "  gl_Position = projection * model * vec4(position, 1.0);" +
+    "}";
+  var FRAGMENT_SHADER =
+    "precision mediump float;" +
+    "uniform vec3 color;" +
+    "varying vec3 vNormal;" +
+    "void main() {" +
+    "  vec3 light = normalize(vec3(0.4, 0.6, 1.0));" +
+    "  float diffuse = abs(dot(normalize(vNormal), light));" +
+    "  gl_FragColor = vec4(color * (0.35 + 0.65 * diffuse), 1.0);" +
+    "}";
+
+  function parseBinary(view) {
+    var count = view.getUint32(80, true);
+    var positions = new Float32Array(count * 9);
+    var normals = new Float32Array(count * 9);
+    for (var i = 0; i < count; i++) {
+      var offset = 84 + i * 50;
+      for (var v = 0; v < 3; v++) {
+        for (var c = 0; c < 3; c++) {
+          positions[i * 9 + v * 3 + c] = view.getFloat32(offset + 12 + v * 12 + c * 4, true);
+        }
+      }
+    }
+    computeNormals(positions, normals);
+    return { positions: positions, normals: normals };
+  }
+
+  function parseASCII(text) {
+    var re = /vertex\s+(\S+)\s+(\S+)\s+(\S+)/g;
+    var values = [];
+    var m;
+    while ((m = re.exec(text))) {
+      values.push(parseFloat(m[1]), parseFloat(m[2]), parseFloat(m[3]));
+    }
+    var positions = new Float32Array(values.length - (values.length % 9));
+    positions.set(values.slice(0, positions.length));
+    var normals = new Float32Array(positions.length);
+    computeNormals(positions, normals);
+    return { positions: positions, normals: normals };
+  }
+
+  // computeNormals sets flat face normals, the ones stored in STL files are
+  // often missing or wrong.
+  function computeNormals(p, n) {
+    for (var i = 0; i < p.length; i += 9) {
+      var ux = p[i + 3] - p[i], uy = p[i + 4] - p[i + 1], uz = p[i + 5] - p[i + 2];
+      var vx = p[i + 6] - p[i], vy = p[i + 7] - p[i + 1], vz = p[i + 8] - p[i + 2];
+      var nx = uy * vz - uz * vy, ny = uz * vx - ux * vz, nz = ux * vy - uy * vx;
+      var len = Math.sqrt(nx * nx + ny * ny + nz * nz) || 1;
+      for (var v = 0; v < 3; v++) {
+        n[i + v * 3] = nx / len;
+        n[i + v * 3 + 1] = ny / len;
+        n[i + v * 3 + 2] = nz / len;
+      }
+    }
+  }
+
+  function parse(buffer) {
+    var view = new DataView(buffer);
+    if (buffer.byteLength >= 84 && 84 + view.getUint32(80, true) * 50 === buffer.byteLength) {
+      return parseBinary(view);
+    }
+    return parseASCII(new TextDecoder().decode(buffer));
+  }
+
+  // normalize centers the model at the origin and scales it to a unit sphere.
+  function normalize(positions) {
+    var min = [Infinity, Infinity, Infinity];
+    var max = [-Infinity, -Infinity, -Infinity];
+    for (var i = 0; i < positions.length; i++) {
+      min[i % 3] = Math.min(min[i % 3], positions[i]);
+      max[i % 3] = Math.max(max[i % 3], positions[i]);
+    }
+    var center = [(min[0] + max[0]) / 2, (min[1] + max[1]) / 2, (min[2] + max[2]) / 2];
+    var radius = Math.sqrt(
+      Math.pow(max[0] - min[0], 2) + Math.pow(max[1] - min[1], 2) + Math.pow(max[2] - min[2], 2)
+    ) / 2 || 1;
+    for (var j = 0; j < positions.length; j++) {
+      positions[j] = (positions[j] - center[j % 3]) / radius;
+    }
+  }
+
+  function multiply(a, b) {
+    var out = new Float32Array(16);
+    for (var i = 0; i < 4; i++) {
+      for (var j = 0; j < 4; j++) {
+        var sum = 0;
+        for (var k = 0; k < 4; k++) {
+          sum += a[k * 4 + j] * b[i * 4 + k];
+        }
+        out[i * 4 + j] = sum;
+      }
+    }
+    return out;
+  }
+
+  function rotateX(a) {
+    var c = Math.cos(a), s = Math.sin(a);
+    return new Float32Array([1, 0, 0, 0, 0, c, s, 0, 0, -s, c, 0, 0, 0, 0, 1]);
+  }
+
+  function rotateY(a) {
+    var c = Math.cos(a), s = Math.sin(a);
+    return new Float32Array([c, 0, -s, 0, 0, 1, 0, 0, s, 0, c, 0, 0, 0, 0, 1]);
+  }
+
+  function translate(z) {
+    return new Float32Array([1, 0, 0, 0, 0, 1, 0, 0, 0, 0, 1, 0, 0, 0, z, 1]);
+  }
+
+  function perspective(fov, aspect, near, far) {
+    var f = 1 / Math.tan(fov / 2);
+    var nf = 1 / (near - far);
+    return new Float32Array([f / aspect, 0, 0, 0, 0, f, 0, 0, 0, 0, (far + near) * nf, -1, 0, 0, 2 * far * near * nf, 0]);
+  }
+
+  function shader(gl, type, source) {
+    var s = gl.createShader(type);
+    gl.shaderSource(s, source);
+    gl.compileShader(s);
+    if (!gl.getShaderParameter(s, gl.COMPILE_STATUS)) {
+      throw new Error(gl.getShaderInfoLog(s));
+    }
+    return s;
+  }
+
+  function isDark() {
+    var mode = document.documentElement.dataset.colorMode || document.documentElement.dataset.defaultTheme || "auto";
+    if (mode === "auto") {
+      return window.matchMedia && window.matchMedia("(prefers-color-scheme: dark)").matches;
+    }
+    return mode.indexOf("dark") === 0;
+  }
+
+  function buffer(gl, program, name, data) {
+    var b = gl.createBuffer();
+    gl.bindBuffer(gl.ARRAY_BUFFER, b);
+    gl.bufferData(gl.ARRAY_BUFFER, data, gl.STATIC_DRAW);
+    var location = gl.getAttribLocation(program, name);
+    gl.enableVertexAttribArray(location);
+    gl.vertexAttribPointer(location, 3, gl.FLOAT, false, 0, 0);
+  }
+
+  function view(el, mesh) {
+    var canvas = document.createElement("canvas");
+    el.appendChild(canvas);
+    var gl = canvas.getContext("webgl");
+    if (!gl) {
+      throw new Error("WebGL is not available");
+    }
+
+    var program = gl.createProgram();
+    gl.attachShader(program, shader(gl, gl.VERTEX_SHADER, VERTEX_SHADER));
+    gl.attachShader(program, shader(gl, gl.FRAGMENT_SHADER, FRAGMENT_SHADER));
+    gl.linkProgram(program);
+    gl.useProgram(program);
+
+    normalize(mesh.positions);
+    buffer(gl, program, "position", mesh.positions);
+    buffer(gl, program, "normal", mesh.normals);
+    var count = mesh.positions.length / 3;
+
+    var yaw = -0.6;
+    var pitch = -0.5;
+    var distance = 3;
+
+    function draw() {
+      var ratio = window.devicePixelRatio || 1;
+      canvas.width = el.clientWidth * ratio;
+      canvas.height = el.clientHeight * ratio;
+      gl.viewport(0, 0, canvas.width, canvas.height);
+      gl.enable(gl.DEPTH_TEST);
+      gl.clearColor(0, 0, 0, 0);
+      gl.clear(gl.COLOR_BUFFER_BIT | gl.DEPTH_BUFFER_BIT);
+
+      var model = multiply(translate(-distance), multiply(rotateX(pitch), rotateY(yaw)));
+      gl.uniformMatrix4fv(gl.getUniformLocation(program, "model"), false, model);
+      gl.uniformMatrix4fv(
+        gl.getUniformLocation(program, "projection"),
+        false,
+        perspective(Math.PI / 4, canvas.width / canvas.height, 0.1, 100)
+      );
+      // accent colors of the GitHub light and dark color modes
+      var color = isDark() ? [0.27, 0.58, 0.97] : [0.04, 0.41, 0.85];
+      gl.uniform3fv(gl.getUniformLocation(program, "color"), color);
+      gl.drawArrays(gl.TRIANGLES, 0, count);
+    }
+
+    var drag = null;
+    el.addEventListener("pointerdown", function (e) {
+      drag = { x: e.clientX, y: e.clientY, yaw: yaw, pitch: pitch };
+      el.setPointerCapture(e.pointerId);
+    });
+    el.addEventListener("pointermove", function (e) {
+      if (!drag) {
+        return;
+      }
+      yaw = drag.yaw + (e.clientX - drag.x) / 100;
+      pitch = Math.max(-Math.PI / 2, Math.min(Math.PI / 2, drag.pitch + (e.clientY - drag.y) / 100));
+      draw();
+    });
+    el.addEventListener("pointerup", function () {
+      drag = null;
+    });
+    el.addEventListener("wheel", function (e) {
+      e.preventDefault();
+      distance = Math.max(1.2, Math.min(20, distance * (e.deltaY < 0 ? 0.9 : 1.1)));
+      draw();
+    });
+
+    // redraw when the color mode or window size changes
+    new MutationObserver(draw).observe(document.documentElement, { attributes: true, attributeFilter: ["data-color-mode"] });
+    if (window.matchMedia) {
+      window.matchMedia("(prefers-color-scheme: dark)").addEventListener("change", draw);
+    }
+    window.addEventListener("resize", draw);
+
+    draw();
+  }
+
+  function fail(el, err) {
+    el.textContent = "Failed to render STL file: " + err.message;
+    el.classList.add("grip-stl-error");
+  }
+
+  function renderAll() {
+    document.querySelectorAll(".grip-stl:not([data-rendered])").forEach(function (el) {
+      el.dataset.rendered = "true";
+      fetch(el.dataset.src)
+        .then(function (res) {
+          if (!res.ok) {
+            throw new Error(res.status + " " + res.statusText);
+          }
+          return res.arrayBuffer();
+        })
+        .then(function (data) {
+          view(el, parse(data));
+        })
+        .catch(function (err) {
+          fail(el, err);
+        });
+    });
+  }
+
+  if (document.readyState === "loading") {
+    document.addEventListener("DOMContentLoaded", renderAll);
+  } else {
+    renderAll();
+  }
+})();
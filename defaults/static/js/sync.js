@@ -0,0 +1,65 @@
+// Scrolls the preview along with an editor. Editor plugins send their cursor
+// line over /sync_ws, this page scrolls to the rendered block of that line,
+// interpolating between the blocks annotated with data-source-line.
+(function () {
+  var delay = 1000;
+  var base = document.documentElement.dataset.basePath || "";
+
+  function currentPath() {
+    var path = decodeURIComponent(location.pathname);
+    // the page may be opened without the base path the proxy strips
+    return base && path.indexOf(base + "/") === 0 ? path.slice(base.length) : path;
+  }
+
+  function scrollToLine(line) {
+    var blocks = document.querySelectorAll("[data-source-line]");
+    var prev, next;
+    for (var i = 0; i < blocks.length; i++) {
+      if (Number(blocks[i].dataset.sourceLine) <= line) {
+        prev = blocks[i];
+      } else {
+        next = blocks[i];
+        break;
+      }
+    }
+    if (!prev) {
+      window.scrollTo(0, 0);
+      return;
+    }
+
+    var prevLine = Number(prev.dataset.sourceLine);
+    var top = prev.getBoundingClientRect().top + window.scrollY;
+    if (next) {
+      var nextTop = next.getBoundingClientRect().top + window.scrollY;
+      var nextLine = Number(next.dataset.sourceLine);
+      top += ((line - prevLine) / (nextLine - prevLine)) * (nextTop - top);
+    }
+    window.scrollTo(0, Math.max(0, top - 16));
+  }
+
+  function connect() {
+    var protocol = location.protocol === "https:" ? "wss://" : "ws://";
+    var ws = new WebSocket(protocol + location.host + base + "/sync_ws");
+
+    ws.onopen = function () {
+      delay = 1000;
+    };
+    ws.onmessage = function (msg) {
+      var data;
+      try {
+        data = JSON.parse(msg.data);
+      } catch (e) {
+        return;
+      }
+      if (data.path === currentPath() && data.line > 0) {
+        scrollToLine(data.line);
+      }
+    };
+    ws.onclose = function () {
+      setTimeout(connect, delay);
+      delay = Math.min(delay * 2, 10000);
+    };
+  }
+
+  connect();
+})();
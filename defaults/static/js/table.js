@@ -0,0 +1,66 @@
+// Makes CSV and TSV tables searchable and sortable by clicking a header.
+(function () {
+  function cellValue(row, index) {
+    var cell = row.cells[index];
+    return cell ? cell.textContent.trim() : "";
+  }
+
+  function compare(a, b) {
+    var x = parseFloat(a);
+    var y = parseFloat(b);
+    if (!isNaN(x) && !isNaN(y) && isFinite(a) && isFinite(b)) {
+      return x - y;
+    }
+    return a.localeCompare(b, undefined, { numeric: true, sensitivity: "base" });
+  }
+
+  function sortable(table) {
+    var headers = table.tHead.rows[0].cells;
+    Array.prototype.forEach.call(headers, function (th, index) {
+      th.tabIndex = 0;
+      th.style.cursor = "pointer";
+      function sort() {
+        var ascending = th.getAttribute("aria-sort") !== "ascending";
+        Array.prototype.forEach.call(headers, function (other) {
+          other.removeAttribute("aria-sort");
+        });
+        th.setAttribute("aria-sort", ascending ? "ascending" : "descending");
+
+        var body = table.tBodies[0];
+        var rows = Array.prototype.slice.call(body.rows);
+        rows.sort(function (a, b) {
+          var result = compare(cellValue(a, index), cellValue(b, index));
+          return ascending ? result : -result;
+        });
+        rows.forEach(function (row) {
+          body.appendChild(row);
+        });
+      }
+      th.addEventListener("click", sort);
+      th.addEventListener("keydown", function (e) {
+        if (e.key === "Enter" || e.key === " ") {
+          e.preventDefault();
+          sort();
+        }
+      });
+    });
+  }
+
+  function searchable(input, table) {
+    input.addEventListener("input", function () {
+      var query = input.value.toLowerCase();
+      Array.prototype.forEach.call(table.tBodies[0].rows, function (row) {
+        row.hidden = query !== "" && row.textContent.toLowerCase().indexOf(query) < 0;
+      });
+    });
+  }
+
+  document.querySelectorAll(".grip-csv").forEach(function (el) {
+    var table = el.querySelector("table");
+    var input = el.querySelector(".grip-csv-search");
+    sortable(table);
+    if (input) {
+      searchable(input, table);
+    }
+  });
+})();
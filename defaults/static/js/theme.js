@@ -0,0 +1,70 @@
+// Applies the selected color mode to the page and syntax highlighting
+// stylesheets. The choice is stored in localStorage, so it persists across
+// pages and reloads. Elements with a data-theme attribute are the stylesheets
+// of one color mode, data-scheme is the light or dark scheme it belongs to.
+(function () {
+  var storageKey = "go-grip-theme";
+  var modes = ["auto", "light", "dark", "dark_dimmed", "dark_high_contrast", "light_high_contrast"];
+  var labels = {
+    auto: gripMessage("Theme: auto"),
+    light: gripMessage("Theme: light"),
+    dark: gripMessage("Theme: dark"),
+    dark_dimmed: gripMessage("Theme: dark dimmed"),
+    dark_high_contrast: gripMessage("Theme: dark high contrast"),
+    light_high_contrast: gripMessage("Theme: light high contrast"),
+  };
+  var root = document.documentElement;
+
+  function current() {
+    // a ?theme= query parameter forces the theme of the page
+    var mode = new URLSearchParams(location.search).get("theme") || localStorage.getItem(storageKey) || root.dataset.defaultTheme;
+    return modes.indexOf(mode) >= 0 ? mode : "auto";
+  }
+
+  // In auto mode only the base light and dark stylesheets follow the system
+  // preference; the dimmed and high contrast modes must be picked explicitly.
+  function media(el, mode) {
+    var name = el.dataset.theme;
+    var scheme = el.dataset.scheme || name;
+    if (mode === "auto") {
+      return name === scheme ? "(prefers-color-scheme: " + scheme + ")" : "not all";
+    }
+    return name === mode ? "all" : "not all";
+  }
+
+  function apply(mode) {
+    document.querySelectorAll("[data-theme]").forEach(function (el) {
+      el.media = media(el, mode);
+    });
+    root.dataset.colorMode = mode;
+    var button = document.getElementById("grip-theme-toggle");
+    if (button) {
+      button.textContent = labels[mode];
+    }
+  }
+
+  apply(current());
+
+  document.addEventListener("DOMContentLoaded", function () {
+    var toolbar = document.querySelector(".grip-toolbar");
+    if (!toolbar) {
+      return;
+    }
+    var button = document.createElement("button");
+    button.id = "grip-theme-toggle";
+    button.className = "grip-button";
+    button.type = "button";
+    button.title = gripMessage("Switch color mode");
+    button.addEventListener("click", function () {
+      var next = modes[(modes.indexOf(current()) + 1) % modes.length];
+      localStorage.setItem(storageKey, next);
+      apply(next);
+      // mermaid diagrams pick their theme when rendered
+      if (document.querySelector(".mermaid")) {
+        location.reload();
+      }
+    });
+    toolbar.appendChild(button);
+    apply(current());
+  });
+})();
@@ -0,0 +1,102 @@
+package pkg
+
+import (
+	"bytes"
+	"html"
+	"regexp"
+	"sort"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// WithAbbreviations enables Python-Markdown and Kramdown style abbreviations:
+// a definition like *[HTML]: HyperText Markup Language turns every HTML in the
+// document into an <abbr> with the definition as tooltip. GitHub doesn't
+// support them.
+func WithAbbreviations(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.abbreviations = enabled
+	}
+}
+
+var abbreviationRegex = regexp.MustCompile(`^\*\[([^\]]+)\]:[ \t]*(.*?)\s*$`)
+
+// extractAbbreviations removes the abbreviation definitions from md and
+// returns them by abbreviation. The definitions are replaced by empty lines,
+// so the lines of the other blocks stay the same. Definitions in fenced code
+// blocks are left alone.
+func extractAbbreviations(md []byte) ([]byte, map[string]string) {
+	var buf bytes.Buffer
+	abbrs := make(map[string]string)
+	var fence string
+	for _, line := range strings.SplitAfter(string(md), "\n") {
+		trimmed := strings.TrimSpace(line)
+		marker, _, isFence := fenceOpening([]byte(trimmed))
+		switch {
+		case fence != "":
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+			}
+		case isFence:
+			fence = marker
+		default:
+			if m := abbreviationRegex.FindStringSubmatch(line); m != nil {
+				abbrs[m[1]] = m[2]
+				if strings.HasSuffix(line, "\n") {
+					buf.WriteString("\n")
+				}
+				continue
+			}
+		}
+		buf.WriteString(line)
+	}
+	if len(abbrs) == 0 {
+		return md, nil
+	}
+	return buf.Bytes(), abbrs
+}
+
+// addAbbreviations wraps the abbreviations in the text of doc in <abbr>
+// elements. Code and links are left alone.
+func addAbbreviations(doc ast.Node, abbrs map[string]string) {
+	if len(abbrs) == 0 {
+		return
+	}
+	keys := make([]string, 0, len(abbrs))
+	for k := range abbrs {
+		keys = append(keys, regexp.QuoteMeta(k))
+	}
+	// prefer the longest abbreviation starting at the same position
+	sort.Slice(keys, func(i, j int) bool { return len(keys[i]) > len(keys[j]) })
+	re := regexp.MustCompile(strings.Join(keys, "|"))
+
+	for _, t := range linkableTexts(doc) {
+		var nodes []ast.Node
+		start := 0
+		for _, m := range re.FindAllIndex(t.Literal, -1) {
+			if !wordBoundary(t.Literal, m[0]-1) || !wordBoundary(t.Literal, m[1]) {
+				continue
+			}
+			title := abbrs[string(t.Literal[m[0]:m[1]])]
+			nodes = append(nodes,
+				&ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:m[0]]}},
+				&ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(`<abbr title="` + html.EscapeString(title) + `">`)}},
+				&ast.Text{Leaf: ast.Leaf{Literal: t.Literal[m[0]:m[1]]}},
+				&ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte("</abbr>")}},
+			)
+			start = m[1]
+		}
+		if nodes == nil {
+			continue
+		}
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:]}})
+		replaceNode(t, nodes)
+	}
+}
+
+// wordBoundary reports whether the byte at i, which may be out of range,
+// does not continue a word.
+func wordBoundary(text []byte, i int) bool {
+	return i < 0 || i >= len(text) || !isAlnum(text[i]) && text[i] != '_'
+}
@@ -0,0 +1,139 @@
+package pkg
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"regexp"
+	"strings"
+	"unicode"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// addHeadingIDs gives every heading without an explicit {#id} the anchor
+// GitHub would generate for it, numbering duplicates like GitHub does.
+func addHeadingIDs(doc ast.Node) {
+	seen := make(map[string]int)
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		if heading.HeadingID == "" {
+			id := headingID(nodeText(heading))
+			if n := seen[id]; n > 0 {
+				heading.HeadingID = fmt.Sprintf("%s-%d", id, n)
+			} else {
+				heading.HeadingID = id
+			}
+		}
+		seen[heading.HeadingID]++
+		return ast.SkipChildren
+	})
+}
+
+// headingID turns heading text into an anchor the way GitHub does: lower
+// case, punctuation dropped and spaces replaced by hyphens.
+func headingID(text string) string {
+	var sb strings.Builder
+	for _, r := range strings.ToLower(strings.TrimSpace(text)) {
+		switch {
+		case r == ' ':
+			sb.WriteRune('-')
+		case r == '-' || r == '_' || unicode.IsLetter(r) || unicode.IsNumber(r) || unicode.IsMark(r):
+			sb.WriteRune(r)
+		}
+	}
+	return sb.String()
+}
+
+// nodeText returns the plain text of a node and its children.
+func nodeText(node ast.Node) string {
+	var sb strings.Builder
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := n.(type) {
+		case *ast.Text:
+			sb.Write(n.Literal)
+		case *ast.Code:
+			sb.Write(n.Literal)
+		}
+		return ast.GoToNext
+	})
+	return sb.String()
+}
+
+// htmlAnchorRegex matches anchors defined in raw HTML, e.g. <a name="top">.
+var htmlAnchorRegex = regexp.MustCompile(`\b(?:id|name)\s*=\s*["']([^"']+)["']`)
+
+// documentAnchors returns the anchors a parsed document can be linked to.
+func documentAnchors(doc ast.Node) map[string]bool {
+	anchors := make(map[string]bool)
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Heading:
+			anchors[n.HeadingID] = true
+		case *ast.HTMLBlock:
+			for _, m := range htmlAnchorRegex.FindAllSubmatch(n.Literal, -1) {
+				anchors[string(m[1])] = true
+			}
+		case *ast.HTMLSpan:
+			for _, m := range htmlAnchorRegex.FindAllSubmatch(n.Literal, -1) {
+				anchors[string(m[1])] = true
+			}
+		}
+		return ast.GoToNext
+	})
+	return anchors
+}
+
+// WithAnchor opens the start page scrolled to anchor, the anchor of a
+// heading like installation or the text of the heading.
+func WithAnchor(anchor string) Option {
+	return func(s *Server) {
+		s.anchor = strings.TrimPrefix(anchor, "#")
+	}
+}
+
+// resolveAnchor returns the anchor of the markdown page name in dir that
+// anchor refers to, warning if the page has none.
+func (s *Server) resolveAnchor(dir http.FileSystem, name string, anchor string) string {
+	if !s.IsMarkdown(name) {
+		return anchor
+	}
+	content, err := readFile(dir, name)
+	if err != nil {
+		return anchor
+	}
+	content, _, _ = s.embed(dir, name, content)
+	anchors := documentAnchors(s.parser.parse(content))
+	if anchors[anchor] {
+		return anchor
+	}
+	id := headingID(anchor)
+	if anchors[id] {
+		return id
+	}
+	// headings starting with an emoji shortcode, like ## :rocket: Usage
+	var match string
+	for a := range anchors {
+		if strings.HasSuffix(a, "-"+id) {
+			if match != "" {
+				match = ""
+				break
+			}
+			match = a
+		}
+	}
+	if match != "" {
+		return match
+	}
+	slog.Warn("anchor not found in page", "anchor", anchor, "page", name)
+	return anchor
+}
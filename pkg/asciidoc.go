@@ -0,0 +1,216 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"html"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strconv"
+	"strings"
+
+	"github.com/bytesparadise/libasciidoc"
+	"github.com/bytesparadise/libasciidoc/pkg/configuration"
+	"github.com/bytesparadise/libasciidoc/pkg/types"
+	log "github.com/sirupsen/logrus"
+)
+
+func init() {
+	// libasciidoc logs timing information of every conversion
+	log.SetLevel(log.WarnLevel)
+}
+
+// maxAsciidocIncludeDepth is how deep includes nest, like the default
+// max-include-depth of Asciidoctor.
+const maxAsciidocIncludeDepth = 64
+
+// asciidocInclude matches include directives, which start at the beginning
+// of a line.
+var asciidocInclude = regexp.MustCompile(`^include::([^\[\s][^\[]*)\[(.*)\][ \t]*$`)
+
+// asciidocIncludeAttr matches the attributes of an include directive.
+var asciidocIncludeAttr = regexp.MustCompile(`(\w+)=("[^"]*"|[^,]*)`)
+
+// asciidocTag matches the tag::name[] and end::name[] lines marking regions
+// of included files.
+var asciidocTag = regexp.MustCompile(`\b(tag|end)::([\w-]+)\[\]`)
+
+// asciidocRenderer returns the renderer of AsciiDoc files, which read the
+// files of include directives from fsys.
+func asciidocRenderer(fsys http.FileSystem) Renderer {
+	return func(content []byte, name string) ([]byte, string, error) {
+		return asciidocToHTML(fsys, content, name)
+	}
+}
+
+// asciidocToHTML converts AsciiDoc source to HTML and returns it with the
+// document title, highlighting source blocks with the page's chroma styles.
+// Includes are expanded from fsys before the conversion, since libasciidoc
+// reads them from anywhere on disk, changing the working directory of the
+// process to do so.
+func asciidocToHTML(fsys http.FileSystem, content []byte, name string) ([]byte, string, error) {
+	content = expandAsciidocIncludes(fsys, []string{path.Clean("/" + name)}, content)
+	config := configuration.NewConfiguration(
+		configuration.WithFilename(name),
+		configuration.WithHeaderFooter(false),
+		configuration.WithAttributes(map[string]interface{}{
+			types.AttrSyntaxHighlighter: "chroma",
+			types.AttrChromaClassPrefix: "",
+		}),
+	)
+
+	var buf bytes.Buffer
+	metadata, err := libasciidoc.Convert(bytes.NewReader(content), &buf, config)
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to convert asciidoc: %v", err)
+	}
+	if metadata.Title == "" {
+		return buf.Bytes(), "", nil
+	}
+	// the document title is part of the header, which is not rendered
+	out := append([]byte("<h1>"+html.EscapeString(metadata.Title)+"</h1>\n"), buf.Bytes()...)
+	return out, metadata.Title, nil
+}
+
+// expandAsciidocIncludes replaces the include directives of content, the
+// file on top of stack, with the files they include, resolved relative to
+// it in fsys. Directives that fail are replaced with a warning like
+// Asciidoctor shows, so none are left for libasciidoc.
+func expandAsciidocIncludes(fsys http.FileSystem, stack []string, content []byte) []byte {
+	var buf bytes.Buffer
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		m := asciidocInclude.FindStringSubmatch(strings.TrimRight(line, "\r\n"))
+		if m == nil {
+			buf.WriteString(line)
+			continue
+		}
+		attrs := make(map[string]string)
+		for _, a := range asciidocIncludeAttr.FindAllStringSubmatch(m[2], -1) {
+			attrs[a[1]] = strings.Trim(a[2], `"`)
+		}
+		included, err := readAsciidocInclude(fsys, stack, m[1], attrs)
+		if err != nil {
+			if !strings.Contains(attrs["opts"], "optional") || !errors.Is(err, errIncludeNotFound) {
+				fmt.Fprintf(&buf, "Unresolved directive in %s - include::%s[%s]\n", path.Base(stack[0]), m[1], m[2])
+			}
+			continue
+		}
+		buf.Write(included)
+		if len(included) > 0 && included[len(included)-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+var errIncludeNotFound = errors.New("file not found")
+
+// readAsciidocInclude returns the lines of the file target included by the
+// file on top of stack, selected by the lines and tags attributes, with the
+// includes of AsciiDoc files expanded and their sections shifted by
+// leveloffset.
+func readAsciidocInclude(fsys http.FileSystem, stack []string, target string, attrs map[string]string) ([]byte, error) {
+	if fsys == nil {
+		return nil, errIncludeNotFound
+	}
+	name := resolveEmbed(stack, target)
+	if slices.Contains(stack, name) || len(stack) > maxAsciidocIncludeDepth {
+		return nil, errors.New("include cycle")
+	}
+	content, err := readFile(fsys, name)
+	if err != nil {
+		return nil, errIncludeNotFound
+	}
+
+	lines := strings.SplitAfter(string(content), "\n")
+	if ranges := attrs["lines"]; ranges != "" {
+		lines = selectLines(lines, ranges)
+	} else if tags := attrs["tags"] + attrs["tag"]; tags != "" {
+		lines = selectTags(lines, strings.FieldsFunc(tags, func(r rune) bool { return r == ';' || r == ',' }))
+	}
+	lines = slices.DeleteFunc(lines, func(line string) bool { return asciidocTag.MatchString(line) })
+	included := []byte(strings.Join(lines, ""))
+
+	switch strings.ToLower(path.Ext(name)) {
+	case ".adoc", ".asciidoc", ".asc", ".ad":
+		included = expandAsciidocIncludes(fsys, append(slices.Clip(stack), name), included)
+		if offset, err := strconv.Atoi(strings.TrimPrefix(attrs["leveloffset"], "+")); err == nil {
+			included = shiftSections(included, offset)
+		}
+	}
+	return included, nil
+}
+
+// selectLines returns the lines of ranges like "1..5;10" or "3..-1", which
+// count from 1 with -1 for the last line.
+func selectLines(lines []string, ranges string) []string {
+	var selected []string
+	for _, r := range strings.FieldsFunc(ranges, func(r rune) bool { return r == ';' || r == ',' }) {
+		from, to, found := strings.Cut(strings.TrimSpace(r), "..")
+		start, err := strconv.Atoi(from)
+		if err != nil {
+			continue
+		}
+		end := start
+		if found {
+			if end, err = strconv.Atoi(to); err != nil {
+				continue
+			}
+		}
+		if end < 0 || end > len(lines) {
+			end = len(lines)
+		}
+		for i := max(start, 1); i <= end; i++ {
+			selected = append(selected, lines[i-1])
+		}
+	}
+	return selected
+}
+
+// selectTags returns the lines between the tag::name[] and end::name[]
+// lines of the tags.
+func selectTags(lines []string, tags []string) []string {
+	var selected []string
+	open := make(map[string]bool)
+	for _, line := range lines {
+		if m := asciidocTag.FindStringSubmatch(line); m != nil {
+			open[m[2]] = m[1] == "tag"
+			continue
+		}
+		for _, tag := range tags {
+			if open[tag] {
+				selected = append(selected, line)
+				break
+			}
+		}
+	}
+	return selected
+}
+
+// shiftSections changes the level of the section titles of content by
+// offset, leaving delimited blocks alone.
+func shiftSections(content []byte, offset int) []byte {
+	var buf bytes.Buffer
+	delimiter := ""
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimRight(line, "\r\n")
+		switch {
+		case delimiter != "":
+			if trimmed == delimiter {
+				delimiter = ""
+			}
+		case len(trimmed) >= 4 && strings.Trim(trimmed, trimmed[:1]) == "" && strings.Contains("-.+/=_*", trimmed[:1]):
+			delimiter = trimmed
+		case strings.HasPrefix(trimmed, "="):
+			level := len(trimmed) - len(strings.TrimLeft(trimmed, "="))
+			if rest := trimmed[level:]; strings.HasPrefix(rest, " ") {
+				line = strings.Repeat("=", min(max(level+offset, 1), 6)) + rest + line[len(trimmed):]
+			}
+		}
+		buf.WriteString(line)
+	}
+	return buf.Bytes()
+}
@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"io/fs"
+	"net/http"
+	"path"
+	"strings"
+	"sync"
+	"text/template"
+	"time"
+
+	"github.com/chrishrb/go-grip/defaults"
+)
+
+// assetFuncs are the functions of the page templates. asset returns the
+// fingerprinted path of a static file, see assetPath.
+var assetFuncs = template.FuncMap{"asset": assetPath}
+
+// asset is a static file served below a path with the hash of its content.
+type asset struct {
+	name    string
+	content []byte
+}
+
+// assets returns the embedded static files and the syntax stylesheets by
+// their paths like static/js/code.js, and by their fingerprinted paths like
+// static/js/code.1a2b3c4d.js. The fingerprinted paths change with the content,
+// so browsers can cache them forever and still load new versions after
+// upgrades of go-grip.
+var assets = sync.OnceValues(func() (map[string]string, map[string]asset) {
+	paths := make(map[string]string)
+	fingerprinted := make(map[string]asset)
+	add := func(name string, content []byte) {
+		sum := sha256.Sum256(content)
+		ext := path.Ext(name)
+		p := strings.TrimSuffix(name, ext) + "." + hex.EncodeToString(sum[:4]) + ext
+		paths[name] = p
+		fingerprinted[p] = asset{name: name, content: content}
+	}
+	_ = fs.WalkDir(defaults.StaticFiles, "static", func(p string, d fs.DirEntry, err error) error {
+		// emojis are images of documents, which keep their paths
+		if err != nil || d.IsDir() || strings.HasPrefix(p, "static/emojis/") {
+			return err
+		}
+		content, err := defaults.StaticFiles.ReadFile(p)
+		if err != nil {
+			return err
+		}
+		add(p, content)
+		return nil
+	})
+	for name, css := range syntaxStylesheets() {
+		add("static/"+name, css)
+	}
+	return paths, fingerprinted
+})
+
+// assetPath returns the fingerprinted path of the static file at p, like
+// static/css/go-grip.css or /static/css/go-grip.css, or p if it is not one.
+func assetPath(p string) string {
+	paths, _ := assets()
+	if fp, ok := paths[strings.TrimPrefix(p, "/")]; ok {
+		if strings.HasPrefix(p, "/") {
+			return "/" + fp
+		}
+		return fp
+	}
+	return p
+}
+
+// staticHandler serves the static files, the fingerprinted paths with
+// headers letting browsers cache them forever, and the plain paths of
+// custom templates with a validator.
+func staticHandler() http.Handler {
+	files := http.FileServer(http.FS(defaults.StaticFiles))
+	syntax := http.NewServeMux()
+	serveSyntaxStylesheets(syntax)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, fingerprinted := assets()
+		a, ok := fingerprinted[strings.TrimPrefix(r.URL.Path, "/")]
+		if !ok {
+			if h, pattern := syntax.Handler(r); pattern != "" {
+				h.ServeHTTP(w, r)
+				return
+			}
+			files.ServeHTTP(w, r)
+			return
+		}
+		w.Header().Set("Cache-Control", "public, max-age=31536000, immutable")
+		http.ServeContent(w, r, a.name, time.Time{}, bytes.NewReader(a.content))
+	})
+}
@@ -0,0 +1,104 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// WithAttributes enables attribute lists like {#id .class key=value}, on the
+// line before a block or at the end of a heading, to target blocks from custom
+// stylesheets. GitHub doesn't support them.
+func WithAttributes(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.attributes = enabled
+	}
+}
+
+// headingAttributes moves the ids of heading attribute lists to the heading
+// IDs, so they are not rendered twice, and the classes and other attributes
+// of {#id .class} after a heading from the heading ID to its attributes.
+func headingAttributes(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+
+		if attr := trailingAttributes(heading); attr != nil {
+			mergeAttributes(heading, attr)
+		}
+		if strings.ContainsAny(heading.HeadingID, " .=") {
+			attr := parseAttributes(heading.HeadingID)
+			heading.HeadingID = string(attr.ID)
+			attr.ID = nil
+			mergeAttributes(heading, attr)
+		}
+		if heading.Attribute != nil && heading.Attribute.ID != nil {
+			heading.HeadingID = string(heading.Attribute.ID)
+			heading.Attribute.ID = nil
+		}
+		return ast.SkipChildren
+	})
+}
+
+// trailingAttributes removes an attribute list without id, like {.class},
+// from the end of a heading and returns it. The parser only recognizes lists
+// starting with an id.
+func trailingAttributes(heading *ast.Heading) *ast.Attribute {
+	children := heading.GetChildren()
+	if len(children) == 0 {
+		return nil
+	}
+	text, ok := children[len(children)-1].(*ast.Text)
+	if !ok {
+		return nil
+	}
+	m := trailingAttributeList.FindSubmatchIndex(text.Literal)
+	if m == nil {
+		return nil
+	}
+	attr := parseAttributes(string(text.Literal[m[2]:m[3]]))
+	text.Literal = text.Literal[:m[0]]
+	return attr
+}
+
+var trailingAttributeList = regexp.MustCompile(`\s*\{(\.[^{}]*)\}\s*$`)
+
+// parseAttributes parses the inside of an attribute list. The heading parser
+// strips the leading # of the first id.
+func parseAttributes(s string) *ast.Attribute {
+	attr := &ast.Attribute{}
+	for i, field := range strings.Fields(s) {
+		switch {
+		case strings.HasPrefix(field, "."):
+			attr.Classes = append(attr.Classes, []byte(field[1:]))
+		case strings.HasPrefix(field, "#"):
+			attr.ID = []byte(field[1:])
+		case strings.Contains(field, "="):
+			key, value, _ := strings.Cut(field, "=")
+			if attr.Attrs == nil {
+				attr.Attrs = make(map[string][]byte)
+			}
+			attr.Attrs[key] = []byte(strings.Trim(value, `"'`))
+		case i == 0:
+			attr.ID = []byte(field)
+		}
+	}
+	return attr
+}
+
+func mergeAttributes(heading *ast.Heading, attr *ast.Attribute) {
+	if heading.Attribute == nil {
+		heading.Attribute = attr
+		return
+	}
+	heading.Attribute.Classes = append(heading.Attribute.Classes, attr.Classes...)
+	for k, v := range attr.Attrs {
+		if heading.Attribute.Attrs == nil {
+			heading.Attribute.Attrs = make(map[string][]byte)
+		}
+		heading.Attribute.Attrs[k] = v
+	}
+}
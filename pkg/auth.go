@@ -0,0 +1,58 @@
+package pkg
+
+import (
+	"crypto/subtle"
+	"net/http"
+	"strings"
+)
+
+const tokenCookie = "go-grip-token"
+
+// authHandler protects next with basic or token authentication if either is
+// configured. A token passed as the token query parameter is stored in a
+// cookie, so links shared with the token keep working for static assets and
+// the reload websocket.
+func (s *Server) authHandler(next http.Handler) http.Handler {
+	if s.authUser == "" && s.authToken == "" {
+		return next
+	}
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.authToken != "" {
+			if token := r.URL.Query().Get("token"); secureEqual(token, s.authToken) {
+				http.SetCookie(w, &http.Cookie{
+					Name:     tokenCookie,
+					Value:    token,
+					Path:     "/",
+					HttpOnly: true,
+					SameSite: http.SameSiteStrictMode,
+				})
+				next.ServeHTTP(w, r)
+				return
+			}
+			if c, err := r.Cookie(tokenCookie); err == nil && secureEqual(c.Value, s.authToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			if token, ok := strings.CutPrefix(r.Header.Get("Authorization"), "Bearer "); ok && secureEqual(token, s.authToken) {
+				next.ServeHTTP(w, r)
+				return
+			}
+		}
+
+		if s.authUser != "" {
+			user, pass, ok := r.BasicAuth()
+			if ok && secureEqual(user, s.authUser) && secureEqual(pass, s.authPass) {
+				next.ServeHTTP(w, r)
+				return
+			}
+			w.Header().Set("WWW-Authenticate", `Basic realm="go-grip", charset="UTF-8"`)
+		}
+
+		http.Error(w, "Unauthorized", http.StatusUnauthorized)
+	})
+}
+
+func secureEqual(a, b string) bool {
+	return a != "" && subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
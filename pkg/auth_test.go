@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gorilla/websocket"
+)
+
+func TestAuthHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Readme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	s := New(WithRoot(dir), WithBasicAuth("user", "pass"), WithTokenAuth("secret"), WithMetrics(true))
+	defer s.Close()
+	srv := httptest.NewServer(s.Handler())
+	defer srv.Close()
+
+	get := func(path string, header ...string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		for i := 0; i < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		return resp
+	}
+	basic := func(user, pass string) string {
+		req, _ := http.NewRequest(http.MethodGet, "/", nil)
+		req.SetBasicAuth(user, pass)
+		return req.Header.Get("Authorization")
+	}
+
+	for _, tt := range []struct {
+		name   string
+		path   string
+		header []string
+		want   int
+	}{
+		{"no credentials", "/README.md", nil, http.StatusUnauthorized},
+		{"basic auth", "/README.md", []string{"Authorization", basic("user", "pass")}, http.StatusOK},
+		{"wrong password", "/README.md", []string{"Authorization", basic("user", "wrong")}, http.StatusUnauthorized},
+		{"wrong user", "/README.md", []string{"Authorization", basic("other", "pass")}, http.StatusUnauthorized},
+		{"bearer token", "/README.md", []string{"Authorization", "Bearer secret"}, http.StatusOK},
+		{"wrong bearer token", "/README.md", []string{"Authorization", "Bearer wrong"}, http.StatusUnauthorized},
+		{"empty bearer token", "/README.md", []string{"Authorization", "Bearer "}, http.StatusUnauthorized},
+		{"wrong token parameter", "/README.md?token=wrong", nil, http.StatusUnauthorized},
+		{"wrong cookie", "/README.md", []string{"Cookie", tokenCookie + "=wrong"}, http.StatusUnauthorized},
+		{"reload events", reloadEventsEndpoint, nil, http.StatusUnauthorized},
+		{"health check", "/healthz", nil, http.StatusOK},
+		{"metrics", "/metrics", nil, http.StatusUnauthorized},
+	} {
+		if resp := get(tt.path, tt.header...); resp.StatusCode != tt.want {
+			t.Errorf("%s: got %d, want %d", tt.name, resp.StatusCode, tt.want)
+		}
+	}
+	if resp := get("/README.md"); !strings.Contains(resp.Header.Get("WWW-Authenticate"), "Basic") {
+		t.Errorf("expected a basic auth challenge, got %q", resp.Header.Get("WWW-Authenticate"))
+	}
+
+	// the token parameter sets a cookie authenticating later requests
+	resp := get("/README.md?token=secret")
+	if resp.StatusCode != http.StatusOK {
+		t.Fatalf("token parameter: got %d", resp.StatusCode)
+	}
+	var cookie *http.Cookie
+	for _, c := range resp.Cookies() {
+		if c.Name == tokenCookie {
+			cookie = c
+		}
+	}
+	if cookie == nil || !cookie.HttpOnly || cookie.Value != "secret" || cookie.Path != "/" {
+		t.Fatalf("expected an HttpOnly token cookie, got %+v", cookie)
+	}
+	if resp := get("/README.md", "Cookie", cookie.String()); resp.StatusCode != http.StatusOK {
+		t.Errorf("cookie: got %d", resp.StatusCode)
+	}
+
+	// the reload websocket is rejected before the upgrade
+	wsURL := "ws" + strings.TrimPrefix(srv.URL, "http") + reloadEndpoint
+	conn, resp, err := websocket.DefaultDialer.Dial(wsURL, nil)
+	if err == nil {
+		conn.Close()
+		t.Fatal("expected the reload websocket to require authentication")
+	}
+	if resp == nil || resp.StatusCode != http.StatusUnauthorized {
+		t.Errorf("expected 401 for the reload websocket, got %v", resp)
+	}
+	conn, _, err = websocket.DefaultDialer.Dial(wsURL, http.Header{"Authorization": {"Bearer secret"}})
+	if err != nil {
+		t.Fatalf("expected the reload websocket with the token, got %v", err)
+	}
+	conn.Close()
+}
@@ -0,0 +1,208 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// autolinkLiterals links bare www. addresses and email addresses like GitHub's
+// extended autolinks. URLs with a scheme are already linked by the parser.
+func autolinkLiterals(doc ast.Node) {
+	for _, t := range linkableTexts(doc) {
+		nodes := splitAutolinks(t.Literal)
+		if _, ok := nodes[0].(*ast.Text); ok && len(nodes) == 1 {
+			continue
+		}
+		replaceNode(t, nodes)
+	}
+}
+
+// linkableTexts returns the text nodes of doc outside of code, links and
+// HTML, which may be turned into links.
+func linkableTexts(doc ast.Node) []*ast.Text {
+	var texts []*ast.Text
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.Link, *ast.Image, *ast.CodeBlock, *ast.Code, *ast.HTMLBlock, *ast.HTMLSpan:
+			return ast.SkipChildren
+		case *ast.Text:
+			if entering {
+				texts = append(texts, n)
+			}
+		}
+		return ast.GoToNext
+	})
+	return texts
+}
+
+// replaceNode replaces node with nodes in the children of its parent.
+func replaceNode(node ast.Node, nodes []ast.Node) {
+	parent := node.GetParent()
+	var children []ast.Node
+	for _, c := range parent.GetChildren() {
+		if c != node {
+			children = append(children, c)
+			continue
+		}
+		for _, n := range nodes {
+			n.SetParent(parent)
+			children = append(children, n)
+		}
+	}
+	parent.SetChildren(children)
+}
+
+// splitAutolinks splits text into text and link nodes.
+func splitAutolinks(text []byte) []ast.Node {
+	var nodes []ast.Node
+	start := 0
+	for i := 0; i < len(text); i++ {
+		var end int
+		var dest string
+		switch {
+		case hasPrefixFold(text[i:], "www.") && autolinkBoundary(text, i):
+			end = i + wwwAutolink(text[i:])
+			dest = "http://" + string(text[i:end])
+		case text[i] == '@':
+			var from int
+			from, end = emailAutolink(text, i)
+			if end == 0 {
+				continue
+			}
+			if from < start {
+				continue
+			}
+			dest = "mailto:" + string(text[from:end])
+			i = from
+		default:
+			continue
+		}
+		if end <= i {
+			continue
+		}
+
+		if i > start {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[start:i]}})
+		}
+		link := &ast.Link{Destination: []byte(dest)}
+		ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: text[i:end]}})
+		nodes = append(nodes, link)
+		start = end
+		i = end - 1
+	}
+	if start < len(text) || len(nodes) == 0 {
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: text[start:]}})
+	}
+	return nodes
+}
+
+// autolinkBoundary reports whether an extended autolink may start at i: at
+// the start of the text, after whitespace or after an opening delimiter.
+func autolinkBoundary(text []byte, i int) bool {
+	if i == 0 {
+		return true
+	}
+	return strings.IndexByte(" \t\n*_~(", text[i-1]) >= 0
+}
+
+// wwwAutolink returns the length of the www. autolink at the start of text,
+// or 0 if its domain is not valid.
+func wwwAutolink(text []byte) int {
+	end := bytes.IndexAny(text, " \t\n<")
+	if end < 0 {
+		end = len(text)
+	}
+	domain := end
+	if i := bytes.IndexAny(text[:end], "/?#"); i >= 0 {
+		domain = i
+	}
+	if !validAutolinkDomain(text[:domain]) {
+		return 0
+	}
+	return trimAutolink(text[:end])
+}
+
+// validAutolinkDomain reports whether domain consists of alphanumeric
+// segments with hyphens and underscores, where the last two segments have no
+// underscores.
+func validAutolinkDomain(domain []byte) bool {
+	segments := bytes.Split(bytes.TrimRight(domain, "."), []byte("."))
+	if len(segments) < 2 {
+		return false
+	}
+	for i, s := range segments {
+		if len(s) == 0 {
+			return false
+		}
+		for _, c := range s {
+			if !isAlnum(c) && c != '-' && c != '_' {
+				return false
+			}
+		}
+		if i >= len(segments)-2 && bytes.IndexByte(s, '_') >= 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// trimAutolink returns the length of link without trailing punctuation,
+// unbalanced closing parentheses and a trailing entity reference.
+func trimAutolink(link []byte) int {
+	end := len(link)
+	for end > 0 {
+		c := link[end-1]
+		switch {
+		case strings.IndexByte("?!.,:*_~'\"", c) >= 0:
+			end--
+		case c == ')' && bytes.Count(link[:end], []byte(")")) > bytes.Count(link[:end], []byte("(")):
+			end--
+		case c == ';':
+			i := end - 2
+			for i >= 0 && isAlnum(link[i]) {
+				i--
+			}
+			if i < 0 || link[i] != '&' || i == end-2 {
+				return end
+			}
+			end = i
+		default:
+			return end
+		}
+	}
+	return end
+}
+
+// emailAutolink returns the bounds of the email address around the @ at i,
+// with end 0 if there is none.
+func emailAutolink(text []byte, at int) (int, int) {
+	from := at
+	for from > 0 && (isAlnum(text[from-1]) || strings.IndexByte(".-_+", text[from-1]) >= 0) {
+		from--
+	}
+	end := at + 1
+	for end < len(text) && (isAlnum(text[end]) || strings.IndexByte(".-_", text[end]) >= 0) {
+		end++
+	}
+	for end > at+1 && text[end-1] == '.' {
+		end--
+	}
+	if from == at || end < len(text) && text[end] == '@' {
+		return 0, 0
+	}
+	domain := text[at+1 : end]
+	if bytes.IndexByte(domain, '.') < 0 || strings.IndexByte("-_", domain[len(domain)-1]) >= 0 {
+		return 0, 0
+	}
+	return from, end
+}
+
+func hasPrefixFold(b []byte, prefix string) bool {
+	return len(b) >= len(prefix) && strings.EqualFold(string(b[:len(prefix)]), prefix)
+}
+
+func isAlnum(c byte) bool {
+	return c >= 'a' && c <= 'z' || c >= 'A' && c <= 'Z' || c >= '0' && c <= '9'
+}
@@ -0,0 +1,84 @@
+package pkg
+
+import (
+	"bytes"
+	"net/http"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// WithBasePath serves go-grip below a path prefix like /preview/, for reverse
+// proxies forwarding that path to it. The links, assets, API requests and
+// live reload connections of the pages point below the prefix. Requests
+// work with and without the prefix, whether the proxy strips it or not.
+func WithBasePath(basePath string) Option {
+	return func(s *Server) {
+		s.basePath = strings.TrimSuffix("/"+strings.Trim(basePath, "/"), "/")
+	}
+}
+
+// urlAttributes are the attributes of HTML elements holding URLs.
+var urlAttributes = map[string]bool{
+	"action":   true,
+	"data":     true,
+	"data-src": true,
+	"href":     true,
+	"poster":   true,
+	"src":      true,
+}
+
+// stripBasePath removes the base path from the requests to h, redirecting
+// the base path itself to the index below it.
+func (s *Server) stripBasePath(h http.Handler) http.Handler {
+	if s.basePath == "" {
+		return h
+	}
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == s.basePath {
+			http.Redirect(w, r, s.basePath+"/", http.StatusMovedPermanently)
+			return
+		}
+		if strings.HasPrefix(r.URL.Path, s.basePath+"/") {
+			http.StripPrefix(s.basePath, h).ServeHTTP(w, r)
+			return
+		}
+		h.ServeHTTP(w, r)
+	})
+}
+
+// prefixURLs returns the HTML page with the base path prepended to the
+// absolute paths of its URLs. Protocol relative URLs are left alone.
+func (s *Server) prefixURLs(page []byte) []byte {
+	if s.basePath == "" {
+		return page
+	}
+	var out bytes.Buffer
+	z := xhtml.NewTokenizer(bytes.NewReader(page))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return out.Bytes()
+		}
+		// Token lowercases the tag in place, keep the raw bytes for
+		// elements without URLs
+		raw := append([]byte(nil), z.Raw()...)
+		if tt != xhtml.StartTagToken && tt != xhtml.SelfClosingTagToken {
+			out.Write(raw)
+			continue
+		}
+		tok := z.Token()
+		changed := false
+		for i, a := range tok.Attr {
+			if urlAttributes[a.Key] && strings.HasPrefix(a.Val, "/") && !strings.HasPrefix(a.Val, "//") {
+				tok.Attr[i].Val = s.basePath + a.Val
+				changed = true
+			}
+		}
+		if changed {
+			out.WriteString(tok.String())
+		} else {
+			out.Write(raw)
+		}
+	}
+}
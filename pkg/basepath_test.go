@@ -0,0 +1,34 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestPrefixURLs(t *testing.T) {
+	s := &Server{}
+	WithBasePath("preview/")(s)
+	if s.basePath != "/preview" {
+		t.Fatalf("expected the base path /preview, got %q", s.basePath)
+	}
+
+	got := string(s.prefixURLs([]byte(`<p><a href="/docs/a.md#x">a</a> <a href="b.md">b</a> <A HREF="//cdn.example.com/x">c</A>` +
+		`<img src="/static/emojis/shipit.png" alt="&lt;"/></p><script>var a = "<a href='/x'>";</script>`)))
+	want := `<p><a href="/preview/docs/a.md#x">a</a> <a href="b.md">b</a> <A HREF="//cdn.example.com/x">c</A>` +
+		`<img src="/preview/static/emojis/shipit.png" alt="&lt;"/></p><script>var a = "<a href='/x'>";</script>`
+	if got != want {
+		t.Errorf("unexpected page\n got: %s\nwant: %s", got, want)
+	}
+
+	var paths []string
+	h := s.stripBasePath(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		paths = append(paths, r.URL.Path)
+	}))
+	for _, p := range []string{"/preview/docs/a.md", "/docs/a.md", "/preview"} {
+		h.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, p, nil))
+	}
+	if len(paths) != 2 || paths[0] != "/docs/a.md" || paths[1] != "/docs/a.md" {
+		t.Errorf("unexpected paths %v", paths)
+	}
+}
@@ -0,0 +1,387 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+	"unicode"
+
+	"golang.org/x/text/unicode/norm"
+)
+
+// bibliography is the file of WithBibliography, read again when it changes.
+type bibliography struct {
+	file  string
+	style string
+
+	mu      sync.Mutex
+	read    bool
+	modTime time.Time
+	entries map[string]*bibEntry
+}
+
+// bibEntry is a work in a bibliography.
+type bibEntry struct {
+	key       string
+	kind      string
+	authors   []bibName
+	year      string
+	title     string
+	container string
+	publisher string
+	volume    string
+	issue     string
+	pages     string
+	url       string
+	doi       string
+}
+
+// bibName is the name of an author, with only family set for organizations.
+type bibName struct {
+	family string
+	given  string
+}
+
+// lookup returns the entries of the file, read again if it changed since
+// the last call, and its modification time. Errors are logged once and leave
+// all citations unresolved.
+func (b *bibliography) lookup() (map[string]*bibEntry, time.Time) {
+	b.mu.Lock()
+	defer b.mu.Unlock()
+
+	info, err := os.Stat(b.file)
+	if err == nil && b.read && info.ModTime().Equal(b.modTime) {
+		return b.entries, b.modTime
+	}
+	b.read, b.entries = true, nil
+	if err == nil {
+		b.modTime = info.ModTime()
+		b.entries, err = readBibliography(b.file)
+	}
+	if err != nil {
+		slog.Warn("failed to read bibliography", "path", b.file, "err", err)
+	}
+	return b.entries, b.modTime
+}
+
+// readBibliography reads the BibTeX or CSL-JSON file at file, told apart by
+// the extension or else the content.
+func readBibliography(file string) (map[string]*bibEntry, error) {
+	data, err := os.ReadFile(file)
+	if err != nil {
+		return nil, err
+	}
+	switch ext := strings.ToLower(filepath.Ext(file)); {
+	case ext == ".bib" || ext == ".bibtex":
+		return parseBibTeX(data)
+	case ext == ".json" || bytes.HasPrefix(bytes.TrimSpace(data), []byte("[")):
+		return parseCSLJSON(data)
+	}
+	return parseBibTeX(data)
+}
+
+// cslItem is an item of a CSL-JSON bibliography, as exported by Zotero.
+type cslItem struct {
+	ID             cslString `json:"id"`
+	Type           string    `json:"type"`
+	Title          cslString `json:"title"`
+	ContainerTitle cslString `json:"container-title"`
+	Publisher      cslString `json:"publisher"`
+	Volume         cslString `json:"volume"`
+	Issue          cslString `json:"issue"`
+	Page           cslString `json:"page"`
+	URL            cslString `json:"URL"`
+	DOI            cslString `json:"DOI"`
+	Author         []cslName `json:"author"`
+	Editor         []cslName `json:"editor"`
+	Issued         cslDate   `json:"issued"`
+}
+
+type cslName struct {
+	Family  string `json:"family"`
+	Given   string `json:"given"`
+	Literal string `json:"literal"`
+}
+
+type cslDate struct {
+	DateParts [][]cslString `json:"date-parts"`
+	Literal   string        `json:"literal"`
+	Raw       string        `json:"raw"`
+}
+
+// cslString is a CSL-JSON variable, which may be written as number.
+type cslString string
+
+func (s *cslString) UnmarshalJSON(data []byte) error {
+	var v any
+	if err := json.Unmarshal(data, &v); err != nil {
+		return err
+	}
+	if v != nil {
+		*s = cslString(fmt.Sprint(v))
+	}
+	return nil
+}
+
+// year returns the year the work was issued.
+func (d cslDate) year() string {
+	if len(d.DateParts) > 0 && len(d.DateParts[0]) > 0 {
+		return string(d.DateParts[0][0])
+	}
+	for _, s := range []string{d.Literal, d.Raw} {
+		if y := bibYear.FindString(s); y != "" {
+			return y
+		}
+	}
+	return ""
+}
+
+var bibYear = regexp.MustCompile(`\b\d{4}\b`)
+
+func parseCSLJSON(data []byte) (map[string]*bibEntry, error) {
+	var items []cslItem
+	if err := json.Unmarshal(data, &items); err != nil {
+		return nil, fmt.Errorf("failed to parse CSL-JSON: %v", err)
+	}
+	entries := make(map[string]*bibEntry, len(items))
+	for _, item := range items {
+		e := &bibEntry{
+			key:       string(item.ID),
+			kind:      item.Type,
+			year:      item.Issued.year(),
+			title:     string(item.Title),
+			container: string(item.ContainerTitle),
+			publisher: string(item.Publisher),
+			volume:    string(item.Volume),
+			issue:     string(item.Issue),
+			pages:     string(item.Page),
+			url:       string(item.URL),
+			doi:       string(item.DOI),
+		}
+		names := item.Author
+		if len(names) == 0 {
+			names = item.Editor
+		}
+		for _, n := range names {
+			if n.Literal != "" {
+				e.authors = append(e.authors, bibName{family: n.Literal})
+			} else {
+				e.authors = append(e.authors, bibName{family: n.Family, given: n.Given})
+			}
+		}
+		entries[e.key] = e
+	}
+	return entries, nil
+}
+
+// parseBibTeX parses the entries of a BibTeX file. @string, @preamble and
+// @comment entries are skipped, LaTeX commands other than accents and
+// escaped characters are left as they are.
+func parseBibTeX(data []byte) (map[string]*bibEntry, error) {
+	entries := make(map[string]*bibEntry)
+	s := string(data)
+	for {
+		at := strings.IndexByte(s, '@')
+		if at < 0 {
+			return entries, nil
+		}
+		s = s[at+1:]
+		open := strings.IndexAny(s, "{(")
+		if open < 0 {
+			return entries, nil
+		}
+		kind := strings.ToLower(strings.TrimSpace(s[:open]))
+		body, rest, ok := bibBlock(s[open:])
+		if !ok {
+			return nil, fmt.Errorf("failed to parse BibTeX: unclosed @%s entry", kind)
+		}
+		s = rest
+		switch kind {
+		case "string", "preamble", "comment":
+			continue
+		}
+		key, fields, _ := strings.Cut(body, ",")
+		e := &bibEntry{key: strings.TrimSpace(key), kind: kind}
+		for name, value := range bibFields(fields) {
+			switch name {
+			case "author":
+				e.authors = bibNames(value)
+			case "editor":
+				if len(e.authors) == 0 {
+					e.authors = bibNames(value)
+				}
+			case "title":
+				e.title = latexText(value)
+			case "journal", "journaltitle", "booktitle":
+				e.container = latexText(value)
+			case "publisher", "institution", "school", "organization":
+				e.publisher = latexText(value)
+			case "year":
+				e.year = latexText(value)
+			case "date":
+				if e.year == "" {
+					e.year = bibYear.FindString(value)
+				}
+			case "volume":
+				e.volume = latexText(value)
+			case "number", "issue":
+				e.issue = latexText(value)
+			case "pages":
+				e.pages = latexText(value)
+			case "url":
+				e.url = strings.TrimSpace(value)
+			case "doi":
+				e.doi = strings.TrimSpace(value)
+			}
+		}
+		if e.key != "" {
+			entries[e.key] = e
+		}
+	}
+}
+
+// bibBlock returns the content of the braces or parentheses s starts with,
+// and the rest of s after them.
+func bibBlock(s string) (string, string, bool) {
+	closing := byte('}')
+	if s[0] == '(' {
+		closing = ')'
+	}
+	depth := 0
+	for i := 1; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			if depth == 0 && closing == '}' {
+				return s[1:i], s[i+1:], true
+			}
+			depth--
+		case ')':
+			if depth == 0 && closing == ')' {
+				return s[1:i], s[i+1:], true
+			}
+		}
+	}
+	return "", "", false
+}
+
+// bibFields returns the name = value fields of a BibTeX entry by their lower
+// case names, with the braces or quotes around the values removed.
+func bibFields(s string) map[string]string {
+	fields := make(map[string]string)
+	for {
+		eq := strings.IndexByte(s, '=')
+		if eq < 0 {
+			return fields
+		}
+		name := strings.ToLower(strings.TrimSpace(strings.TrimLeft(s[:eq], ", \t\r\n")))
+		s = strings.TrimLeft(s[eq+1:], " \t\r\n")
+		var value string
+		switch {
+		case strings.HasPrefix(s, "{"):
+			var ok bool
+			if value, s, ok = bibBlock(s); !ok {
+				return fields
+			}
+		case strings.HasPrefix(s, `"`):
+			end := 1
+			for depth := 0; end < len(s) && (s[end] != '"' || depth > 0); end++ {
+				switch s[end] {
+				case '{':
+					depth++
+				case '}':
+					depth--
+				}
+			}
+			value, s = s[1:min(end, len(s))], s[min(end+1, len(s)):]
+		default:
+			end := strings.IndexByte(s, ',')
+			if end < 0 {
+				end = len(s)
+			}
+			value, s = strings.TrimSpace(s[:end]), s[end:]
+		}
+		fields[name] = value
+	}
+}
+
+// bibNames splits the names of a BibTeX author field, written as "Family,
+// Given" or "Given Family" and separated by "and". Names in braces are kept
+// whole, like organizations.
+func bibNames(s string) []bibName {
+	var names []bibName
+	for _, name := range splitBibNames(s) {
+		name = strings.TrimSpace(name)
+		switch {
+		case name == "":
+		case strings.HasPrefix(name, "{") && strings.HasSuffix(name, "}"):
+			names = append(names, bibName{family: latexText(name)})
+		case strings.Contains(name, ","):
+			family, given, _ := strings.Cut(name, ",")
+			names = append(names, bibName{family: latexText(family), given: latexText(given)})
+		default:
+			words := strings.Fields(name)
+			names = append(names, bibName{
+				family: latexText(words[len(words)-1]),
+				given:  latexText(strings.Join(words[:len(words)-1], " ")),
+			})
+		}
+	}
+	return names
+}
+
+// splitBibNames splits s at the "and" separating names outside braces.
+func splitBibNames(s string) []string {
+	var names []string
+	depth, start := 0, 0
+	for i := 0; i < len(s); i++ {
+		switch s[i] {
+		case '{':
+			depth++
+		case '}':
+			depth--
+		case ' ', '\t', '\n', '\r':
+			if depth == 0 && len(s) > i+4 && strings.EqualFold(s[i+1:i+4], "and") && unicode.IsSpace(rune(s[i+4])) {
+				names = append(names, s[start:i])
+				start = i + 5
+				i += 4
+			}
+		}
+	}
+	return append(names, s[start:])
+}
+
+// latexAccents are the combining characters of LaTeX accent commands.
+var latexAccents = map[byte]string{
+	'"': "̈", '\'': "́", '`': "̀", '^': "̂", '~': "̃",
+	'=': "̄", '.': "̇", 'c': "̧", 'v': "̌", 'u': "̆",
+}
+
+// latexCommand matches accent commands like \"o, \"{o}, {\"o} and \c{c}, and
+// escaped characters like \&.
+var latexCommand = regexp.MustCompile(`\\([` + "\"'`^~=." + `])\{?(\w)\}?|\\([cvu])(?:\{(\w)\}|\s+(\w))|\\([&%$#_])`)
+
+// latexText converts a BibTeX value to plain text, with accents composed
+// with their letters.
+func latexText(s string) string {
+	s = latexCommand.ReplaceAllStringFunc(s, func(m string) string {
+		sub := latexCommand.FindStringSubmatch(m)
+		switch {
+		case sub[1] != "":
+			return sub[2] + latexAccents[sub[1][0]]
+		case sub[3] != "":
+			return sub[4] + sub[5] + latexAccents[sub[3][0]]
+		}
+		return sub[6]
+	})
+	s = strings.NewReplacer("{", "", "}", "", "---", "—", "--", "–", "~", " ").Replace(s)
+	return norm.NFC.String(strings.Join(strings.Fields(s), " "))
+}
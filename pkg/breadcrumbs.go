@@ -0,0 +1,40 @@
+package pkg
+
+import (
+	"html"
+	"net/url"
+	"strings"
+)
+
+// WithBreadcrumbs shows the path of served pages above them like GitHub,
+// e.g. project / docs / guide / page.md, with links to the directories.
+func WithBreadcrumbs(enabled bool) Option {
+	return func(s *Server) {
+		s.breadcrumbs = enabled
+	}
+}
+
+// breadcrumbs renders the path of the page name below the served directory,
+// which is named root.
+func breadcrumbs(root string, name string) string {
+	segments := strings.Split(strings.Trim(name, "/"), "/")
+	if root == "" || root == "." || root == "/" {
+		root = "root"
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<nav class="grip-breadcrumbs" aria-label="Breadcrumbs">`)
+	sb.WriteString(`<a href="/">` + html.EscapeString(root) + `</a>`)
+	href := "/"
+	for i, seg := range segments {
+		sb.WriteString(`<span class="grip-breadcrumbs-sep">/</span>`)
+		if i == len(segments)-1 {
+			sb.WriteString(`<strong aria-current="page">` + html.EscapeString(seg) + `</strong>`)
+			break
+		}
+		href += url.PathEscape(seg) + "/"
+		sb.WriteString(`<a href="` + html.EscapeString(href) + `">` + html.EscapeString(seg) + `</a>`)
+	}
+	sb.WriteString(`</nav>`)
+	return sb.String()
+}
@@ -0,0 +1,96 @@
+package pkg
+
+import (
+	"container/list"
+	"sync"
+	"time"
+)
+
+// renderCache keeps rendered pages keyed by path and modification time. The
+// least recently used pages are evicted once the total size exceeds maxBytes.
+type renderCache struct {
+	mu       sync.Mutex
+	maxBytes int64
+	size     int64
+	entries  map[string]*list.Element
+	lru      *list.List
+}
+
+type cacheEntry struct {
+	key     string
+	modTime time.Time
+	page    page
+	meta    pageMeta
+}
+
+func newRenderCache(maxBytes int64) *renderCache {
+	return &renderCache{
+		maxBytes: maxBytes,
+		entries:  make(map[string]*list.Element),
+		lru:      list.New(),
+	}
+}
+
+// get returns the cached page and its metadata if it was rendered from a
+// file with the given modification time.
+func (c *renderCache) get(key string, modTime time.Time) (page, pageMeta, bool) {
+	if c == nil {
+		return nil, pageMeta{}, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	el, ok := c.entries[key]
+	if !ok {
+		return nil, pageMeta{}, false
+	}
+	entry := el.Value.(*cacheEntry)
+	if !entry.modTime.Equal(modTime) {
+		return nil, pageMeta{}, false
+	}
+	c.lru.MoveToFront(el)
+	return entry.page, entry.meta, true
+}
+
+// put stores a page with its metadata. Pages larger than the cache are not
+// stored.
+func (c *renderCache) put(key string, modTime time.Time, p page, meta pageMeta) {
+	if c == nil || p.size() > c.maxBytes {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	if el, ok := c.entries[key]; ok {
+		c.remove(el)
+	}
+
+	c.entries[key] = c.lru.PushFront(&cacheEntry{key: key, modTime: modTime, page: p, meta: meta})
+	c.size += p.size()
+
+	for c.size > c.maxBytes {
+		c.remove(c.lru.Back())
+	}
+}
+
+func (c *renderCache) remove(el *list.Element) {
+	entry := c.lru.Remove(el).(*cacheEntry)
+	delete(c.entries, entry.key)
+	c.size -= entry.page.size()
+}
+
+// clear removes all cached pages.
+func (c *renderCache) clear() {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	c.entries = make(map[string]*list.Element)
+	c.lru.Init()
+	c.size = 0
+}
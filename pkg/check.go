@@ -0,0 +1,221 @@
+package pkg
+
+import (
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// BrokenLink is a link or image of a markdown file that doesn't resolve.
+type BrokenLink struct {
+	// File is the slash separated path of the markdown file in the checked
+	// directory.
+	File string
+	// Line is the line of the link in the file, 0 if it is unknown.
+	Line   int
+	Kind   string
+	Target string
+	Reason string
+}
+
+func (b BrokenLink) String() string {
+	loc := b.File
+	if b.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", b.File, b.Line)
+	}
+	return fmt.Sprintf("%s: broken %s %q: %s", loc, b.Kind, b.Target, b.Reason)
+}
+
+// CheckReport lists the broken links found by CheckLinks, and the prose
+// issues with WithProseLint.
+type CheckReport struct {
+	Files  int
+	Broken []BrokenLink
+	Prose  []ProseIssue
+}
+
+type linkChecker struct {
+	s          *Server
+	root       string
+	fsys       http.FileSystem
+	anchors    map[string]map[string]bool
+	dictionary map[string]bool
+}
+
+// CheckLinks renders all markdown files below dir and verifies that their
+// relative links and images point to existing files, and that links to
+// headings of markdown files point to existing anchors. Links to other sites
+// are not checked. With WithProseLint the text of the files is linted too.
+func (s *Server) CheckLinks(dir string) (*CheckReport, error) {
+	root, err := filepath.Abs(dir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	c := &linkChecker{
+		s:       s,
+		root:    root,
+		fsys:    rootFS{root: http.Dir(root), hidden: s.hidden},
+		anchors: make(map[string]map[string]bool),
+	}
+	if s.prose != nil {
+		if c.dictionary, err = s.prose.dictionary(root); err != nil {
+			return nil, err
+		}
+	}
+
+	var files []string
+	err = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if p != root && strings.HasPrefix(d.Name(), ".") && (!s.hidden || d.Name() == ".git") {
+			if d.IsDir() {
+				return filepath.SkipDir
+			}
+			return nil
+		}
+		if !d.IsDir() && s.IsMarkdown(d.Name()) {
+			rel, err := filepath.Rel(root, p)
+			if err != nil {
+				return err
+			}
+			files = append(files, "/"+filepath.ToSlash(rel))
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, fmt.Errorf("failed to read directory: %v", err)
+	}
+
+	report := &CheckReport{Files: len(files)}
+	for _, file := range files {
+		broken, prose, err := c.checkFile(file)
+		if err != nil {
+			return nil, err
+		}
+		report.Broken = append(report.Broken, broken...)
+		report.Prose = append(report.Prose, prose...)
+	}
+	return report, nil
+}
+
+// parse renders the markdown file name and remembers its anchors.
+func (c *linkChecker) parse(name string) (ast.Node, []byte, error) {
+	content, err := os.ReadFile(filepath.Join(c.root, filepath.FromSlash(name)))
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to read file %s: %v", name, err)
+	}
+	embedded, _ := c.s.embedFiles(c.fsys, name, content)
+	doc := c.s.parser.parse(embedded)
+	c.anchors[name] = documentAnchors(doc)
+	return doc, content, nil
+}
+
+func (c *linkChecker) checkFile(name string) ([]BrokenLink, []ProseIssue, error) {
+	doc, content, err := c.parse(name)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var broken []BrokenLink
+	offset := 0
+	check := func(kind string, dest []byte) {
+		target := string(dest)
+		reason := c.resolve(name, target)
+		if reason == "" {
+			return
+		}
+		// the AST has no positions, so search the link in the source
+		line := 0
+		if i := strings.Index(string(content[offset:]), target); i >= 0 {
+			offset += i
+			line = strings.Count(string(content[:offset]), "\n") + 1
+		}
+		broken = append(broken, BrokenLink{
+			File:   strings.TrimPrefix(name, "/"),
+			Line:   line,
+			Kind:   kind,
+			Target: target,
+			Reason: reason,
+		})
+	}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			check("link", n.Destination)
+		case *ast.Image:
+			check("image", n.Destination)
+		}
+		return ast.GoToNext
+	})
+
+	if c.s.prose == nil {
+		return broken, nil, nil
+	}
+	prose, err := c.s.prose.lint(doc, content, c.dictionary)
+	if err != nil {
+		return nil, nil, fmt.Errorf("failed to lint %s: %v", name, err)
+	}
+	for i := range prose {
+		prose[i].File = strings.TrimPrefix(name, "/")
+	}
+	return broken, prose, nil
+}
+
+// resolve returns why the link target of the file name is broken, or an
+// empty string if it resolves.
+func (c *linkChecker) resolve(name string, target string) string {
+	if target == "" {
+		return ""
+	}
+	u, err := url.Parse(target)
+	if err != nil {
+		return "invalid URL"
+	}
+	if u.Scheme != "" || u.Host != "" || strings.HasPrefix(target, "//") {
+		return ""
+	}
+
+	file := name
+	if u.Path != "" {
+		file = path.Join(path.Dir(name), u.Path)
+		if strings.HasPrefix(u.Path, "/") {
+			file = path.Clean(u.Path)
+		}
+		f, err := c.fsys.Open(file)
+		if err != nil {
+			return "file not found"
+		}
+		info, err := f.Stat()
+		f.Close()
+		if err != nil || info.IsDir() {
+			return ""
+		}
+	}
+
+	if u.Fragment == "" || !c.s.IsMarkdown(file) {
+		return ""
+	}
+	anchors, ok := c.anchors[file]
+	if !ok {
+		if _, _, err := c.parse(file); err != nil {
+			return err.Error()
+		}
+		anchors = c.anchors[file]
+	}
+	if !anchors[u.Fragment] {
+		return fmt.Sprintf("anchor #%s not found", u.Fragment)
+	}
+	return ""
+}
@@ -0,0 +1,293 @@
+package pkg
+
+import (
+	"fmt"
+	"html/template"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Citation styles of WithBibliography.
+const (
+	// CitationNumeric cites works by their number in the references, like
+	// [1, p. 3].
+	CitationNumeric = "numeric"
+	// CitationAuthorDate cites works by their authors and year, like
+	// (Doe 2020, p. 3).
+	CitationAuthorDate = "author-date"
+)
+
+// WithBibliography resolves pandoc style citations like [@doe2020], [@doe2020,
+// p. 3] or [see @doe2020; @roe2019] against the entries of the BibTeX (.bib)
+// or CSL-JSON (.json) file, formatted in style, CitationNumeric or
+// CitationAuthorDate, and lists the cited works in a References section at
+// the end of the document. The file is read again when it changes.
+func WithBibliography(file string, style string) ParserOption {
+	return func(p *Parser) {
+		p.bibliography = &bibliography{file: file, style: style}
+	}
+}
+
+// bibliographyModTime returns the modification time of the bibliography
+// file of WithBibliography, zero without.
+func (m Parser) bibliographyModTime() time.Time {
+	if m.bibliography == nil {
+		return time.Time{}
+	}
+	_, modTime := m.bibliography.lookup()
+	return modTime
+}
+
+// citationGroup matches brackets containing citations.
+var citationGroup = regexp.MustCompile(`\[([^\[\]]*@[^\[\]]+)\]`)
+
+// citationPart matches a citation of a group, with optional prefix and
+// locator, like "see @doe2020, p. 3".
+var citationPart = regexp.MustCompile(`^\s*(?:(.*?)\s+)?@([\p{L}\p{N}_](?:[\p{L}\p{N}_:.#$%&+?<>~/-]*[\p{L}\p{N}_])?)\s*(?:,\s*(.*?))?\s*$`)
+
+type citation struct {
+	prefix  string
+	key     string
+	locator string
+}
+
+// parseCitations returns the citations of the content of a group, or false
+// if it isn't one, e.g. [user@example.com].
+func parseCitations(group string) ([]citation, bool) {
+	var cites []citation
+	for _, part := range strings.Split(group, ";") {
+		m := citationPart.FindStringSubmatch(part)
+		if m == nil {
+			return nil, false
+		}
+		cites = append(cites, citation{prefix: m[1], key: m[2], locator: m[3]})
+	}
+	return cites, true
+}
+
+// citeWorks replaces the citations of doc with links to their works in the
+// references added at the end of doc, before the footnotes. Citations of
+// unknown works are marked.
+func (m Parser) citeWorks(doc ast.Node) {
+	if m.bibliography == nil {
+		return
+	}
+	entries, _ := m.bibliography.lookup()
+
+	var cited []*bibEntry
+	numbers := make(map[string]int)
+	cite := func(c citation) (string, bool) {
+		e, ok := entries[c.key]
+		if !ok {
+			return "", false
+		}
+		if numbers[c.key] == 0 {
+			cited = append(cited, e)
+			numbers[c.key] = len(cited)
+		}
+		label := strconv.Itoa(numbers[c.key])
+		if m.bibliography.style == CitationAuthorDate {
+			label = e.shortAuthors() + " " + e.displayYear()
+		}
+		return fmt.Sprintf(`<a href="#ref-%s" class="grip-citation">%s</a>`,
+			template.HTMLEscapeString(c.key), template.HTMLEscapeString(label)), true
+	}
+
+	for _, t := range linkableTexts(doc) {
+		matches := citationGroup.FindAllSubmatchIndex(t.Literal, -1)
+		if matches == nil {
+			continue
+		}
+		var nodes []ast.Node
+		start := 0
+		for _, match := range matches {
+			cites, ok := parseCitations(string(t.Literal[match[2]:match[3]]))
+			if !ok {
+				continue
+			}
+			html := m.formatCitations(cites, cite, string(t.Literal[match[0]:match[1]]))
+			nodes = append(nodes,
+				&ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:match[0]]}},
+				&ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(html)}})
+			start = match[1]
+		}
+		if nodes == nil {
+			continue
+		}
+		nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:]}})
+		replaceNode(t, nodes)
+	}
+
+	if len(cited) > 0 {
+		m.addReferences(doc, cited)
+	}
+}
+
+// formatCitations returns the HTML of a group of citations, or the source
+// of the group marked as unknown if it cites works not in the bibliography.
+func (m Parser) formatCitations(cites []citation, cite func(citation) (string, bool), source string) string {
+	var parts []string
+	for _, c := range cites {
+		link, ok := cite(c)
+		if !ok {
+			return fmt.Sprintf(`<span class="grip-citation-missing" title="Unknown citation: %s">%s</span>`,
+				template.HTMLEscapeString(c.key), template.HTMLEscapeString(source))
+		}
+		part := link
+		if c.prefix != "" {
+			part = template.HTMLEscapeString(c.prefix) + " " + part
+		}
+		if c.locator != "" {
+			part += ", " + template.HTMLEscapeString(c.locator)
+		}
+		parts = append(parts, part)
+	}
+	if m.bibliography.style == CitationAuthorDate {
+		return "(" + strings.Join(parts, "; ") + ")"
+	}
+	return "[" + strings.Join(parts, "; ") + "]"
+}
+
+// addReferences adds the References heading and the list of the cited works
+// to doc, in the order they are cited, or sorted by author and year in the
+// author-date style.
+func (m Parser) addReferences(doc ast.Node, cited []*bibEntry) {
+	tag := "ol"
+	if m.bibliography.style == CitationAuthorDate {
+		tag = "ul"
+		sort.SliceStable(cited, func(i, j int) bool {
+			a, b := cited[i].sortKey(), cited[j].sortKey()
+			return a < b
+		})
+	}
+	var list strings.Builder
+	fmt.Fprintf(&list, "<%s class=\"grip-references\">\n", tag)
+	for _, e := range cited {
+		fmt.Fprintf(&list, "<li id=\"ref-%s\">%s</li>\n", template.HTMLEscapeString(e.key), e.reference())
+	}
+	fmt.Fprintf(&list, "</%s>\n", tag)
+
+	heading := &ast.Heading{Level: 2}
+	ast.AppendChild(heading, &ast.Text{Leaf: ast.Leaf{Literal: []byte("References")}})
+	nodes := []ast.Node{heading, &ast.HTMLBlock{Leaf: ast.Leaf{Literal: []byte(list.String())}}}
+
+	children := doc.GetChildren()
+	i := len(children)
+	for j, child := range children {
+		if _, ok := child.(*ast.Footnotes); ok {
+			i = j
+			break
+		}
+	}
+	children = append(children[:i], append(nodes, children[i:]...)...)
+	for _, n := range nodes {
+		n.SetParent(doc)
+	}
+	doc.SetChildren(children)
+}
+
+// shortAuthors returns the family names of the authors for citations, like
+// "Doe", "Doe and Roe" or "Doe et al.", or else the title.
+func (e *bibEntry) shortAuthors() string {
+	switch len(e.authors) {
+	case 0:
+		return e.title
+	case 1:
+		return e.authors[0].family
+	case 2:
+		return e.authors[0].family + " and " + e.authors[1].family
+	}
+	return e.authors[0].family + " et al."
+}
+
+func (e *bibEntry) displayYear() string {
+	if e.year == "" {
+		return "n.d."
+	}
+	return e.year
+}
+
+func (e *bibEntry) sortKey() string {
+	return strings.ToLower(e.shortAuthors() + "\x00" + e.year + "\x00" + e.title)
+}
+
+// reference returns the HTML of the entry in the references, like
+// "Doe, J., & Roe, R. (2020). Title. <em>Journal</em>, 12(3), 45–67."
+func (e *bibEntry) reference() string {
+	esc := template.HTMLEscapeString
+	var names []string
+	for _, n := range e.authors {
+		name := n.family
+		if initials := nameInitials(n.given); initials != "" {
+			name += ", " + initials
+		}
+		names = append(names, esc(name))
+	}
+
+	var b strings.Builder
+	title := esc(e.title)
+	if e.container == "" {
+		title = "<em>" + title + "</em>"
+	}
+	switch len(names) {
+	case 0:
+		b.WriteString(sentence(title) + " ")
+	case 1:
+		b.WriteString(names[0] + " ")
+	default:
+		b.WriteString(strings.Join(names[:len(names)-1], ", ") + ", &amp; " + names[len(names)-1] + " ")
+	}
+	fmt.Fprintf(&b, "(%s).", esc(e.displayYear()))
+	if len(names) > 0 && e.title != "" {
+		b.WriteString(" " + sentence(title))
+	}
+	if e.container != "" {
+		b.WriteString(" <em>" + esc(e.container) + "</em>")
+		if e.volume != "" {
+			b.WriteString(", " + esc(e.volume))
+			if e.issue != "" {
+				b.WriteString("(" + esc(e.issue) + ")")
+			}
+		}
+		if e.pages != "" {
+			b.WriteString(", " + esc(strings.ReplaceAll(e.pages, "-", "–")))
+		}
+		b.WriteString(".")
+	}
+	if e.publisher != "" {
+		b.WriteString(" " + sentence(esc(e.publisher)))
+	}
+	link := e.url
+	if e.doi != "" {
+		link = "https://doi.org/" + strings.TrimPrefix(e.doi, "https://doi.org/")
+	}
+	if link != "" {
+		fmt.Fprintf(&b, ` <a href="%s">%s</a>`, esc(link), esc(link))
+	}
+	return b.String()
+}
+
+// nameInitials returns the initials of given names, like "J. R." for "John
+// Ronald".
+func nameInitials(given string) string {
+	var initials []string
+	for _, name := range strings.Fields(given) {
+		r := []rune(name)
+		initials = append(initials, string(r[0])+".")
+	}
+	return strings.Join(initials, " ")
+}
+
+// sentence ends s with a period, unless it already ends with punctuation.
+func sentence(s string) string {
+	trimmed := strings.TrimSuffix(s, "</em>")
+	if strings.HasSuffix(trimmed, ".") || strings.HasSuffix(trimmed, "?") || strings.HasSuffix(trimmed, "!") {
+		return s
+	}
+	return s + "."
+}
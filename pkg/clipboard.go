@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"log/slog"
+	"os"
+	"os/exec"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// clipboardPage is the path under which the clipboard is served.
+const clipboardPage = "clipboard.md"
+
+// clipboardInterval is how often the clipboard is checked for changes.
+const clipboardInterval = 500 * time.Millisecond
+
+// WithClipboard serves the contents of the system clipboard as markdown
+// instead of a file, re-rendered whenever the clipboard changes, to draft
+// comments before pasting them. The clipboard is read with pbpaste on macOS,
+// PowerShell on Windows and wl-paste, xclip or xsel elsewhere.
+func WithClipboard(enabled bool) Option {
+	return func(s *Server) {
+		s.clipboard = nil
+		if enabled {
+			s.clipboard = &clipboardSource{}
+		}
+	}
+}
+
+// clipboardSource holds the clipboard contents while they are served.
+type clipboardSource struct {
+	mu      sync.Mutex
+	content []byte
+	command []string
+}
+
+// clipboardCommands returns the commands printing the clipboard on this
+// system, in order of preference.
+func clipboardCommands() [][]string {
+	switch runtime.GOOS {
+	case "darwin":
+		return [][]string{{"pbpaste"}}
+	case "windows":
+		return [][]string{{"powershell.exe", "-NoProfile", "-Command", "Get-Clipboard -Raw"}}
+	}
+	var cmds [][]string
+	if os.Getenv("WAYLAND_DISPLAY") != "" {
+		cmds = append(cmds, []string{"wl-paste", "--no-newline"})
+	}
+	return append(cmds,
+		[]string{"xclip", "-selection", "clipboard", "-out"},
+		[]string{"xsel", "--clipboard", "--output"},
+	)
+}
+
+// init finds the clipboard command and reads the clipboard for the first
+// time. Some commands fail while the clipboard is empty, so only a missing
+// command is an error.
+func (c *clipboardSource) init() error {
+	for _, cmd := range clipboardCommands() {
+		if _, err := exec.LookPath(cmd[0]); err == nil {
+			c.command = cmd
+			break
+		}
+	}
+	if c.command == nil {
+		return errors.New("no clipboard command found, install wl-clipboard, xclip or xsel")
+	}
+	if _, err := c.poll(); err != nil {
+		slog.Warn("failed to read clipboard", "err", err)
+	}
+	return nil
+}
+
+// poll reads the clipboard and reports whether it changed.
+func (c *clipboardSource) poll() (bool, error) {
+	out, err := exec.Command(c.command[0], c.command[1:]...).Output()
+	if err != nil {
+		return false, err
+	}
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	if bytes.Equal(out, c.content) {
+		return false, nil
+	}
+	c.content = out
+	return true, nil
+}
+
+// watch polls the clipboard until ctx is cancelled, calling onChange when
+// it changed.
+func (c *clipboardSource) watch(ctx context.Context, onChange func()) {
+	ticker := time.NewTicker(clipboardInterval)
+	defer ticker.Stop()
+	var lastErr string
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+		changed, err := c.poll()
+		if err != nil {
+			// only log when the error changes, not twice a second
+			if err.Error() != lastErr {
+				slog.Warn("failed to read clipboard", "err", err)
+				lastErr = err.Error()
+			}
+			continue
+		}
+		lastErr = ""
+		if changed {
+			onChange()
+		}
+	}
+}
+
+func (c *clipboardSource) bytes() []byte {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]byte(nil), c.content...)
+}
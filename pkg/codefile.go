@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/alecthomas/chroma/v2"
+	chroma_html "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// WithRenderCode shows source files without renderer highlighted in the
+// layout, like GitHub's file view, instead of serving them as text. Line
+// numbers link to #L10, and #L10-L20 highlights a range of lines.
+func WithRenderCode(enabled bool) Option {
+	return func(s *Server) {
+		s.renderCode = enabled
+	}
+}
+
+// pageRenderer returns the renderer of the file requested by r in fsys.
+// Browsers navigating to source files get the view of codeRenderer.
+func (s *Server) pageRenderer(r *http.Request, fsys http.FileSystem) (Renderer, bool) {
+	if render, ok := s.renderer(r.URL.Path, fsys); ok {
+		return render, true
+	}
+	if !strings.Contains(r.Header.Get("Accept"), "text/html") {
+		return nil, false
+	}
+	return s.codeRenderer(r.URL.Path)
+}
+
+// codeRenderer returns the renderer of the highlighted view of the file p,
+// if WithRenderCode is set and chroma knows its language. Images, videos and
+// HTML files keep being served as they are, since pages load them.
+func (s *Server) codeRenderer(p string) (Renderer, bool) {
+	if !s.renderCode {
+		return nil, false
+	}
+	ext := strings.ToLower(path.Ext(p))
+	if _, ok := mediaTypes[ext]; ok || ext == ".html" || ext == ".htm" {
+		return nil, false
+	}
+	if lexers.Match(path.Base(p)) == nil {
+		return nil, false
+	}
+	return s.renderCodeFile, true
+}
+
+// renderCodeFile renders a source file with a header naming it and its
+// lines numbered.
+func (s *Server) renderCodeFile(content []byte, name string) ([]byte, string, error) {
+	base := path.Base(name)
+	lexer := lexers.Match(base)
+	if lexer == nil {
+		lexer = lexers.Fallback
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, string(content))
+	if err != nil {
+		return nil, "", fmt.Errorf("failed to highlight %s: %v", name, err)
+	}
+
+	var buf bytes.Buffer
+	lines := bytes.Count(content, []byte("\n"))
+	if len(content) > 0 && content[len(content)-1] != '\n' {
+		lines++
+	}
+	raw := (&url.URL{Path: "./" + base, RawQuery: "raw=1"}).String()
+	buf.WriteString(`<div class="grip-code-file">` + "\n")
+	buf.WriteString(`<div class="grip-code-file-header"><span>` + html.EscapeString(base) + `</span>` +
+		`<span>` + html.EscapeString(s.msg("%d lines", lines)) + ` · ` + formatSize(int64(len(content))) + `</span>` +
+		`<a href="` + html.EscapeString(raw) + `">` + html.EscapeString(s.msg("View raw")) + `</a></div>` + "\n")
+	formatter := chroma_html.New(
+		chroma_html.WithClasses(true),
+		chroma_html.WithLineNumbers(true),
+		chroma_html.WithLinkableLineNumbers(true, "L"),
+	)
+	if err := formatter.Format(&buf, styles.Fallback, iterator); err != nil {
+		return nil, "", fmt.Errorf("failed to highlight %s: %v", name, err)
+	}
+	buf.WriteString("\n</div>\n")
+	buf.WriteString(`<script src="` + assetPath("/static/js/codelines.js") + `"></script>` + "\n")
+	return buf.Bytes(), base, nil
+}
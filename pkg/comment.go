@@ -0,0 +1,72 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+)
+
+// WithCommentMode previews files like GitHub renders issue and pull request
+// comments instead of files: without table of contents and, when gh is not
+// nil, rendered by the markdown API of the GitHub instance in gfm mode, with
+// references resolved in repo, so drafts look exactly like they will post.
+// The parser should use hard wraps and references to match without the API,
+// see WithHardWraps and WithReferences.
+func WithCommentMode(enabled bool, gh *GitHub, repo string) Option {
+	return func(s *Server) {
+		s.commentMode = enabled
+		s.commentAPI = nil
+		if enabled && gh != nil {
+			s.commentAPI = &commentRenderer{github: gh, repo: repo}
+		}
+	}
+}
+
+// commentRenderer renders markdown with the markdown API of GitHub.
+type commentRenderer struct {
+	github *GitHub
+	repo   string
+}
+
+// render returns the HTML GitHub renders for a comment of md.
+func (c *commentRenderer) render(md []byte) ([]byte, error) {
+	body, err := json.Marshal(struct {
+		Text    string `json:"text"`
+		Mode    string `json:"mode"`
+		Context string `json:"context,omitempty"`
+	}{string(md), "gfm", c.repo})
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequest(http.MethodPost, c.github.APIURL+"/markdown", bytes.NewReader(body))
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	req.Header.Set("Content-Type", "application/json")
+	c.github.authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render with the GitHub API: %v", err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to render with the GitHub API: %s", resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// renderComment renders markdown like renderMarkdown does in comment mode.
+func (s *Server) renderComment(content []byte, name string) ([]byte, string, error) {
+	title := extractTitle(content, path.Base(name))
+	if s.commentAPI == nil {
+		return s.parser.MdToHTMLFile(content, name), title, nil
+	}
+	out, err := s.commentAPI.render(content)
+	return out, title, err
+}
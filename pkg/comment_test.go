@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestCommentRenderer(t *testing.T) {
+	api := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		var req struct{ Text, Mode, Context string }
+		if r.Method != http.MethodPost || r.URL.Path != "/api/v3/markdown" {
+			http.NotFound(w, r)
+			return
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		if got := r.Header.Get("Authorization"); got != "Bearer secret" {
+			t.Errorf("Authorization = %q", got)
+		}
+		w.Write([]byte("<p>" + req.Text + " " + req.Mode + " " + req.Context + "</p>"))
+	}))
+	defer api.Close()
+
+	s := NewServer(nil, 0, "auto", false, false, NewParser("auto"),
+		WithCommentMode(true, NewGitHub(api.URL, "secret"), "acme/web"))
+	out, title, err := s.renderMarkdown([]byte("hi"), "/comment.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(out) != "<p>hi gfm acme/web</p>" || title != "comment" {
+		t.Errorf("renderMarkdown() = %q, %q", out, title)
+	}
+}
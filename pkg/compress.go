@@ -0,0 +1,154 @@
+package pkg
+
+import (
+	"compress/gzip"
+	"io"
+	"mime"
+	"net/http"
+	"strings"
+
+	"github.com/andybalholm/brotli"
+)
+
+var compressibleTypes = map[string]bool{
+	"text/html":              true,
+	"text/css":               true,
+	"text/javascript":        true,
+	"application/javascript": true,
+	"application/json":       true,
+	"image/svg+xml":          true,
+}
+
+// compressHandler compresses HTML, CSS and JS responses with brotli or gzip,
+// depending on the encodings accepted by the client. Compressed responses get
+// the ETag of the handler with the encoding appended, as their bodies differ,
+// and conditional requests with such ETags reach the handler without it.
+func compressHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		encoding := negotiateEncoding(r.Header.Get("Accept-Encoding"))
+		if encoding == "" || r.Header.Get("Range") != "" || r.Header.Get("Upgrade") != "" {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		w.Header().Add("Vary", "Accept-Encoding")
+		cw := &compressWriter{ResponseWriter: w, encoding: encoding}
+		suffix := "-" + encoding + `"`
+		for _, name := range []string{"If-None-Match", "If-Match"} {
+			if v := r.Header.Get(name); strings.Contains(v, suffix) {
+				if !cw.conditional {
+					r = r.Clone(r.Context())
+					cw.conditional = true
+				}
+				r.Header.Set(name, strings.ReplaceAll(v, suffix, `"`))
+			}
+		}
+		defer cw.Close()
+		next.ServeHTTP(cw, r)
+	})
+}
+
+// encodedETag returns the ETag of a response compressed with encoding.
+func encodedETag(etag string, encoding string) string {
+	if !strings.HasSuffix(etag, `"`) {
+		return etag
+	}
+	return strings.TrimSuffix(etag, `"`) + "-" + encoding + `"`
+}
+
+// negotiateEncoding picks brotli over gzip if the client accepts it.
+func negotiateEncoding(accept string) string {
+	var gz bool
+	for _, part := range strings.Split(accept, ",") {
+		name, params, _ := strings.Cut(strings.TrimSpace(part), ";")
+		if strings.ReplaceAll(params, " ", "") == "q=0" {
+			continue
+		}
+		switch strings.TrimSpace(name) {
+		case "br":
+			return "br"
+		case "gzip":
+			gz = true
+		}
+	}
+	if gz {
+		return "gzip"
+	}
+	return ""
+}
+
+type compressWriter struct {
+	http.ResponseWriter
+	encoding string
+	writer   interface {
+		io.WriteCloser
+		Flush() error
+	}
+	wroteHeader bool
+	// conditional is set if the request had ETags of compressed responses,
+	// which 304 Not Modified answers keep
+	conditional bool
+}
+
+func (cw *compressWriter) WriteHeader(status int) {
+	if cw.wroteHeader {
+		return
+	}
+	cw.wroteHeader = true
+
+	h := cw.Header()
+	mediaType, _, _ := mime.ParseMediaType(h.Get("Content-Type"))
+	if status == http.StatusOK && h.Get("Content-Encoding") == "" && compressibleTypes[mediaType] {
+		h.Set("Content-Encoding", cw.encoding)
+		h.Del("Content-Length")
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", encodedETag(etag, cw.encoding))
+		}
+		if cw.encoding == "br" {
+			cw.writer = brotli.NewWriter(cw.ResponseWriter)
+		} else {
+			cw.writer = gzip.NewWriter(cw.ResponseWriter)
+		}
+	} else if status == http.StatusNotModified && cw.conditional {
+		if etag := h.Get("ETag"); etag != "" {
+			h.Set("ETag", encodedETag(etag, cw.encoding))
+		}
+	}
+	cw.ResponseWriter.WriteHeader(status)
+}
+
+func (cw *compressWriter) Write(b []byte) (int, error) {
+	if !cw.wroteHeader {
+		if cw.Header().Get("Content-Type") == "" {
+			cw.Header().Set("Content-Type", http.DetectContentType(b))
+		}
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		return cw.writer.Write(b)
+	}
+	return cw.ResponseWriter.Write(b)
+}
+
+// Flush writes the data compressed so far to the client, for streamed
+// responses.
+func (cw *compressWriter) Flush() {
+	if !cw.wroteHeader {
+		cw.WriteHeader(http.StatusOK)
+	}
+	if cw.writer != nil {
+		cw.writer.Flush()
+	}
+	http.NewResponseController(cw.ResponseWriter).Flush()
+}
+
+func (cw *compressWriter) Close() error {
+	if cw.writer != nil {
+		return cw.writer.Close()
+	}
+	return nil
+}
+
+func (cw *compressWriter) Unwrap() http.ResponseWriter {
+	return cw.ResponseWriter
+}
@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"bufio"
+	"compress/gzip"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/andybalholm/brotli"
+)
+
+func TestNegotiateEncoding(t *testing.T) {
+	for accept, want := range map[string]string{
+		"":                      "",
+		"deflate":               "",
+		"gzip, deflate":         "gzip",
+		"gzip, deflate, br":     "br",
+		"br;q=0, gzip":          "gzip",
+		"gzip; q=0":             "",
+		"identity, gzip;q=0.5":  "gzip",
+		"br;q=0.1, gzip;q=1.0 ": "br",
+	} {
+		if got := negotiateEncoding(accept); got != want {
+			t.Errorf("negotiateEncoding(%q) = %q, want %q", accept, got, want)
+		}
+	}
+}
+
+func TestCompressHandler(t *testing.T) {
+	const body = "<p>compressible page</p>"
+	h := compressHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/image.png" {
+			w.Header().Set("Content-Type", "image/png")
+		} else {
+			w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		}
+		w.Header().Set("ETag", `W/"abc"`)
+		http.ServeContent(w, r, "", time.Time{}, strings.NewReader(body))
+	}))
+	serve := func(path string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	for _, tt := range []struct {
+		encoding string
+		decode   func(io.Reader) (io.Reader, error)
+	}{
+		{"gzip", func(r io.Reader) (io.Reader, error) { return gzip.NewReader(r) }},
+		{"br", func(r io.Reader) (io.Reader, error) { return brotli.NewReader(r), nil }},
+	} {
+		rec := serve("/", "Accept-Encoding", tt.encoding)
+		if got := rec.Header().Get("Content-Encoding"); got != tt.encoding {
+			t.Fatalf("%s: got Content-Encoding %q", tt.encoding, got)
+		}
+		if got := rec.Header().Get("Vary"); got != "Accept-Encoding" {
+			t.Errorf("%s: got Vary %q", tt.encoding, got)
+		}
+		etag := rec.Header().Get("ETag")
+		if want := `W/"abc-` + tt.encoding + `"`; etag != want {
+			t.Errorf("%s: got ETag %s, want %s", tt.encoding, etag, want)
+		}
+		r, err := tt.decode(rec.Body)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if data, err := io.ReadAll(r); err != nil || string(data) != body {
+			t.Errorf("%s: got %q: %v", tt.encoding, data, err)
+		}
+
+		// the ETag of the compressed page is current
+		rec = serve("/", "Accept-Encoding", tt.encoding, "If-None-Match", etag)
+		if rec.Code != http.StatusNotModified || rec.Header().Get("ETag") != etag {
+			t.Errorf("%s: expected 304 with ETag %s, got %d with %s", tt.encoding, etag, rec.Code, rec.Header().Get("ETag"))
+		}
+	}
+
+	rec := serve("/")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("ETag") != `W/"abc"` || rec.Body.String() != body {
+		t.Errorf("expected the identity body with its ETag, got %q with %s", rec.Header().Get("Content-Encoding"), rec.Header().Get("ETag"))
+	}
+	if rec := serve("/", "If-None-Match", `W/"abc-gzip"`); rec.Code != http.StatusOK {
+		t.Errorf("expected the ETag of the gzip body not to match the identity body, got %d", rec.Code)
+	}
+
+	rec = serve("/", "Accept-Encoding", "gzip", "Range", "bytes=0-2")
+	if rec.Code != http.StatusPartialContent || rec.Header().Get("Content-Encoding") != "" || rec.Body.String() != "<p>" {
+		t.Errorf("expected an uncompressed range, got %d %q", rec.Code, rec.Body)
+	}
+	rec = serve("/", "Accept-Encoding", "gzip", "Connection", "Upgrade", "Upgrade", "websocket")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("Vary") != "" {
+		t.Errorf("expected upgrade requests to be passed through, got %q", rec.Header().Get("Content-Encoding"))
+	}
+	rec = serve("/image.png", "Accept-Encoding", "gzip")
+	if rec.Header().Get("Content-Encoding") != "" || rec.Header().Get("ETag") != `W/"abc"` {
+		t.Errorf("expected images to stay uncompressed, got %q with %s", rec.Header().Get("Content-Encoding"), rec.Header().Get("ETag"))
+	}
+}
+
+func TestCompressHandlerFlushes(t *testing.T) {
+	r := newReloader(t.TempDir())
+	more := make(chan struct{})
+	srv := httptest.NewServer(compressHandler(r.handle(http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Content-Type", "text/html")
+		io.WriteString(w, "first\n")
+		http.NewResponseController(w).Flush()
+		<-more
+		io.WriteString(w, "second\n")
+	}))))
+	defer srv.Close()
+	defer close(more)
+
+	get := func(path string) *http.Response {
+		t.Helper()
+		req, err := http.NewRequest(http.MethodGet, srv.URL+path, nil)
+		if err != nil {
+			t.Fatal(err)
+		}
+		req.Header.Set("Accept-Encoding", "gzip")
+		resp, err := http.DefaultClient.Do(req)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return resp
+	}
+
+	// reload events arrive while the stream is open
+	resp := get(reloadEventsEndpoint)
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+	r.broadcast("reload")
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil || strings.TrimSpace(line) != "data: reload" {
+		t.Errorf("event = %q: %v", line, err)
+	}
+
+	// compressed data written before a flush arrives before the response ends
+	resp = get("/page.html")
+	defer resp.Body.Close()
+	if resp.Header.Get("Content-Encoding") != "gzip" {
+		t.Fatalf("expected a gzip response, got %q", resp.Header.Get("Content-Encoding"))
+	}
+	zr, err := gzip.NewReader(resp.Body)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, err = bufio.NewReader(zr).ReadString('\n')
+	if err != nil || line != "first\n" {
+		t.Errorf("got %q before the end of the response: %v", line, err)
+	}
+}
@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"net/http"
+	"slices"
+	"strings"
+)
+
+// DefaultCORSMethods are the methods allowed for other origins by WithCORS.
+var DefaultCORSMethods = []string{"GET", "HEAD", "OPTIONS"}
+
+// WithCORS allows pages on the given origins, like https://editor.example.com,
+// or "*" for any origin, to fetch rendered pages, fragments, the API and
+// static assets with the given methods, e.g. from a browser-based editor.
+// Without methods DefaultCORSMethods are allowed.
+func WithCORS(origins []string, methods []string) Option {
+	return func(s *Server) {
+		s.corsOrigins = origins
+		s.corsMethods = methods
+		if len(methods) == 0 {
+			s.corsMethods = DefaultCORSMethods
+		}
+	}
+}
+
+// corsHandler adds the CORS headers for allowed origins to the responses of
+// next and answers preflight requests, which carry no credentials, before
+// authentication.
+func (s *Server) corsHandler(next http.Handler) http.Handler {
+	if len(s.corsOrigins) == 0 {
+		return next
+	}
+	anyOrigin := slices.Contains(s.corsOrigins, "*")
+	methods := strings.ToUpper(strings.Join(s.corsMethods, ", "))
+
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		origin := r.Header.Get("Origin")
+		w.Header().Add("Vary", "Origin")
+		if origin == "" || !anyOrigin && !slices.Contains(s.corsOrigins, strings.TrimRight(origin, "/")) {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		h := w.Header()
+		if anyOrigin {
+			h.Set("Access-Control-Allow-Origin", "*")
+		} else {
+			h.Set("Access-Control-Allow-Origin", origin)
+		}
+		h.Set("Access-Control-Allow-Methods", methods)
+		h.Set("Access-Control-Expose-Headers", strings.Join(metaHeaders, ", "))
+		if r.Method == http.MethodOptions && r.Header.Get("Access-Control-Request-Method") != "" {
+			if headers := r.Header.Get("Access-Control-Request-Headers"); headers != "" {
+				h.Set("Access-Control-Allow-Headers", headers)
+			}
+			h.Set("Access-Control-Max-Age", "600")
+			w.WriteHeader(http.StatusNoContent)
+			return
+		}
+		next.ServeHTTP(w, r)
+	})
+}
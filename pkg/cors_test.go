@@ -0,0 +1,83 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestCORSHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Readme\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	serve := func(h http.Handler, method string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(method, "/README.md", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	s := New(WithRoot(dir), WithCORS([]string{"https://editor.example.com"}, nil), WithTokenAuth("secret"))
+	defer s.Close()
+	h := s.Handler()
+
+	rec := serve(h, http.MethodGet, "Origin", "https://editor.example.com", "Authorization", "Bearer secret")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the page, got %d", rec.Code)
+	}
+	for name, want := range map[string]string{
+		"Access-Control-Allow-Origin":  "https://editor.example.com",
+		"Access-Control-Allow-Methods": "GET, HEAD, OPTIONS",
+	} {
+		if got := rec.Header().Get(name); got != want {
+			t.Errorf("%s = %q, want %q", name, got, want)
+		}
+	}
+	if !strings.Contains(rec.Header().Get("Access-Control-Expose-Headers"), metaHeaders[0]) {
+		t.Errorf("expected the metadata headers to be exposed, got %q", rec.Header().Get("Access-Control-Expose-Headers"))
+	}
+	if !strings.Contains(strings.Join(rec.Header().Values("Vary"), ","), "Origin") {
+		t.Errorf("expected Vary: Origin, got %q", rec.Header().Values("Vary"))
+	}
+
+	// other origins get no CORS headers
+	for _, origin := range []string{"https://evil.example.com", ""} {
+		rec = serve(h, http.MethodGet, "Origin", origin, "Authorization", "Bearer secret")
+		if rec.Code != http.StatusOK || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+			t.Errorf("origin %q: got %d allowing %q", origin, rec.Code, rec.Header().Get("Access-Control-Allow-Origin"))
+		}
+	}
+
+	// preflight requests are answered without credentials
+	rec = serve(h, http.MethodOptions, "Origin", "https://editor.example.com",
+		"Access-Control-Request-Method", "GET", "Access-Control-Request-Headers", "authorization")
+	if rec.Code != http.StatusNoContent || rec.Header().Get("Access-Control-Allow-Headers") != "authorization" || rec.Header().Get("Access-Control-Max-Age") == "" {
+		t.Errorf("expected a preflight answer, got %d with %v", rec.Code, rec.Header())
+	}
+	rec = serve(h, http.MethodOptions, "Origin", "https://evil.example.com", "Access-Control-Request-Method", "GET")
+	if rec.Code != http.StatusUnauthorized || rec.Header().Get("Access-Control-Allow-Origin") != "" {
+		t.Errorf("expected the preflight of another origin to need credentials, got %d", rec.Code)
+	}
+	// the request itself still needs them
+	if rec = serve(h, http.MethodGet, "Origin", "https://editor.example.com"); rec.Code != http.StatusUnauthorized {
+		t.Errorf("expected 401 without credentials, got %d", rec.Code)
+	}
+
+	s = New(WithRoot(dir), WithCORS([]string{"*"}, []string{"get", "post"}))
+	defer s.Close()
+	rec = serve(s.Handler(), http.MethodGet, "Origin", "https://any.example.com")
+	if got := rec.Header().Get("Access-Control-Allow-Origin"); got != "*" {
+		t.Errorf("expected any origin to be allowed, got %q", got)
+	}
+	if got := rec.Header().Get("Access-Control-Allow-Methods"); got != "GET, POST" {
+		t.Errorf("got methods %q, want GET, POST", got)
+	}
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/csv"
+	"fmt"
+	"html"
+	"path"
+	"strings"
+)
+
+// renderCSV renders a CSV or TSV file as a searchable, sortable table,
+// using the first record as header like GitHub does.
+func renderCSV(content []byte, name string) ([]byte, string, error) {
+	comma := ','
+	if strings.EqualFold(path.Ext(name), ".tsv") {
+		comma = '\t'
+	}
+	return csvToHTML(content, comma), path.Base(name), nil
+}
+
+func csvToHTML(content []byte, comma rune) []byte {
+	r := csv.NewReader(bytes.NewReader(bytes.TrimPrefix(content, []byte("\xef\xbb\xbf"))))
+	r.Comma = comma
+	r.FieldsPerRecord = -1
+	r.LazyQuotes = true
+
+	var buf bytes.Buffer
+	records, err := r.ReadAll()
+	if err != nil {
+		// show the file as is, like for any other file that can't be rendered
+		fmt.Fprintf(&buf, "<p><strong>This file could not be rendered as a table: %s</strong></p>\n", html.EscapeString(err.Error()))
+		fmt.Fprintf(&buf, "<pre><code>%s</code></pre>\n", html.EscapeString(string(content)))
+		return buf.Bytes()
+	}
+	if len(records) == 0 {
+		return []byte("<p>This file is empty.</p>\n")
+	}
+
+	columns := 0
+	for _, record := range records {
+		columns = max(columns, len(record))
+	}
+
+	buf.WriteString(`<div class="grip-csv">` + "\n")
+	buf.WriteString(`<input type="search" class="grip-csv-search" placeholder="Search this file…" aria-label="Search this file">` + "\n")
+	buf.WriteString("<table class=\"grip-csv-table\">\n<thead>\n<tr>")
+	for i := 0; i < columns; i++ {
+		fmt.Fprintf(&buf, "<th>%s</th>", html.EscapeString(field(records[0], i)))
+	}
+	buf.WriteString("</tr>\n</thead>\n<tbody>\n")
+	for _, record := range records[1:] {
+		buf.WriteString("<tr>")
+		for i := 0; i < columns; i++ {
+			fmt.Fprintf(&buf, "<td>%s</td>", html.EscapeString(field(record, i)))
+		}
+		buf.WriteString("</tr>\n")
+	}
+	buf.WriteString("</tbody>\n</table>\n</div>\n")
+	buf.WriteString(`<script src="` + assetPath("/static/js/table.js") + `"></script>` + "\n")
+	return buf.Bytes()
+}
+
+func field(record []string, i int) string {
+	if i < len(record) {
+		return record[i]
+	}
+	return ""
+}
@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"errors"
+	"fmt"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"strconv"
+	"time"
+)
+
+// DaemonEnv is set in the environment of servers started by StartDaemon.
+const DaemonEnv = "GO_GRIP_DAEMON"
+
+// daemonStartTimeout is how long StartDaemon waits for the server to
+// register itself.
+const daemonStartTimeout = 10 * time.Second
+
+// IsDaemon reports whether the process is a server started by StartDaemon.
+func IsDaemon() bool {
+	return os.Getenv(DaemonEnv) == "1"
+}
+
+// StartDaemon runs the executable of the process again with args in the
+// background, detached from the terminal, with its output appended to
+// logFile. It waits until the server registered itself, see ListInstances,
+// and returns its instance, so stop and list find it.
+func StartDaemon(args []string, logFile string) (Instance, error) {
+	exe, err := os.Executable()
+	if err != nil {
+		return Instance{}, fmt.Errorf("failed to find executable: %v", err)
+	}
+	if err := os.MkdirAll(filepath.Dir(logFile), 0755); err != nil {
+		return Instance{}, fmt.Errorf("failed to create log directory: %v", err)
+	}
+	log, err := os.OpenFile(logFile, os.O_WRONLY|os.O_CREATE|os.O_APPEND, 0644)
+	if err != nil {
+		return Instance{}, fmt.Errorf("failed to open log file: %v", err)
+	}
+	defer log.Close()
+
+	cmd := exec.Command(exe, args...)
+	cmd.Env = append(os.Environ(), DaemonEnv+"=1")
+	cmd.Stdout = log
+	cmd.Stderr = log
+	cmd.SysProcAttr = daemonProcAttr()
+	if err := cmd.Start(); err != nil {
+		return Instance{}, fmt.Errorf("failed to start daemon: %v", err)
+	}
+	exited := make(chan error, 1)
+	go func() {
+		exited <- cmd.Wait()
+	}()
+
+	deadline := time.After(daemonStartTimeout)
+	tick := time.NewTicker(100 * time.Millisecond)
+	defer tick.Stop()
+	for {
+		select {
+		case err := <-exited:
+			if err == nil {
+				err = errors.New("exited")
+			}
+			return Instance{}, fmt.Errorf("daemon failed to start: %v, see %s", err, logFile)
+		case <-deadline:
+			return Instance{}, fmt.Errorf("daemon %d did not start serving in %s, see %s", cmd.Process.Pid, daemonStartTimeout, logFile)
+		case <-tick.C:
+			if inst, err := FindInstance(cmd.Process.Pid); err == nil && inst.ID == cmd.Process.Pid {
+				return inst, nil
+			}
+		}
+	}
+}
+
+// DaemonLogFile returns the log file in the user cache directory of a daemon
+// serving on port.
+func DaemonLogFile(port int) (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %v", err)
+	}
+	return filepath.Join(cacheDir, "go-grip", "logs", fmt.Sprintf("serve-%d.log", port)), nil
+}
+
+// WritePIDFile writes the process ID to file and returns a function that
+// removes the file again.
+func WritePIDFile(file string) (func(), error) {
+	if err := os.WriteFile(file, []byte(strconv.Itoa(os.Getpid())+"\n"), 0644); err != nil {
+		return nil, fmt.Errorf("failed to write pid file: %v", err)
+	}
+	return func() { os.Remove(file) }, nil
+}
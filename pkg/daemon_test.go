@@ -0,0 +1,94 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"os"
+	"os/signal"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestStartDaemon(t *testing.T) {
+	if IsDaemon() {
+		// the daemon started by the test
+		if os.Getenv("GO_GRIP_TEST_DAEMON") == "fail" {
+			fmt.Println("daemon failed")
+			os.Exit(1)
+		}
+		ctx, stop := signal.NotifyContext(context.Background(), syscall.SIGTERM)
+		defer stop()
+		unregister, err := registerInstance(Instance{ID: os.Getpid(), Port: 6419})
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer unregister()
+		fmt.Println("daemon running")
+		<-ctx.Done()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("stops the daemon with SIGTERM")
+	}
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	logFile := filepath.Join(t.TempDir(), "logs", "serve.log")
+	args := []string{"-test.run=^TestStartDaemon$"}
+
+	inst, err := StartDaemon(args, logFile)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if inst.ID == os.Getpid() || inst.Port != 6419 {
+		t.Errorf("expected the instance of the daemon, got %+v", inst)
+	}
+	if found, err := FindInstance(6419); err != nil || found.ID != inst.ID {
+		t.Errorf("expected to find the daemon by its port, got %+v: %v", found, err)
+	}
+	if log, _ := os.ReadFile(logFile); !strings.Contains(string(log), "daemon running") {
+		t.Errorf("expected the output of the daemon in its log file, got %q", log)
+	}
+
+	if err := inst.Stop(); err != nil {
+		t.Fatal(err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for {
+		if _, err := FindInstance(inst.ID); err != nil {
+			break
+		}
+		if time.Now().After(deadline) {
+			t.Fatal("expected the daemon to stop")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	t.Setenv("GO_GRIP_TEST_DAEMON", "fail")
+	if _, err := StartDaemon(args, logFile); err == nil || !strings.Contains(err.Error(), logFile) {
+		t.Errorf("expected the failure to point to the log file, got %v", err)
+	}
+	if log, _ := os.ReadFile(logFile); !strings.Contains(string(log), "daemon failed") {
+		t.Errorf("expected the output of the failed daemon to be appended, got %q", log)
+	}
+}
+
+func TestWritePIDFile(t *testing.T) {
+	file := filepath.Join(t.TempDir(), "grip.pid")
+	remove, err := WritePIDFile(file)
+	if err != nil {
+		t.Fatal(err)
+	}
+	data, err := os.ReadFile(file)
+	if err != nil || strings.TrimSpace(string(data)) != strconv.Itoa(os.Getpid()) {
+		t.Errorf("got %q: %v", data, err)
+	}
+	remove()
+	if _, err := os.Stat(file); !os.IsNotExist(err) {
+		t.Errorf("expected the pid file to be removed, got %v", err)
+	}
+}
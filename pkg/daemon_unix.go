@@ -0,0 +1,11 @@
+//go:build !windows
+
+package pkg
+
+import "syscall"
+
+// daemonProcAttr starts the daemon in a session of its own, so it keeps
+// running when the terminal closes.
+func daemonProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{Setsid: true}
+}
@@ -0,0 +1,15 @@
+//go:build windows
+
+package pkg
+
+import "syscall"
+
+// detachedProcess is the DETACHED_PROCESS creation flag, which the syscall
+// package doesn't define.
+const detachedProcess = 0x00000008
+
+// daemonProcAttr starts the daemon without console, so it keeps running when
+// the console closes.
+func daemonProcAttr() *syscall.SysProcAttr {
+	return &syscall.SysProcAttr{CreationFlags: syscall.CREATE_NEW_PROCESS_GROUP | detachedProcess}
+}
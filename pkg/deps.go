@@ -0,0 +1,93 @@
+package pkg
+
+import (
+	"log/slog"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// assetRef matches the URLs of images, media, embedded objects and
+// stylesheets in rendered HTML.
+var assetRef = regexp.MustCompile(`\s(?:src|poster|data)="([^"]+)"|<link\s[^>]*?href="([^"]+)"`)
+
+// pageDependencies returns the slash separated paths of the local files
+// referenced by the rendered parts of the page name.
+func pageDependencies(name string, parts ...[]byte) []string {
+	var deps []string
+	for _, part := range parts {
+		for _, m := range assetRef.FindAllSubmatch(part, -1) {
+			u, err := url.Parse(string(m[1]) + string(m[2]))
+			if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+				continue
+			}
+			deps = append(deps, resolveEmbed([]string{path.Clean("/" + name)}, u.Path))
+		}
+	}
+	return deps
+}
+
+// watchDependencies watches the files behind the slash separated paths
+// names, which pages read, if they are outside the watched directory, e.g.
+// because of a symlink. Changes inside the directory already reload. Their
+// changes are never taken for reload loops.
+func (r *reloader) watchDependencies(names []string) {
+	for _, name := range names {
+		link := filepath.Join(r.directory, filepath.FromSlash(name))
+		file, err := filepath.EvalSymlinks(link)
+		if err != nil {
+			continue
+		}
+		r.mu.Lock()
+		r.read[link] = struct{}{}
+		r.read[file] = struct{}{}
+		delete(r.loops, link)
+		delete(r.loops, file)
+		r.mu.Unlock()
+		r.watchFile(file)
+	}
+}
+
+// watchFile watches file for changes if it is outside the watched directory.
+func (r *reloader) watchFile(file string) {
+	file, err := filepath.Abs(file)
+	if err != nil || r.contains(file) {
+		return
+	}
+
+	r.mu.Lock()
+	_, ok := r.deps[file]
+	r.deps[file] = struct{}{}
+	r.mu.Unlock()
+	if ok {
+		return
+	}
+	slog.Debug("watching dependency", "path", file)
+	select {
+	case r.added <- file:
+	default:
+		// the watcher picks up all dependencies when it restarts
+	}
+}
+
+// contains reports whether file is in the watched directory, resolving
+// symlinks of the directory itself.
+func (r *reloader) contains(file string) bool {
+	for _, dir := range []string{r.directory, r.realDirectory} {
+		if rel, err := filepath.Rel(dir, file); err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return true
+		}
+	}
+	return false
+}
+
+// dependency reports whether a change of file outside the watched directory
+// needs a reload.
+func (r *reloader) dependency(file string) bool {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	_, ok := r.deps[filepath.Clean(file)]
+	return ok
+}
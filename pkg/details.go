@@ -0,0 +1,47 @@
+package pkg
+
+import (
+	"bytes"
+	"regexp"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+var (
+	detailsOpenRegex  = regexp.MustCompile(`(?i)^\s*<details[\s>]`)
+	detailsCloseRegex = regexp.MustCompile(`(?i)</details>\s*$`)
+	blankLineRegex    = regexp.MustCompile(`\n[ \t]*\n`)
+)
+
+// expandDetails renders markdown inside <details> blocks like GitHub. The
+// parser turns everything up to the closing </details> into one HTML block,
+// while in GitHub flavored markdown an HTML block ends at the first blank
+// line, so the content after it is parsed as markdown with parse.
+func expandDetails(doc ast.Node, parse func([]byte) ast.Node) {
+	var blocks []*ast.HTMLBlock
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if block, ok := node.(*ast.HTMLBlock); ok && entering && detailsOpenRegex.Match(block.Literal) {
+			blocks = append(blocks, block)
+		}
+		return ast.GoToNext
+	})
+
+	for _, block := range blocks {
+		loc := blankLineRegex.FindIndex(block.Literal)
+		if loc == nil {
+			continue
+		}
+		head, body := block.Literal[:loc[0]], block.Literal[loc[1]:]
+		var tail []byte
+		if m := detailsCloseRegex.FindIndex(body); m != nil {
+			body, tail = body[:m[0]], bytes.TrimSpace(body[m[0]:])
+		}
+
+		nodes := []ast.Node{&ast.HTMLBlock{Leaf: ast.Leaf{Literal: head}}}
+		nodes = append(nodes, parse(body).GetChildren()...)
+		if tail != nil {
+			nodes = append(nodes, &ast.HTMLBlock{Leaf: ast.Leaf{Literal: tail}})
+		}
+		replaceNode(block, nodes)
+	}
+}
@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+)
+
+// DefaultDiagramCacheSize is the disk space in bytes rendered diagrams may
+// take up before the least recently used ones are evicted.
+const DefaultDiagramCacheSize = 256 << 20
+
+// diagramCache keeps rendered PlantUML and Graphviz diagrams on disk keyed by
+// the hash of their source, so unchanged diagrams don't have to be rendered
+// again on reload. Mermaid diagrams are rendered in the browser.
+type diagramCache struct {
+	mu       sync.Mutex
+	dir      string
+	maxBytes int64
+}
+
+// WithDiagramCache caches rendered diagrams in dir, evicting the least
+// recently used ones once they take up more than maxBytes. Diagrams are not
+// cached without it, or with an empty dir or a maxBytes of 0.
+func WithDiagramCache(dir string, maxBytes int64) ParserOption {
+	return func(p *Parser) {
+		p.diagrams = newDiagramCache(dir, maxBytes)
+	}
+}
+
+func newDiagramCache(dir string, maxBytes int64) *diagramCache {
+	if dir == "" || maxBytes <= 0 {
+		return nil
+	}
+	return &diagramCache{dir: dir, maxBytes: maxBytes}
+}
+
+// render returns the diagram rendered from source by the renderer named by
+// kind, from the cache if possible.
+func (c *diagramCache) render(kind string, source string, render func() ([]byte, error)) ([]byte, error) {
+	if c == nil {
+		return render()
+	}
+	sum := sha256.Sum256([]byte(kind + "\x00" + source))
+	file := filepath.Join(c.dir, hex.EncodeToString(sum[:])+".svg")
+	if svg, err := os.ReadFile(file); err == nil {
+		// the modification time tracks the last use for eviction
+		now := time.Now()
+		_ = os.Chtimes(file, now, now)
+		return svg, nil
+	}
+
+	svg, err := render()
+	if err != nil {
+		return nil, err
+	}
+	if err := os.MkdirAll(c.dir, 0755); err != nil {
+		return svg, nil
+	}
+	if err := writeFileAtomic(file, svg); err == nil {
+		c.evict()
+	}
+	return svg, nil
+}
+
+// writeFileAtomic writes data to a temporary file next to file and renames
+// it, so concurrent requests never read a partly written diagram or image.
+func writeFileAtomic(file string, data []byte) error {
+	tmp, err := os.CreateTemp(filepath.Dir(file), ".tmp-*")
+	if err != nil {
+		return err
+	}
+	_, err = tmp.Write(data)
+	if closeErr := tmp.Close(); err == nil {
+		err = closeErr
+	}
+	if err == nil {
+		err = os.Chmod(tmp.Name(), 0644)
+	}
+	if err == nil {
+		err = os.Rename(tmp.Name(), file)
+	}
+	if err != nil {
+		os.Remove(tmp.Name())
+	}
+	return err
+}
+
+// evict removes the least recently used diagrams until the cache takes up at
+// most maxBytes.
+func (c *diagramCache) evict() {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+
+	entries, err := os.ReadDir(c.dir)
+	if err != nil {
+		return
+	}
+	var files []os.FileInfo
+	var size int64
+	for _, e := range entries {
+		info, err := e.Info()
+		if err != nil || !info.Mode().IsRegular() {
+			continue
+		}
+		files = append(files, info)
+		size += info.Size()
+	}
+	if size <= c.maxBytes {
+		return
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].ModTime().Before(files[j].ModTime()) })
+	for _, f := range files {
+		if size <= c.maxBytes {
+			break
+		}
+		if err := os.Remove(filepath.Join(c.dir, f.Name())); err != nil {
+			slog.Debug("failed to evict diagram", "file", f.Name(), "err", err)
+			continue
+		}
+		size -= f.Size()
+	}
+}
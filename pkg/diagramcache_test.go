@@ -0,0 +1,48 @@
+package pkg
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+func TestDiagramCache(t *testing.T) {
+	if newDiagramCache("", 20) != nil || NewParser("light").diagrams != nil {
+		t.Error("expected no diagram cache without a directory")
+	}
+
+	dir := t.TempDir()
+	cache := newDiagramCache(dir, 20)
+	renders := 0
+	render := func(svg string) func() ([]byte, error) {
+		return func() ([]byte, error) {
+			renders++
+			return []byte(svg), nil
+		}
+	}
+
+	for i := 0; i < 2; i++ {
+		svg, err := cache.render("dot", "a -> b", render("<svg>1</svg>"))
+		if err != nil || string(svg) != "<svg>1</svg>" {
+			t.Fatalf("unexpected diagram %q: %v", svg, err)
+		}
+	}
+	if renders != 1 {
+		t.Errorf("expected the diagram to be rendered once, got %d renders", renders)
+	}
+
+	// the second diagram doesn't fit next to the first one
+	if _, err := cache.render("dot", "b -> c", render("<svg>2</svg>")); err != nil {
+		t.Fatal(err)
+	}
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || !strings.HasSuffix(entries[0].Name(), ".svg") {
+		t.Errorf("expected the least recently used diagram to be evicted, got %v", entries)
+	}
+	if _, err := cache.render("dot", "a -> b", render("<svg>1</svg>")); err != nil || renders != 3 {
+		t.Errorf("expected the evicted diagram to be rendered again, got %d renders: %v", renders, err)
+	}
+}
@@ -0,0 +1,197 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+)
+
+type diffOp int
+
+const (
+	diffSame diffOp = iota
+	diffAdded
+	diffRemoved
+)
+
+type diffBlock struct {
+	op   diffOp
+	html string
+}
+
+// DiffHTML renders two versions of a markdown document as one document in
+// which blocks added in the new version and blocks removed from the old one
+// are highlighted, like GitHub's rich diff. Changed blocks show up as removed
+// and added.
+func (m Parser) DiffHTML(old []byte, new []byte) []byte {
+	blocks := diffBlocks(m.blocks(old), m.blocks(new))
+
+	var buf bytes.Buffer
+	for _, b := range blocks {
+		switch b.op {
+		case diffAdded:
+			buf.WriteString(`<div class="grip-diff-added">` + b.html + "</div>\n")
+		case diffRemoved:
+			buf.WriteString(`<div class="grip-diff-removed">` + b.html + "</div>\n")
+		default:
+			buf.WriteString(b.html)
+		}
+	}
+	return buf.Bytes()
+}
+
+// blocks renders each top-level block of a document on its own.
+func (m Parser) blocks(md []byte) []string {
+	doc := m.parse(md)
+	var out []string
+	for _, child := range doc.GetChildren() {
+		out = append(out, string(m.render(child)))
+	}
+	return out
+}
+
+// diffBlocks returns the blocks of the longest common subsequence of old and
+// new, with the blocks of old missing in new as removed and the blocks of new
+// missing in old as added.
+func diffBlocks(old []string, new []string) []diffBlock {
+	// lcs[i][j] is the length of the longest common subsequence of old[i:]
+	// and new[j:]
+	lcs := make([][]int, len(old)+1)
+	for i := range lcs {
+		lcs[i] = make([]int, len(new)+1)
+	}
+	for i := len(old) - 1; i >= 0; i-- {
+		for j := len(new) - 1; j >= 0; j-- {
+			if old[i] == new[j] {
+				lcs[i][j] = lcs[i+1][j+1] + 1
+			} else {
+				lcs[i][j] = max(lcs[i+1][j], lcs[i][j+1])
+			}
+		}
+	}
+
+	var out []diffBlock
+	i, j := 0, 0
+	for i < len(old) && j < len(new) {
+		switch {
+		case old[i] == new[j]:
+			out = append(out, diffBlock{diffSame, new[j]})
+			i++
+			j++
+		case lcs[i+1][j] >= lcs[i][j+1]:
+			out = append(out, diffBlock{diffRemoved, old[i]})
+			i++
+		default:
+			out = append(out, diffBlock{diffAdded, new[j]})
+			j++
+		}
+	}
+	for ; i < len(old); i++ {
+		out = append(out, diffBlock{diffRemoved, old[i]})
+	}
+	for ; j < len(new); j++ {
+		out = append(out, diffBlock{diffAdded, new[j]})
+	}
+	return out
+}
+
+// diffHandler serves /diff?file=README.md&from=HEAD&to=main, the rich diff of
+// a markdown file between two git revisions. Without to, the file of the
+// working tree is compared, without from, HEAD. Instead of from, old=other.md
+// compares against another file of the served directory.
+func (s *site) diffHandler(directory string, dir http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		q := r.URL.Query()
+		name := path.Clean("/" + q.Get("file"))
+		if !s.IsMarkdown(name) {
+			http.Error(w, "file must be a markdown file", http.StatusBadRequest)
+			return
+		}
+
+		oldName, from, to := name, q.Get("from"), q.Get("to")
+		if q.Has("old") {
+			oldName = path.Clean("/" + q.Get("old"))
+			from = ""
+		} else if from == "" {
+			from = "HEAD"
+		}
+		files := rootFS{hidden: s.hidden}
+		if !files.allowed(name) || !files.allowed(oldName) {
+			http.NotFound(w, r)
+			return
+		}
+
+		old, err := s.readRevision(directory, dir, oldName, from)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		new, err := s.readRevision(directory, dir, name, to)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		content, err := s.limitRender(func(ctx context.Context) ([]byte, error) {
+			p := *s.parser
+			p.ctx = ctx
+			return p.DiffHTML(old, new), nil
+		})
+		if err != nil {
+			s.serveRenderError(w, err)
+			return
+		}
+
+		label := func(file string, rev string) string {
+			if rev == "" {
+				rev = "working tree"
+			}
+			if file != name {
+				return strings.TrimPrefix(file, "/") + " (" + rev + ")"
+			}
+			return rev
+		}
+		summary := fmt.Sprintf(`<p class="grip-diff-summary">Comparing <code>%s</code> with <code>%s</code></p>`,
+			html.EscapeString(label(oldName, from)), html.EscapeString(label(name, to)))
+
+		var buf bytes.Buffer
+		diffPage := s.htmlPage(summary+"\n"+string(content), "Diff of "+path.Base(name))
+		diffPage.Reload = s.reload
+		diffPage.Transport = s.reloadTransport
+		if err := s.executeTemplate(&buf, diffPage); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "no-store")
+		w.Write(buf.Bytes())
+	})
+}
+
+// readRevision reads a file of the served directory as of a git revision, or
+// from dir if rev is empty. A file missing in the revision reads as empty, so
+// new files show up as added.
+func (s *Server) readRevision(directory string, dir http.FileSystem, name string, rev string) ([]byte, error) {
+	if rev == "" {
+		f, err := dir.Open(name)
+		if err != nil {
+			return nil, fmt.Errorf("failed to open %s: %v", strings.TrimPrefix(name, "/"), err)
+		}
+		defer f.Close()
+		return io.ReadAll(f)
+	}
+
+	if _, err := git(directory, "rev-parse", "--verify", rev+"^{commit}"); err != nil {
+		return nil, fmt.Errorf("unknown git ref %q: %v", rev, err)
+	}
+	content, err := git(directory, "show", rev+":."+name)
+	if err != nil {
+		return nil, nil
+	}
+	return content, nil
+}
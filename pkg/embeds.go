@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"fmt"
+	"html"
+	"net/url"
+	"regexp"
+	"strings"
+)
+
+// WithEmbeds shows links to YouTube videos and GitHub Gists, which are the
+// only content of their paragraph, as embedded players and snippets. They
+// load from the internet, so they are off by default.
+func WithEmbeds(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.embeds = enabled
+	}
+}
+
+var (
+	youtubeIDRegex = regexp.MustCompile(`^[\w-]{11}$`)
+	gistPathRegex  = regexp.MustCompile(`^/[\w-]+/[0-9a-f]+$`)
+	startRegex     = regexp.MustCompile(`^[0-9]+$`)
+)
+
+// embed returns the embedded player or snippet for a YouTube or Gist link,
+// or an empty string for other links.
+func embed(dest []byte) string {
+	u, err := url.Parse(string(dest))
+	if err != nil || (u.Scheme != "http" && u.Scheme != "https") {
+		return ""
+	}
+	host := strings.TrimPrefix(strings.ToLower(u.Host), "www.")
+	switch {
+	case host == "youtube.com" || host == "m.youtube.com" || host == "youtu.be":
+		id := u.Query().Get("v")
+		if host == "youtu.be" {
+			id = strings.TrimPrefix(u.Path, "/")
+		} else if rest, ok := strings.CutPrefix(u.Path, "/shorts/"); ok {
+			id = rest
+		}
+		if !youtubeIDRegex.MatchString(id) {
+			return ""
+		}
+		src := "https://www.youtube-nocookie.com/embed/" + id
+		if t := strings.TrimSuffix(u.Query().Get("t"), "s"); startRegex.MatchString(t) {
+			src += "?start=" + t
+		}
+		// a span, as the embed replaces a link inside a paragraph
+		return fmt.Sprintf(`<span class="grip-embed grip-youtube"><iframe src="%s" title="YouTube video" loading="lazy" allow="encrypted-media; picture-in-picture; fullscreen" allowfullscreen></iframe></span>`, html.EscapeString(src))
+	case host == "gist.github.com":
+		p := strings.TrimSuffix(u.Path, "/")
+		if !gistPathRegex.MatchString(p) {
+			return ""
+		}
+		// the gist script writes into the document it is loaded in, which
+		// only works while the page is parsed, so it gets its own document.
+		// It is sandboxed in its own origin and tells embeds.js its height.
+		doc := `<base target="_blank"><script src="https://gist.github.com` + p + `.js"></script>` + gistHeightScript
+		return fmt.Sprintf(`<iframe class="grip-embed grip-gist" srcdoc="%s" sandbox="allow-scripts allow-popups" title="Gist" loading="lazy"></iframe>`, html.EscapeString(doc))
+	}
+	return ""
+}
+
+// gistHeightScript posts the height of a gist to the page embedding it,
+// which can't read the document of the sandboxed frame.
+const gistHeightScript = `<script>
+function gripHeight() { parent.postMessage({ gripEmbedHeight: document.documentElement.scrollHeight }, "*"); }
+addEventListener("load", gripHeight);
+new ResizeObserver(gripHeight).observe(document.documentElement);
+</script>`
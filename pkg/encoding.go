@@ -0,0 +1,172 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"strings"
+	"unicode/utf16"
+	"unicode/utf8"
+)
+
+// Encoding is a text encoding of source files, which are converted to UTF-8
+// before rendering.
+type Encoding string
+
+const (
+	// EncodingAuto detects the encoding from a byte order mark, or else
+	// from the content: UTF-8 if it is valid, UTF-16 if it looks like it,
+	// UTF-8 with the invalid bytes replaced if it has any valid multi-byte
+	// sequence, Windows-1252 otherwise.
+	EncodingAuto        Encoding = "auto"
+	EncodingUTF8        Encoding = "utf-8"
+	EncodingUTF16LE     Encoding = "utf-16le"
+	EncodingUTF16BE     Encoding = "utf-16be"
+	EncodingLatin1      Encoding = "iso-8859-1"
+	EncodingWindows1252 Encoding = "windows-1252"
+)
+
+var encodingAliases = map[string]Encoding{
+	"":             EncodingAuto,
+	"auto":         EncodingAuto,
+	"utf-8":        EncodingUTF8,
+	"utf8":         EncodingUTF8,
+	"utf-16":       EncodingUTF16LE,
+	"utf-16le":     EncodingUTF16LE,
+	"utf16le":      EncodingUTF16LE,
+	"utf-16be":     EncodingUTF16BE,
+	"utf16be":      EncodingUTF16BE,
+	"iso-8859-1":   EncodingLatin1,
+	"latin1":       EncodingLatin1,
+	"latin-1":      EncodingLatin1,
+	"windows-1252": EncodingWindows1252,
+	"cp1252":       EncodingWindows1252,
+}
+
+// ParseEncoding returns the encoding with the name or one of its aliases,
+// like latin1 or cp1252.
+func ParseEncoding(name string) (Encoding, error) {
+	e, ok := encodingAliases[strings.ToLower(strings.TrimSpace(name))]
+	if !ok {
+		return "", fmt.Errorf("unknown encoding %q, expected auto, utf-8, utf-16le, utf-16be, iso-8859-1 or windows-1252", name)
+	}
+	return e, nil
+}
+
+// WithEncoding reads source files in the encoding instead of detecting it.
+func WithEncoding(e Encoding) Option {
+	return func(s *Server) {
+		s.encoding = e
+	}
+}
+
+var (
+	bomUTF8    = []byte{0xef, 0xbb, 0xbf}
+	bomUTF16LE = []byte{0xff, 0xfe}
+	bomUTF16BE = []byte{0xfe, 0xff}
+)
+
+// Decode converts content in the encoding to UTF-8 without byte order mark.
+func (e Encoding) Decode(content []byte) []byte {
+	if e == "" || e == EncodingAuto {
+		e = detectEncoding(content)
+	}
+	switch e {
+	case EncodingUTF16LE:
+		return decodeUTF16(bytes.TrimPrefix(content, bomUTF16LE), false)
+	case EncodingUTF16BE:
+		return decodeUTF16(bytes.TrimPrefix(content, bomUTF16BE), true)
+	case EncodingLatin1:
+		return decodeSingleByte(content, nil)
+	case EncodingWindows1252:
+		return decodeSingleByte(content, &windows1252)
+	}
+	content = bytes.TrimPrefix(content, bomUTF8)
+	if !utf8.Valid(content) {
+		content = bytes.ToValidUTF8(content, []byte("\uFFFD"))
+	}
+	return content
+}
+
+// detectEncoding guesses the encoding of content.
+func detectEncoding(content []byte) Encoding {
+	switch {
+	case bytes.HasPrefix(content, bomUTF8):
+		return EncodingUTF8
+	case bytes.HasPrefix(content, bomUTF16LE):
+		return EncodingUTF16LE
+	case bytes.HasPrefix(content, bomUTF16BE):
+		return EncodingUTF16BE
+	case utf8.Valid(content):
+		return EncodingUTF8
+	}
+
+	// ASCII text in UTF-16 has every other byte zero
+	var even, odd int
+	for i, b := range content {
+		if b != 0 {
+			continue
+		}
+		if i%2 == 0 {
+			even++
+		} else {
+			odd++
+		}
+	}
+	switch half := len(content) / 2; {
+	case len(content)%2 == 0 && odd > half/2 && even == 0:
+		return EncodingUTF16LE
+	case len(content)%2 == 0 && even > half/2 && odd == 0:
+		return EncodingUTF16BE
+	}
+	if hasMultiByteRune(content) {
+		return EncodingUTF8
+	}
+	return EncodingWindows1252
+}
+
+// hasMultiByteRune reports whether content has a valid multi-byte UTF-8
+// sequence, which is unlikely to occur by chance in Windows-1252 text.
+func hasMultiByteRune(content []byte) bool {
+	for len(content) > 0 {
+		r, size := utf8.DecodeRune(content)
+		if r != utf8.RuneError && size > 1 {
+			return true
+		}
+		content = content[size:]
+	}
+	return false
+}
+
+func decodeUTF16(content []byte, bigEndian bool) []byte {
+	units := make([]uint16, len(content)/2)
+	for i := range units {
+		if bigEndian {
+			units[i] = uint16(content[2*i])<<8 | uint16(content[2*i+1])
+		} else {
+			units[i] = uint16(content[2*i+1])<<8 | uint16(content[2*i])
+		}
+	}
+	return []byte(string(utf16.Decode(units)))
+}
+
+// decodeSingleByte decodes latin-1, or Windows-1252 with the characters of
+// the bytes 0x80 to 0x9f in high.
+func decodeSingleByte(content []byte, high *[32]rune) []byte {
+	var buf bytes.Buffer
+	buf.Grow(len(content) + len(content)/8)
+	for _, b := range content {
+		r := rune(b)
+		if high != nil && b >= 0x80 && b < 0xa0 {
+			r = high[b-0x80]
+		}
+		buf.WriteRune(r)
+	}
+	return buf.Bytes()
+}
+
+// windows1252 are the characters of the bytes 0x80 to 0x9f in Windows-1252.
+// Unassigned bytes keep their latin-1 control characters.
+var windows1252 = [32]rune{
+	'€', 0x81, '‚', 'ƒ', '„', '…', '†', '‡', 'ˆ', '‰', 'Š', '‹', 'Œ', 0x8d, 'Ž', 0x8f,
+	0x90, '‘', '’', '“', '”', '•', '–', '—', '˜', '™', 'š', '›', 'œ', 0x9d, 'ž', 'Ÿ',
+}
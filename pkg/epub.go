@@ -0,0 +1,452 @@
+package pkg
+
+import (
+	"archive/zip"
+	"bytes"
+	"crypto/rand"
+	"fmt"
+	"html"
+	"io"
+	"io/fs"
+	"log/slog"
+	"mime"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"text/template"
+	"time"
+
+	"github.com/chrishrb/go-grip/defaults"
+	xhtml "golang.org/x/net/html"
+	"golang.org/x/net/html/atom"
+)
+
+// epubChapter is a markdown file rendered into a chapter of an EPUB.
+type epubChapter struct {
+	ID    string
+	File  string
+	Title string
+	Body  string
+
+	source string
+}
+
+// epubResource is an image or stylesheet packaged into an EPUB.
+type epubResource struct {
+	ID        string
+	File      string
+	MediaType string
+	data      []byte
+}
+
+// epubBook collects the contents of an EPUB while chapters are rendered.
+type epubBook struct {
+	Title      string
+	Identifier string
+	Modified   string
+	Chapters   []*epubChapter
+	Resources  []*epubResource
+
+	// images maps the source of an image to its resource
+	images map[string]*epubResource
+	// chapters maps the absolute path of a markdown file to its chapter
+	chapters map[string]*epubChapter
+}
+
+// GenerateEPUB renders markdown files as the chapters of an EPUB 3 book at
+// outputPath, with the images they reference. The book is titled like its
+// first chapter unless title is set.
+func (s *Server) GenerateEPUB(files []string, outputPath string, title string) error {
+	if len(files) == 0 {
+		return fmt.Errorf("no markdown files to export")
+	}
+
+	id := make([]byte, 16)
+	if _, err := rand.Read(id); err != nil {
+		return err
+	}
+	b := &epubBook{
+		Title:      title,
+		Identifier: fmt.Sprintf("urn:uuid:%x-%x-%x-%x-%x", id[0:4], id[4:6], id[6:8], id[8:10], id[10:]),
+		Modified:   time.Now().UTC().Format("2006-01-02T15:04:05Z"),
+		images:     make(map[string]*epubResource),
+		chapters:   make(map[string]*epubChapter),
+	}
+
+	// chapters are numbered first, so links between them can be resolved
+	contents := make([][]byte, len(files))
+	for i, file := range files {
+		abs, err := filepath.Abs(file)
+		if err != nil {
+			return fmt.Errorf("failed to get absolute path: %v", err)
+		}
+		content, err := os.ReadFile(abs)
+		if err != nil {
+			return fmt.Errorf("failed to read file %s: %v", file, err)
+		}
+		content, _ = s.embedFiles(http.Dir(filepath.Dir(abs)), filepath.Base(abs), content)
+		contents[i] = content
+
+		ch := &epubChapter{
+			ID:     fmt.Sprintf("chapter-%d", i+1),
+			File:   fmt.Sprintf("chapter-%d.xhtml", i+1),
+			Title:  extractTitle(content, filepath.Base(abs)),
+			source: abs,
+		}
+		b.Chapters = append(b.Chapters, ch)
+		b.chapters[abs] = ch
+	}
+	if b.Title == "" {
+		b.Title = b.Chapters[0].Title
+	}
+
+	p := *s.parser
+	p.sourceLines = false
+	for i, ch := range b.Chapters {
+		_, content := parseFrontMatter(contents[i])
+		body, err := b.xhtml(p.MdToHTML(content), filepath.Dir(ch.source))
+		if err != nil {
+			return fmt.Errorf("failed to convert %s: %v", ch.source, err)
+		}
+		ch.Body = body
+	}
+
+	css, err := fs.ReadFile(defaults.StaticFiles, "static/css/github-markdown-light.css")
+	if err != nil {
+		return err
+	}
+	css = append(css, "\n"+getCssCode("github")...)
+	b.Resources = append(b.Resources, &epubResource{ID: "style", File: "style.css", MediaType: "text/css", data: css})
+
+	out, err := os.Create(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to create file: %v", err)
+	}
+	defer out.Close()
+	if err := b.write(out); err != nil {
+		return fmt.Errorf("failed to write EPUB: %v", err)
+	}
+
+	slog.Info("generated EPUB file", "path", outputPath)
+	return nil
+}
+
+// xhtml turns rendered HTML into the XHTML body of a chapter. Scripts are
+// dropped, images are packaged into the book and links to other chapters
+// point to their files.
+func (b *epubBook) xhtml(rendered []byte, dir string) (string, error) {
+	body := &xhtml.Node{Type: xhtml.ElementNode, Data: "body", DataAtom: atom.Body}
+	nodes, err := xhtml.ParseFragment(bytes.NewReader(rendered), body)
+	if err != nil {
+		return "", err
+	}
+	for _, n := range nodes {
+		body.AppendChild(n)
+	}
+	b.rewrite(body, dir)
+
+	var buf bytes.Buffer
+	for c := body.FirstChild; c != nil; c = c.NextSibling {
+		if err := xhtml.Render(&buf, c); err != nil {
+			return "", err
+		}
+	}
+	return buf.String(), nil
+}
+
+func (b *epubBook) rewrite(n *xhtml.Node, dir string) {
+	for c := n.FirstChild; c != nil; {
+		next := c.NextSibling
+		if c.Type == xhtml.ElementNode {
+			switch {
+			case c.DataAtom == atom.Script:
+				n.RemoveChild(c)
+				c = next
+				continue
+			case c.DataAtom == atom.Img:
+				if !b.packImage(c, dir) {
+					// an image the reader could not show
+					alt := &xhtml.Node{Type: xhtml.TextNode, Data: attr(c, "alt")}
+					n.InsertBefore(alt, c)
+					n.RemoveChild(c)
+					c = next
+					continue
+				}
+			case c.DataAtom == atom.A:
+				b.rewriteLink(c, dir)
+			case c.Namespace == "svg" && n.Namespace != "svg":
+				setAttr(c, "xmlns", "http://www.w3.org/2000/svg")
+			case c.Namespace == "math" && n.Namespace != "math":
+				setAttr(c, "xmlns", "http://www.w3.org/1998/Math/MathML")
+			}
+		}
+		b.rewrite(c, dir)
+		c = next
+	}
+}
+
+// packImage adds the image of an img element to the book, reporting false
+// if it could not be read.
+func (b *epubBook) packImage(img *xhtml.Node, dir string) bool {
+	src := attr(img, "src")
+	if strings.HasPrefix(src, "data:") {
+		return true
+	}
+
+	var key string
+	var read func() ([]byte, error)
+	switch u, err := url.Parse(src); {
+	case err != nil || src == "":
+		return false
+	case u.Scheme == "http" || u.Scheme == "https":
+		key = src
+		read = func() ([]byte, error) { return fetchImage(src) }
+	case u.Scheme != "":
+		return false
+	case strings.HasPrefix(u.Path, "/static/"):
+		key = "embedded:" + u.Path
+		read = func() ([]byte, error) {
+			_, fingerprinted := assets()
+			if a, ok := fingerprinted[strings.TrimPrefix(u.Path, "/")]; ok {
+				return a.content, nil
+			}
+			return fs.ReadFile(defaults.StaticFiles, strings.TrimPrefix(u.Path, "/"))
+		}
+	default:
+		file := filepath.Join(dir, filepath.FromSlash(u.Path))
+		key = file
+		read = func() ([]byte, error) { return os.ReadFile(file) }
+	}
+
+	res, ok := b.images[key]
+	if !ok {
+		data, err := read()
+		if err != nil {
+			slog.Warn("failed to add image to EPUB", "src", src, "err", err)
+			return false
+		}
+		ext := strings.ToLower(path.Ext(strings.SplitN(path.Base(src), "?", 2)[0]))
+		mediaType := mime.TypeByExtension(ext)
+		if !strings.HasPrefix(mediaType, "image/") {
+			slog.Warn("failed to add image to EPUB", "src", src, "err", "unknown image type")
+			return false
+		}
+		n := len(b.images) + 1
+		res = &epubResource{
+			ID:        fmt.Sprintf("image-%d", n),
+			File:      fmt.Sprintf("images/image-%d%s", n, ext),
+			MediaType: strings.SplitN(mediaType, ";", 2)[0],
+			data:      data,
+		}
+		b.images[key] = res
+		b.Resources = append(b.Resources, res)
+	}
+	setAttr(img, "src", res.File)
+	return true
+}
+
+// rewriteLink points links to markdown files of the book to their chapter.
+// Other local links can't be followed in a book and are dropped.
+func (b *epubBook) rewriteLink(a *xhtml.Node, dir string) {
+	href := attr(a, "href")
+	u, err := url.Parse(href)
+	if err != nil || u.Scheme != "" || u.Host != "" || u.Path == "" {
+		return
+	}
+	if ch, ok := b.chapters[filepath.Join(dir, filepath.FromSlash(u.Path))]; ok {
+		target := ch.File
+		if u.Fragment != "" {
+			target += "#" + u.Fragment
+		}
+		setAttr(a, "href", target)
+		return
+	}
+	removeAttr(a, "href")
+}
+
+func fetchImage(src string) ([]byte, error) {
+	resp, err := httpClient.Get(src)
+	if err != nil {
+		return nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != 200 {
+		return nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	return io.ReadAll(io.LimitReader(resp.Body, maxProxiedImageSize))
+}
+
+func attr(n *xhtml.Node, key string) string {
+	for _, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			return a.Val
+		}
+	}
+	return ""
+}
+
+func setAttr(n *xhtml.Node, key string, val string) {
+	for i, a := range n.Attr {
+		if a.Key == key && a.Namespace == "" {
+			n.Attr[i].Val = val
+			return
+		}
+	}
+	n.Attr = append(n.Attr, xhtml.Attribute{Key: key, Val: val})
+}
+
+func removeAttr(n *xhtml.Node, key string) {
+	attrs := n.Attr[:0]
+	for _, a := range n.Attr {
+		if a.Key != key || a.Namespace != "" {
+			attrs = append(attrs, a)
+		}
+	}
+	n.Attr = attrs
+}
+
+var epubTemplates = template.Must(template.New("").Funcs(template.FuncMap{
+	"xml": html.EscapeString,
+	"inc": func(i int) int { return i + 1 },
+}).Parse(`
+{{- define "container.xml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<container version="1.0" xmlns="urn:oasis:names:tc:opendocument:xmlns:container">
+  <rootfiles>
+    <rootfile full-path="OEBPS/content.opf" media-type="application/oebps-package+xml"/>
+  </rootfiles>
+</container>
+{{end}}
+
+{{- define "content.opf" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<package xmlns="http://www.idpf.org/2007/opf" version="3.0" unique-identifier="book-id">
+  <metadata xmlns:dc="http://purl.org/dc/elements/1.1/">
+    <dc:identifier id="book-id">{{.Identifier}}</dc:identifier>
+    <dc:title>{{xml .Title}}</dc:title>
+    <dc:language>en</dc:language>
+    <meta property="dcterms:modified">{{.Modified}}</meta>
+  </metadata>
+  <manifest>
+    <item id="nav" href="nav.xhtml" media-type="application/xhtml+xml" properties="nav"/>
+    <item id="ncx" href="toc.ncx" media-type="application/x-dtbncx+xml"/>
+    {{- range .Chapters}}
+    <item id="{{.ID}}" href="{{.File}}" media-type="application/xhtml+xml"/>
+    {{- end}}
+    {{- range .Resources}}
+    <item id="{{.ID}}" href="{{.File}}" media-type="{{.MediaType}}"/>
+    {{- end}}
+  </manifest>
+  <spine toc="ncx">
+    {{- range .Chapters}}
+    <itemref idref="{{.ID}}"/>
+    {{- end}}
+  </spine>
+</package>
+{{end}}
+
+{{- define "nav.xhtml" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{xml .Title}}</title>
+</head>
+<body>
+  <nav epub:type="toc" id="toc">
+    <h1>{{xml .Title}}</h1>
+    <ol>
+      {{- range .Chapters}}
+      <li><a href="{{.File}}">{{xml .Title}}</a></li>
+      {{- end}}
+    </ol>
+  </nav>
+</body>
+</html>
+{{end}}
+
+{{- define "toc.ncx" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<ncx xmlns="http://www.daisy.org/z3986/2005/ncx/" version="2005-1">
+  <head>
+    <meta name="dtb:uid" content="{{.Identifier}}"/>
+  </head>
+  <docTitle><text>{{xml .Title}}</text></docTitle>
+  <navMap>
+    {{- range $i, $ch := .Chapters}}
+    <navPoint id="nav-{{$ch.ID}}" playOrder="{{inc $i}}">
+      <navLabel><text>{{xml $ch.Title}}</text></navLabel>
+      <content src="{{$ch.File}}"/>
+    </navPoint>
+    {{- end}}
+  </navMap>
+</ncx>
+{{end}}
+
+{{- define "chapter" -}}
+<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE html>
+<html xmlns="http://www.w3.org/1999/xhtml" xmlns:epub="http://www.idpf.org/2007/ops" lang="en" xml:lang="en">
+<head>
+  <meta charset="UTF-8"/>
+  <title>{{xml .Title}}</title>
+  <link rel="stylesheet" type="text/css" href="style.css"/>
+</head>
+<body class="markdown-body">
+{{.Body}}
+</body>
+</html>
+{{end}}
+`))
+
+// write packages the book. The uncompressed mimetype entry has to come
+// first, so readers can detect the format.
+func (b *epubBook) write(w io.Writer) error {
+	zw := zip.NewWriter(w)
+	mimetype, err := zw.CreateHeader(&zip.FileHeader{Name: "mimetype", Method: zip.Store})
+	if err != nil {
+		return err
+	}
+	if _, err := io.WriteString(mimetype, "application/epub+zip"); err != nil {
+		return err
+	}
+
+	add := func(name string, tmpl string, data any) error {
+		f, err := zw.Create(name)
+		if err != nil {
+			return err
+		}
+		return epubTemplates.ExecuteTemplate(f, tmpl, data)
+	}
+	if err := add("META-INF/container.xml", "container.xml", b); err != nil {
+		return err
+	}
+	if err := add("OEBPS/content.opf", "content.opf", b); err != nil {
+		return err
+	}
+	if err := add("OEBPS/nav.xhtml", "nav.xhtml", b); err != nil {
+		return err
+	}
+	if err := add("OEBPS/toc.ncx", "toc.ncx", b); err != nil {
+		return err
+	}
+	for _, ch := range b.Chapters {
+		if err := add("OEBPS/"+ch.File, "chapter", ch); err != nil {
+			return err
+		}
+	}
+	for _, res := range b.Resources {
+		f, err := zw.Create("OEBPS/" + res.File)
+		if err != nil {
+			return err
+		}
+		if _, err := f.Write(res.data); err != nil {
+			return err
+		}
+	}
+	return zw.Close()
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"archive/zip"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateEPUB(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"intro.md": "# Introduction\n\nSee [usage](usage.md) and [elsewhere](other.txt).\n",
+		"usage.md": "# Usage & Setup\n\nRun it.\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	output := filepath.Join(dir, "book.epub")
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"))
+	if err := s.GenerateEPUB([]string{filepath.Join(dir, "intro.md"), filepath.Join(dir, "usage.md")}, output, ""); err != nil {
+		t.Fatal(err)
+	}
+
+	zr, err := zip.OpenReader(output)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer zr.Close()
+	if first := zr.File[0]; first.Name != "mimetype" || first.Method != zip.Store {
+		t.Errorf("got first entry %s with method %d, want the stored mimetype", first.Name, first.Method)
+	}
+	entries := make(map[string]string)
+	for _, f := range zr.File {
+		r, err := f.Open()
+		if err != nil {
+			t.Fatal(err)
+		}
+		data, err := io.ReadAll(r)
+		r.Close()
+		if err != nil {
+			t.Fatal(err)
+		}
+		entries[f.Name] = string(data)
+	}
+
+	for name, want := range map[string][]string{
+		"OEBPS/content.opf": {
+			"<dc:title>Introduction</dc:title>",
+			`<item id="chapter-1" href="chapter-1.xhtml" media-type="application/xhtml+xml"/>`,
+			`<item id="chapter-2" href="chapter-2.xhtml" media-type="application/xhtml+xml"/>`,
+			`<item id="style" href="style.css" media-type="text/css"/>`,
+			"<itemref idref=\"chapter-1\"/>\n    <itemref idref=\"chapter-2\"/>",
+		},
+		"OEBPS/nav.xhtml": {
+			"<li><a href=\"chapter-1.xhtml\">Introduction</a></li>\n      <li><a href=\"chapter-2.xhtml\">Usage &amp; Setup</a></li>",
+		},
+		"OEBPS/toc.ncx": {
+			`<navPoint id="nav-chapter-2" playOrder="2">`,
+		},
+		"OEBPS/chapter-1.xhtml": {
+			`<a href="chapter-2.xhtml">usage</a>`,
+			"elsewhere",
+		},
+	} {
+		for _, w := range want {
+			if !strings.Contains(entries[name], w) {
+				t.Errorf("%s: expected %q in:\n%s", name, w, entries[name])
+			}
+		}
+	}
+	if strings.Contains(entries["OEBPS/chapter-1.xhtml"], "other.txt") {
+		t.Errorf("expected the link to a file outside the book to be dropped")
+	}
+}
@@ -0,0 +1,163 @@
+package pkg
+
+import (
+	"errors"
+	"html"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strings"
+)
+
+// maxSuggestions is the number of nearby files a not found page suggests.
+const maxSuggestions = 5
+
+// serveNotFound answers a request for a missing page with a page of the
+// layout naming the path, suggesting the files nearest to it and linking
+// back to the index.
+func (s *Server) serveNotFound(w http.ResponseWriter, dir http.FileSystem, name string) {
+	var sb strings.Builder
+	sb.WriteString(`<p>` + html.EscapeString(s.msg("The file %s doesn't exist.", name)) + `</p>` + "\n")
+	if files := nearbyFiles(dir, name, maxSuggestions); len(files) > 0 {
+		sb.WriteString(`<p>` + html.EscapeString(s.msg("Nearby files:")) + `</p>` + "\n<ul>\n")
+		for _, f := range files {
+			href := (&url.URL{Path: f}).EscapedPath()
+			sb.WriteString(`<li><a href="` + html.EscapeString(href) + `">` + html.EscapeString(path.Base(f)) + `</a></li>` + "\n")
+		}
+		sb.WriteString("</ul>\n")
+	}
+	s.serveErrorPage(w, http.StatusNotFound, s.msg("Page not found"), sb.String())
+}
+
+// serveRenderError answers a request for a page that failed to render with a
+// page of the layout showing the error, see renderError for the status.
+func (s *site) serveRenderError(w http.ResponseWriter, err error) {
+	status := renderErrorStatus(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	body := `<pre class="grip-error-detail">` + html.EscapeString(s.errorDetail(err)) + `</pre>` + "\n"
+	s.serveErrorPage(w, status, s.msg("This page failed to render"), body)
+}
+
+// errorDetail returns the message of err with the paths of files in the
+// served directory relative to it, so error pages don't tell where the
+// directory is on the host.
+func (s *site) errorDetail(err error) string {
+	msg := err.Error()
+	for _, dir := range s.dirs {
+		if filepath.Dir(dir) == dir {
+			// everything is in the root directory of the file system
+			continue
+		}
+		sep := regexp.QuoteMeta(string(filepath.Separator))
+		re := regexp.MustCompile(regexp.QuoteMeta(dir) + `(` + sep + `|\b|$)`)
+		msg = re.ReplaceAllStringFunc(msg, func(m string) string {
+			if m == dir {
+				return "."
+			}
+			return ""
+		})
+	}
+	return msg
+}
+
+// serveErrorPage answers with status and a page of the layout titled title,
+// falling back to a plain text error if the layout fails as well.
+func (s *Server) serveErrorPage(w http.ResponseWriter, status int, title string, body string) {
+	content := `<div class="grip-error-page">` + "\n" +
+		`<h1>` + html.EscapeString(title) + `</h1>` + "\n" + body +
+		`<p><a href="/">` + html.EscapeString(s.msg("Back to the index")) + `</a></p>` + "\n</div>\n"
+	page, err := s.layoutPage([]byte(content), title, pageNav{})
+	if err != nil {
+		http.Error(w, title, status)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
+
+// wantsErrorPage reports whether a failed request should be answered with
+// an error page: browsers navigating to a page missing in dir, but not
+// scripts or images loading from it.
+func wantsErrorPage(r *http.Request, err error) bool {
+	return errors.Is(err, fs.ErrNotExist) && r.Method == http.MethodGet &&
+		strings.Contains(r.Header.Get("Accept"), "text/html") && !strings.HasPrefix(r.URL.Path, "/static/")
+}
+
+// nearbyFiles returns up to n files and directories of the closest existing
+// parent directory of name, the ones with the most similar names first.
+func nearbyFiles(dir http.FileSystem, name string, n int) []string {
+	base := strings.ToLower(path.Base(name))
+	parent := path.Dir(path.Clean("/" + name))
+	for {
+		if infos, err := readDir(dir, parent); err == nil {
+			type candidate struct {
+				path     string
+				distance int
+			}
+			var candidates []candidate
+			for _, info := range infos {
+				if strings.HasPrefix(info.Name(), ".") {
+					continue
+				}
+				p := path.Join(parent, info.Name())
+				if info.IsDir() {
+					p += "/"
+				}
+				candidates = append(candidates, candidate{p, editDistance(base, strings.ToLower(info.Name()))})
+			}
+			sort.SliceStable(candidates, func(i, j int) bool {
+				if candidates[i].distance != candidates[j].distance {
+					return candidates[i].distance < candidates[j].distance
+				}
+				return candidates[i].path < candidates[j].path
+			})
+			var files []string
+			for _, c := range candidates[:min(n, len(candidates))] {
+				files = append(files, c.path)
+			}
+			return files
+		}
+		if parent == "/" {
+			return nil
+		}
+		parent = path.Dir(parent)
+	}
+}
+
+func readDir(dir http.FileSystem, name string) ([]fs.FileInfo, error) {
+	f, err := dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return f.Readdir(-1)
+}
+
+// editDistance is the Levenshtein distance of a and b.
+func editDistance(a, b string) int {
+	ra, rb := []rune(a), []rune(b)
+	prev := make([]int, len(rb)+1)
+	for j := range prev {
+		prev[j] = j
+	}
+	for i := 1; i <= len(ra); i++ {
+		cur := make([]int, len(rb)+1)
+		cur[0] = i
+		for j := 1; j <= len(rb); j++ {
+			cost := 1
+			if ra[i-1] == rb[j-1] {
+				cost = 0
+			}
+			cur[j] = min(prev[j]+1, cur[j-1]+1, prev[j-1]+cost)
+		}
+		prev = cur
+	}
+	return prev[len(rb)]
+}
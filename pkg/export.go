@@ -0,0 +1,349 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"slices"
+	"strings"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+)
+
+// siteExport exports the markdown files of a directory, or a single one, to
+// static HTML pages, remembering the files each page embeds, so pages can be
+// regenerated when one of them changes.
+type siteExport struct {
+	s      *Server
+	dir    string
+	output string
+	// single is the name of the only exported file, "" to export the
+	// whole directory
+	single string
+	// varsFile is the absolute path of the file of WithVars, all pages
+	// are regenerated when it changes
+	varsFile string
+	// bibliography is the absolute path of the file of WithBibliography,
+	// all pages are regenerated when it changes
+	bibliography string
+	// exclude are the patterns of WithReloadExclude and the caches of the
+	// server, see reloadExcludes
+	exclude []string
+
+	pages    map[string]indexEntry
+	manifest map[string]ManifestPage
+	deps     map[string][]string
+}
+
+func (s *Server) newSiteExport(dirPath string, outputDir string, single string) (*siteExport, error) {
+	absDirPath, err := filepath.Abs(dirPath)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return nil, fmt.Errorf("failed to get absolute path: %v", err)
+	}
+	e := &siteExport{
+		s:        s,
+		dir:      absDirPath,
+		output:   absOutputDir,
+		single:   single,
+		pages:    make(map[string]indexEntry),
+		manifest: make(map[string]ManifestPage),
+		deps:     make(map[string][]string),
+		exclude:  s.reloadExcludes(),
+	}
+	if s.vars && s.varsFile != "" {
+		if e.varsFile, err = filepath.Abs(s.varsFile); err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+	}
+	if s.parser.bibliography != nil {
+		if e.bibliography, err = filepath.Abs(s.parser.bibliography.file); err != nil {
+			return nil, fmt.Errorf("failed to get absolute path: %v", err)
+		}
+	}
+	return e, nil
+}
+
+// exportAll exports all pages, the static files and the index of the
+// directory if it has no README.
+func (e *siteExport) exportAll() error {
+	if err := os.MkdirAll(e.output, 0755); err != nil {
+		return fmt.Errorf("failed to create output directory: %v", err)
+	}
+	staticDir := filepath.Join(e.output, "static")
+	if err := os.MkdirAll(staticDir, 0755); err != nil {
+		return fmt.Errorf("failed to create static directory: %v", err)
+	}
+	if err := copyStaticFiles(staticDir); err != nil {
+		return fmt.Errorf("failed to copy static files: %v", err)
+	}
+
+	if e.single != "" {
+		return e.exportPage(e.single)
+	}
+
+	entries, err := os.ReadDir(e.dir)
+	if err != nil {
+		return fmt.Errorf("failed to read directory: %v", err)
+	}
+	foundMarkdown := false
+	for _, entry := range entries {
+		if entry.IsDir() || !e.s.IsMarkdown(entry.Name()) {
+			continue
+		}
+		foundMarkdown = true
+		if err := e.exportPage(entry.Name()); err != nil {
+			return err
+		}
+	}
+	if !foundMarkdown {
+		return fmt.Errorf("no markdown files found in directory %s", e.dir)
+	}
+	if err := e.writeIndex(); err != nil {
+		return err
+	}
+	return e.writeManifest()
+}
+
+// exportFileName returns the name of the HTML page of a markdown file.
+func exportFileName(name string) string {
+	if name == "README.md" {
+		return "index.html"
+	}
+	return strings.TrimSuffix(name, filepath.Ext(name)) + ".html"
+}
+
+// exportPage renders the markdown file name of the directory into its page.
+// Drafts are skipped in directories unless WithDrafts is set.
+func (e *siteExport) exportPage(name string) error {
+	mdFilePath := filepath.Join(e.dir, name)
+	content, err := os.ReadFile(mdFilePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %v", mdFilePath, err)
+	}
+	fm, _ := parseFrontMatter(content)
+	if fm.Draft && !e.s.drafts && e.single == "" {
+		slog.Info("skipping draft", "path", mdFilePath)
+		return e.removePage(name)
+	}
+	raw := content
+	content, _, embedded := e.s.embed(http.Dir(e.dir), name, content)
+
+	title := extractTitle(content, name)
+	htmlFile := exportFileName(name)
+	outputFilePath := filepath.Join(e.output, htmlFile)
+	html := e.s.htmlPage(string(e.s.parser.MdToHTML(content)), title)
+	if err := e.s.writeHTMLFile(outputFilePath, html); err != nil {
+		return fmt.Errorf("failed to write HTML file %s: %v", outputFilePath, err)
+	}
+	slog.Info("generated HTML file", "path", outputFilePath)
+
+	e.pages[name] = indexEntry{file: htmlFile, title: title, order: fm.Order}
+	if e.single == "" {
+		page, err := e.s.manifestPage(name, raw, content, e.output, htmlFile, title, fm.Order)
+		if err != nil {
+			return err
+		}
+		e.manifest[name] = page
+	}
+	var deps []string
+	for _, f := range embedded {
+		deps = append(deps, filepath.Join(e.dir, filepath.FromSlash(f)))
+	}
+	e.deps[name] = deps
+	return nil
+}
+
+// removePage removes the page of the markdown file name, if it was
+// exported.
+func (e *siteExport) removePage(name string) error {
+	if _, ok := e.pages[name]; !ok {
+		return nil
+	}
+	delete(e.pages, name)
+	delete(e.manifest, name)
+	delete(e.deps, name)
+	outputFilePath := filepath.Join(e.output, exportFileName(name))
+	if err := os.Remove(outputFilePath); err != nil && !errors.Is(err, fs.ErrNotExist) {
+		return fmt.Errorf("failed to remove HTML file %s: %v", outputFilePath, err)
+	}
+	slog.Info("removed HTML file", "path", outputFilePath)
+	return nil
+}
+
+// writeIndex generates the index page listing the pages of a directory
+// without README.
+func (e *siteExport) writeIndex() error {
+	if e.single != "" {
+		return nil
+	}
+	if _, ok := e.pages["README.md"]; ok {
+		return nil
+	}
+
+	var entries []indexEntry
+	for _, entry := range e.pages {
+		entries = append(entries, entry)
+	}
+	dirName := filepath.Base(e.dir)
+	html := e.s.htmlPage(e.s.generateDirectoryIndex(dirName, entries), dirName)
+	indexPath := filepath.Join(e.output, "index.html")
+	if err := e.s.writeHTMLFile(indexPath, html); err != nil {
+		return fmt.Errorf("failed to write index file: %v", err)
+	}
+	slog.Info("generated index file", "path", indexPath)
+	return nil
+}
+
+// writeManifest writes the ManifestFile listing the pages of a directory.
+func (e *siteExport) writeManifest() error {
+	if e.single != "" {
+		return nil
+	}
+	var pages []ManifestPage
+	for _, page := range e.manifest {
+		pages = append(pages, page)
+	}
+	return writeManifest(e.output, pages)
+}
+
+// WatchExport exports the markdown files of the directory at input, or the
+// file at input, to static HTML pages in outputDir like
+// GenerateDirectoryFiles, then regenerates the pages of the files that change,
+// or embed files that change, until ctx is cancelled.
+func (s *Server) WatchExport(ctx context.Context, input string, outputDir string) error {
+	info, err := os.Stat(input)
+	if err != nil {
+		return fmt.Errorf("file not found: %s - %v", input, err)
+	}
+	dir, single := input, ""
+	if !info.IsDir() {
+		if !s.IsMarkdown(input) {
+			return fmt.Errorf("file '%s' must be a markdown file", input)
+		}
+		dir, single = filepath.Dir(input), filepath.Base(input)
+	}
+	e, err := s.newSiteExport(dir, outputDir, single)
+	if err != nil {
+		return err
+	}
+	if err := e.exportAll(); err != nil {
+		return err
+	}
+	slog.Info("output directory", "path", e.output)
+
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+	// the pages written to an output directory inside the exported one are
+	// no changes
+	skip := func(file string) bool {
+		return within(e.output, file) || reloadExcluded(e.dir, e.exclude, file)
+	}
+	if err := addRecursive(w, e.dir, skip); err != nil {
+		return err
+	}
+	for _, deps := range e.deps {
+		for _, dep := range deps {
+			addDependency(w, dep)
+		}
+	}
+	if e.varsFile != "" {
+		addDependency(w, e.varsFile)
+	}
+	if e.bibliography != "" {
+		addDependency(w, e.bibliography)
+	}
+	slog.Info("watching for changes", "path", e.dir)
+
+	debounce := s.reloadDebounce
+	if debounce <= 0 {
+		debounce = DefaultReloadDebounce
+	}
+	changed := make(map[string]bool)
+	timer := time.NewTimer(0)
+	<-timer.C
+	for {
+		select {
+		case <-ctx.Done():
+			return nil
+		case err, ok := <-w.Errors:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			// pages keep being regenerated for the changes still reported
+			slog.Error("file watcher error", "err", err)
+		case ev, ok := <-w.Events:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			if ev.Has(fsnotify.Create) {
+				if err := addRecursive(w, ev.Name, skip); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					slog.Error("failed to watch directory", "path", ev.Name, "err", err)
+				}
+			}
+			if temporaryFile(ev.Name) || skip(ev.Name) {
+				continue
+			}
+			changed[filepath.Clean(ev.Name)] = true
+			timer.Reset(debounce)
+		case <-timer.C:
+			for _, dep := range e.update(changed) {
+				addDependency(w, dep)
+			}
+			clear(changed)
+		}
+	}
+}
+
+// update regenerates the pages of the changed files and the pages embedding
+// them, returning the files the regenerated pages embed. Errors are logged,
+// so a broken page doesn't stop the export.
+func (e *siteExport) update(changed map[string]bool) []string {
+	regenerate := make(map[string]bool)
+	for file := range changed {
+		if filepath.Dir(file) == e.dir && e.s.IsMarkdown(file) && (e.single == "" || filepath.Base(file) == e.single) {
+			regenerate[filepath.Base(file)] = true
+		}
+		for name, deps := range e.deps {
+			if slices.Contains(deps, file) || file == e.varsFile || file == e.bibliography {
+				regenerate[name] = true
+			}
+		}
+	}
+
+	var deps []string
+	for name := range regenerate {
+		var err error
+		if _, statErr := os.Stat(filepath.Join(e.dir, name)); errors.Is(statErr, fs.ErrNotExist) {
+			err = e.removePage(name)
+		} else {
+			err = e.exportPage(name)
+			deps = append(deps, e.deps[name]...)
+		}
+		if err != nil {
+			slog.Error("failed to export page", "path", name, "err", err)
+		}
+	}
+	if len(regenerate) > 0 {
+		// titles and order of the index may have changed
+		if err := e.writeIndex(); err != nil {
+			slog.Error("failed to export index", "err", err)
+		}
+		if err := e.writeManifest(); err != nil {
+			slog.Error("failed to export manifest", "err", err)
+		}
+	}
+	return deps
+}
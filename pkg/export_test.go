@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"encoding/json"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestSiteExportUpdate(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("a.md", "# A\n\n<!-- include: part.md -->\n")
+	write("b.md", "# B\n")
+	write("part.md", "part one\n")
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"), WithIncludes(true))
+	e, err := s.newSiteExport(dir, filepath.Join(dir, "site"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.exportAll(); err != nil {
+		t.Fatal(err)
+	}
+
+	write("part.md", "part two\n")
+	os.Remove(filepath.Join(dir, "b.md"))
+	e.update(map[string]bool{filepath.Join(dir, "part.md"): true, filepath.Join(dir, "b.md"): true})
+
+	page, err := os.ReadFile(filepath.Join(dir, "site", "a.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(page), "part two") {
+		t.Error("page including the changed file was not regenerated")
+	}
+	if _, err := os.Stat(filepath.Join(dir, "site", "b.html")); !os.IsNotExist(err) {
+		t.Errorf("page of the removed file was kept: %v", err)
+	}
+	index, err := os.ReadFile(filepath.Join(dir, "site", "index.html"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Contains(string(index), "b.html") {
+		t.Error("index lists the removed page")
+	}
+}
+
+func TestExportManifest(t *testing.T) {
+	dir := t.TempDir()
+	write := func(name string, content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("b.md", "# B\n\n## Usage\n")
+	write("a.md", "---\norder: 2\n---\n# A\n")
+	write("c.md", "---\norder: 1\ntitle: Intro\n---\ntext\n")
+
+	readManifest := func(output string) Manifest {
+		t.Helper()
+		data, err := os.ReadFile(filepath.Join(output, ManifestFile))
+		if err != nil {
+			t.Fatal(err)
+		}
+		var m Manifest
+		if err := json.Unmarshal(data, &m); err != nil {
+			t.Fatal(err)
+		}
+		return m
+	}
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"))
+	output := filepath.Join(t.TempDir(), "site")
+	if err := s.GenerateDirectoryFiles(dir, output); err != nil {
+		t.Fatal(err)
+	}
+	m := readManifest(output)
+	var order []string
+	for _, p := range m.Pages {
+		order = append(order, p.Source+"="+p.Output+":"+p.Title)
+	}
+	if got, want := strings.Join(order, " "), "c.md=c.html:Intro a.md=a.html:A b.md=b.html:B"; got != want {
+		t.Errorf("pages = %q, want %q", got, want)
+	}
+	b := m.Pages[2]
+	source, _ := os.ReadFile(filepath.Join(dir, "b.md"))
+	page, _ := os.ReadFile(filepath.Join(output, "b.html"))
+	if b.SourceChecksum != checksum(source) || b.Checksum != checksum(page) {
+		t.Errorf("unexpected checksums %+v", b)
+	}
+	if len(b.Outline) != 1 || b.Outline[0].Slug != "b" || len(b.Outline[0].Children) != 1 || b.Outline[0].Children[0].Text != "Usage" {
+		t.Errorf("unexpected outline %+v", b.Outline)
+	}
+
+	// the watched export keeps the manifest up to date
+	e, err := s.newSiteExport(dir, filepath.Join(dir, "watched"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := e.exportAll(); err != nil {
+		t.Fatal(err)
+	}
+	write("b.md", "# B\n")
+	os.Remove(filepath.Join(dir, "a.md"))
+	e.update(map[string]bool{filepath.Join(dir, "b.md"): true, filepath.Join(dir, "a.md"): true})
+	m = readManifest(e.output)
+	if len(m.Pages) != 2 || m.Pages[1].Source != "b.md" || len(m.Pages[1].Outline[0].Children) != 0 {
+		t.Errorf("manifest was not updated: %+v", m.Pages)
+	}
+}
@@ -0,0 +1,270 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+)
+
+// normalizeFences rewrites the fenced code blocks the markdown parser reads
+// differently than GitHub into a form it reads the same way:
+//
+//   - fences in list items are indented four spaces per list level, with a
+//     blank line before them, since the parser only continues list items
+//     with indented blocks after blank lines
+//   - the indentation of indented fences is removed from their lines
+//   - closing fences longer than the opening one are shortened to it
+//   - fences left open are closed at the end of their list item or the
+//     document
+//
+// Fences the parser already reads like GitHub are kept, and md is returned
+// as is if there are none, so the parsed blocks still point into it.
+func normalizeFences(md []byte) []byte {
+	if !bytes.Contains(md, []byte("```")) && !bytes.Contains(md, []byte("~~~")) {
+		return md
+	}
+	lines := bytes.SplitAfter(md, []byte("\n"))
+	var out bytes.Buffer
+	changed := false
+	// content columns of the open list items
+	var items []int
+	prevBlank := true
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		if len(bytes.TrimSpace(line)) == 0 {
+			out.Write(line)
+			prevBlank = true
+			continue
+		}
+		indent := indentation(line)
+		rest := bytes.TrimLeft(line, " \t")
+
+		if width := listMarkerWidth(rest); width > 0 {
+			for len(items) > 0 && items[len(items)-1] > indent {
+				items = items[:len(items)-1]
+			}
+			items = append(items, indent+width)
+			out.Write(line)
+			prevBlank = false
+			continue
+		}
+
+		marker, info, isFence := fenceOpening(rest)
+		// lines indented less than a list item end it, unless they are
+		// lazy continuation lines of a paragraph
+		if prevBlank || isFence {
+			for len(items) > 0 && indent < items[len(items)-1] {
+				items = items[:len(items)-1]
+			}
+		}
+		base := 0
+		if len(items) > 0 {
+			base = items[len(items)-1]
+		}
+		if !isFence || indent-base > 3 {
+			out.Write(line)
+			prevBlank = false
+			continue
+		}
+
+		// find the closing fence, or the end of the list item
+		end, closed := i+1, false
+		for ; end < len(lines); end++ {
+			l := lines[end]
+			if len(bytes.TrimSpace(l)) == 0 {
+				continue
+			}
+			ind := indentation(l)
+			if len(items) > 0 && ind < base {
+				break
+			}
+			if ind-base <= 3 && isFenceClosing(bytes.TrimLeft(l, " \t"), marker) {
+				closed = true
+				break
+			}
+		}
+
+		rewrite := len(items) > 0 || !closed
+		if closed && string(bytes.TrimSpace(lines[end])) != marker {
+			rewrite = true
+		}
+		for _, l := range lines[i+1 : end] {
+			if indent > 0 && indentation(l) > 0 {
+				rewrite = true
+			}
+		}
+		if !rewrite {
+			last := end
+			if closed {
+				last++
+			}
+			for _, l := range lines[i:min(last, len(lines))] {
+				out.Write(l)
+			}
+			i = last - 1
+			prevBlank = false
+			continue
+		}
+
+		changed = true
+		prefix := strings.Repeat(" ", 4*len(items))
+		if len(items) > 0 && !prevBlank {
+			out.WriteByte('\n')
+		}
+		out.WriteString(prefix + marker + info + "\n")
+		for j, l := range lines[i+1 : end] {
+			if len(l) == 0 && i+1+j == len(lines)-1 {
+				// the end of a document ending with a newline
+				continue
+			}
+			l = bytes.TrimRight(l, "\n")
+			if len(bytes.TrimSpace(l)) == 0 {
+				out.WriteByte('\n')
+				continue
+			}
+			out.WriteString(prefix)
+			out.Write(stripIndentation(l, indent))
+			out.WriteByte('\n')
+		}
+		out.WriteString(prefix + marker + "\n")
+		if closed {
+			i = end
+		} else {
+			i = end - 1
+		}
+		prevBlank = false
+	}
+	if !changed {
+		return md
+	}
+	return out.Bytes()
+}
+
+// indentation returns the column of the first character of line that isn't
+// a space or tab, with tab stops every four columns.
+func indentation(line []byte) int {
+	col := 0
+	for _, c := range line {
+		switch c {
+		case ' ':
+			col++
+		case '\t':
+			col += 4 - col%4
+		default:
+			return col
+		}
+	}
+	return col
+}
+
+// stripIndentation removes up to n columns of indentation from line.
+func stripIndentation(line []byte, n int) []byte {
+	col := 0
+	for i, c := range line {
+		next := col
+		switch c {
+		case ' ':
+			next++
+		case '\t':
+			next += 4 - col%4
+		default:
+			return line[i:]
+		}
+		if next > n {
+			return line[i:]
+		}
+		col = next
+	}
+	return nil
+}
+
+// listMarkerWidth returns the width of the list marker at the start of line
+// with the spaces following it, or 0 if line doesn't start a list item.
+func listMarkerWidth(line []byte) int {
+	i := 0
+	switch {
+	case len(line) > 0 && (line[0] == '-' || line[0] == '*' || line[0] == '+'):
+		i = 1
+	default:
+		for i < len(line) && i < 9 && line[i] >= '0' && line[i] <= '9' {
+			i++
+		}
+		if i == 0 || i >= len(line) || (line[i] != '.' && line[i] != ')') {
+			return 0
+		}
+		i++
+	}
+	if i < len(line) && line[i] == '\n' {
+		return i + 1
+	}
+	spaces := 0
+	for i+spaces < len(line) && line[i+spaces] == ' ' {
+		spaces++
+	}
+	switch {
+	case spaces == 0:
+		return 0
+	case spaces > 4:
+		// the content is an indented code block, one space belongs
+		// to the marker
+		spaces = 1
+	}
+	if isThematicBreak(line) {
+		return 0
+	}
+	return i + spaces
+}
+
+// isThematicBreak reports whether line is a horizontal rule like "- - -".
+func isThematicBreak(line []byte) bool {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || (line[0] != '-' && line[0] != '*' && line[0] != '_') {
+		return false
+	}
+	n := 0
+	for _, c := range line {
+		switch c {
+		case line[0]:
+			n++
+		case ' ', '\t':
+		default:
+			return false
+		}
+	}
+	return n >= 3
+}
+
+// fenceOpening returns the marker and the info string of an opening code
+// fence at the start of line.
+func fenceOpening(line []byte) (marker string, info string, ok bool) {
+	if len(line) < 3 || (line[0] != '`' && line[0] != '~') {
+		return "", "", false
+	}
+	n := 0
+	for n < len(line) && line[n] == line[0] {
+		n++
+	}
+	if n < 3 {
+		return "", "", false
+	}
+	info = strings.TrimSpace(string(line[n:]))
+	if line[0] == '`' && strings.Contains(info, "`") {
+		return "", "", false
+	}
+	return string(line[:n]), info, true
+}
+
+// isFenceClosing reports whether line closes a code block opened with
+// marker: a fence of the same character, at least as long, without info.
+func isFenceClosing(line []byte, marker string) bool {
+	line = bytes.TrimRight(line, " \t\r\n")
+	if len(line) < len(marker) {
+		return false
+	}
+	for _, c := range line {
+		if c != marker[0] {
+			return false
+		}
+	}
+	return true
+}
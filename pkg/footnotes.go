@@ -0,0 +1,153 @@
+package pkg
+
+import (
+	"fmt"
+	"html/template"
+	"io"
+	"log/slog"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	mdhtml "github.com/gomarkdown/markdown/html"
+)
+
+// Footnote markers of WithFootnoteStyle.
+const (
+	// FootnoteNumeric numbers footnotes 1, 2, 3 like GitHub does.
+	FootnoteNumeric = "numeric"
+	// FootnoteSymbols marks footnotes with *, †, ‡, §, ‖ and ¶, doubled
+	// after the sixth footnote.
+	FootnoteSymbols = "symbols"
+)
+
+// DefaultFootnoteBacklink is the text of the links from footnotes back to
+// their references.
+const DefaultFootnoteBacklink = "↩"
+
+// footnoteSymbols are the markers of FootnoteSymbols in the order of the
+// Chicago Manual of Style, matching the counter style of go-grip.css.
+var footnoteSymbols = []string{"*", "†", "‡", "§", "‖", "¶"}
+
+// WithFootnoteStyle marks footnotes like [^1] with markers, FootnoteNumeric
+// or FootnoteSymbols, and links them back to their references with backlink
+// instead of DefaultFootnoteBacklink.
+func WithFootnoteStyle(markers string, backlink string) ParserOption {
+	return func(p *Parser) {
+		p.footnoteMarkers = markers
+		p.footnoteBacklink = backlink
+	}
+}
+
+// footnoteMarker returns the marker of the nth footnote.
+func (m Parser) footnoteMarker(n int) string {
+	if m.footnoteMarkers != FootnoteSymbols {
+		return strconv.Itoa(n)
+	}
+	symbol := footnoteSymbols[(n-1)%len(footnoteSymbols)]
+	return strings.Repeat(symbol, (n-1)/len(footnoteSymbols)+1)
+}
+
+// footnoteSlug returns the id suffix of the footnote named name.
+func footnoteSlug(name []byte) string {
+	return string(mdhtml.Slugify(name))
+}
+
+// renderFootnotes replaces the footnote references of doc with links to
+// their footnotes, and adds links back to the references to the footnotes,
+// with the markup and ids GitHub uses, so its stylesheet applies.
+func (m Parser) renderFootnotes(doc ast.Node) {
+	backlink := m.footnoteBacklink
+	if backlink == "" {
+		backlink = DefaultFootnoteBacklink
+	}
+	class := ""
+	if m.footnoteMarkers == FootnoteSymbols {
+		class = ` class="grip-footnote-symbol"`
+	}
+
+	var links []*ast.Link
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if link, ok := node.(*ast.Link); ok && entering && link.NoteID != 0 {
+			links = append(links, link)
+		}
+		return ast.GoToNext
+	})
+	refs := make(map[string]int)
+	for _, link := range links {
+		slug := footnoteSlug(link.Destination)
+		refs[slug]++
+		id := "fnref-" + slug
+		if n := refs[slug]; n > 1 {
+			id += "-" + strconv.Itoa(n)
+		}
+		ref := fmt.Sprintf(`<sup><a href="#fn-%s" id="%s"%s data-footnote-ref aria-describedby="footnote-label">%s</a></sup>`,
+			slug, id, class, template.HTMLEscapeString(m.footnoteMarker(link.NoteID)))
+		replaceNode(link, []ast.Node{&ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(ref)}}})
+	}
+
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		item, ok := node.(*ast.ListItem)
+		if !ok || !entering || item.RefLink == nil {
+			return ast.GoToNext
+		}
+		slug := footnoteSlug(item.RefLink)
+		var links strings.Builder
+		for n := 1; n <= refs[slug]; n++ {
+			id, label, sup := "fnref-"+slug, slug, ""
+			if n > 1 {
+				id += "-" + strconv.Itoa(n)
+				label += "-" + strconv.Itoa(n)
+				sup = "<sup>" + strconv.Itoa(n) + "</sup>"
+			}
+			fmt.Fprintf(&links, ` <a href="#%s" data-footnote-backref class="data-footnote-backref" aria-label="Back to reference %s">%s%s</a>`,
+				id, label, template.HTMLEscapeString(backlink), sup)
+		}
+		// the links go at the end of the last paragraph, like GitHub's
+		var parent ast.Node = item
+		if children := item.Children; len(children) > 0 {
+			if p, ok := children[len(children)-1].(*ast.Paragraph); ok {
+				parent = p
+			}
+		}
+		ast.AppendChild(parent, &ast.HTMLSpan{Leaf: ast.Leaf{Literal: []byte(links.String())}})
+		return ast.SkipChildren
+	})
+}
+
+// renderHookFootnotes renders the list of footnotes in the section GitHub
+// renders, instead of a div behind a horizontal rule.
+func (m Parser) renderHookFootnotes(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	var out string
+	switch n := node.(type) {
+	case *ast.Footnotes:
+		if entering {
+			out = "<section data-footnotes class=\"footnotes\"><h2 class=\"sr-only\" id=\"footnote-label\">Footnotes</h2>\n"
+		}
+	case *ast.List:
+		if !n.IsFootnotesList {
+			return ast.GoToNext, false
+		}
+		out = "</ol>\n</section>\n"
+		if entering {
+			out = "<ol>\n"
+			if m.footnoteMarkers == FootnoteSymbols {
+				out = "<ol class=\"grip-footnote-symbols\">\n"
+			}
+		}
+	case *ast.ListItem:
+		if n.RefLink == nil {
+			return ast.GoToNext, false
+		}
+		out = "</li>\n"
+		if entering {
+			out = fmt.Sprintf(`<li id="fn-%s">`, footnoteSlug(n.RefLink))
+		}
+	default:
+		return ast.GoToNext, false
+	}
+	if _, err := io.WriteString(w, out); err != nil {
+		slog.Error("failed to write HTML", "err", err)
+	}
+	return ast.GoToNext, true
+}
@@ -0,0 +1,69 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"net/http"
+	"regexp"
+)
+
+var scriptRegex = regexp.MustCompile(`(?is)<script\b[^>]*>.*?</script>`)
+
+// fragmentHandler serves /fragment/<path> of a rendered file, e.g. a markdown
+// document, as the article only, without layout and scripts, so other
+// applications can embed it.
+func (s *Server) fragmentHandler(dir http.FileSystem) http.Handler {
+	return http.StripPrefix("/fragment", http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		render, ok := s.renderer(r.URL.Path, dir)
+		if !ok {
+			http.NotFound(w, r)
+			return
+		}
+
+		f, err := dir.Open(r.URL.Path)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+
+		info, err := f.Stat()
+		if err != nil || info.IsDir() {
+			http.NotFound(w, r)
+			return
+		}
+		if reason, status, err := s.previewError(r.URL.Path, f); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if reason != "" {
+			http.Error(w, reason, status)
+			return
+		}
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, embedded := s.embedFiles(dir, r.URL.Path, content)
+		modTime := info.ModTime()
+		if embedded.After(modTime) {
+			modTime = embedded
+		}
+
+		htmlContent, err := s.limitRender(func(ctx context.Context) ([]byte, error) {
+			out, _, err := s.timedRenderer(ctx, render, r.URL.Path, nil)(content, r.URL.Path)
+			return out, err
+		})
+		if err != nil {
+			renderError(w, err)
+			return
+		}
+		htmlContent = s.prefixURLs(scriptRegex.ReplaceAll(htmlContent, nil))
+
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		w.Header().Set("Cache-Control", "public, max-age=60")
+		http.ServeContent(w, r, "", modTime, bytes.NewReader(htmlContent))
+	}))
+}
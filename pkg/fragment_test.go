@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestFragmentHandler(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.Mkdir(filepath.Join(dir, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		"README.md":  "# Readme\n\n<script>alert(1)</script>\n\n![logo](/logo.png)\n",
+		"large.md":   strings.Repeat("text ", 100),
+		"logo.png":   "png",
+		"docs/a.txt": "text",
+		".hidden.md": "# Hidden\n",
+	} {
+		if err := os.WriteFile(filepath.Join(dir, filepath.FromSlash(name)), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	s := New(WithRoot(dir), WithBasePath("/preview"), WithMaxPreviewSize(100))
+	defer s.Close()
+	h := s.Handler()
+	get := func(path string, header ...string) *httptest.ResponseRecorder {
+		t.Helper()
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := get("/preview/fragment/README.md")
+	if rec.Code != http.StatusOK {
+		t.Fatalf("expected the fragment, got %d", rec.Code)
+	}
+	body := rec.Body.String()
+	if !strings.Contains(body, "Readme</h1>") || !strings.Contains(body, `src="/preview/logo.png"`) {
+		t.Errorf("expected the article with prefixed URLs, got:\n%s", body)
+	}
+	for _, unwanted := range []string{"<html", "<script", "alert(1)"} {
+		if strings.Contains(body, unwanted) {
+			t.Errorf("expected no %q in the fragment:\n%s", unwanted, body)
+		}
+	}
+	if got := rec.Header().Get("Cache-Control"); got != "public, max-age=60" {
+		t.Errorf("got Cache-Control %q", got)
+	}
+	if ct := rec.Header().Get("Content-Type"); !strings.HasPrefix(ct, "text/html") {
+		t.Errorf("got Content-Type %q", ct)
+	}
+	lastModified := rec.Header().Get("Last-Modified")
+	if _, err := time.Parse(http.TimeFormat, lastModified); err != nil {
+		t.Fatalf("expected Last-Modified, got %q", lastModified)
+	}
+	if rec := get("/preview/fragment/README.md", "If-Modified-Since", lastModified); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unmodified file, got %d", rec.Code)
+	}
+
+	for path, want := range map[string]int{
+		"/preview/fragment/missing.md": http.StatusNotFound,
+		"/preview/fragment/docs":       http.StatusNotFound,
+		"/preview/fragment/logo.png":   http.StatusNotFound,
+		"/preview/fragment/.hidden.md": http.StatusNotFound,
+		"/preview/fragment/large.md":   http.StatusRequestEntityTooLarge,
+	} {
+		if rec := get(path); rec.Code != want {
+			t.Errorf("%s: got %d, want %d", path, rec.Code, want)
+		}
+	}
+}
@@ -0,0 +1,70 @@
+package pkg
+
+import (
+	"bytes"
+
+	"gopkg.in/yaml.v2"
+)
+
+// frontMatter holds the fields of a YAML front matter block go-grip uses when
+// exporting directories.
+type frontMatter struct {
+	Title string `yaml:"title"`
+	Draft bool   `yaml:"draft"`
+	// Order sorts pages in directory indexes, pages without come last.
+	Order *int `yaml:"order"`
+}
+
+// parseFrontMatter returns the front matter at the start of a markdown file,
+// delimited by --- lines, and the content after it. Content without valid
+// front matter is returned as is.
+func parseFrontMatter(content []byte) (frontMatter, []byte) {
+	var fm frontMatter
+	_, data, rest, ok := cutFrontMatter(content)
+	if !ok {
+		return fm, content
+	}
+	if err := yaml.Unmarshal(data, &fm); err != nil {
+		return frontMatter{}, content
+	}
+	return fm, rest
+}
+
+// blankFrontMatter replaces the front matter block of content with empty
+// lines, so it is not rendered and the lines after it keep their numbers.
+func blankFrontMatter(content []byte) []byte {
+	block, _, rest, ok := cutFrontMatter(content)
+	if !ok {
+		return content
+	}
+	out := bytes.Repeat([]byte("\n"), bytes.Count(block, []byte("\n")))
+	return append(out, rest...)
+}
+
+// cutFrontMatter splits the front matter block delimited by --- lines off
+// the start of a markdown file, returning the block, the YAML inside it and
+// the content after it.
+func cutFrontMatter(content []byte) (block []byte, data []byte, rest []byte, ok bool) {
+	rest, ok = bytes.CutPrefix(content, []byte("---\n"))
+	if !ok {
+		rest, ok = bytes.CutPrefix(content, []byte("---\r\n"))
+	}
+	if !ok {
+		return nil, nil, content, false
+	}
+
+	start := len(content) - len(rest)
+	for off := 0; off < len(rest); {
+		end := bytes.IndexByte(rest[off:], '\n')
+		line := rest[off:]
+		if end >= 0 {
+			line = rest[off : off+end+1]
+		}
+		if trimmed := bytes.TrimRight(line, "\r\n"); string(trimmed) == "---" || string(trimmed) == "..." {
+			n := start + off + len(line)
+			return content[:n], rest[:off], content[n:], true
+		}
+		off += len(line)
+	}
+	return nil, nil, content, false
+}
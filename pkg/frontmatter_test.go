@@ -0,0 +1,97 @@
+package pkg
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseFrontMatter(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		title   string
+		draft   bool
+		content string
+	}{
+		{"fields", "---\ntitle: Setup\ndraft: true\norder: 2\n---\n# Body\n", "Setup", true, "# Body\n"},
+		{"dots", "---\ntitle: Setup\n...\nbody", "Setup", false, "body"},
+		{"none", "# Body\n", "", false, "# Body\n"},
+		{"thematic break", "---\n\ntext\n", "", false, "---\n\ntext\n"},
+		{"invalid yaml", "---\n: [\n---\nbody", "", false, "---\n: [\n---\nbody"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			fm, content := parseFrontMatter([]byte(tt.input))
+			if fm.Title != tt.title || fm.Draft != tt.draft || string(content) != tt.content {
+				t.Errorf("got title %q, draft %v, content %q", fm.Title, fm.Draft, content)
+			}
+		})
+	}
+}
+
+func TestMdToHTMLFrontMatter(t *testing.T) {
+	out := string(NewParser("light", WithSourceLines()).MdToHTML([]byte("---\ntitle: Setup\ndraft: true\n---\n# Body\n")))
+	if strings.Contains(out, "<hr") || strings.Contains(out, "title:") || strings.Contains(out, "draft") {
+		t.Errorf("front matter was rendered: %s", out)
+	}
+	if !strings.Contains(out, `data-source-line="5"`) {
+		t.Errorf("expected the heading on line 5: %s", out)
+	}
+}
+
+func TestGenerateDirectoryIndexOrder(t *testing.T) {
+	one, two := 1, 2
+	got := (&Server{}).generateDirectoryIndex("docs", []indexEntry{
+		{file: "a.html", title: "A"},
+		{file: "b.html", title: "B", order: &two},
+		{file: "c.html", title: "C", order: &one},
+		{file: "index.html", title: "Index"},
+	})
+
+	c, b, a := strings.Index(got, ">C<"), strings.Index(got, ">B<"), strings.Index(got, ">A<")
+	if c < 0 || !(c < b && b < a) {
+		t.Errorf("pages are not ordered C, B, A:\n%s", got)
+	}
+	if strings.Contains(got, "Index") {
+		t.Errorf("index lists itself:\n%s", got)
+	}
+}
+
+func TestSubstituteVars(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"vars.yaml": "version: 1.10\nproduct: Grip\nurls:\n  docs: https://example.com\n",
+		"doc.md":    "---\nversion: 2.0\n---\n# {{ .product }} {{ .version }}\n\n<!-- include: part.md -->\n",
+		"part.md":   "See {{ .urls.docs }}.\n",
+		"broken.md": "# {{ .missing }}\n",
+		"code.md":   "Run `{{ .product }}` with {{ .product }}:\n\n````yaml\nname: \"{{ .name }}\"\n```\n````\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"), WithIncludes(true), WithVars(true, filepath.Join(dir, "vars.yaml")))
+	for name, want := range map[string]string{
+		"doc.md":    "---\nversion: 2.0\n---\n# Grip 2.0\n\nSee https://example.com.\n",
+		"code.md":   "Run `{{ .product }}` with Grip:\n\n````yaml\nname: \"{{ .name }}\"\n```\n````\n",
+		"broken.md": "> [!CAUTION]\n> Failed to substitute variables: template: /broken.md:1:5: executing \"/broken.md\" at <.missing>: map has no entry for key \"missing\"\n\n# {{ .missing }}\n",
+	} {
+		got, latest := s.embedFiles(http.Dir(dir), name, []byte(files[name]))
+		if string(got) != want {
+			t.Errorf("%s: got %q, want %q", name, got, want)
+		}
+		if latest.IsZero() {
+			t.Errorf("%s: expected the modification time of the variables", name)
+		}
+	}
+
+	WithVars(false, "")(s)
+	if got, _ := s.embedFiles(http.Dir(dir), "broken.md", []byte(files["broken.md"])); string(got) != files["broken.md"] {
+		t.Errorf("expected no substitution without WithVars, got %q", got)
+	}
+}
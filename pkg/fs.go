@@ -0,0 +1,186 @@
+package pkg
+
+import (
+	"errors"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strings"
+)
+
+// rootFS serves the files below root. Paths that try to escape the root are
+// rejected, and unless hidden is set, so are dotfiles and dot-directories.
+type rootFS struct {
+	root   http.FileSystem
+	hidden bool
+}
+
+func (fsys rootFS) Open(name string) (http.File, error) {
+	if !fsys.allowed(name) {
+		return nil, fs.ErrNotExist
+	}
+
+	f, err := fsys.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	if fsys.hidden {
+		return f, nil
+	}
+	return hiddenFilterFile{f}, nil
+}
+
+// allowed reports whether name is a clean path inside the root.
+func (fsys rootFS) allowed(name string) bool {
+	if strings.ContainsRune(name, 0) {
+		return false
+	}
+	// backslashes and drive letters are path separators on windows
+	if runtime.GOOS == "windows" && strings.ContainsAny(name, `\:`) {
+		return false
+	}
+
+	for _, seg := range strings.Split(name, "/") {
+		if seg == ".." {
+			return false
+		}
+		if !fsys.hidden && strings.HasPrefix(seg, ".") && seg != "." {
+			return false
+		}
+	}
+	return true
+}
+
+// hiddenFilterFile hides dotfiles from directory listings.
+type hiddenFilterFile struct {
+	http.File
+}
+
+func (f hiddenFilterFile) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	visible := infos[:0]
+	for _, info := range infos {
+		if !strings.HasPrefix(info.Name(), ".") {
+			visible = append(visible, info)
+		}
+	}
+	return visible, err
+}
+
+// symlinkFS serves the files below dir like http.Dir with an explicit policy
+// for symbolic links. When follow is set, links are resolved and listed as
+// the files they point to, also outside of dir, except links to a directory
+// the listing is inside of, which would loop. Otherwise paths through links
+// are rejected and links are left out of listings.
+type symlinkFS struct {
+	dir string
+	// root is dir with its links resolved
+	root   string
+	follow bool
+}
+
+// newSymlinkFS returns the symlinkFS of dir, resolving the links of dir
+// itself once.
+func newSymlinkFS(dir string, follow bool) symlinkFS {
+	root, err := filepath.EvalSymlinks(dir)
+	if err != nil {
+		slog.Debug("failed to resolve served directory", "path", dir, "err", err)
+		root = filepath.Clean(dir)
+	}
+	return symlinkFS{dir: dir, root: root, follow: follow}
+}
+
+func (fsys symlinkFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	real, err := fsys.resolve(name)
+	if err != nil {
+		return nil, err
+	}
+	f, err := http.Dir(fsys.dir).Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return symlinkFile{File: f, fsys: fsys, name: name, real: real}, nil
+}
+
+// resolve returns the path of name on disk with all links resolved. Without
+// follow, paths through links below the root do not exist.
+func (fsys symlinkFS) resolve(name string) (string, error) {
+	real, err := filepath.EvalSymlinks(filepath.Join(fsys.dir, filepath.FromSlash(name)))
+	if err != nil {
+		if !errors.Is(err, fs.ErrNotExist) {
+			slog.Debug("failed to resolve symlink", "path", name, "err", err)
+		}
+		return "", fs.ErrNotExist
+	}
+	if !fsys.follow && real != filepath.Join(fsys.root, filepath.FromSlash(name)) {
+		return "", fs.ErrNotExist
+	}
+	return real, nil
+}
+
+// symlinkFile applies the link policy of fsys to directory listings.
+type symlinkFile struct {
+	http.File
+	fsys symlinkFS
+	name string
+	real string
+}
+
+func (f symlinkFile) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := f.File.Readdir(count)
+	if len(infos) == 0 {
+		return infos, err
+	}
+
+	// the directories this listing is inside of, to detect loops
+	var parents []string
+	if f.fsys.follow {
+		for name := f.name; ; name = path.Dir(name) {
+			if real, err := f.fsys.resolve(name); err == nil {
+				parents = append(parents, real)
+			}
+			if name == "/" {
+				break
+			}
+		}
+	}
+
+	visible := infos[:0]
+	for _, info := range infos {
+		if info.Mode()&fs.ModeSymlink == 0 {
+			visible = append(visible, info)
+			continue
+		}
+		if !f.fsys.follow {
+			continue
+		}
+		target, rerr := filepath.EvalSymlinks(filepath.Join(f.real, info.Name()))
+		if rerr != nil {
+			continue
+		}
+		if slices.ContainsFunc(parents, func(p string) bool { return within(target, p) }) {
+			slog.Debug("skipping symlink loop", "path", path.Join(f.name, info.Name()), "target", target)
+			continue
+		}
+		if resolved, serr := os.Stat(target); serr == nil {
+			visible = append(visible, renamedInfo{resolved, info.Name()})
+		}
+	}
+	return visible, err
+}
+
+// renamedInfo is the FileInfo of a link target under the name of the link.
+type renamedInfo struct {
+	fs.FileInfo
+	name string
+}
+
+func (i renamedInfo) Name() string {
+	return i.name
+}
@@ -0,0 +1,384 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"slices"
+	"strings"
+	"testing"
+)
+
+func TestRootFSTraversal(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	for _, dir := range []string{root, filepath.Join(root, ".git"), filepath.Join(root, "docs")} {
+		if err := os.MkdirAll(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+	files := map[string]string{
+		filepath.Join(parent, "secret.txt"):    "secret",
+		filepath.Join(root, "README.md"):       "readme",
+		filepath.Join(root, ".env"):            "env",
+		filepath.Join(root, ".git", "config"):  "config",
+		filepath.Join(root, "docs", "page.md"): "page",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		path   string
+		hidden bool
+		want   int
+	}{
+		{"/README.md", false, http.StatusOK},
+		{"/docs/page.md", false, http.StatusOK},
+		{"/../secret.txt", false, http.StatusNotFound},
+		{"/../../../../etc/passwd", false, http.StatusNotFound},
+		{"/%2e%2e/secret.txt", false, http.StatusNotFound},
+		{"/docs/%2e%2e/%2e%2e/secret.txt", false, http.StatusNotFound},
+		{"/..%2fsecret.txt", false, http.StatusNotFound},
+		{"/....//secret.txt", false, http.StatusNotFound},
+		{"/README.md%00.txt", false, http.StatusNotFound},
+		{"/.env", false, http.StatusNotFound},
+		{"/.git/config", false, http.StatusNotFound},
+		{"/docs/../.git/config", false, http.StatusNotFound},
+		{"/.env", true, http.StatusOK},
+		{"/.git/config", true, http.StatusOK},
+		{"/../secret.txt", true, http.StatusNotFound},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.path, func(t *testing.T) {
+			handler := http.FileServer(rootFS{root: http.Dir(root), hidden: tt.hidden})
+			req := httptest.NewRequest(http.MethodGet, tt.path, nil)
+
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+
+			if rec.Code != tt.want {
+				t.Errorf("GET %s: got status %d, want %d", tt.path, rec.Code, tt.want)
+			}
+			if rec.Body.String() == "secret" {
+				t.Errorf("GET %s: served a file outside the root", tt.path)
+			}
+		})
+	}
+}
+
+func TestRootFSHidesDotfilesInListings(t *testing.T) {
+	root := t.TempDir()
+	for _, name := range []string{"visible.md", ".hidden"} {
+		if err := os.WriteFile(filepath.Join(root, name), nil, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	f, err := rootFS{root: http.Dir(root)}.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	infos, err := f.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "visible.md" {
+		t.Errorf("got listing %v, want only visible.md", infos)
+	}
+}
+
+func TestGitFS(t *testing.T) {
+	if _, err := exec.LookPath("git"); err != nil {
+		t.Skip("git not installed")
+	}
+	repo := t.TempDir()
+	docs := filepath.Join(repo, "docs")
+	if err := os.MkdirAll(docs, 0755); err != nil {
+		t.Fatal(err)
+	}
+	run := func(args ...string) {
+		t.Helper()
+		cmd := exec.Command("git", args...)
+		cmd.Dir = repo
+		cmd.Env = append(os.Environ(), "GIT_AUTHOR_NAME=t", "GIT_AUTHOR_EMAIL=t@example.com",
+			"GIT_COMMITTER_NAME=t", "GIT_COMMITTER_EMAIL=t@example.com")
+		if out, err := cmd.CombinedOutput(); err != nil {
+			t.Fatalf("git %v: %v\n%s", args, err, out)
+		}
+	}
+	write := func(content string) {
+		t.Helper()
+		if err := os.WriteFile(filepath.Join(docs, "README.md"), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	run("init", "-q")
+	write("# old")
+	run("add", ".")
+	run("commit", "-q", "-m", "old")
+	run("tag", "v1")
+	write("# new")
+	run("commit", "-q", "-am", "new")
+
+	fsys, err := newGitFS(docs, "v1")
+	if err != nil {
+		t.Fatal(err)
+	}
+	f, err := fsys.Open("/README.md")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(content) != "# old" {
+		t.Errorf("got %q, want the content at v1", content)
+	}
+
+	root, err := fsys.Open("/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	infos, err := root.Readdir(-1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(infos) != 1 || infos[0].Name() != "README.md" {
+		t.Errorf("got listing %v, want only README.md", infos)
+	}
+
+	if _, err := fsys.Open("/missing.md"); err == nil {
+		t.Error("opened a file missing at v1")
+	}
+	if _, err := newGitFS(docs, "v2"); err == nil {
+		t.Error("resolved an unknown ref")
+	}
+}
+
+func TestMountFS(t *testing.T) {
+	parent := t.TempDir()
+	for _, dir := range []string{"docs", "design/rfcs", "design/private", "secret"} {
+		if err := os.MkdirAll(filepath.Join(parent, dir), 0755); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	root, mounts, err := mountRoots([]string{filepath.Join(parent, "docs"), filepath.Join(parent, "design", "rfcs")})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if root != parent || len(mounts) != 2 || mounts[0] != "design/rfcs" || mounts[1] != "docs" {
+		t.Fatalf("mountRoots() = %s, %v", root, mounts)
+	}
+	if _, _, err := mountRoots([]string{parent, filepath.Join(parent, "docs")}); err == nil {
+		t.Error("nested roots should be rejected")
+	}
+
+	fsys := mountFS{root: http.Dir(root), mounts: mounts}
+	for name, want := range map[string]bool{
+		"/docs":                true,
+		"/design/rfcs":         true,
+		"/design":              true,
+		"/design/private":      false,
+		"/secret":              false,
+		"/design/../secret":    false,
+		"/docs/../secret/file": false,
+	} {
+		f, err := fsys.Open(name)
+		if (err == nil) != want {
+			t.Errorf("Open(%s) error = %v, want found %v", name, err, want)
+		}
+		if err == nil {
+			f.Close()
+		}
+	}
+
+	f, err := fsys.Open("/design")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	infos, err := f.Readdir(-1)
+	if err != nil || len(infos) != 1 || infos[0].Name() != "rfcs" {
+		t.Errorf("Readdir(/design) = %v, %v", infos, err)
+	}
+}
+
+func TestSymlinkFS(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(root, "docs", "page.md"): "page",
+		filepath.Join(parent, "outside.md"):    "outside",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	links := map[string]string{
+		filepath.Join(root, "linked"):         filepath.Join(root, "docs"),
+		filepath.Join(root, "docs", "loop"):   root,
+		filepath.Join(root, "docs", "self"):   filepath.Join(root, "docs"),
+		filepath.Join(root, "alias.md"):       filepath.Join(root, "docs", "page.md"),
+		filepath.Join(root, "dangling.md"):    filepath.Join(root, "missing.md"),
+		filepath.Join(root, "outside.md"):     filepath.Join(parent, "outside.md"),
+		filepath.Join(root, "docs", "cycle1"): filepath.Join(root, "docs", "cycle2"),
+		filepath.Join(root, "docs", "cycle2"): filepath.Join(root, "docs", "cycle1"),
+	}
+	for link, target := range links {
+		if err := os.Symlink(target, link); err != nil {
+			t.Skipf("symlinks not supported: %v", err)
+		}
+	}
+
+	listing := func(fsys symlinkFS, name string) []string {
+		t.Helper()
+		f, err := fsys.Open(name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		infos, err := f.Readdir(-1)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var names []string
+		for _, info := range infos {
+			names = append(names, info.Name())
+		}
+		slices.Sort(names)
+		return names
+	}
+
+	follow := newSymlinkFS(root, true)
+	for name, want := range map[string]bool{
+		"/docs/page.md":   true,
+		"/linked/page.md": true,
+		"/alias.md":       true,
+		"/dangling.md":    false,
+		"/outside.md":     true,
+		"/docs/cycle1":    false,
+		"/docs/loop/docs": true,
+	} {
+		f, err := follow.Open(name)
+		if (err == nil) != want {
+			t.Errorf("follow: Open(%s) error = %v, want found %v", name, err, want)
+		}
+		if err == nil {
+			f.Close()
+		}
+	}
+	if got := listing(follow, "/"); !slices.Equal(got, []string{"alias.md", "docs", "linked", "outside.md"}) {
+		t.Errorf("follow: Readdir(/) = %v", got)
+	}
+	if got := listing(follow, "/linked"); !slices.Equal(got, []string{"page.md"}) {
+		t.Errorf("follow: Readdir(/linked) = %v, want the loops left out", got)
+	}
+
+	reject := newSymlinkFS(root, false)
+	for name, want := range map[string]bool{
+		"/docs/page.md":   true,
+		"/linked/page.md": false,
+		"/alias.md":       false,
+		"/outside.md":     false,
+		"/docs/loop/docs": false,
+	} {
+		f, err := reject.Open(name)
+		if (err == nil) != want {
+			t.Errorf("reject: Open(%s) error = %v, want found %v", name, err, want)
+		}
+		if err == nil {
+			f.Close()
+		}
+	}
+	if got := listing(reject, "/docs"); !slices.Equal(got, []string{"page.md"}) {
+		t.Errorf("reject: Readdir(/docs) = %v", got)
+	}
+}
+
+func TestOrgIncludesStayInRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.MkdirAll(filepath.Join(root, "docs"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(parent, "secret.org"):      "outside the root",
+		filepath.Join(root, "docs", "part.org"):  "inside the root",
+		filepath.Join(root, "docs", "setup.org"): "#+TITLE: From setup",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := rootFS{root: http.Dir(root)}
+
+	doc := "#+SETUPFILE: setup.org\n\n#+INCLUDE: \"part.org\" export html\n\n#+INCLUDE: \"../../secret.org\" export html\n\n#+INCLUDE: \"" + filepath.Join(parent, "secret.org") + "\" export html\n"
+	out, title, err := orgRenderer(fsys)([]byte(doc), "/docs/index.org")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(string(out), "inside the root") || title != "From setup" {
+		t.Errorf("expected the files inside the root to be included, got %q with title %q", out, title)
+	}
+	if strings.Contains(string(out), "outside the root") {
+		t.Errorf("included a file outside the root: %s", out)
+	}
+}
+
+func TestAsciidocIncludesStayInRoot(t *testing.T) {
+	parent := t.TempDir()
+	root := filepath.Join(parent, "root")
+	if err := os.MkdirAll(filepath.Join(root, "docs", "parts"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for name, content := range map[string]string{
+		filepath.Join(parent, "secret.adoc"):               "outside the root",
+		filepath.Join(root, "docs", "parts", "intro.adoc"): "= Intro\n\nincluded intro\n\ninclude::../code.go[tag=main]\n",
+		filepath.Join(root, "docs", "code.go"):             "package main\n// tag::main[]\nfunc main() {}\n// end::main[]\nfunc other() {}\n",
+	} {
+		if err := os.WriteFile(name, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+	fsys := rootFS{root: http.Dir(root)}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	doc := "= Book\n\ninclude::parts/intro.adoc[leveloffset=+1]\n\ninclude::../../secret.adoc[]\n\ninclude::" + filepath.Join(parent, "secret.adoc") + "[]\n\ninclude::missing.adoc[opts=optional]\n"
+	out, _, err := asciidocRenderer(fsys)([]byte(doc), "/docs/book.adoc")
+	if err != nil {
+		t.Fatal(err)
+	}
+	got := string(out)
+	for _, want := range []string{"included intro", "<h2", "func main() {}", "Unresolved directive in book.adoc - include::../../secret.adoc[]"} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+		}
+	}
+	for _, unwanted := range []string{"outside the root", "func other", "missing.adoc"} {
+		if strings.Contains(got, unwanted) {
+			t.Errorf("output contains %q\ngot:\n%s", unwanted, got)
+		}
+	}
+	if now, _ := os.Getwd(); now != wd {
+		t.Errorf("working directory changed to %s", now)
+	}
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"html/template"
+	"strings"
+
+	"github.com/chrishrb/go-grip/defaults"
+)
+
+// OpenStreetMapTiles is the tile URL template of OpenStreetMap, see
+// WithMapTiles.
+const OpenStreetMapTiles = "https://tile.openstreetmap.org/{z}/{x}/{y}.png"
+
+var mapTemplate = template.Must(template.New("map.html").Funcs(assetFuncs).ParseFS(defaults.Templates, "templates/map/map.html"))
+
+type geoMap struct {
+	Format  string
+	Content template.JS
+	Tiles   string
+}
+
+// WithMapTiles draws GeoJSON and TopoJSON maps on raster tiles from the given
+// URL template with {z}, {x} and {y} placeholders, like OpenStreetMapTiles.
+// Maps are drawn without tiles by default, so rendering works offline.
+func WithMapTiles(url string) ParserOption {
+	return func(p *Parser) {
+		p.mapTiles = url
+	}
+}
+
+// renderMap renders GeoJSON or TopoJSON source as an interactive map, format
+// is either "geojson" or "topojson".
+func renderMap(content string, format string, tiles string) (string, error) {
+	if !json.Valid([]byte(content)) {
+		return "", fmt.Errorf("invalid %s", format)
+	}
+	m := geoMap{
+		Format: format,
+		// the JSON is embedded in a script element, which must not be closed by it
+		Content: template.JS(strings.ReplaceAll(content, "</", `<\/`)),
+		Tiles:   tiles,
+	}
+	var tpl bytes.Buffer
+	if err := mapTemplate.Execute(&tpl, m); err != nil {
+		return "", err
+	}
+	return tpl.String(), nil
+}
+
+// renderMapFile renders .geojson and .topojson files as a map.
+func (m Parser) renderMapFile(content []byte, name string) ([]byte, string, error) {
+	format := "geojson"
+	if strings.HasSuffix(strings.ToLower(name), ".topojson") {
+		format = "topojson"
+	}
+	out, err := renderMap(string(content), format, m.mapTiles)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(out), "", nil
+}
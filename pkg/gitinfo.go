@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"encoding/json"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+)
+
+// GitInfo describes the revision of a served file.
+type GitInfo struct {
+	// Branch is the checked out branch, or the ref set with WithGitRef.
+	Branch string `json:"branch"`
+	// Commit is the last commit changing the file, empty if the file was
+	// never committed.
+	Commit  string     `json:"commit,omitempty"`
+	Author  string     `json:"author,omitempty"`
+	Date    *time.Time `json:"date,omitempty"`
+	Subject string     `json:"subject,omitempty"`
+	// Modified reports uncommitted changes of the file in the working tree.
+	Modified bool `json:"modified"`
+}
+
+// WithGitInfo shows the branch and the last commit of a page in a bar below
+// it, so everyone looking at a shared preview knows which revision it shows.
+func WithGitInfo(enabled bool) Option {
+	return func(s *Server) {
+		s.gitInfo = enabled
+	}
+}
+
+// readGitInfo returns the revision of the file name in directory.
+func (s *Server) readGitInfo(directory string, name string) (*GitInfo, error) {
+	rel := "." + name
+	info := &GitInfo{Branch: s.gitRef}
+	rev := "HEAD"
+	if s.gitRef != "" {
+		rev = s.gitRef
+	} else {
+		out, err := git(directory, "rev-parse", "--abbrev-ref", "HEAD")
+		if err != nil {
+			return nil, err
+		}
+		info.Branch = strings.TrimSpace(string(out))
+		out, err = git(directory, "status", "--porcelain", "--", rel)
+		if err != nil {
+			return nil, err
+		}
+		info.Modified = len(strings.TrimSpace(string(out))) > 0
+	}
+
+	out, err := git(directory, "log", "-1", "--format=%H%x00%an%x00%aI%x00%s", rev, "--", rel)
+	if err != nil {
+		return nil, err
+	}
+	fields := strings.SplitN(strings.TrimSpace(string(out)), "\x00", 4)
+	if len(fields) == 4 {
+		info.Commit = fields[0]
+		info.Author = fields[1]
+		if date, err := time.Parse(time.RFC3339, fields[2]); err == nil {
+			info.Date = &date
+		}
+		info.Subject = fields[3]
+	}
+	return info, nil
+}
+
+// gitInfoHandler serves the GitInfo of the file given by the file query
+// parameter as JSON, 404 if the directory is not in a git repository.
+func (s *Server) gitInfoHandler(directory string) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Query().Get("file"))
+		if !(rootFS{hidden: s.hidden}).allowed(name) {
+			http.NotFound(w, r)
+			return
+		}
+
+		info, err := s.readGitInfo(directory, name)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusNotFound)
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(info); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
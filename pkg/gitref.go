@@ -0,0 +1,171 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/fs"
+	"net/http"
+	"os/exec"
+	"path"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// WithGitRef serves the files of a commit, branch or tag instead of the
+// working tree, e.g. to preview how a README looked at a release.
+func WithGitRef(ref string) Option {
+	return func(s *Server) {
+		s.gitRef = ref
+	}
+}
+
+// gitFS serves the files of the directory dir as of a commit, read with the
+// git binary. The tree is listed once, file contents are read when opened.
+type gitFS struct {
+	dir     string
+	commit  string
+	modTime time.Time
+	entries map[string]gitEntry
+}
+
+type gitEntry struct {
+	name   string
+	hash   string
+	size   int64
+	isTree bool
+}
+
+// newGitFS resolves ref in the repository containing dir and lists the part
+// of its tree below dir.
+func newGitFS(dir string, ref string) (*gitFS, error) {
+	out, err := git(dir, "rev-parse", "--verify", ref+"^{commit}")
+	if err != nil {
+		return nil, fmt.Errorf("unknown git ref %q: %v", ref, err)
+	}
+	g := &gitFS{
+		dir:     dir,
+		commit:  strings.TrimSpace(string(out)),
+		entries: map[string]gitEntry{"/": {name: "/", isTree: true}},
+	}
+
+	out, err = git(dir, "show", "-s", "--format=%ct", g.commit)
+	if err != nil {
+		return nil, err
+	}
+	if sec, err := strconv.ParseInt(strings.TrimSpace(string(out)), 10, 64); err == nil {
+		g.modTime = time.Unix(sec, 0)
+	}
+
+	// listing the tree in dir, names are relative to it
+	out, err = git(dir, "ls-tree", "-r", "-t", "-l", "-z", g.commit, ".")
+	if err != nil {
+		return nil, err
+	}
+	for _, line := range bytes.Split(out, []byte{0}) {
+		// <mode> SP <type> SP <hash> SP+ <size> TAB <path>
+		meta, name, ok := strings.Cut(string(line), "\t")
+		if !ok {
+			continue
+		}
+		fields := strings.Fields(meta)
+		if len(fields) != 4 || fields[1] == "commit" {
+			continue
+		}
+		size, _ := strconv.ParseInt(fields[3], 10, 64)
+		p := "/" + name
+		g.entries[p] = gitEntry{name: path.Base(p), hash: fields[2], size: size, isTree: fields[1] == "tree"}
+	}
+	return g, nil
+}
+
+// git runs a git command in dir and returns its output.
+func git(dir string, args ...string) ([]byte, error) {
+	var stderr bytes.Buffer
+	cmd := exec.Command("git", args...)
+	cmd.Dir = dir
+	cmd.Stderr = &stderr
+	out, err := cmd.Output()
+	if err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("git %s: %s", args[0], msg)
+		}
+		return nil, fmt.Errorf("git %s: %v", args[0], err)
+	}
+	return out, nil
+}
+
+func (g *gitFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	e, ok := g.entries[name]
+	if !ok {
+		return nil, fs.ErrNotExist
+	}
+	if e.isTree {
+		return &gitFile{fs: g, entry: e, path: name, Reader: bytes.NewReader(nil)}, nil
+	}
+	content, err := git(g.dir, "cat-file", "blob", e.hash)
+	if err != nil {
+		return nil, err
+	}
+	return &gitFile{fs: g, entry: e, path: name, Reader: bytes.NewReader(content)}, nil
+}
+
+// gitFile is a file or directory of a gitFS.
+type gitFile struct {
+	*bytes.Reader
+	fs    *gitFS
+	entry gitEntry
+	path  string
+	read  bool
+}
+
+func (f *gitFile) Close() error {
+	return nil
+}
+
+func (f *gitFile) Stat() (fs.FileInfo, error) {
+	return gitFileInfo{f.entry, f.fs.modTime}, nil
+}
+
+func (f *gitFile) Readdir(count int) ([]fs.FileInfo, error) {
+	if !f.entry.isTree {
+		return nil, fmt.Errorf("%s is not a directory", f.path)
+	}
+	if f.read {
+		if count > 0 {
+			return nil, io.EOF
+		}
+		return nil, nil
+	}
+	f.read = true
+
+	prefix := strings.TrimSuffix(f.path, "/") + "/"
+	var infos []fs.FileInfo
+	for p, e := range f.fs.entries {
+		rest, ok := strings.CutPrefix(p, prefix)
+		if ok && rest != "" && !strings.Contains(rest, "/") {
+			infos = append(infos, gitFileInfo{e, f.fs.modTime})
+		}
+	}
+	return infos, nil
+}
+
+type gitFileInfo struct {
+	entry   gitEntry
+	modTime time.Time
+}
+
+func (i gitFileInfo) Name() string       { return i.entry.name }
+func (i gitFileInfo) Size() int64        { return i.entry.size }
+func (i gitFileInfo) ModTime() time.Time { return i.modTime }
+func (i gitFileInfo) IsDir() bool        { return i.entry.isTree }
+func (i gitFileInfo) Sys() any           { return nil }
+
+func (i gitFileInfo) Mode() fs.FileMode {
+	if i.entry.isTree {
+		return fs.ModeDir | 0555
+	}
+	return 0444
+}
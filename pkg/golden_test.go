@@ -0,0 +1,39 @@
+package pkg
+
+import (
+	"testing"
+
+	"github.com/chrishrb/go-grip/pkg/griptest"
+)
+
+func TestGolden(t *testing.T) {
+	griptest.Run(t, "testdata/golden", NewParser("auto").MdToHTML)
+}
+
+func TestCanonicalHTML(t *testing.T) {
+	in := `<p class="b"  id="a">Some   <em>text</em> &amp; more<br></p>
+<pre><code>  a &lt; b
+</code></pre>`
+	want := `<p class="b" id="a">
+  Some
+  <em>
+    text
+  </em>
+  &amp; more
+  <br/>
+</p>
+<pre>
+<code>  a &lt; b
+</code>
+</pre>
+`
+	if got := string(CanonicalHTML([]byte(in))); got != want {
+		t.Errorf("CanonicalHTML =\n%s\nwant\n%s", got, want)
+	}
+	// attribute order doesn't matter
+	a := CanonicalHTML([]byte(`<a href="x" title="y">link</a>`))
+	b := CanonicalHTML([]byte(`<a title="y" href="x">link</a>`))
+	if string(a) != string(b) {
+		t.Errorf("attribute order changes the snapshot: %s != %s", a, b)
+	}
+}
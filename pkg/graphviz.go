@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const graphvizTimeout = 30 * time.Second
+
+// DefaultGraphvizDot is the Graphviz binary used to render ```dot code blocks
+// if it is installed.
+const DefaultGraphvizDot = "dot"
+
+// installedGraphvizDot returns DefaultGraphvizDot if it is in PATH, or an
+// empty string to show dot code blocks as code.
+func installedGraphvizDot() string {
+	if _, err := exec.LookPath(DefaultGraphvizDot); err != nil {
+		return ""
+	}
+	return DefaultGraphvizDot
+}
+
+// WithGraphviz renders ```dot and ```graphviz code blocks to SVG with the
+// Graphviz dot binary at path. An empty path shows them as code instead.
+func WithGraphviz(path string) ParserOption {
+	return func(p *Parser) {
+		p.graphvizDot = path
+	}
+}
+
+// renderGraphviz lays out a DOT graph with dot and returns it as inline SVG.
+// Rendered graphs are kept in cache. dot is killed when ctx is done.
+func renderGraphviz(ctx context.Context, source string, dot string, cache *diagramCache) (string, error) {
+	svg, err := cache.render("dot:"+dot, source, func() ([]byte, error) {
+		return runGraphviz(ctx, source, dot)
+	})
+	if err != nil {
+		return "", err
+	}
+	return `<div class="grip-graphviz">` + string(svg) + `</div>`, nil
+}
+
+// runGraphviz runs dot and returns the SVG without XML declaration and doctype,
+// which are not allowed inside HTML.
+func runGraphviz(ctx context.Context, source string, dot string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, graphvizTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, dot, "-Tsvg")
+	cmd.Stdin = strings.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to render graphviz diagram: %s", msg)
+		}
+		return nil, fmt.Errorf("failed to render graphviz diagram: %v", err)
+	}
+
+	svg := stdout.Bytes()
+	i := bytes.Index(svg, []byte("<svg"))
+	if i < 0 {
+		return nil, fmt.Errorf("failed to render graphviz diagram: dot returned no SVG")
+	}
+	return svg[i:], nil
+}
@@ -0,0 +1,107 @@
+// Package griptest renders markdown fixtures and compares the output with
+// golden HTML files. It is used by go-grip's own tests and can be used by
+// programs embedding go-grip to catch rendering regressions.
+//
+// A fixture directory contains pairs of files, e.g. tables.md and
+// tables.html. Set GRIPTEST_UPDATE=1 to (re)write the golden files from the
+// current output.
+package griptest
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"testing"
+)
+
+// RenderFunc converts markdown to HTML.
+type RenderFunc func(markdown []byte) []byte
+
+var (
+	whitespace    = regexp.MustCompile(`\s+`)
+	betweenTags   = regexp.MustCompile(`>\s+<`)
+	tagBoundaries = regexp.MustCompile(`>\s*<`)
+)
+
+// Normalize makes rendered HTML comparable by unifying line endings and
+// collapsing whitespace, including all whitespace between tags.
+func Normalize(html []byte) []byte {
+	s := strings.ReplaceAll(string(html), "\r\n", "\n")
+	s = whitespace.ReplaceAllString(s, " ")
+	s = betweenTags.ReplaceAllString(s, "><")
+	return []byte(strings.TrimSpace(s))
+}
+
+// Compare reports whether got and want are equal after normalization. If
+// they are not, the returned string describes the first difference.
+func Compare(got, want []byte) (bool, string) {
+	g := lines(Normalize(got))
+	w := lines(Normalize(want))
+
+	for i := 0; i < len(g) || i < len(w); i++ {
+		var gl, wl string
+		if i < len(g) {
+			gl = g[i]
+		}
+		if i < len(w) {
+			wl = w[i]
+		}
+		if gl != wl {
+			return false, fmt.Sprintf("line %d:\n  got:  %s\n  want: %s", i+1, gl, wl)
+		}
+	}
+	return true, ""
+}
+
+// Run renders every *.md fixture in dir and compares the output with the
+// golden *.html file of the same name, running each fixture as a subtest.
+func Run(t *testing.T, dir string, render RenderFunc) {
+	t.Helper()
+
+	fixtures, err := filepath.Glob(filepath.Join(dir, "*.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(fixtures) == 0 {
+		t.Fatalf("no fixtures found in %s", dir)
+	}
+
+	update := os.Getenv("GRIPTEST_UPDATE") != ""
+
+	for _, fixture := range fixtures {
+		name := strings.TrimSuffix(filepath.Base(fixture), ".md")
+		t.Run(name, func(t *testing.T) {
+			input, err := os.ReadFile(fixture)
+			if err != nil {
+				t.Fatal(err)
+			}
+			got := render(input)
+
+			golden := strings.TrimSuffix(fixture, ".md") + ".html"
+			if update {
+				if err := os.WriteFile(golden, got, 0644); err != nil {
+					t.Fatal(err)
+				}
+				return
+			}
+
+			want, err := os.ReadFile(golden)
+			if err != nil {
+				t.Fatalf("missing golden file, run with GRIPTEST_UPDATE=1: %v", err)
+			}
+			if ok, diff := Compare(got, want); !ok {
+				t.Errorf("output differs from %s\n%s", golden, diff)
+			}
+		})
+	}
+}
+
+// lines splits normalized HTML after each tag so differences can be
+// reported close to where they occur.
+func lines(html []byte) []string {
+	s := tagBoundaries.ReplaceAllString(string(html), ">\n<")
+	return strings.Split(string(bytes.TrimSpace([]byte(s))), "\n")
+}
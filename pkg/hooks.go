@@ -0,0 +1,44 @@
+package pkg
+
+import "github.com/gomarkdown/markdown/ast"
+
+// SourceTransform rewrites markdown source before it is parsed, e.g. to
+// expand custom shortcodes into markdown.
+type SourceTransform func(md []byte) []byte
+
+// ASTVisitor inspects or modifies the parsed document before it is rendered,
+// e.g. to rewrite link destinations.
+type ASTVisitor func(doc ast.Node)
+
+// HTMLFilter rewrites the rendered HTML of a document.
+type HTMLFilter func(html []byte) []byte
+
+// WithSourceTransform adds a transform run on the source of every document.
+// Transforms run in the order they were added.
+func WithSourceTransform(t SourceTransform) ParserOption {
+	return func(p *Parser) {
+		p.transforms = append(p.transforms, t)
+	}
+}
+
+// WithASTVisitor adds a visitor run on every parsed document, after the
+// built-in processing such as heading anchors.
+func WithASTVisitor(v ASTVisitor) ParserOption {
+	return func(p *Parser) {
+		p.visitors = append(p.visitors, v)
+	}
+}
+
+// WithHTMLFilter adds a filter run on the rendered HTML of every document.
+func WithHTMLFilter(f HTMLFilter) ParserOption {
+	return func(p *Parser) {
+		p.filters = append(p.filters, f)
+	}
+}
+
+// Use registers extensions such as WithSourceTransform on an existing parser.
+func (m *Parser) Use(opts ...ParserOption) {
+	for _, opt := range opts {
+		opt(m)
+	}
+}
@@ -0,0 +1,238 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+)
+
+const (
+	imageProxyPath = "/camo/"
+
+	// DefaultImageCacheTTL is how long proxied images are served from the
+	// cache before they are fetched again.
+	DefaultImageCacheTTL = 24 * time.Hour
+
+	maxProxiedImageSize = 20 << 20
+)
+
+// imgSrc matches the src attribute of img tags, written by the renderer or
+// as raw HTML in the document.
+var imgSrc = regexp.MustCompile(`(<img\b[^>]*?\ssrc=")(https?://[^"]+)(")`)
+
+// imageProxy fetches remote images for the preview like GitHub's camo, and
+// keeps them in a disk cache so they still show up offline. Proxy URLs are
+// signed, so the proxy only fetches images referenced by rendered documents.
+type imageProxy struct {
+	cacheDir string
+	ttl      time.Duration
+	offline  bool
+
+	// the key signing proxy URLs is generated on first use, see signingKey
+	keyOnce sync.Once
+	key     []byte
+	keyErr  error
+}
+
+// WithImageProxy serves remote images of documents through the local server,
+// caching them in cacheDir for ttl. cacheDir defaults to the user cache
+// directory. In offline mode images are only served from the cache, with a
+// placeholder for images that were never fetched.
+func WithImageProxy(cacheDir string, ttl time.Duration, offline bool) Option {
+	return func(s *Server) {
+		if cacheDir == "" {
+			if dir, err := os.UserCacheDir(); err == nil {
+				cacheDir = filepath.Join(dir, "go-grip", "images")
+			}
+		}
+		if ttl <= 0 {
+			ttl = DefaultImageCacheTTL
+		}
+		s.imageProxy = &imageProxy{cacheDir: cacheDir, ttl: ttl, offline: offline}
+	}
+}
+
+// signingKey returns the key signing proxy URLs, generating it on first use.
+func (p *imageProxy) signingKey() ([]byte, error) {
+	p.keyOnce.Do(func() {
+		key := make([]byte, 32)
+		if _, err := rand.Read(key); err != nil {
+			p.keyErr = fmt.Errorf("failed to generate image proxy key: %v", err)
+			return
+		}
+		p.key = key
+	})
+	return p.key, p.keyErr
+}
+
+func (p *imageProxy) sign(rawURL string) (string, error) {
+	key, err := p.signingKey()
+	if err != nil {
+		return "", err
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write([]byte(rawURL))
+	return hex.EncodeToString(mac.Sum(nil)), nil
+}
+
+// rewrite points remote image sources of rendered HTML to the proxy. Without
+// a signing key they are left alone.
+func (p *imageProxy) rewrite(out []byte) []byte {
+	return imgSrc.ReplaceAllFunc(out, func(m []byte) []byte {
+		parts := imgSrc.FindSubmatch(m)
+		rawURL := html.UnescapeString(string(parts[2]))
+		sig, err := p.sign(rawURL)
+		if err != nil {
+			return m
+		}
+		proxied := imageProxyPath + sig + "?url=" + url.QueryEscape(rawURL)
+		return []byte(string(parts[1]) + html.EscapeString(proxied) + string(parts[3]))
+	})
+}
+
+func (p *imageProxy) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	rawURL := r.URL.Query().Get("url")
+	want, err := p.sign(rawURL)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	sig := strings.TrimPrefix(r.URL.Path, imageProxyPath)
+	if !hmac.Equal([]byte(sig), []byte(want)) {
+		http.Error(w, "invalid signature", http.StatusForbidden)
+		return
+	}
+
+	sum := sha256.Sum256([]byte(rawURL))
+	cacheFile := filepath.Join(p.cacheDir, hex.EncodeToString(sum[:]))
+	cached := openCachedImage(cacheFile)
+	if cached != nil {
+		defer cached.file.Close()
+		if p.offline || time.Since(cached.modTime) < p.ttl {
+			p.serveImage(w, r, cached.contentType, cached.modTime, cached.body)
+			return
+		}
+	}
+	if p.offline {
+		servePlaceholder(w, "offline", http.StatusOK)
+		return
+	}
+
+	contentType, body, err := p.fetch(rawURL, cacheFile)
+	if err != nil {
+		slog.Warn("failed to fetch image", "url", rawURL, "err", err)
+		if cached != nil {
+			// a stale copy is better than none
+			p.serveImage(w, r, cached.contentType, cached.modTime, cached.body)
+			return
+		}
+		servePlaceholder(w, "unavailable", http.StatusBadGateway)
+		return
+	}
+	p.serveImage(w, r, contentType, time.Now(), bytes.NewReader(body))
+}
+
+// maxImageTypeLen limits the line of the content type of cached images.
+const maxImageTypeLen = 256
+
+// cachedImage is an open file of the image cache. Cache files start with a
+// line of the content type followed by the image, so both are replaced
+// together when an image is fetched again.
+type cachedImage struct {
+	file        *os.File
+	contentType string
+	modTime     time.Time
+	body        *io.SectionReader
+}
+
+// openCachedImage opens the cached copy of an image, or returns nil if there
+// is none.
+func openCachedImage(cacheFile string) *cachedImage {
+	f, err := os.Open(cacheFile)
+	if err != nil {
+		return nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		f.Close()
+		return nil
+	}
+	head := make([]byte, maxImageTypeLen)
+	n, _ := f.ReadAt(head, 0)
+	i := bytes.IndexByte(head[:n], '\n')
+	if i < 0 || !strings.HasPrefix(string(head[:i]), "image/") {
+		f.Close()
+		return nil
+	}
+	return &cachedImage{
+		file:        f,
+		contentType: string(head[:i]),
+		modTime:     info.ModTime(),
+		body:        io.NewSectionReader(f, int64(i+1), info.Size()-int64(i+1)),
+	}
+}
+
+// fetch downloads an image into the cache and returns its content type and
+// content.
+func (p *imageProxy) fetch(rawURL string, cacheFile string) (string, []byte, error) {
+	resp, err := httpClient.Get(rawURL)
+	if err != nil {
+		return "", nil, err
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return "", nil, fmt.Errorf("unexpected status %s", resp.Status)
+	}
+	contentType := resp.Header.Get("Content-Type")
+	if !strings.HasPrefix(contentType, "image/") || len(contentType) >= maxImageTypeLen || strings.ContainsAny(contentType, "\r\n") {
+		return "", nil, fmt.Errorf("unexpected content type %q", contentType)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxProxiedImageSize+1))
+	if err != nil {
+		return "", nil, err
+	}
+	if len(body) > maxProxiedImageSize {
+		return "", nil, fmt.Errorf("image larger than %d bytes", maxProxiedImageSize)
+	}
+
+	if err := os.MkdirAll(p.cacheDir, 0755); err != nil {
+		return "", nil, err
+	}
+	if err := writeFileAtomic(cacheFile, append([]byte(contentType+"\n"), body...)); err != nil {
+		return "", nil, err
+	}
+	return contentType, body, nil
+}
+
+func (p *imageProxy) serveImage(w http.ResponseWriter, r *http.Request, contentType string, modTime time.Time, content io.ReadSeeker) {
+	w.Header().Set("Content-Type", contentType)
+	// images may contain scripts, e.g. SVGs opened directly
+	w.Header().Set("Content-Security-Policy", "default-src 'none'; style-src 'unsafe-inline'; sandbox")
+	w.Header().Set("Cache-Control", fmt.Sprintf("max-age=%d", int(p.ttl.Seconds())))
+	http.ServeContent(w, r, "", modTime, content)
+}
+
+// servePlaceholder answers with an image showing why the real one is missing.
+func servePlaceholder(w http.ResponseWriter, reason string, status int) {
+	w.Header().Set("Content-Type", "image/svg+xml")
+	w.Header().Set("Cache-Control", "no-store")
+	w.WriteHeader(status)
+	fmt.Fprintf(w, `<svg xmlns="http://www.w3.org/2000/svg" width="120" height="20" role="img" aria-label="image %[1]s">`+
+		`<rect width="120" height="20" rx="3" fill="#d0d7de"/>`+
+		`<text x="60" y="14" fill="#57606a" font-family="sans-serif" font-size="11" text-anchor="middle">image %[1]s</text></svg>`, reason)
+}
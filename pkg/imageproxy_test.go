@@ -0,0 +1,100 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func TestImageProxy(t *testing.T) {
+	var fetches atomic.Int32
+	upstream := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		fetches.Add(1)
+		w.Header().Set("Content-Type", "image/png")
+		w.Write([]byte("png " + r.URL.Path))
+	}))
+	defer upstream.Close()
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"), WithImageProxy(t.TempDir(), time.Hour, false))
+	p := s.imageProxy
+	get := func(target string) *httptest.ResponseRecorder {
+		t.Helper()
+		rec := httptest.NewRecorder()
+		p.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, target, nil))
+		return rec
+	}
+	proxied := func(rawURL string) string {
+		t.Helper()
+		sig, err := p.sign(rawURL)
+		if err != nil {
+			t.Fatal(err)
+		}
+		return imageProxyPath + sig + "?url=" + url.QueryEscape(rawURL)
+	}
+	image := upstream.URL + "/a.png"
+
+	out := string(p.rewrite([]byte(`<img src="` + image + `" alt="a">`)))
+	if want := `<img src="` + strings.ReplaceAll(proxied(image), "&", "&amp;") + `" alt="a">`; out != want {
+		t.Errorf("got %s, want %s", out, want)
+	}
+
+	for _, target := range []string{
+		imageProxyPath + "?url=" + url.QueryEscape(image),
+		imageProxyPath + strings.Repeat("0", 64) + "?url=" + url.QueryEscape(image),
+		proxied(image) + "x",
+	} {
+		if rec := get(target); rec.Code != http.StatusForbidden {
+			t.Errorf("%s: expected 403, got %d", target, rec.Code)
+		}
+	}
+	if fetches.Load() != 0 {
+		t.Fatalf("expected no fetches for invalid signatures, got %d", fetches.Load())
+	}
+
+	// the first request fetches the image, the next one reads the cache
+	for i := 0; i < 2; i++ {
+		rec := get(proxied(image))
+		if rec.Code != http.StatusOK || rec.Body.String() != "png /a.png" || rec.Header().Get("Content-Type") != "image/png" {
+			t.Fatalf("request %d: got %d %q of type %q", i, rec.Code, rec.Body, rec.Header().Get("Content-Type"))
+		}
+	}
+	if fetches.Load() != 1 {
+		t.Errorf("expected one fetch, got %d", fetches.Load())
+	}
+
+	// expired images are fetched again
+	files, err := os.ReadDir(p.cacheDir)
+	if err != nil || len(files) != 1 {
+		t.Fatalf("expected one cache file, got %v: %v", files, err)
+	}
+	old := time.Now().Add(-2 * time.Hour)
+	if err := os.Chtimes(filepath.Join(p.cacheDir, files[0].Name()), old, old); err != nil {
+		t.Fatal(err)
+	}
+	if rec := get(proxied(image)); rec.Code != http.StatusOK || fetches.Load() != 2 {
+		t.Errorf("expected the expired image to be fetched again, got %d after %d fetches", rec.Code, fetches.Load())
+	}
+
+	// offline, expired images are served from the cache and missing ones
+	// get a placeholder
+	if err := os.Chtimes(filepath.Join(p.cacheDir, files[0].Name()), old, old); err != nil {
+		t.Fatal(err)
+	}
+	p.offline = true
+	if rec := get(proxied(image)); rec.Body.String() != "png /a.png" {
+		t.Errorf("expected the cached image offline, got %q", rec.Body)
+	}
+	rec := get(proxied(upstream.URL + "/b.png"))
+	if rec.Code != http.StatusOK || rec.Header().Get("Content-Type") != "image/svg+xml" || !strings.Contains(rec.Body.String(), "image offline") {
+		t.Errorf("expected the offline placeholder, got %d %q", rec.Code, rec.Body)
+	}
+	if fetches.Load() != 2 {
+		t.Errorf("expected no fetches offline, got %d", fetches.Load()-2)
+	}
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+	"time"
+)
+
+// includeRegex matches an include directive on a line of its own, e.g.
+// <!-- include: chapters/intro.md -->.
+var includeRegex = regexp.MustCompile(`^<!--\s*include:\s*(.+?)\s*-->$`)
+
+// embedFiles fills in the source snippets of the markdown file name, a slash
+// separated path in fsys, and expands its include directives and variables
+// if enabled.
+// Files are converted to UTF-8 first, see WithEncoding, and MDX files into
+// markdown, see MDXToMarkdown. It
+// also returns the latest modification time of the embedded files, which is
+// zero if nothing was embedded.
+func (s *Server) embedFiles(fsys http.FileSystem, name string, content []byte) ([]byte, time.Time) {
+	content, latest, _ := s.embed(fsys, name, content)
+	return content, latest
+}
+
+// embed is embedFiles, also returning the paths of the embedded files.
+func (s *Server) embed(fsys http.FileSystem, name string, content []byte) ([]byte, time.Time, []string) {
+	content = s.encoding.Decode(content)
+	if !s.IsMarkdown(name) {
+		return content, time.Time{}, nil
+	}
+	if isMDX(name) {
+		content = MDXToMarkdown(content)
+	}
+	e := embedder{fsys: fsys, includes: s.includes, encoding: s.encoding}
+	if s.vars {
+		vars, modTime, err := s.readVars(content)
+		if err != nil {
+			block, _, body, _ := cutFrontMatter(content)
+			var buf bytes.Buffer
+			buf.Write(block)
+			writeVarsError(&buf, err)
+			buf.Write(body)
+			content = buf.Bytes()
+		}
+		e.vars, e.latest = vars, modTime
+	}
+	if modTime := s.parser.bibliographyModTime(); modTime.After(e.latest) {
+		e.latest = modTime
+	}
+	return e.expand([]string{path.Clean("/" + name)}, content), e.latest, e.files
+}
+
+type embedder struct {
+	fsys     http.FileSystem
+	includes bool
+	encoding Encoding
+	// vars are substituted into the expanded files if not nil, see WithVars
+	vars   map[string]any
+	latest time.Time
+	files  []string
+}
+
+// expand embeds files into content, the file on top of stack. Directives in
+// fenced code blocks are left alone, and files that would include themselves
+// are reported instead of being expanded.
+func (e *embedder) expand(stack []string, content []byte) []byte {
+	if e.vars != nil {
+		content = substituteVars(stack[len(stack)-1], content, e.vars)
+	}
+	var buf bytes.Buffer
+	var fence string
+	skip := false
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+				skip = false
+			}
+			if !skip {
+				buf.WriteString(line)
+			}
+			continue
+		}
+		if marker, _, ok := fenceOpening([]byte(trimmed)); ok {
+			fence = marker
+			// a snippet replaces the content of its code block
+			_, attrs := parseFenceInfo(strings.TrimLeft(trimmed, "`~"))
+			if file := attrs["file"]; file != "" {
+				snippet, err := e.snippet(stack, file, attrs["lines"])
+				if err != nil {
+					writeEmbedError(&buf, "embed snippet", file, err)
+					buf.WriteString(line)
+				} else {
+					buf.WriteString(line)
+					buf.WriteString(snippet)
+					skip = true
+				}
+				continue
+			}
+			buf.WriteString(line)
+			continue
+		}
+
+		m := includeRegex.FindStringSubmatch(trimmed)
+		if m == nil || !e.includes {
+			buf.WriteString(line)
+			continue
+		}
+
+		target := resolveEmbed(stack, m[1])
+		if slices.Contains(stack, target) {
+			writeEmbedError(&buf, "include", m[1], fmt.Errorf("include cycle %s", strings.Join(append(stack, target), " → ")))
+			continue
+		}
+		included, err := e.read(target)
+		if err != nil {
+			writeEmbedError(&buf, "include", m[1], err)
+			continue
+		}
+
+		buf.Write(e.expand(append(slices.Clip(stack), target), included))
+		if buf.Len() > 0 && buf.Bytes()[buf.Len()-1] != '\n' {
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+// resolveEmbed resolves name relative to the file on top of stack.
+func resolveEmbed(stack []string, name string) string {
+	if strings.HasPrefix(name, "/") {
+		return path.Clean(name)
+	}
+	return path.Join(path.Dir(stack[len(stack)-1]), name)
+}
+
+// read returns the content of the file name and records its modification time.
+func (e *embedder) read(name string) ([]byte, error) {
+	f, err := e.fsys.Open(name)
+	if err != nil {
+		return nil, fmt.Errorf("file not found")
+	}
+	defer f.Close()
+
+	info, err := f.Stat()
+	if err != nil {
+		return nil, err
+	}
+	if info.IsDir() {
+		return nil, fmt.Errorf("is a directory")
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, err
+	}
+	if info.ModTime().After(e.latest) {
+		e.latest = info.ModTime()
+	}
+	e.files = append(e.files, name)
+	return e.encoding.Decode(content), nil
+}
+
+// writeEmbedError shows a file that failed to embed as a caution alert,
+// saying what failed, e.g. "include".
+func writeEmbedError(buf *bytes.Buffer, action string, name string, err error) {
+	fmt.Fprintf(buf, "\n> [!CAUTION]\n> Failed to %s `%s`: %v\n\n", action, name, err)
+}
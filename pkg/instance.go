@@ -0,0 +1,119 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"net/url"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+)
+
+// Instance describes a running preview server. Every server registers itself
+// with a small file in the instance directory while it is running.
+type Instance struct {
+	ID      int       `json:"id"`
+	Port    int       `json:"port"`
+	File    string    `json:"file"`
+	URLs    []string  `json:"urls"`
+	Started time.Time `json:"started"`
+}
+
+func instanceDir() (string, error) {
+	cacheDir, err := os.UserCacheDir()
+	if err != nil {
+		return "", fmt.Errorf("failed to get cache directory: %v", err)
+	}
+	return filepath.Join(cacheDir, "go-grip", "instances"), nil
+}
+
+// registerInstance records the current process as a running instance and
+// returns a function that removes the record again. Queries are removed from
+// the URLs, since they may carry the access token, and only the user can
+// read the record.
+func registerInstance(inst Instance) (func(), error) {
+	dir, err := instanceDir()
+	if err != nil {
+		return nil, err
+	}
+	inst.URLs = append([]string(nil), inst.URLs...)
+	for i, u := range inst.URLs {
+		if parsed, err := url.Parse(u); err == nil {
+			parsed.RawQuery, parsed.Fragment = "", ""
+			inst.URLs[i] = parsed.String()
+		}
+	}
+	if err := os.MkdirAll(dir, 0700); err != nil {
+		return nil, fmt.Errorf("failed to create instance directory: %v", err)
+	}
+
+	data, err := json.Marshal(inst)
+	if err != nil {
+		return nil, err
+	}
+
+	file := filepath.Join(dir, strconv.Itoa(inst.ID)+".json")
+	if err := os.WriteFile(file, data, 0600); err != nil {
+		return nil, fmt.Errorf("failed to write instance file: %v", err)
+	}
+
+	return func() { os.Remove(file) }, nil
+}
+
+// ListInstances returns all running instances sorted by ID. Records of
+// processes that are no longer running are removed.
+func ListInstances() ([]Instance, error) {
+	dir, err := instanceDir()
+	if err != nil {
+		return nil, err
+	}
+
+	files, err := filepath.Glob(filepath.Join(dir, "*.json"))
+	if err != nil {
+		return nil, err
+	}
+
+	var instances []Instance
+	for _, file := range files {
+		data, err := os.ReadFile(file)
+		if err != nil {
+			continue
+		}
+		var inst Instance
+		if err := json.Unmarshal(data, &inst); err != nil || !processRunning(inst.ID) {
+			os.Remove(file)
+			continue
+		}
+		instances = append(instances, inst)
+	}
+
+	sort.Slice(instances, func(i, j int) bool {
+		return instances[i].ID < instances[j].ID
+	})
+	return instances, nil
+}
+
+// FindInstance returns the running instance with the given ID or port.
+func FindInstance(idOrPort int) (Instance, error) {
+	instances, err := ListInstances()
+	if err != nil {
+		return Instance{}, err
+	}
+	for _, inst := range instances {
+		if inst.ID == idOrPort || inst.Port == idOrPort {
+			return inst, nil
+		}
+	}
+	return Instance{}, fmt.Errorf("no running instance with id or port %d", idOrPort)
+}
+
+// Stop asks the instance to shut down.
+func (inst Instance) Stop() error {
+	p, err := os.FindProcess(inst.ID)
+	if err != nil {
+		return err
+	}
+	return stopProcess(p)
+}
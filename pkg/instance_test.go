@@ -0,0 +1,46 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"runtime"
+	"slices"
+	"strconv"
+	"testing"
+)
+
+func TestRegisterInstanceHidesTokens(t *testing.T) {
+	t.Setenv("XDG_CACHE_HOME", t.TempDir())
+	t.Setenv("HOME", t.TempDir())
+	t.Setenv("LocalAppData", t.TempDir())
+
+	unregister, err := registerInstance(Instance{
+		ID:   os.Getpid(),
+		URLs: []string{"http://localhost:6419/README.md?token=secret", "http://192.168.1.2:6419/?token=secret#top"},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer unregister()
+
+	instances, err := ListInstances()
+	if err != nil || len(instances) != 1 {
+		t.Fatalf("expected the registered instance, got %v: %v", instances, err)
+	}
+	want := []string{"http://localhost:6419/README.md", "http://192.168.1.2:6419/"}
+	if !slices.Equal(instances[0].URLs, want) {
+		t.Errorf("URLs = %v, want %v", instances[0].URLs, want)
+	}
+
+	if runtime.GOOS == "windows" {
+		return
+	}
+	dir, _ := instanceDir()
+	info, err := os.Stat(filepath.Join(dir, strconv.Itoa(os.Getpid())+".json"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if perm := info.Mode().Perm(); perm != 0600 {
+		t.Errorf("instance file is readable by others: %v", perm)
+	}
+}
@@ -0,0 +1,20 @@
+//go:build !windows
+
+package pkg
+
+import (
+	"os"
+	"syscall"
+)
+
+func processRunning(pid int) bool {
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	return p.Signal(syscall.Signal(0)) == nil
+}
+
+func stopProcess(p *os.Process) error {
+	return p.Signal(syscall.SIGTERM)
+}
@@ -0,0 +1,21 @@
+//go:build windows
+
+package pkg
+
+import (
+	"os"
+)
+
+func processRunning(pid int) bool {
+	// FindProcess opens a handle and fails if the process does not exist
+	p, err := os.FindProcess(pid)
+	if err != nil {
+		return false
+	}
+	p.Release()
+	return true
+}
+
+func stopProcess(p *os.Process) error {
+	return p.Kill()
+}
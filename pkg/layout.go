@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"sync"
+	"text/template"
+
+	"github.com/chrishrb/go-grip/defaults"
+)
+
+var defaultLayout = template.Must(template.New("layout.html").Funcs(assetFuncs).ParseFS(defaults.Templates, "templates/layout.html"))
+
+// PartialNames are the blocks of the page layout that WithPartials can fill:
+// the end of the head element, the top of the page, the end of the content
+// and the footer.
+var PartialNames = []string{"head", "header", "after-content", "footer"}
+
+// layout provides the parsed page template. The embedded template is parsed
+// once, a user-supplied override and partials are parsed again only when one
+// of the files changes.
+type layout struct {
+	override string
+	partials map[string]string
+
+	mu      sync.Mutex
+	tmpl    *template.Template
+	version string
+}
+
+// WithPartials fills blocks of the page layout, see PartialNames, with the
+// template files they map to, e.g. to add an analytics snippet to the head
+// or a navigation bar to the header without replacing the whole layout. The
+// partials are executed with the data of the page and parsed again whenever
+// they change.
+func WithPartials(partials map[string]string) Option {
+	return func(s *Server) {
+		if s.layout == nil {
+			s.layout = &layout{}
+		}
+		s.layout.partials = partials
+	}
+}
+
+// files returns the template files of the layout.
+func (l *layout) files() []string {
+	var files []string
+	if l.override != "" {
+		files = append(files, l.override)
+	}
+	for _, name := range PartialNames {
+		if file, ok := l.partials[name]; ok {
+			files = append(files, file)
+		}
+	}
+	return files
+}
+
+// get returns the current template and reports whether it changed since the
+// last call.
+func (l *layout) get() (*template.Template, bool, error) {
+	if l == nil || len(l.files()) == 0 {
+		return defaultLayout, false, nil
+	}
+
+	var version strings.Builder
+	for _, file := range l.files() {
+		info, err := os.Stat(file)
+		if err != nil {
+			return nil, false, fmt.Errorf("failed to read template: %v", err)
+		}
+		fmt.Fprintf(&version, "%s@%d;", file, info.ModTime().UnixNano())
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	if l.tmpl != nil && version.String() == l.version {
+		return l.tmpl, false, nil
+	}
+
+	tmpl, err := l.parse()
+	if err != nil {
+		return nil, false, err
+	}
+	changed := l.tmpl != nil
+	l.tmpl = tmpl
+	l.version = version.String()
+	return tmpl, changed, nil
+}
+
+// parse parses the override, or a copy of the embedded template, and
+// defines the partials in it.
+func (l *layout) parse() (*template.Template, error) {
+	var tmpl *template.Template
+	var err error
+	if l.override != "" {
+		tmpl, err = template.New(filepath.Base(l.override)).Funcs(assetFuncs).ParseFiles(l.override)
+	} else {
+		tmpl, err = defaultLayout.Clone()
+	}
+	if err != nil {
+		return nil, fmt.Errorf("failed to parse template: %v", err)
+	}
+
+	for _, name := range PartialNames {
+		file, ok := l.partials[name]
+		if !ok {
+			continue
+		}
+		content, err := os.ReadFile(file)
+		if err != nil {
+			return nil, fmt.Errorf("failed to read %s partial: %v", name, err)
+		}
+		if _, err := tmpl.New(name).Parse(string(content)); err != nil {
+			return nil, fmt.Errorf("failed to parse %s partial: %v", name, err)
+		}
+	}
+	return tmpl, nil
+}
+
+// currentLayout returns the page template, dropping cached pages when a
+// template override changed.
+func (s *Server) currentLayout() (*template.Template, error) {
+	tmpl, changed, err := s.layout.get()
+	if err != nil {
+		return nil, err
+	}
+	if changed {
+		s.cache.clear()
+	}
+	return tmpl, nil
+}
+
+func (s *Server) executeTemplate(w io.Writer, html htmlStruct) error {
+	tmpl, err := s.currentLayout()
+	if err != nil {
+		return err
+	}
+	html.Lang = s.lang
+	if html.Lang == "" {
+		html.Lang = DefaultLang
+	}
+	html.Messages = s.messages()
+	html.BasePath = s.basePath
+	if !s.strictOffline && s.basePath == "" {
+		return tmpl.Execute(w, html)
+	}
+
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, html); err != nil {
+		return err
+	}
+	if s.strictOffline {
+		if err := checkOffline(buf.Bytes()); err != nil {
+			return err
+		}
+	}
+	_, err = w.Write(s.prefixURLs(buf.Bytes()))
+	return err
+}
@@ -0,0 +1,80 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"net/http"
+)
+
+var (
+	errTooManyRenders = errors.New("too many concurrent renders")
+	errRenderTimeout  = errors.New("render timed out")
+)
+
+// limitRender runs fn while enforcing the configured limits on concurrent
+// renders and render time. The context passed to fn is cancelled when the
+// render times out, stopping the diagram commands and requests of the render
+// and the writing of its HTML. Parsing the markdown can't be interrupted
+// though, so a render that times out keeps its slot until fn actually
+// returns: slow documents make further renders fail with errTooManyRenders
+// instead of piling up.
+func (s *Server) limitRender(fn func(ctx context.Context) ([]byte, error)) ([]byte, error) {
+	fn = s.metrics.instrument(fn)
+	if s.renderSlots != nil {
+		select {
+		case s.renderSlots <- struct{}{}:
+		default:
+			return nil, errTooManyRenders
+		}
+	}
+
+	if s.renderTimeout <= 0 {
+		defer s.releaseRenderSlot()
+		return fn(context.Background())
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), s.renderTimeout)
+	defer cancel()
+
+	type result struct {
+		html []byte
+		err  error
+	}
+	done := make(chan result, 1)
+	go func() {
+		defer s.releaseRenderSlot()
+		html, err := fn(ctx)
+		done <- result{html, err}
+	}()
+
+	select {
+	case res := <-done:
+		return res.html, res.err
+	case <-ctx.Done():
+		return nil, errRenderTimeout
+	}
+}
+
+func (s *Server) releaseRenderSlot() {
+	if s.renderSlots != nil {
+		<-s.renderSlots
+	}
+}
+
+// renderError reports a failed render, answering limit violations with 503.
+func renderError(w http.ResponseWriter, err error) {
+	status := renderErrorStatus(err)
+	if status == http.StatusServiceUnavailable {
+		w.Header().Set("Retry-After", "1")
+	}
+	http.Error(w, err.Error(), status)
+}
+
+// renderErrorStatus returns the status of a failed render: 503 for limit
+// violations, 500 otherwise.
+func renderErrorStatus(err error) int {
+	if errors.Is(err, errTooManyRenders) || errors.Is(err, errRenderTimeout) {
+		return http.StatusServiceUnavailable
+	}
+	return http.StatusInternalServerError
+}
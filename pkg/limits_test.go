@@ -0,0 +1,79 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime"
+	"testing"
+	"time"
+)
+
+func TestRenderTimeoutStopsDiagrams(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs a shell script as dot")
+	}
+	dot := filepath.Join(t.TempDir(), "dot")
+	if err := os.WriteFile(dot, []byte("#!/bin/sh\nexec sleep 10\n"), 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light", WithGraphviz(dot)),
+		WithMaxRenders(1), WithRenderTimeout(50*time.Millisecond))
+	if _, err := s.Render([]byte("```dot\ndigraph { a -> b }\n```\n")); !errors.Is(err, errRenderTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// the slot is released once the killed command returns
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.renderSlots) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the diagram command to be stopped")
+		}
+		time.Sleep(10 * time.Millisecond)
+	}
+}
+
+func TestRenderTimeoutKeepsSlot(t *testing.T) {
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"),
+		WithMaxRenders(1), WithRenderTimeout(20*time.Millisecond))
+
+	release := make(chan struct{})
+	stopped := make(chan error, 1)
+	_, err := s.limitRender(func(ctx context.Context) ([]byte, error) {
+		<-ctx.Done()
+		// like a parse, which doesn't look at the context
+		<-release
+		stopped <- ctx.Err()
+		return nil, nil
+	})
+	if !errors.Is(err, errRenderTimeout) {
+		t.Fatalf("expected a timeout, got %v", err)
+	}
+
+	// the slot is taken until the render returns
+	ran := false
+	render := func(ctx context.Context) ([]byte, error) {
+		ran = true
+		return []byte("ok"), nil
+	}
+	if _, err := s.limitRender(render); !errors.Is(err, errTooManyRenders) || ran {
+		t.Fatalf("expected the slot to be taken, got %v", err)
+	}
+
+	close(release)
+	if err := <-stopped; !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("expected the context of the render to be cancelled, got %v", err)
+	}
+	deadline := time.Now().Add(5 * time.Second)
+	for len(s.renderSlots) > 0 {
+		if time.Now().After(deadline) {
+			t.Fatal("expected the slot to be released")
+		}
+		time.Sleep(time.Millisecond)
+	}
+	if out, err := s.limitRender(render); err != nil || string(out) != "ok" {
+		t.Errorf("expected the next render to run, got %q: %v", out, err)
+	}
+}
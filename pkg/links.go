@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"net/url"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// rewriteRelativeURLs resolves relative link and image destinations against
+// the given base URLs. Empty bases leave the destinations untouched.
+func rewriteRelativeURLs(doc ast.Node, linkBase string, imageBase string) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			n.Destination = resolveURL(linkBase, n.Destination)
+		case *ast.Image:
+			n.Destination = resolveURL(imageBase, n.Destination)
+		}
+		return ast.GoToNext
+	})
+}
+
+func resolveURL(base string, dest []byte) []byte {
+	if base == "" || len(dest) == 0 || dest[0] == '#' {
+		return dest
+	}
+	ref, err := url.Parse(string(dest))
+	if err != nil || ref.IsAbs() || strings.HasPrefix(string(dest), "//") {
+		return dest
+	}
+	b, err := url.Parse(base)
+	if err != nil {
+		return dest
+	}
+	return []byte(b.ResolveReference(ref).String())
+}
+
+// WithRepoLinks resolves links of served documents the way GitHub does for
+// the git repository containing dir, the served directory: links starting
+// with a slash from the root of the repository, relative ones against the
+// document. Links that leave dir can't be previewed and are marked with the
+// grip-link-outside class. Without a repository, dir is the root.
+func WithRepoLinks(dir string) ParserOption {
+	return func(p *Parser) {
+		p.repoPrefix = repoPrefix(dir)
+	}
+}
+
+// repoPrefix returns the slash separated path of dir below the root of its
+// git repository, or "" if dir is the root or not in a repository.
+func repoPrefix(dir string) string {
+	abs, err := filepath.Abs(dir)
+	if err != nil {
+		return ""
+	}
+	for root := abs; ; {
+		if _, err := os.Stat(filepath.Join(root, ".git")); err == nil {
+			rel, err := filepath.Rel(root, abs)
+			if err != nil || rel == "." {
+				return ""
+			}
+			return filepath.ToSlash(rel)
+		}
+		parent := filepath.Dir(root)
+		if parent == root {
+			return ""
+		}
+		root = parent
+	}
+}
+
+// resolveLocalLinks resolves the local links and images of the document at
+// name, a slash separated path below the served directory, whose path in the
+// repository starts with prefix. Relative links that stay in the served
+// directory are left alone, the browser resolves them.
+func resolveLocalLinks(doc ast.Node, prefix string, name string) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if !entering {
+			return ast.GoToNext
+		}
+		switch n := node.(type) {
+		case *ast.Link:
+			dest, inside := resolveLocalURL(prefix, name, n.Destination)
+			n.Destination = dest
+			if !inside {
+				n.AdditionalAttributes = append(n.AdditionalAttributes,
+					`class="grip-link-outside"`, `title="Outside of the previewed directory"`)
+			}
+		case *ast.Image:
+			n.Destination, _ = resolveLocalURL(prefix, name, n.Destination)
+		}
+		return ast.GoToNext
+	})
+}
+
+// resolveLocalURL returns the destination of a link in the served directory
+// and whether the link points into it at all.
+func resolveLocalURL(prefix string, name string, dest []byte) ([]byte, bool) {
+	ref, err := url.Parse(string(dest))
+	if err != nil || ref.IsAbs() || ref.Host != "" || ref.Path == "" || strings.HasPrefix(string(dest), "//") {
+		return dest, true
+	}
+
+	var target string
+	if strings.HasPrefix(ref.Path, "/") {
+		target = ref.Path
+	} else {
+		target = path.Dir(path.Join("/", prefix, strings.TrimPrefix(name, "/"))) + "/" + ref.Path
+	}
+	repoPath, ok := cleanRepoPath(target)
+	if !ok {
+		return dest, false
+	}
+
+	served := repoPath
+	if prefix != "" {
+		rest, ok := strings.CutPrefix(repoPath, "/"+prefix)
+		if !ok || (rest != "" && !strings.HasPrefix(rest, "/")) {
+			return dest, false
+		}
+		served = "/" + strings.TrimPrefix(rest, "/")
+	}
+	if !strings.HasPrefix(ref.Path, "/") || served == ref.Path {
+		return dest, true
+	}
+	if strings.HasSuffix(ref.Path, "/") && !strings.HasSuffix(served, "/") {
+		served += "/"
+	}
+	ref.Path = served
+	return []byte(ref.String()), true
+}
+
+// cleanRepoPath cleans an absolute slash separated path, reporting false if
+// it climbs above the root with "..".
+func cleanRepoPath(p string) (string, bool) {
+	var parts []string
+	for _, part := range strings.Split(p, "/") {
+		switch part {
+		case "", ".":
+		case "..":
+			if len(parts) == 0 {
+				return "", false
+			}
+			parts = parts[:len(parts)-1]
+		default:
+			parts = append(parts, part)
+		}
+	}
+	return "/" + strings.Join(parts, "/"), true
+}
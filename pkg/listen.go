@@ -0,0 +1,227 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"errors"
+	"fmt"
+	"log/slog"
+	"net"
+	"os"
+	"strconv"
+	"strings"
+	"syscall"
+)
+
+type listener struct {
+	host   string
+	scheme string
+	net.Listener
+}
+
+// WithListen listens on the unix domain sockets at addrs, given as
+// unix:PATH, instead of the hosts and port.
+func WithListen(addrs []string) Option {
+	return func(s *Server) {
+		s.listenAddrs = addrs
+	}
+}
+
+// listen opens the listeners passed by systemd socket activation, the
+// configured unix sockets or a TCP listener for every configured host.
+// Either all listeners are opened or none are.
+func (s *Server) listen() ([]listener, error) {
+	tlsConfig, err := s.tlsConfig()
+	if err != nil {
+		return nil, err
+	}
+
+	scheme := "http"
+	if tlsConfig != nil {
+		scheme = "https"
+	}
+
+	listeners, err := systemdListeners()
+	if err == nil && listeners == nil {
+		if len(s.listenAddrs) > 0 {
+			listeners, err = listenSockets(s.listenAddrs)
+		} else {
+			listeners, err = s.listenTCP()
+		}
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	for i := range listeners {
+		listeners[i].scheme = scheme
+		if tlsConfig != nil {
+			listeners[i].Listener = tls.NewListener(listeners[i].Listener, tlsConfig)
+		}
+		if !isLoopback(listeners[i].Addr()) && s.authUser == "" && s.authToken == "" {
+			slog.Warn("listening without authentication, use --auth or --auth-token to restrict access", "addr", listeners[i].Addr().String())
+		}
+	}
+	return listeners, nil
+}
+
+// listenTCP listens on the port on all hosts. If port scanning is enabled
+// and the port is in use, the following ports are tried until all hosts can
+// be bound.
+func (s *Server) listenTCP() ([]listener, error) {
+	for port := s.port; port <= s.port+s.portScan; port++ {
+		listeners, err := s.listenPort(port)
+		if errors.Is(err, syscall.EADDRINUSE) && port < s.port+s.portScan {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+
+		if port != s.port {
+			slog.Info("port is in use, using the next free one", "port", s.port, "next", port)
+			s.port = port
+		}
+		return listeners, nil
+	}
+
+	return nil, fmt.Errorf("no free port in range %d-%d", s.port, s.port+s.portScan)
+}
+
+func (s *Server) listenPort(port int) ([]listener, error) {
+	var listeners []listener
+	for _, host := range s.hosts {
+		l, err := net.Listen("tcp", net.JoinHostPort(host, strconv.Itoa(port)))
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", host, err)
+		}
+		listeners = append(listeners, listener{host: host, Listener: l})
+	}
+	return listeners, nil
+}
+
+// urls returns the base URLs under which the listener can be reached.
+// Wildcard addresses (0.0.0.0, ::) are expanded to the addresses of all
+// local interfaces.
+func (l listener) urls() []string {
+	addr := l.Addr().(*net.TCPAddr)
+	port := strconv.Itoa(addr.Port)
+
+	if !addr.IP.IsUnspecified() {
+		return []string{fmt.Sprintf("%s://%s/", l.scheme, net.JoinHostPort(l.host, port))}
+	}
+
+	ifaddrs, err := net.InterfaceAddrs()
+	if err != nil {
+		return []string{fmt.Sprintf("%s://%s/", l.scheme, net.JoinHostPort("localhost", port))}
+	}
+
+	var urls []string
+	for _, a := range ifaddrs {
+		ipnet, ok := a.(*net.IPNet)
+		if !ok || ipnet.IP.IsLinkLocalUnicast() {
+			continue
+		}
+		// 0.0.0.0 only accepts IPv4 connections
+		if addr.IP.To4() != nil && ipnet.IP.To4() == nil {
+			continue
+		}
+		urls = append(urls, fmt.Sprintf("%s://%s/", l.scheme, net.JoinHostPort(ipnet.IP.String(), port)))
+	}
+	return urls
+}
+
+// isLoopback reports whether addr can only be reached from this machine.
+func isLoopback(addr net.Addr) bool {
+	if addr.Network() == "unix" {
+		return true
+	}
+	tcpAddr, ok := addr.(*net.TCPAddr)
+	return ok && tcpAddr.IP.IsLoopback()
+}
+
+func listenSockets(addrs []string) ([]listener, error) {
+	var listeners []listener
+	for _, addr := range addrs {
+		path, ok := strings.CutPrefix(addr, "unix:")
+		var l net.Listener
+		var err error
+		if !ok {
+			err = fmt.Errorf("unsupported address %q, expected unix:PATH", addr)
+		} else {
+			l, err = listenUnix(path)
+		}
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to listen on %s: %w", addr, err)
+		}
+		listeners = append(listeners, listener{host: path, Listener: l})
+	}
+	return listeners, nil
+}
+
+// listenUnix listens on the unix domain socket at path, replacing a socket
+// left behind by a server that didn't shut down cleanly.
+func listenUnix(path string) (net.Listener, error) {
+	l, err := net.Listen("unix", path)
+	if err == nil || !errors.Is(err, syscall.EADDRINUSE) {
+		return l, err
+	}
+	if conn, dialErr := net.Dial("unix", path); dialErr == nil {
+		conn.Close()
+		return nil, err
+	}
+	if err := os.Remove(path); err != nil {
+		return nil, err
+	}
+	return net.Listen("unix", path)
+}
+
+// listenFDsStart is the first file descriptor passed by systemd.
+const listenFDsStart = 3
+
+// systemdListeners returns the listeners passed by systemd socket
+// activation, see sd_listen_fds(3), or nil if there are none.
+func systemdListeners() ([]listener, error) {
+	pid, err := strconv.Atoi(os.Getenv("LISTEN_PID"))
+	if err != nil || pid != os.Getpid() {
+		return nil, nil
+	}
+	n, err := strconv.Atoi(os.Getenv("LISTEN_FDS"))
+	if err != nil || n <= 0 {
+		return nil, nil
+	}
+	names := strings.Split(os.Getenv("LISTEN_FDNAMES"), ":")
+	// the sockets are not meant for child processes
+	os.Unsetenv("LISTEN_PID")
+	os.Unsetenv("LISTEN_FDS")
+	os.Unsetenv("LISTEN_FDNAMES")
+
+	var listeners []listener
+	for i := 0; i < n; i++ {
+		name := "LISTEN_FD_" + strconv.Itoa(listenFDsStart+i)
+		if i < len(names) && names[i] != "" {
+			name = names[i]
+		}
+		f := os.NewFile(uintptr(listenFDsStart+i), name)
+		l, err := net.FileListener(f)
+		f.Close()
+		if err != nil {
+			for _, l := range listeners {
+				l.Close()
+			}
+			return nil, fmt.Errorf("failed to use socket %s of systemd: %v", name, err)
+		}
+		var host string
+		if addr, ok := l.Addr().(*net.TCPAddr); ok {
+			host = addr.IP.String()
+		}
+		listeners = append(listeners, listener{host: host, Listener: l})
+	}
+	slog.Debug("using sockets of systemd", "count", n)
+	return listeners, nil
+}
@@ -0,0 +1,138 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"io"
+	"net"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strconv"
+	"syscall"
+	"testing"
+	"time"
+)
+
+func TestListenUnixSockets(t *testing.T) {
+	if runtime.GOOS == "windows" {
+		t.Skip("needs unix domain sockets")
+	}
+	// socket paths are limited to about 100 bytes
+	dir, err := os.MkdirTemp("", "grip")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	sock := filepath.Join(dir, "grip.sock")
+
+	// a socket left behind by a server that didn't shut down is replaced
+	stale, err := net.ListenUnix("unix", &net.UnixAddr{Name: sock, Net: "unix"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	stale.SetUnlinkOnClose(false)
+	stale.Close()
+
+	s := NewServer([]string{"127.0.0.1"}, 0, "light", false, false, NewParser("light"), WithListen([]string{"unix:" + sock}))
+	listeners, err := s.listen()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(listeners) != 1 || listeners[0].Addr().Network() != "unix" || !isLoopback(listeners[0].Addr()) {
+		t.Fatalf("expected a unix socket, got %v", listeners)
+	}
+	srv := s.newHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		io.WriteString(w, "over the socket")
+	}))
+	go srv.Serve(listeners[0])
+	defer srv.Close()
+
+	client := &http.Client{Transport: &http.Transport{
+		DialContext: func(ctx context.Context, _, _ string) (net.Conn, error) {
+			return (&net.Dialer{}).DialContext(ctx, "unix", sock)
+		},
+	}}
+	resp, err := client.Get("http://grip/")
+	if err != nil {
+		t.Fatal(err)
+	}
+	body, _ := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if string(body) != "over the socket" {
+		t.Errorf("got %q", body)
+	}
+
+	// a socket a server is listening on is left alone
+	if _, err := listenSockets([]string{"unix:" + sock}); !errors.Is(err, syscall.EADDRINUSE) {
+		t.Errorf("expected the socket to be in use, got %v", err)
+	}
+	if _, err := listenSockets([]string{"tcp:127.0.0.1:6419"}); err == nil {
+		t.Error("expected addresses other than unix sockets to be rejected")
+	}
+}
+
+func TestSystemdListeners(t *testing.T) {
+	if os.Getenv("GO_GRIP_TEST_SYSTEMD") == "1" {
+		// systemd sets the process ID of the service it starts
+		os.Setenv("LISTEN_PID", strconv.Itoa(os.Getpid()))
+		listeners, err := systemdListeners()
+		if err != nil || len(listeners) != 1 {
+			t.Fatalf("expected the socket of systemd, got %v: %v", listeners, err)
+		}
+		if os.Getenv("LISTEN_FDS") != "" {
+			t.Error("expected the variables of systemd to be removed")
+		}
+		conn, err := listeners[0].Accept()
+		if err != nil {
+			t.Fatal(err)
+		}
+		io.WriteString(conn, "activated")
+		conn.Close()
+		return
+	}
+	if runtime.GOOS == "windows" {
+		t.Skip("systemd socket activation passes file descriptors")
+	}
+
+	l, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer l.Close()
+	f, err := l.(*net.TCPListener).File()
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestSystemdListeners$")
+	cmd.Env = append(os.Environ(), "GO_GRIP_TEST_SYSTEMD=1", "LISTEN_FDS=1", "LISTEN_FDNAMES=http")
+	cmd.ExtraFiles = []*os.File{f}
+	out := make(chan []byte, 1)
+	go func() {
+		b, err := cmd.CombinedOutput()
+		if err != nil {
+			b = append(b, err.Error()...)
+		}
+		out <- b
+	}()
+
+	conn, err := net.Dial("tcp", l.Addr().String())
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	// the connection waits on the socket of the test if the server fails
+	conn.SetDeadline(time.Now().Add(10 * time.Second))
+	got, _ := io.ReadAll(conn)
+	if string(got) != "activated" {
+		t.Errorf("expected the server to accept on the socket of systemd, got %q:\n%s", got, <-out)
+		return
+	}
+	if b := <-out; cmd.ProcessState == nil || !cmd.ProcessState.Success() {
+		t.Errorf("server failed:\n%s", b)
+	}
+}
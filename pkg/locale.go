@@ -0,0 +1,101 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/chrishrb/go-grip/defaults"
+)
+
+// DefaultLang is the language of the user interface without WithLang. Its
+// strings are the keys of the catalogs of the other languages.
+const DefaultLang = "en"
+
+// catalogs maps languages to the translations of the English user interface
+// strings, embedded from defaults/locales.
+var catalogs = loadCatalogs()
+
+func loadCatalogs() map[string]map[string]string {
+	entries, err := defaults.Locales.ReadDir("locales")
+	if err != nil {
+		panic(err)
+	}
+	catalogs := make(map[string]map[string]string)
+	for _, e := range entries {
+		content, err := defaults.Locales.ReadFile("locales/" + e.Name())
+		if err != nil {
+			panic(err)
+		}
+		var catalog map[string]string
+		if err := json.Unmarshal(content, &catalog); err != nil {
+			panic(fmt.Sprintf("invalid catalog %s: %v", e.Name(), err))
+		}
+		catalogs[strings.TrimSuffix(e.Name(), path.Ext(e.Name()))] = catalog
+	}
+	return catalogs
+}
+
+// Languages returns the languages the user interface is available in.
+func Languages() []string {
+	langs := []string{DefaultLang}
+	for lang := range catalogs {
+		langs = append(langs, lang)
+	}
+	slices.Sort(langs)
+	return langs
+}
+
+// ValidLang reports whether the user interface is available in lang, a
+// language tag like de or pt-BR. Regional variants fall back to their
+// language.
+func ValidLang(lang string) bool {
+	return resolveLang(lang) != ""
+}
+
+// resolveLang returns the catalog for lang, or "" if there is none.
+func resolveLang(lang string) string {
+	lang = strings.ToLower(strings.ReplaceAll(lang, "_", "-"))
+	base, _, _ := strings.Cut(lang, "-")
+	for _, l := range []string{lang, base} {
+		if _, ok := catalogs[l]; ok || l == DefaultLang {
+			return l
+		}
+	}
+	return ""
+}
+
+// WithLang translates the user interface around the rendered documents, like
+// index and error pages and the buttons of the toolbar, into lang, see
+// Languages. Unknown languages and missing strings fall back to English.
+func WithLang(lang string) Option {
+	return func(s *Server) {
+		s.lang = resolveLang(lang)
+	}
+}
+
+// msg returns the translation of the English user interface string key,
+// formatted with args like fmt.Sprintf.
+func (s *Server) msg(key string, args ...any) string {
+	text := key
+	if t, ok := catalogs[s.lang][key]; ok {
+		text = t
+	}
+	if len(args) == 0 {
+		return text
+	}
+	return fmt.Sprintf(text, args...)
+}
+
+// messages returns the catalog of the language as JSON for the scripts of
+// the page, see i18n.js.
+func (s *Server) messages() string {
+	catalog := catalogs[s.lang]
+	if catalog == nil {
+		return "{}"
+	}
+	b, _ := json.Marshal(catalog)
+	return string(b)
+}
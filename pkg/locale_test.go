@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestCatalogs(t *testing.T) {
+	// translations keep the verbs of the English strings in order, the
+	// scripts fill them in order too
+	verbs := func(s string) string {
+		var v []string
+		for i := strings.Index(s, "%"); i >= 0 && i+1 < len(s); i = strings.Index(s, "%") {
+			v = append(v, s[i:i+2])
+			s = s[i+2:]
+		}
+		return strings.Join(v, "")
+	}
+	for lang, catalog := range catalogs {
+		for key, text := range catalog {
+			if verbs(key) != verbs(text) {
+				t.Errorf("%s: %q translates %q with different verbs", lang, text, key)
+			}
+		}
+	}
+}
+
+func TestMsg(t *testing.T) {
+	s := &Server{}
+	if got := s.msg("%d words", 3); got != "3 words" {
+		t.Errorf("expected English without a language, got %q", got)
+	}
+	WithLang("de-AT")(s)
+	if got := s.msg("%d words", 3); got != "3 Wörter" {
+		t.Errorf("expected German for de-AT, got %q", got)
+	}
+	if got := s.msg("Not translated"); got != "Not translated" {
+		t.Errorf("expected missing strings in English, got %q", got)
+	}
+	if ValidLang("xx") || !ValidLang("en-GB") || !ValidLang("zh_CN") {
+		t.Error("unexpected ValidLang results")
+	}
+}
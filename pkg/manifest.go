@@ -0,0 +1,90 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"log/slog"
+	"os"
+	"path/filepath"
+	"sort"
+)
+
+// ManifestFile is the manifest written next to the pages of an exported
+// directory.
+const ManifestFile = "grip-manifest.json"
+
+// Manifest lists the HTML pages of an exported directory, so site generators
+// and search indexers can consume them without parsing the HTML.
+type Manifest struct {
+	// Pages are ordered like the index, by the order field of their front
+	// matter, then by file name.
+	Pages []ManifestPage `json:"pages"`
+}
+
+// ManifestPage is an exported markdown file.
+type ManifestPage struct {
+	// Source is the slash separated path of the markdown file in the
+	// exported directory.
+	Source string `json:"source"`
+	// Output is the slash separated path of the page in the output
+	// directory.
+	Output string `json:"output"`
+	Title  string `json:"title"`
+	// Outline is the heading tree of the page, with the anchors of the
+	// headings, see Parser.Outline.
+	Outline []*OutlineHeading `json:"outline"`
+	// SourceChecksum and Checksum are the SHA-256 of the markdown file and
+	// the page, as "sha256:" followed by the hex digest.
+	SourceChecksum string `json:"sourceChecksum"`
+	Checksum       string `json:"checksum"`
+
+	order *int
+}
+
+// manifestPage returns the manifest entry of the markdown file source, read
+// as raw and expanded to content, exported to the page output in outputDir.
+func (s *Server) manifestPage(source string, raw []byte, content []byte, outputDir string, output string, title string, order *int) (ManifestPage, error) {
+	page, err := os.ReadFile(filepath.Join(outputDir, output))
+	if err != nil {
+		return ManifestPage{}, fmt.Errorf("failed to read page: %v", err)
+	}
+	outline := s.parser.Outline(content)
+	if outline == nil {
+		outline = []*OutlineHeading{}
+	}
+	return ManifestPage{
+		Source:         filepath.ToSlash(source),
+		Output:         filepath.ToSlash(output),
+		Title:          title,
+		Outline:        outline,
+		SourceChecksum: checksum(raw),
+		Checksum:       checksum(page),
+		order:          order,
+	}, nil
+}
+
+func checksum(content []byte) string {
+	sum := sha256.Sum256(content)
+	return "sha256:" + hex.EncodeToString(sum[:])
+}
+
+// writeManifest writes the ManifestFile of the pages to outputDir.
+func writeManifest(outputDir string, pages []ManifestPage) error {
+	pages = append([]ManifestPage{}, pages...)
+	sort.Slice(pages, func(i, j int) bool {
+		a := indexEntry{file: pages[i].Output, order: pages[i].order}
+		return a.less(indexEntry{file: pages[j].Output, order: pages[j].order})
+	})
+	data, err := json.MarshalIndent(Manifest{Pages: pages}, "", "  ")
+	if err != nil {
+		return fmt.Errorf("failed to encode manifest: %v", err)
+	}
+	manifestPath := filepath.Join(outputDir, ManifestFile)
+	if err := os.WriteFile(manifestPath, append(data, '\n'), 0644); err != nil {
+		return fmt.Errorf("failed to write manifest: %v", err)
+	}
+	slog.Info("generated manifest", "path", manifestPath)
+	return nil
+}
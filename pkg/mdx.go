@@ -0,0 +1,128 @@
+package pkg
+
+import (
+	"html"
+	"path"
+	"regexp"
+	"strings"
+)
+
+var (
+	mdxStatement = regexp.MustCompile(`^(import|export)\s`)
+	mdxComment   = regexp.MustCompile(`^\{/\*.*\*/\}$`)
+	mdxTag       = regexp.MustCompile(`^</?([A-Z][\w.]*)`)
+	mdxCloseTag  = regexp.MustCompile(`</[A-Z][\w.]*>`)
+)
+
+// isMDX reports whether name is an MDX file.
+func isMDX(name string) bool {
+	return strings.EqualFold(path.Ext(name), ".mdx")
+}
+
+// MDXToMarkdown makes the prose and code blocks of an MDX document
+// previewable as markdown: ES import and export statements and {/* */}
+// comments are removed, the tags of JSX components around markdown are
+// dropped, keeping their content, and self-closing components are shown as
+// placeholders. Removed lines are replaced by empty lines, so the lines of
+// the other blocks stay the same.
+func MDXToMarkdown(content []byte) []byte {
+	lines := strings.SplitAfter(string(content), "\n")
+	var out strings.Builder
+	var fence string
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		trimmed := strings.TrimSpace(line)
+		marker, _, isFence := fenceOpening([]byte(trimmed))
+		switch {
+		case fence != "":
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+			}
+		case isFence:
+			fence = marker
+		case mdxStatement.MatchString(line):
+			end := statementEnd(lines, i)
+			blankLines(&out, lines[i:end+1])
+			i = end
+			continue
+		case mdxComment.MatchString(trimmed):
+			blankLines(&out, lines[i:i+1])
+			continue
+		case mdxTag.MatchString(trimmed):
+			end, col := tagEnd(lines, i)
+			if end < 0 {
+				break
+			}
+			tag := strings.TrimSpace(strings.Join(lines[i:end], "") + lines[end][:col])
+			rest := mdxCloseTag.ReplaceAllString(strings.TrimSpace(lines[end][col:]), "")
+			blankLines(&out, lines[i:end])
+			switch {
+			case strings.HasSuffix(tag, "/>"):
+				name := mdxTag.FindStringSubmatch(tag)[1]
+				out.WriteString(`<span class="grip-mdx-component" title="MDX component">&lt;` + html.EscapeString(name) + ` /&gt;</span>` + rest + "\n")
+			case rest != "":
+				out.WriteString(rest + "\n")
+			default:
+				out.WriteString("\n")
+			}
+			i = end
+			continue
+		}
+		out.WriteString(line)
+	}
+	return []byte(out.String())
+}
+
+// statementEnd returns the last line of the import or export statement
+// starting on line start, the first line where its brackets are balanced.
+func statementEnd(lines []string, start int) int {
+	depth := 0
+	for i := start; i < len(lines); i++ {
+		for _, c := range lines[i] {
+			switch c {
+			case '{', '(', '[':
+				depth++
+			case '}', ')', ']':
+				depth--
+			}
+		}
+		if depth <= 0 {
+			return i
+		}
+	}
+	return len(lines) - 1
+}
+
+// tagEnd returns the line and the column after the > ending the JSX tag
+// starting on line start. Attributes may span lines and contain > in
+// strings and {expressions}. The line is -1 if the tag doesn't end.
+func tagEnd(lines []string, start int) (int, int) {
+	depth := 0
+	var quote rune
+	for i := start; i < len(lines); i++ {
+		for col, c := range lines[i] {
+			switch {
+			case quote != 0:
+				if c == quote {
+					quote = 0
+				}
+			case c == '"' || c == '\'' || c == '`':
+				quote = c
+			case c == '{':
+				depth++
+			case c == '}':
+				depth--
+			case c == '>' && depth == 0:
+				return i, col + 1
+			}
+		}
+	}
+	return -1, 0
+}
+
+// blankLines writes an empty line for every line.
+func blankLines(out *strings.Builder, lines []string) {
+	for range lines {
+		out.WriteString("\n")
+	}
+}
@@ -0,0 +1,86 @@
+package pkg
+
+import (
+	"fmt"
+	"html"
+	"io"
+	"log/slog"
+	"net/url"
+	"path"
+	"slices"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+var (
+	videoExtensions = []string{".mp4", ".m4v", ".mov", ".webm", ".ogv"}
+	audioExtensions = []string{".mp3", ".m4a", ".wav", ".ogg", ".oga", ".opus", ".flac"}
+)
+
+// mediaPlayer returns an HTML5 player for a video or audio file like GitHub
+// shows for uploaded videos, or an empty string for other files.
+func mediaPlayer(dest []byte, title string) string {
+	u, err := url.Parse(string(dest))
+	if err != nil {
+		return ""
+	}
+	ext := strings.ToLower(path.Ext(u.Path))
+	var tag string
+	switch {
+	case slices.Contains(videoExtensions, ext):
+		tag = "video"
+	case slices.Contains(audioExtensions, ext):
+		tag = "audio"
+	default:
+		return ""
+	}
+	attrs := fmt.Sprintf(`src="%s" controls preload="metadata" class="grip-%s"`, html.EscapeString(string(dest)), tag)
+	if title != "" {
+		attrs += ` title="` + html.EscapeString(title) + `"`
+	}
+	return "<" + tag + " " + attrs + "></" + tag + ">"
+}
+
+// renderHookImage renders images of video and audio files as players.
+func renderHookImage(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	image := node.(*ast.Image)
+	player := mediaPlayer(image.Destination, nodeText(image))
+	if player == "" {
+		return ast.GoToNext, false
+	}
+	if entering {
+		if _, err := io.WriteString(w, player); err != nil {
+			slog.Error("failed to write HTML", "err", err)
+		}
+	}
+	return ast.SkipChildren, true
+}
+
+// renderHookLink renders links to video and audio files, which are the only
+// content of their paragraph, as players, and with WithEmbeds links to
+// YouTube and Gists as embeds. Other links stay links.
+func (m Parser) renderHookLink(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	link := node.(*ast.Link)
+	for _, sibling := range link.GetParent().GetChildren() {
+		if t, ok := sibling.(*ast.Text); sibling != node && !(ok && strings.TrimSpace(string(t.Literal)) == "") {
+			return ast.GoToNext, false
+		}
+	}
+	if _, ok := link.GetParent().(*ast.Paragraph); !ok {
+		return ast.GoToNext, false
+	}
+	player := mediaPlayer(link.Destination, string(link.Title))
+	if player == "" && m.embeds {
+		player = embed(link.Destination)
+	}
+	if player == "" {
+		return ast.GoToNext, false
+	}
+	if entering {
+		if _, err := io.WriteString(w, player); err != nil {
+			slog.Error("failed to write HTML", "err", err)
+		}
+	}
+	return ast.SkipChildren, true
+}
@@ -0,0 +1,112 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// Metadata headers of rendered pages, which let scripts and editor plugins
+// poll the state of a document with HEAD requests instead of fetching it.
+const (
+	// HeaderRenderTime is the time rendering the page took in milliseconds,
+	// also when it is served from the render cache.
+	HeaderRenderTime = "X-Grip-Render-Time"
+	// HeaderSourceMtime is the modification time of the source file, or of
+	// the latest file it embeds, in RFC 3339 format with nanoseconds.
+	HeaderSourceMtime = "X-Grip-Source-Mtime"
+	// HeaderOutlineCount is the number of headings of a markdown document.
+	HeaderOutlineCount = "X-Grip-Outline-Count"
+)
+
+// metaHeaders are exposed to other origins allowed by WithCORS.
+var metaHeaders = []string{HeaderRenderTime, HeaderSourceMtime, HeaderOutlineCount}
+
+// pageMeta is what the metadata headers tell about a rendered page.
+type pageMeta struct {
+	// renderTime is zero if the page was not rendered, like for HEAD requests
+	// missing the render cache
+	renderTime time.Duration
+	// headings is -1 for files other than markdown
+	headings int
+}
+
+// setMetaHeaders sets the metadata headers of a page rendered from a source
+// modified at modTime.
+func setMetaHeaders(h http.Header, modTime time.Time, meta pageMeta) {
+	h.Set(HeaderSourceMtime, modTime.UTC().Format(time.RFC3339Nano))
+	if meta.renderTime > 0 {
+		h.Set(HeaderRenderTime, strconv.FormatFloat(float64(meta.renderTime)/float64(time.Millisecond), 'f', 3, 64))
+	}
+	if meta.headings >= 0 {
+		h.Set(HeaderOutlineCount, strconv.Itoa(meta.headings))
+	}
+}
+
+// countHeadings returns the number of headings of a parsed document, the
+// entries of its Outline.
+func countHeadings(doc ast.Node) int {
+	n := 0
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if _, ok := node.(*ast.Heading); ok && entering {
+			n++
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+	return n
+}
+
+// headingCount returns the number of headings of the page of the file name
+// with the content, -1 if it isn't a markdown document. Only the blocks are
+// parsed, for pages that are not rendered, see renderPage.
+func (s *Server) headingCount(name string, content []byte) int {
+	if content == nil || !s.IsMarkdown(name) {
+		return -1
+	}
+	return countHeadings(s.parser.parseBlocks(blankFrontMatter(content)))
+}
+
+// sourceETag returns the ETag of the page with the cache key rendered from
+// sources modified at modTime. It is a weak one of the source rather than a
+// hash of the page, so HEAD requests can tell it without rendering and GET
+// and HEAD requests always agree on it.
+func sourceETag(key string, modTime time.Time) string {
+	h := sha256.Sum256([]byte(key + "\x00" + modTime.UTC().Format(time.RFC3339Nano)))
+	return `W/"` + hex.EncodeToString(h[:8]) + `"`
+}
+
+// serveHead answers a HEAD request for a page that is not rendered with its
+// etag, answering conditional requests like http.ServeContent does.
+func serveHead(w http.ResponseWriter, r *http.Request, etag string, modTime time.Time) {
+	w.Header().Set("ETag", etag)
+	w.Header().Set("Last-Modified", modTime.UTC().Format(http.TimeFormat))
+	if notModified(r, etag, modTime) {
+		w.Header().Del("Content-Type")
+		w.WriteHeader(http.StatusNotModified)
+		return
+	}
+	w.WriteHeader(http.StatusOK)
+}
+
+// notModified reports whether the If-None-Match header of r matches etag,
+// with the weak comparison, or, without it, whether the If-Modified-Since
+// header is not before modTime.
+func notModified(r *http.Request, etag string, modTime time.Time) bool {
+	if inm := r.Header.Get("If-None-Match"); inm != "" {
+		for _, tag := range strings.Split(inm, ",") {
+			tag = strings.TrimSpace(tag)
+			if tag == "*" || strings.TrimPrefix(tag, "W/") == strings.TrimPrefix(etag, "W/") {
+				return true
+			}
+		}
+		return false
+	}
+	since, err := http.ParseTime(r.Header.Get("If-Modified-Since"))
+	return err == nil && !modTime.Truncate(time.Second).After(since)
+}
@@ -0,0 +1,123 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sync"
+	"time"
+)
+
+// renderBuckets are the upper bounds in seconds of the render latency
+// histogram.
+var renderBuckets = []float64{0.005, 0.01, 0.025, 0.05, 0.1, 0.25, 0.5, 1, 2.5, 5, 10}
+
+// metrics counts renders, reloads and render cache lookups for the /metrics
+// endpoint. A nil *metrics discards everything.
+type metrics struct {
+	mu           sync.Mutex
+	renders      uint64
+	renderErrors uint64
+	renderCounts []uint64
+	renderSum    float64
+	reloads      uint64
+	cacheHits    uint64
+	cacheMisses  uint64
+}
+
+func newMetrics() *metrics {
+	return &metrics{renderCounts: make([]uint64, len(renderBuckets))}
+}
+
+// WithMetrics serves Prometheus metrics at /metrics and a health check at
+// /healthz. The health check doesn't require authentication, so reverse
+// proxies and orchestrators can use it.
+func WithMetrics(enabled bool) Option {
+	return func(s *Server) {
+		if enabled {
+			s.metrics = newMetrics()
+		} else {
+			s.metrics = nil
+		}
+	}
+}
+
+// instrument wraps a render to record its duration and outcome.
+func (m *metrics) instrument(fn func(ctx context.Context) ([]byte, error)) func(ctx context.Context) ([]byte, error) {
+	if m == nil {
+		return fn
+	}
+	return func(ctx context.Context) ([]byte, error) {
+		start := time.Now()
+		html, err := fn(ctx)
+		seconds := time.Since(start).Seconds()
+
+		m.mu.Lock()
+		defer m.mu.Unlock()
+		m.renders++
+		if err != nil {
+			m.renderErrors++
+		}
+		m.renderSum += seconds
+		for i, le := range renderBuckets {
+			if seconds <= le {
+				m.renderCounts[i]++
+			}
+		}
+		return html, err
+	}
+}
+
+func (m *metrics) reloaded() {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	m.reloads++
+	m.mu.Unlock()
+}
+
+func (m *metrics) cacheLookup(hit bool) {
+	if m == nil {
+		return
+	}
+	m.mu.Lock()
+	if hit {
+		m.cacheHits++
+	} else {
+		m.cacheMisses++
+	}
+	m.mu.Unlock()
+}
+
+// ServeHTTP writes the metrics in the Prometheus text format.
+func (m *metrics) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+	counter := func(name string, help string, v uint64) {
+		fmt.Fprintf(w, "# HELP %s %s\n# TYPE %s counter\n%s %d\n", name, help, name, name, v)
+	}
+	counter("go_grip_renders_total", "Documents rendered.", m.renders)
+	counter("go_grip_render_errors_total", "Renders that failed.", m.renderErrors)
+
+	fmt.Fprintf(w, "# HELP go_grip_render_duration_seconds Time spent rendering documents.\n")
+	fmt.Fprintf(w, "# TYPE go_grip_render_duration_seconds histogram\n")
+	for i, le := range renderBuckets {
+		fmt.Fprintf(w, "go_grip_render_duration_seconds_bucket{le=\"%g\"} %d\n", le, m.renderCounts[i])
+	}
+	fmt.Fprintf(w, "go_grip_render_duration_seconds_bucket{le=\"+Inf\"} %d\n", m.renders)
+	fmt.Fprintf(w, "go_grip_render_duration_seconds_sum %g\n", m.renderSum)
+	fmt.Fprintf(w, "go_grip_render_duration_seconds_count %d\n", m.renders)
+
+	counter("go_grip_reloads_total", "Reloads sent to browsers after file changes.", m.reloads)
+	counter("go_grip_cache_hits_total", "Pages served from the render cache.", m.cacheHits)
+	counter("go_grip_cache_misses_total", "Pages rendered because they were not cached.", m.cacheMisses)
+}
+
+// healthz answers health checks while the server is running.
+func healthz(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+	fmt.Fprintln(w, "ok")
+}
@@ -0,0 +1,174 @@
+package pkg
+
+import (
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// mkdocsConfig is the mkdocs.yml of the served docs, see
+// https://www.mkdocs.org/user-guide/configuration/#nav.
+type mkdocsConfig struct {
+	file string
+	// prefix is the slash separated path of the docs_dir in the served
+	// directory, empty if the docs_dir is served.
+	prefix string
+}
+
+// mkdocsNav caches the nav of the mkdocs.yml of the served directory, which
+// is read again after a file changed, since it holds the titles of pages.
+type mkdocsNav struct {
+	mu     sync.Mutex
+	config *mkdocsConfig
+	book   *book
+	// modTime is the modification time of the config book was read from,
+	// zero if it has to be read
+	modTime time.Time
+}
+
+// find looks for the mkdocs.yml of the served directory, see findMkDocs.
+func (n *mkdocsNav) find(directory string) {
+	config := findMkDocs(directory)
+	n.mu.Lock()
+	n.config, n.book, n.modTime = config, nil, time.Time{}
+	n.mu.Unlock()
+}
+
+func (n *mkdocsNav) invalidate() {
+	n.mu.Lock()
+	n.book, n.modTime = nil, time.Time{}
+	n.mu.Unlock()
+}
+
+// get returns the nav of dir as book and the modification time of the
+// config, nil if there is none.
+func (n *mkdocsNav) get(dir http.FileSystem) (*book, time.Time) {
+	n.mu.Lock()
+	defer n.mu.Unlock()
+	if n.config == nil {
+		return nil, time.Time{}
+	}
+	info, err := os.Stat(n.config.file)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	if !info.ModTime().Equal(n.modTime) {
+		n.book = n.config.read(dir)
+		n.modTime = info.ModTime()
+	}
+	return n.book, n.modTime
+}
+
+// findMkDocs looks for the mkdocs.yml of the served directory, either in it or
+// in its parent when the directory is the docs_dir.
+func findMkDocs(directory string) *mkdocsConfig {
+	served, err := filepath.Abs(directory)
+	if err != nil {
+		return nil
+	}
+	for _, dir := range []string{served, filepath.Dir(served)} {
+		for _, name := range []string{"mkdocs.yml", "mkdocs.yaml"} {
+			file := filepath.Join(dir, name)
+			content, err := os.ReadFile(file)
+			if err != nil {
+				continue
+			}
+			var config struct {
+				DocsDir string `yaml:"docs_dir"`
+			}
+			if err := yaml.Unmarshal(content, &config); err != nil {
+				continue
+			}
+			if config.DocsDir == "" {
+				config.DocsDir = "docs"
+			}
+			docsDir := filepath.Join(dir, config.DocsDir)
+			rel, err := filepath.Rel(served, docsDir)
+			if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+				continue
+			}
+			prefix := filepath.ToSlash(rel)
+			if prefix == "." {
+				prefix = ""
+			}
+			return &mkdocsConfig{file: file, prefix: prefix}
+		}
+	}
+	return nil
+}
+
+// read returns the nav section of the config as book, nil if it has none.
+// Pages listed without a title are titled like their page.
+func (c *mkdocsConfig) read(dir http.FileSystem) *book {
+	content, err := os.ReadFile(c.file)
+	if err != nil {
+		return nil
+	}
+	var config struct {
+		Nav []interface{} `yaml:"nav"`
+	}
+	if err := yaml.Unmarshal(content, &config); err != nil || len(config.Nav) == 0 {
+		return nil
+	}
+
+	b := &book{}
+	c.addNav(b, dir, config.Nav, 0)
+	return b
+}
+
+// addNav adds the entries of a nav list at depth to b. An entry is a page
+// path, a single "title: path" mapping or a "title: [entries]" section.
+func (c *mkdocsConfig) addNav(b *book, dir http.FileSystem, nav []interface{}, depth int) {
+	for _, entry := range nav {
+		var title string
+		target := entry
+		if m, ok := entry.(map[interface{}]interface{}); ok && len(m) == 1 {
+			for k, v := range m {
+				title, target = fmt.Sprint(k), v
+			}
+		}
+
+		switch t := target.(type) {
+		case []interface{}:
+			b.chapters = append(b.chapters, bookChapter{title: title, depth: depth, part: true})
+			c.addNav(b, dir, t, depth+1)
+		case string:
+			ch := bookChapter{title: title, depth: depth}
+			if strings.Contains(t, "://") {
+				ch.url = t
+			} else {
+				ch.path = path.Clean("/" + path.Join(c.prefix, t))
+			}
+			if ch.title == "" {
+				ch.title = pageTitle(dir, ch.path, t)
+			}
+			b.chapters = append(b.chapters, ch)
+		}
+	}
+}
+
+// pageTitle returns the title of the page at name, or fallback if it can't
+// be read.
+func pageTitle(dir http.FileSystem, name string, fallback string) string {
+	if name == "" {
+		return fallback
+	}
+	f, err := dir.Open(name)
+	if err != nil {
+		return fallback
+	}
+	defer f.Close()
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return fallback
+	}
+	return extractTitle(content, path.Base(name))
+}
@@ -0,0 +1,155 @@
+package pkg
+
+import (
+	"context"
+	"fmt"
+	"io/fs"
+	"net/http"
+	"net/url"
+	"path"
+	"path/filepath"
+	"sort"
+	"strings"
+)
+
+// ServeRoots serves several directories, e.g. the doc trees of a monorepo,
+// from one server. Each directory is mounted at its path relative to the
+// common parent directory, like /docs/ and /design/rfcs/, and the root path
+// shows an index of the directories.
+func (s *Server) ServeRoots(ctx context.Context, dirs []string) error {
+	parent, mounts, err := mountRoots(dirs)
+	if err != nil {
+		return err
+	}
+	if s.gitRef != "" {
+		return fmt.Errorf("serving several directories of a git ref is not supported")
+	}
+	s.mounts = mounts
+	return s.serve(ctx, parent, "", "")
+}
+
+// mountRoots returns the common parent directory of dirs and their slash
+// separated paths relative to it.
+func mountRoots(dirs []string) (string, []string, error) {
+	var abs []string
+	for _, dir := range dirs {
+		a, err := filepath.Abs(dir)
+		if err != nil {
+			return "", nil, err
+		}
+		abs = append(abs, a)
+	}
+
+	parent := abs[0]
+	for _, a := range abs[1:] {
+		for !within(parent, a) {
+			next := filepath.Dir(parent)
+			if next == parent {
+				return "", nil, fmt.Errorf("%s and %s have no common parent directory", abs[0], a)
+			}
+			parent = next
+		}
+	}
+
+	var mounts []string
+	for i, a := range abs {
+		for j, b := range abs {
+			if i != j && within(b, a) {
+				return "", nil, fmt.Errorf("%s is inside %s, serve only one of them", dirs[i], dirs[j])
+			}
+		}
+		rel, err := filepath.Rel(parent, a)
+		if err != nil {
+			return "", nil, err
+		}
+		mounts = append(mounts, filepath.ToSlash(rel))
+	}
+	sort.Strings(mounts)
+	return parent, mounts, nil
+}
+
+// within reports whether path is dir or inside it.
+func within(dir string, p string) bool {
+	rel, err := filepath.Rel(dir, p)
+	return err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+}
+
+// mountFS serves only the mounted directories of root and the directories
+// leading to them.
+type mountFS struct {
+	root   http.FileSystem
+	mounts []string
+}
+
+func (m mountFS) Open(name string) (http.File, error) {
+	name = path.Clean("/" + name)
+	for _, mnt := range m.mounts {
+		if name == "/"+mnt || strings.HasPrefix(name, "/"+mnt+"/") {
+			return m.root.Open(name)
+		}
+	}
+	children := m.children(name)
+	if len(children) == 0 {
+		return nil, fs.ErrNotExist
+	}
+	f, err := m.root.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	return mountDir{File: f, children: children}, nil
+}
+
+// children returns the names of the entries of the directory name which are
+// or lead to mounted directories.
+func (m mountFS) children(name string) map[string]bool {
+	prefix := strings.TrimSuffix(name, "/") + "/"
+	children := make(map[string]bool)
+	for _, mnt := range m.mounts {
+		if rest, ok := strings.CutPrefix("/"+mnt, prefix); ok {
+			child, _, _ := strings.Cut(rest, "/")
+			children[child] = true
+		}
+	}
+	return children
+}
+
+// mountDir lists only the entries of a directory leading to mounts.
+type mountDir struct {
+	http.File
+	children map[string]bool
+}
+
+func (d mountDir) Readdir(count int) ([]fs.FileInfo, error) {
+	infos, err := d.File.Readdir(count)
+	mounted := infos[:0]
+	for _, info := range infos {
+		if d.children[info.Name()] {
+			mounted = append(mounted, info)
+		}
+	}
+	return mounted, err
+}
+
+// serveMountIndex renders the index of the mounted directories, linking the
+// README of each, titled with its title, if it has one.
+func (s *site) serveMountIndex(w http.ResponseWriter, dir http.FileSystem) {
+	var sb strings.Builder
+	sb.WriteString("# " + s.name + "\n\n")
+	escape := strings.NewReplacer("[", "\\[", "]", "\\]")
+	for _, mnt := range s.mounts {
+		title, target := mnt, "/"+mnt+"/"
+		for _, readme := range []string{"README.md", "index.md"} {
+			if content, err := readFile(dir, target+readme); err == nil {
+				title = extractTitle(content, mnt)
+				target += readme
+				break
+			}
+		}
+		fmt.Fprintf(&sb, "- [%s](%s)", escape.Replace(title), (&url.URL{Path: target}).EscapedPath())
+		if title != mnt {
+			sb.WriteString(" `" + mnt + "`")
+		}
+		sb.WriteString("\n")
+	}
+	s.serveMarkdown(w, []byte(sb.String()), "index.md")
+}
@@ -0,0 +1,157 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"path"
+	"regexp"
+	"slices"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// RemoteAsset is a resource a page loads from another host, which fails to
+// load without network access.
+type RemoteAsset struct {
+	// Kind is script, stylesheet, font, image, frame or tiles.
+	Kind string
+	URL  string
+}
+
+func (a RemoteAsset) String() string {
+	return a.Kind + " " + a.URL
+}
+
+// WithStrictOffline makes rendering fail for pages that load stylesheets,
+// scripts, fonts, frames or map tiles from other hosts, see AuditOffline, so
+// previews and exports are guaranteed to work without network access.
+// Remote images are left to WithImageProxy.
+func WithStrictOffline(enabled bool) Option {
+	return func(s *Server) {
+		s.strictOffline = enabled
+	}
+}
+
+var (
+	cssImport = regexp.MustCompile(`@import\s+(?:url\(\s*)?["']?([^"')\s;]+)[^;]*;?`)
+	cssURL    = regexp.MustCompile(`url\(\s*["']?([^"')]+?)["']?\s*\)`)
+)
+
+// AuditOffline returns the remote assets an HTML page loads: scripts,
+// stylesheets, frames, map tiles and the fonts and images of inline CSS.
+// Image elements and links are not included.
+func AuditOffline(page []byte) []RemoteAsset {
+	var assets []RemoteAsset
+	add := func(kind string, u string) {
+		if remoteURL(u) {
+			assets = append(assets, RemoteAsset{Kind: kind, URL: u})
+		}
+	}
+	addCSS := func(css string) {
+		for _, m := range cssImport.FindAllStringSubmatch(css, -1) {
+			add("stylesheet", m[1])
+		}
+		// the url() of imports is not a font or image
+		css = cssImport.ReplaceAllString(css, "")
+		for _, m := range cssURL.FindAllStringSubmatch(css, -1) {
+			add(cssURLKind(m[1]), m[1])
+		}
+	}
+
+	z := xhtml.NewTokenizer(bytes.NewReader(page))
+	inStyle := false
+	for {
+		switch z.Next() {
+		case xhtml.ErrorToken:
+			return dedupeAssets(assets)
+		case xhtml.TextToken:
+			if inStyle {
+				addCSS(string(z.Text()))
+			}
+		case xhtml.EndTagToken:
+			inStyle = false
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			name, _ := z.TagName()
+			attrs := make(map[string]string)
+			for {
+				key, val, more := z.TagAttr()
+				attrs[string(key)] = string(val)
+				if !more {
+					break
+				}
+			}
+			inStyle = string(name) == "style"
+
+			switch string(name) {
+			case "script":
+				add("script", attrs["src"])
+			case "link":
+				rel := strings.Fields(strings.ToLower(attrs["rel"]))
+				switch {
+				case attrs["as"] == "font":
+					add("font", attrs["href"])
+				case attrs["as"] == "script" || slices.Contains(rel, "modulepreload"):
+					add("script", attrs["href"])
+				case slices.Contains(rel, "stylesheet") || slices.Contains(rel, "preload") || slices.Contains(rel, "prefetch"):
+					add("stylesheet", attrs["href"])
+				}
+			case "iframe", "frame", "embed":
+				add("frame", attrs["src"])
+				if doc := attrs["srcdoc"]; doc != "" {
+					assets = append(assets, AuditOffline([]byte(doc))...)
+				}
+			case "object":
+				add("frame", attrs["data"])
+			}
+			if style := attrs["style"]; style != "" {
+				addCSS(style)
+			}
+			if tiles := attrs["data-tiles"]; tiles != "" {
+				add("tiles", tiles)
+			}
+		}
+	}
+}
+
+// checkOffline returns an error listing the remote assets of page parts.
+func checkOffline(parts ...[]byte) error {
+	var assets []string
+	for _, p := range parts {
+		for _, a := range AuditOffline(p) {
+			assets = append(assets, a.String())
+		}
+	}
+	if len(assets) == 0 {
+		return nil
+	}
+	return fmt.Errorf("page loads remote assets in strict offline mode: %s", strings.Join(assets, ", "))
+}
+
+// remoteURL reports whether u points to another host.
+func remoteURL(u string) bool {
+	u = strings.ToLower(strings.TrimSpace(u))
+	return strings.HasPrefix(u, "http://") || strings.HasPrefix(u, "https://") || strings.HasPrefix(u, "//")
+}
+
+// cssURLKind tells fonts from images referenced by url() in CSS.
+func cssURLKind(u string) string {
+	u, _, _ = strings.Cut(u, "?")
+	switch strings.ToLower(path.Ext(u)) {
+	case ".woff", ".woff2", ".ttf", ".otf", ".eot":
+		return "font"
+	}
+	return "image"
+}
+
+func dedupeAssets(assets []RemoteAsset) []RemoteAsset {
+	seen := make(map[RemoteAsset]bool)
+	unique := assets[:0]
+	for _, a := range assets {
+		if !seen[a] {
+			seen[a] = true
+			unique = append(unique, a)
+		}
+	}
+	return unique
+}
@@ -0,0 +1,41 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestAuditOffline(t *testing.T) {
+	page := []byte(`<html><head>
+<link rel="stylesheet" href="/static/css/go-grip.css">
+<link rel="stylesheet" href="https://cdn.example.com/site.css">
+<link rel="preload" as="font" href="//fonts.example.com/a.woff2">
+<link rel="icon" href="https://example.com/favicon.ico">
+<script src="/static/js/code.js"></script>
+<script src="https://cdn.example.com/app.js"></script>
+<style>@import url("https://fonts.example.com/css?family=Inter"); body { font: url(https://fonts.example.com/b.ttf?v=1) }</style>
+</head><body>
+<img src="https://example.com/logo.png"><a href="https://example.com">site</a>
+<div style="background: url('https://example.com/bg.png')"></div>
+<iframe srcdoc="&lt;script src=&quot;https://gist.github.com/a/1.js&quot;&gt;&lt;/script&gt;"></iframe>
+<div class="grip-map" data-tiles="https://tile.example.com/{z}/{x}/{y}.png"></div>
+<script src="https://cdn.example.com/app.js"></script>
+</body></html>`)
+
+	want := []RemoteAsset{
+		{"stylesheet", "https://cdn.example.com/site.css"},
+		{"font", "//fonts.example.com/a.woff2"},
+		{"script", "https://cdn.example.com/app.js"},
+		{"stylesheet", "https://fonts.example.com/css?family=Inter"},
+		{"font", "https://fonts.example.com/b.ttf?v=1"},
+		{"image", "https://example.com/bg.png"},
+		{"script", "https://gist.github.com/a/1.js"},
+		{"tiles", "https://tile.example.com/{z}/{x}/{y}.png"},
+	}
+	if got := AuditOffline(page); !reflect.DeepEqual(got, want) {
+		t.Errorf("AuditOffline() =\n%v\nwant\n%v", got, want)
+	}
+	if got := AuditOffline([]byte(`<p>offline</p><script src="static/js/code.js"></script>`)); len(got) != 0 {
+		t.Errorf("AuditOffline() of a local page = %v", got)
+	}
+}
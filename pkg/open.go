@@ -1,10 +1,14 @@
 package pkg
 
 import (
+	"errors"
+	"os"
 	"os/exec"
 	"runtime"
+	"strings"
 )
 
+// Open opens url in the default browser of the system.
 func Open(url string) error {
 	var cmd string
 	var args []string
@@ -21,3 +25,84 @@ func Open(url string) error {
 	args = append(args, url)
 	return exec.Command(cmd, args...).Start()
 }
+
+// OpenWith opens url with command, e.g. "firefox --new-window %s", where %s
+// is replaced by the URL or the URL is appended if there is none. Without
+// command, the commands of the BROWSER environment variable are tried in
+// order, and without it Open is used.
+func OpenWith(command string, url string) error {
+	if command != "" {
+		return runBrowser(command, url)
+	}
+	browsers := os.Getenv("BROWSER")
+	if browsers == "" {
+		return Open(url)
+	}
+	sep := ":"
+	if runtime.GOOS == "windows" {
+		sep = ";"
+	}
+	err := errors.New("no command in $BROWSER")
+	for _, c := range strings.Split(browsers, sep) {
+		if strings.TrimSpace(c) == "" {
+			continue
+		}
+		if err = runBrowser(c, url); err == nil {
+			return nil
+		}
+	}
+	return err
+}
+
+func runBrowser(command string, url string) error {
+	args := splitCommand(command)
+	if len(args) == 0 {
+		return errors.New("empty browser command")
+	}
+	replaced := false
+	for i, a := range args[1:] {
+		if strings.Contains(a, "%s") {
+			args[i+1] = strings.ReplaceAll(a, "%s", url)
+			replaced = true
+		}
+	}
+	if !replaced {
+		args = append(args, url)
+	}
+	return exec.Command(args[0], args[1:]...).Start()
+}
+
+// splitCommand splits a command line into its arguments at spaces outside
+// of single or double quotes.
+func splitCommand(command string) []string {
+	var args []string
+	var arg strings.Builder
+	inArg := false
+	var quote rune
+	for _, r := range command {
+		switch {
+		case quote != 0:
+			if r == quote {
+				quote = 0
+			} else {
+				arg.WriteRune(r)
+			}
+		case r == '"' || r == '\'':
+			quote = r
+			inArg = true
+		case r == ' ' || r == '\t':
+			if inArg {
+				args = append(args, arg.String())
+				arg.Reset()
+				inArg = false
+			}
+		default:
+			arg.WriteRune(r)
+			inArg = true
+		}
+	}
+	if inArg {
+		args = append(args, arg.String())
+	}
+	return args
+}
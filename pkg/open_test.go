@@ -0,0 +1,14 @@
+package pkg
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestSplitCommand(t *testing.T) {
+	got := splitCommand(`"/Applications/Google Chrome.app/chrome" --app=%s  --profile-directory='Profile 1'`)
+	want := []string{"/Applications/Google Chrome.app/chrome", "--app=%s", "--profile-directory=Profile 1"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("splitCommand() = %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,288 @@
+package pkg
+
+import "time"
+
+// Option configures optional Server behaviour.
+type Option func(*Server)
+
+// WithTheme selects the color mode of rendered pages, e.g. "dark" or "auto".
+func WithTheme(theme string) Option {
+	return func(s *Server) {
+		s.theme = theme
+	}
+}
+
+// WithRoot sets the directory previewed by Handler.
+func WithRoot(dir string) Option {
+	return func(s *Server) {
+		s.root = dir
+	}
+}
+
+// WithBoundingBox enables or disables the box around rendered pages.
+func WithBoundingBox(enabled bool) Option {
+	return func(s *Server) {
+		s.boundingBox = enabled
+	}
+}
+
+// WithParser renders markdown with the given parser instead of one created by
+// New from the theme and WithParserOptions.
+func WithParser(p *Parser) Option {
+	return func(s *Server) {
+		s.parser = p
+	}
+}
+
+// WithParserOptions configures the parser created by New.
+func WithParserOptions(opts ...ParserOption) Option {
+	return func(s *Server) {
+		s.parserOpts = append(s.parserOpts, opts...)
+	}
+}
+
+// WithTLS serves over HTTPS using the given certificate and key files.
+func WithTLS(certFile string, keyFile string) Option {
+	return func(s *Server) {
+		s.tlsCert = certFile
+		s.tlsKey = keyFile
+	}
+}
+
+// WithSelfSignedTLS serves over HTTPS using a certificate generated at startup.
+func WithSelfSignedTLS() Option {
+	return func(s *Server) {
+		s.tlsSelfSigned = true
+	}
+}
+
+// WithHTTP2 enables or disables HTTP/2 over TLS, which loads pages with
+// many images and diagrams over a single connection. It is enabled by
+// default; plain HTTP always uses HTTP/1.1.
+func WithHTTP2(enabled bool) Option {
+	return func(s *Server) {
+		s.http2 = enabled
+	}
+}
+
+// Default timeouts of WithTimeouts. Responses have no write timeout by
+// default, renders are limited by WithRenderTimeout instead.
+const (
+	DefaultReadTimeout = 30 * time.Second
+	DefaultIdleTimeout = 2 * time.Minute
+)
+
+// WithTimeouts limits the time to read a request, to write a response and
+// to keep an idle connection open for further requests, instead of
+// DefaultReadTimeout, no write timeout and DefaultIdleTimeout. A zero
+// duration is unlimited. The live reload connections are not limited.
+func WithTimeouts(read time.Duration, write time.Duration, idle time.Duration) Option {
+	return func(s *Server) {
+		s.readTimeout = read
+		s.writeTimeout = write
+		s.idleTimeout = idle
+	}
+}
+
+// WithBasicAuth requires HTTP basic authentication for every request.
+func WithBasicAuth(user string, pass string) Option {
+	return func(s *Server) {
+		s.authUser = user
+		s.authPass = pass
+	}
+}
+
+// WithTokenAuth requires a bearer token for every request. The token can be
+// sent in the Authorization header or as the token query parameter.
+func WithTokenAuth(token string) Option {
+	return func(s *Server) {
+		s.authToken = token
+	}
+}
+
+// WithMaxRenders limits the number of markdown documents rendered at the same
+// time. Requests beyond the limit are rejected with 503.
+func WithMaxRenders(n int) Option {
+	return func(s *Server) {
+		if n > 0 {
+			s.renderSlots = make(chan struct{}, n)
+		}
+	}
+}
+
+// WithRenderTimeout aborts requests whose render takes longer than d with 503
+// and stops the diagram commands and requests of the render. Parsing the
+// markdown is not interrupted, and the render keeps its slot of
+// WithMaxRenders until the parse is done.
+func WithRenderTimeout(d time.Duration) Option {
+	return func(s *Server) {
+		s.renderTimeout = d
+	}
+}
+
+// WithPortScan tries up to n following ports if the configured port is
+// already in use.
+func WithPortScan(n int) Option {
+	return func(s *Server) {
+		s.portScan = n
+	}
+}
+
+// WithRemoteSource serves a remote document instead of a local file.
+func WithRemoteSource(src *RemoteSource) Option {
+	return func(s *Server) {
+		s.remote = src
+	}
+}
+
+// WithCacheSize limits the memory used for cached pages to maxBytes. A size
+// of zero disables the render cache.
+func WithCacheSize(maxBytes int64) Option {
+	return func(s *Server) {
+		if maxBytes <= 0 {
+			s.cache = nil
+			return
+		}
+		s.cache = newRenderCache(maxBytes)
+	}
+}
+
+// WithTemplate replaces the embedded page layout with the template file at
+// path. The file is parsed again whenever it changes.
+func WithTemplate(path string) Option {
+	return func(s *Server) {
+		if s.layout == nil {
+			s.layout = &layout{}
+		}
+		s.layout.override = path
+	}
+}
+
+// WithCompression enables or disables gzip and brotli compression of HTML,
+// CSS and JS responses. Compression is enabled by default.
+func WithCompression(enabled bool) Option {
+	return func(s *Server) {
+		s.compress = enabled
+	}
+}
+
+// WithHidden allows serving dotfiles and dot-directories such as .git.
+func WithHidden(hidden bool) Option {
+	return func(s *Server) {
+		s.hidden = hidden
+	}
+}
+
+// WithFollowSymlinks sets whether symbolic links below the served directory
+// are followed, which is the default. Links to a directory they are inside of
+// are left out of listings. Links are followed wherever they point to, so a
+// link to a file or directory outside of the served directory serves it too.
+// When disabled, paths through links are not found.
+func WithFollowSymlinks(follow bool) Option {
+	return func(s *Server) {
+		s.symlinks = follow
+	}
+}
+
+// WithIncludes expands <!-- include: file.md --> directives in markdown files
+// with the content of the included file, relative to the including one.
+func WithIncludes(enabled bool) Option {
+	return func(s *Server) {
+		s.includes = enabled
+	}
+}
+
+// WithMarkdownExtensions sets the file extensions rendered as markdown,
+// replacing DefaultMarkdownExtensions.
+func WithMarkdownExtensions(exts []string) Option {
+	return func(s *Server) {
+		s.setMarkdownExtensions(exts)
+	}
+}
+
+// WithRenderer renders files with the extension ext, e.g. ".txt", using r,
+// replacing the built-in renderer of the extension if there is one.
+func WithRenderer(ext string, r Renderer) Option {
+	return func(s *Server) {
+		s.renderers[normalizeExtension(ext)] = fixedRenderer(r)
+		if s.customRenderers == nil {
+			s.customRenderers = make(map[string]bool)
+		}
+		s.customRenderers[normalizeExtension(ext)] = true
+	}
+}
+
+// WithStartPage opens the page at the path, which may have a query, in the
+// browser instead of the served file.
+func WithStartPage(page string) Option {
+	return func(s *Server) {
+		s.startPage = page
+	}
+}
+
+// WithLightbox shows images enlarged in an overlay when they are clicked.
+func WithLightbox(enabled bool) Option {
+	return func(s *Server) {
+		s.lightbox = enabled
+	}
+}
+
+// WithReload enables or disables live reload. Without it no files are
+// watched and pages open no websocket, e.g. for read-only serving where
+// inotify watches are scarce. Live reload is enabled by default.
+func WithReload(enabled bool) Option {
+	return func(s *Server) {
+		s.reload = enabled
+	}
+}
+
+// WithReloadDebounce waits for d without further file changes before
+// reloading the page, instead of DefaultReloadDebounce.
+func WithReloadDebounce(d time.Duration) Option {
+	return func(s *Server) {
+		s.reloadDebounce = d
+	}
+}
+
+// WithReloadExclude ignores changes of the files matching patterns, like
+// logs or build output written inside the served directory, for live reload
+// and WatchExport. Patterns without a slash are globs matched against every
+// path element, like *.log or node_modules; other patterns are globs matched
+// against the slash separated path relative to the directory and its parent
+// directories, like build/*.html, or absolute paths of files or directories.
+// Caches of go-grip inside the directory and the output of WatchExport are
+// excluded without patterns.
+func WithReloadExclude(patterns []string) Option {
+	return func(s *Server) {
+		s.reloadExclude = patterns
+	}
+}
+
+// WithReloadTransport selects how browsers are notified about changes:
+// ReloadAuto, ReloadWebSocket or ReloadSSE.
+func WithReloadTransport(transport string) Option {
+	return func(s *Server) {
+		s.reloadTransport = transport
+	}
+}
+
+// WithBrowserCommand opens pages with command instead of the default
+// browser, see OpenWith.
+func WithBrowserCommand(command string) Option {
+	return func(s *Server) {
+		s.browserCmd = command
+	}
+}
+
+// open opens url in the browser configured for the server.
+func (s *Server) open(url string) error {
+	return OpenWith(s.browserCmd, url)
+}
+
+// WithDrafts exports pages marked with draft: true in their front matter,
+// which directory exports skip otherwise.
+func WithDrafts(enabled bool) Option {
+	return func(s *Server) {
+		s.drafts = enabled
+	}
+}
@@ -0,0 +1,57 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"html"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+
+	"github.com/niklasfasching/go-org/org"
+)
+
+// orgRenderer returns the renderer of Org files, which read the files of
+// #+INCLUDE and #+SETUPFILE from fsys.
+func orgRenderer(fsys http.FileSystem) Renderer {
+	return func(content []byte, name string) ([]byte, string, error) {
+		return orgToHTML(fsys, content, name)
+	}
+}
+
+// orgToHTML converts Org source to HTML, including the #+TITLE of the
+// document, and returns it with the title. Included files are read from
+// fsys, relative to the document, and never from outside of it.
+func orgToHTML(fsys http.FileSystem, content []byte, name string) ([]byte, string, error) {
+	conf := org.New()
+	conf.ReadFile = func(filename string) ([]byte, error) {
+		if fsys == nil {
+			return nil, errors.New("includes are not supported here")
+		}
+		// relative paths are joined with the directory of name by go-org
+		return readFile(fsys, path.Clean("/"+filepath.ToSlash(filename)))
+	}
+	// GitHub shows no table of contents
+	conf.DefaultSettings["OPTIONS"] = strings.Replace(conf.DefaultSettings["OPTIONS"], "toc:t", "toc:nil", 1)
+	doc := conf.Parse(bytes.NewReader(content), name)
+
+	w := org.NewHTMLWriter()
+	// top level headlines are H1 like on GitHub
+	w.TopLevelHLevel = 1
+	w.HighlightCodeBlock = func(source, lang string, inline bool, params map[string]string) string {
+		if code, ok := highlightCode(source, lang); ok && !inline {
+			return code
+		}
+		if inline {
+			return "<code>" + html.EscapeString(source) + "</code>"
+		}
+		return "<pre><code>" + html.EscapeString(source) + "</code></pre>"
+	}
+
+	out, err := doc.Write(w)
+	if err != nil {
+		return nil, "", err
+	}
+	return []byte(out), doc.Get("TITLE"), nil
+}
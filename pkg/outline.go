@@ -0,0 +1,150 @@
+package pkg
+
+import (
+	"encoding/json"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// OutlineHeading is a heading of a markdown document with the headings of its
+// section nested below it.
+type OutlineHeading struct {
+	Level int    `json:"level"`
+	Text  string `json:"text"`
+	Slug  string `json:"slug"`
+	// Offset is the byte offset of the heading line in the file, -1 if it
+	// could not be located.
+	Offset   int               `json:"offset"`
+	Children []*OutlineHeading `json:"children"`
+}
+
+// Outline returns the heading tree of markdown source, with the same slugs
+// the rendered headings get as anchors.
+func (m Parser) Outline(content []byte) []*OutlineHeading {
+	offsets := headingOffsets(content)
+
+	var roots []*OutlineHeading
+	var stack []*OutlineHeading
+	i := 0
+	ast.WalkFunc(m.parse(content), func(node ast.Node, entering bool) ast.WalkStatus {
+		heading, ok := node.(*ast.Heading)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		h := &OutlineHeading{
+			Level:    heading.Level,
+			Text:     strings.TrimSpace(nodeText(heading)),
+			Slug:     heading.HeadingID,
+			Offset:   -1,
+			Children: []*OutlineHeading{},
+		}
+		if i < len(offsets) {
+			h.Offset = offsets[i]
+			i++
+		}
+
+		for len(stack) > 0 && stack[len(stack)-1].Level >= h.Level {
+			stack = stack[:len(stack)-1]
+		}
+		if len(stack) == 0 {
+			roots = append(roots, h)
+		} else {
+			parent := stack[len(stack)-1]
+			parent.Children = append(parent.Children, h)
+		}
+		stack = append(stack, h)
+		return ast.SkipChildren
+	})
+	return roots
+}
+
+// headingOffsets returns the byte offsets of the ATX and setext heading lines
+// outside of fenced code blocks. The AST has no positions, so headings are
+// matched to these offsets in order.
+func headingOffsets(content []byte) []int {
+	var offsets []int
+	var fence string
+	offset := 0
+	prevText := -1
+	for _, line := range strings.SplitAfter(string(content), "\n") {
+		start := offset
+		offset += len(line)
+		trimmed := strings.TrimSpace(line)
+
+		if fence != "" {
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+			}
+			prevText = -1
+			continue
+		}
+		if marker, _, ok := fenceOpening([]byte(trimmed)); ok {
+			fence = marker
+			prevText = -1
+			continue
+		}
+
+		if level := len(trimmed) - len(strings.TrimLeft(trimmed, "#")); level >= 1 && level <= 6 &&
+			(len(trimmed) == level || trimmed[level] == ' ' || trimmed[level] == '\t') {
+			offsets = append(offsets, start)
+			prevText = -1
+			continue
+		}
+		if prevText >= 0 && trimmed != "" && (strings.Trim(trimmed, "=") == "" || strings.Trim(trimmed, "-") == "") {
+			offsets = append(offsets, prevText)
+			prevText = -1
+			continue
+		}
+		if trimmed == "" {
+			prevText = -1
+		} else if prevText < 0 {
+			prevText = start
+		}
+	}
+	return offsets
+}
+
+// outlineHandler serves the heading tree of the markdown file given by the
+// file query parameter as JSON, for editor integrations.
+func (s *Server) outlineHandler(dir http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Query().Get("file"))
+		if !s.IsMarkdown(name) {
+			http.Error(w, "file must be a markdown file", http.StatusBadRequest)
+			return
+		}
+
+		f, err := dir.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		if reason, status, err := s.previewError(name, f); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		} else if reason != "" {
+			http.Error(w, reason, status)
+			return
+		}
+
+		content, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		outline := s.parser.Outline(content)
+		if outline == nil {
+			outline = []*OutlineHeading{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		if err := json.NewEncoder(w).Encode(outline); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
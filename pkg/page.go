@@ -0,0 +1,95 @@
+package pkg
+
+import (
+	"bytes"
+	"errors"
+	"io"
+)
+
+// page is a page rendered into the layout template, kept as the layout
+// around the rendered content and the content itself, so the content of
+// large documents is never copied into one buffer with the layout.
+type page [][]byte
+
+// contentMarker stands in for the content when executing the layout, to
+// split the layout around it.
+const contentMarker = "\x00grip-content\x00"
+
+func (p page) size() int64 {
+	var n int64
+	for _, part := range p {
+		n += int64(len(part))
+	}
+	return n
+}
+
+// WriteTo writes the parts of the page to w one after another.
+func (p page) WriteTo(w io.Writer) (int64, error) {
+	var n int64
+	for _, part := range p {
+		m, err := w.Write(part)
+		n += int64(m)
+		if err != nil {
+			return n, err
+		}
+	}
+	return n, nil
+}
+
+// reader returns a reader of the page for http.ServeContent, which needs to
+// seek for range requests.
+func (p page) reader() io.ReadSeeker {
+	return &pageReader{page: p, size: p.size()}
+}
+
+type pageReader struct {
+	page page
+	size int64
+	off  int64
+}
+
+func (r *pageReader) Read(b []byte) (int, error) {
+	if r.off >= r.size {
+		return 0, io.EOF
+	}
+	n := 0
+	start := int64(0)
+	for _, part := range r.page {
+		end := start + int64(len(part))
+		if r.off < end && n < len(b) {
+			m := copy(b[n:], part[r.off-start:])
+			n += m
+			r.off += int64(m)
+		}
+		start = end
+	}
+	return n, nil
+}
+
+func (r *pageReader) Seek(offset int64, whence int) (int64, error) {
+	switch whence {
+	case io.SeekStart:
+	case io.SeekCurrent:
+		offset += r.off
+	case io.SeekEnd:
+		offset += r.size
+	default:
+		return 0, errors.New("invalid whence")
+	}
+	if offset < 0 {
+		return 0, errors.New("negative position")
+	}
+	r.off = offset
+	return offset, nil
+}
+
+// splitLayout splits an executed layout at the content marker and puts the
+// content in between.
+func splitLayout(layout []byte, content []byte) page {
+	head, tail, ok := bytes.Cut(layout, []byte(contentMarker))
+	if !ok {
+		// the template doesn't show the content
+		return page{layout}
+	}
+	return page{head, content, tail}
+}
@@ -0,0 +1,389 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"slices"
+	"strconv"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+)
+
+func TestPageReader(t *testing.T) {
+	p := splitLayout([]byte("<body>"+contentMarker+"</body>"), []byte("<p>content</p>"))
+	if len(p) != 3 {
+		t.Fatalf("expected the layout to be split around the content, got %q", p)
+	}
+
+	r := p.reader()
+	all, err := io.ReadAll(r)
+	if err != nil || string(all) != "<body><p>content</p></body>" {
+		t.Fatalf("unexpected page %q: %v", all, err)
+	}
+
+	if _, err := r.Seek(4, io.SeekStart); err != nil {
+		t.Fatal(err)
+	}
+	buf := make([]byte, 10)
+	n, err := io.ReadFull(r, buf)
+	if err != nil || string(buf[:n]) != "y><p>conte" {
+		t.Errorf("unexpected range %q: %v", buf[:n], err)
+	}
+	if end, _ := r.Seek(0, io.SeekEnd); end != p.size() {
+		t.Errorf("expected the end at %d, got %d", p.size(), end)
+	}
+}
+
+func TestPreviewError(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string][]byte{
+		"text.md":   []byte("# Text"),
+		"utf16.md":  {0xff, 0xfe, '#', 0, ' ', 0, 'A', 0},
+		"binary.md": {'#', 0, 1, 2, 0, 0, 0, 0xff},
+		"large.md":  bytes.Repeat([]byte("a"), 2048),
+		"model.stl": {0, 0, 0, 0},
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), content, 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := &Server{maxPreviewSize: 1024}
+	for name, want := range map[string]struct {
+		reason string
+		status int
+	}{
+		"text.md":   {"", 0},
+		"utf16.md":  {"", 0},
+		"binary.md": {"This file is binary and can't be previewed.", http.StatusUnsupportedMediaType},
+		"large.md":  {"This file is too large to preview (2.0 KB).", http.StatusRequestEntityTooLarge},
+		"model.stl": {"", 0},
+	} {
+		f, err := http.Dir(dir).Open("/" + name)
+		if err != nil {
+			t.Fatal(err)
+		}
+		got, status, err := s.previewError("/"+name, f)
+		if err != nil {
+			t.Fatal(err)
+		}
+		if got != want.reason || status != want.status {
+			t.Errorf("previewError(%s) = %q, %d, want %q, %d", name, got, status, want.reason, want.status)
+		}
+		if content, _ := io.ReadAll(f); want.reason == "" && !bytes.Equal(content, files[name]) {
+			t.Errorf("previewError(%s) did not rewind the file", name)
+		}
+		f.Close()
+	}
+}
+
+func TestLayoutPartials(t *testing.T) {
+	dir := t.TempDir()
+	head := filepath.Join(dir, "head.html")
+	footer := filepath.Join(dir, "footer.html")
+	if err := os.WriteFile(head, []byte(`<meta name="title" content="{{ .Title }}">`), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(footer, []byte(`<footer>custom</footer>`), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	l := &layout{partials: map[string]string{"head": head, "footer": footer}}
+	tmpl, _, err := l.get()
+	if err != nil {
+		t.Fatal(err)
+	}
+	var buf bytes.Buffer
+	if err := tmpl.Execute(&buf, map[string]any{"Title": "Page", "BoundingBox": true}); err != nil {
+		t.Fatal(err)
+	}
+	page := buf.String()
+	if !bytes.Contains(buf.Bytes(), []byte(`<meta name="title" content="Page">`)) || !bytes.Contains(buf.Bytes(), []byte("<footer>custom</footer>")) {
+		t.Errorf("expected the partials in the page, got %s", page)
+	}
+	if bytes.Contains(buf.Bytes(), []byte("Made with")) {
+		t.Error("expected the footer partial to replace the default footer")
+	}
+
+	// the embedded layout is left alone
+	buf.Reset()
+	if err := defaultLayout.Execute(&buf, map[string]any{"BoundingBox": true}); err != nil {
+		t.Fatal(err)
+	}
+	if !bytes.Contains(buf.Bytes(), []byte("Made with")) {
+		t.Error("expected the default footer in the embedded layout")
+	}
+}
+
+func TestNearbyFiles(t *testing.T) {
+	dir := t.TempDir()
+	for _, name := range []string{"docs/guide.md", "docs/install.md", "docs/.hidden.md", "README.md"} {
+		p := filepath.Join(dir, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte("# "+name), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for name, want := range map[string][]string{
+		"/docs/gide.md":         {"/docs/guide.md", "/docs/install.md"},
+		"/docs/missing/page.md": {"/docs/guide.md", "/docs/install.md"},
+		"/dcs/guide.md":         {"/README.md", "/docs/"},
+	} {
+		got := nearbyFiles(http.Dir(dir), name, 2)
+		if !slices.Equal(got, want) {
+			t.Errorf("nearbyFiles(%s) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestErrorDetail(t *testing.T) {
+	root := filepath.Join(t.TempDir(), "docs")
+	s := &site{dirs: []string{root}}
+	err := fmt.Errorf("open %s: permission denied; %s: is a directory; %s", filepath.Join(root, "a", "b.md"), root, root+"2")
+	want := fmt.Sprintf("open %s: permission denied; .: is a directory; %s", filepath.Join("a", "b.md"), root+"2")
+	if got := s.errorDetail(err); got != want {
+		t.Errorf("got %q, want %q", got, want)
+	}
+}
+
+func TestCodeRenderer(t *testing.T) {
+	s := &Server{renderCode: true}
+	for name, want := range map[string]bool{
+		"main.go":    true,
+		"Makefile":   true,
+		"index.html": false,
+		"image.png":  false,
+		"data.xyz":   false,
+	} {
+		if _, ok := s.codeRenderer("/" + name); ok != want {
+			t.Errorf("codeRenderer(%s) = %v, want %v", name, ok, want)
+		}
+	}
+
+	out, title, err := s.renderCodeFile([]byte("package main\n\nfunc main() {}\n"), "/src/main.go")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if title != "main.go" {
+		t.Errorf("unexpected title %q", title)
+	}
+	for _, want := range []string{"3 lines", `id="L3"`, `href="#L3"`, `href="./main.go?raw=1"`} {
+		if !bytes.Contains(out, []byte(want)) {
+			t.Errorf("expected %q in %s", want, out)
+		}
+	}
+
+	s.renderCode = false
+	if _, ok := s.codeRenderer("/main.go"); ok {
+		t.Error("expected no code view without WithRenderCode")
+	}
+}
+
+func TestPageRevision(t *testing.T) {
+	s := &Server{parser: NewParser("light")}
+	page, _, err := s.renderPage(s.renderMarkdown, []byte("# Title"), "/doc.md", pageNav{Revision: pageRevision([]byte("# Title"))})
+	if err != nil {
+		t.Fatal(err)
+	}
+	html, _ := io.ReadAll(page.reader())
+	want := `data-revision="` + pageRevision([]byte("# Title")) + `"`
+	if !bytes.Contains(html, []byte(want)) || !bytes.Contains(html, []byte(assetPath("/static/js/share.js"))) {
+		t.Errorf("expected the revision and the share button in %s", html)
+	}
+	if pageRevision([]byte("# Title")) == pageRevision([]byte("# Changed")) {
+		t.Error("expected a new revision for changed content")
+	}
+}
+
+func TestStaticAssets(t *testing.T) {
+	fp := assetPath("/static/css/go-grip.css")
+	if fp == "/static/css/go-grip.css" || !strings.HasPrefix(fp, "/static/css/go-grip.") || !strings.HasSuffix(fp, ".css") {
+		t.Fatalf("expected a fingerprinted path, got %q", fp)
+	}
+	if p := assetPath("/static/emojis/shipit.png"); p != "/static/emojis/shipit.png" {
+		t.Errorf("expected emojis to keep their path, got %q", p)
+	}
+
+	h := staticHandler()
+	for _, tt := range []struct {
+		path, cacheControl string
+	}{
+		{fp, "public, max-age=31536000, immutable"},
+		{"/static/css/go-grip.css", ""},
+		{assetPath("/static/syntax-light.css"), "public, max-age=31536000, immutable"},
+		{"/static/syntax-light.css", "public, max-age=3600"},
+	} {
+		rec := httptest.NewRecorder()
+		h.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, tt.path, nil))
+		if rec.Code != http.StatusOK {
+			t.Errorf("%s: expected status 200, got %d", tt.path, rec.Code)
+		}
+		if got := rec.Header().Get("Cache-Control"); got != tt.cacheControl {
+			t.Errorf("%s: expected Cache-Control %q, got %q", tt.path, tt.cacheControl, got)
+		}
+	}
+}
+
+func TestMetaHeaders(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "doc.md"), []byte("# Title\n\n## Section\n\ntext\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	info, err := os.Stat(filepath.Join(dir, "doc.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	s := NewServer(nil, 0, "light", false, false, NewParser("light")).newSite(dir)
+	fs := http.Dir(dir)
+	serve := func(method string, header ...string) *httptest.ResponseRecorder {
+		f, err := fs.Open("/doc.md")
+		if err != nil {
+			t.Fatal(err)
+		}
+		defer f.Close()
+		req := httptest.NewRequest(method, "/doc.md", nil)
+		for i := 0; i+1 < len(header); i += 2 {
+			req.Header.Set(header[i], header[i+1])
+		}
+		rec := httptest.NewRecorder()
+		s.serveRenderedFile(rec, req, fs, f, s.renderMarkdown)
+		return rec
+	}
+
+	// HEAD before the page is cached skips rendering
+	rec := serve(http.MethodHead)
+	if rec.Code != http.StatusOK || rec.Body.Len() != 0 {
+		t.Fatalf("expected an empty 200 response, got %d with %d bytes", rec.Code, rec.Body.Len())
+	}
+	if got := rec.Header().Get(HeaderOutlineCount); got != "2" {
+		t.Errorf("expected 2 headings, got %q", got)
+	}
+	if got, want := rec.Header().Get(HeaderSourceMtime), info.ModTime().UTC().Format(time.RFC3339Nano); got != want {
+		t.Errorf("expected source mtime %q, got %q", want, got)
+	}
+	if got := rec.Header().Get(HeaderRenderTime); got != "" {
+		t.Errorf("expected no render time without rendering, got %q", got)
+	}
+	etag := rec.Header().Get("ETag")
+	if !strings.HasPrefix(etag, `W/"`) {
+		t.Errorf("expected a weak ETag of the source, got %q", etag)
+	}
+	if rec := serve(http.MethodHead, "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for a matching ETag, got %d", rec.Code)
+	}
+	if rec := serve(http.MethodHead, "If-Modified-Since", info.ModTime().UTC().Format(http.TimeFormat)); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for an unmodified source, got %d", rec.Code)
+	}
+	if _, _, ok := s.cache.get(dir+"\x00"+parsePageQuery(httptest.NewRequest(http.MethodGet, "/doc.md", nil)).cacheKey("/doc.md"), info.ModTime()); ok {
+		t.Error("expected HEAD not to render the page into the cache")
+	}
+
+	rec = serve(http.MethodGet)
+	if got := rec.Header().Get("ETag"); got != etag {
+		t.Errorf("expected GET to send the ETag %q of HEAD, got %q", etag, got)
+	}
+	renderTime := rec.Header().Get(HeaderRenderTime)
+	if _, err := strconv.ParseFloat(renderTime, 64); err != nil {
+		t.Errorf("expected the render time in milliseconds, got %q", renderTime)
+	}
+	if got := rec.Header().Get(HeaderOutlineCount); got != "2" {
+		t.Errorf("expected 2 headings, got %q", got)
+	}
+
+	// HEAD after the page is cached tells everything GET does
+	rec = serve(http.MethodHead)
+	if got := rec.Header().Get(HeaderRenderTime); got != renderTime {
+		t.Errorf("expected the cached render time %q, got %q", renderTime, got)
+	}
+	if rec.Header().Get("ETag") != etag || rec.Header().Get("Content-Length") == "" {
+		t.Errorf("expected the ETag %q and length of the cached page, got %v", etag, rec.Header())
+	}
+	if rec := serve(http.MethodGet, "If-None-Match", etag); rec.Code != http.StatusNotModified {
+		t.Errorf("expected 304 for GET with the ETag of HEAD, got %d", rec.Code)
+	}
+}
+
+func TestHandlersKeepTheirState(t *testing.T) {
+	s := New(WithBreadcrumbs(true), WithPager(true))
+	defer s.Close()
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	names := []string{"alpha", "beta"}
+	handlers := make([]http.Handler, len(names))
+	var wg sync.WaitGroup
+	for i, name := range names {
+		dir := filepath.Join(t.TempDir(), name)
+		if err := os.Mkdir(dir, 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(filepath.Join(dir, "README.md"), []byte("# Readme of "+name+"\n"), 0644); err != nil {
+			t.Fatal(err)
+		}
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			handlers[i], _ = s.handler(ctx, dir, "", "")
+		}()
+	}
+	wg.Wait()
+
+	for round := 0; round < 3; round++ {
+		for i, name := range names {
+			wg.Add(1)
+			go func() {
+				defer wg.Done()
+				rec := httptest.NewRecorder()
+				handlers[i].ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/README.md", nil))
+				body := rec.Body.String()
+				if rec.Code != http.StatusOK || !strings.Contains(body, "Readme of "+name) {
+					t.Errorf("%s: got %d without its readme", name, rec.Code)
+				}
+				if !strings.Contains(body, `<a href="/">`+name+`</a>`) {
+					t.Errorf("%s: expected the breadcrumbs to start at %s", name, name)
+				}
+			}()
+		}
+	}
+	wg.Wait()
+}
+
+func TestWriteHTMLExpandsStdin(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.WriteFile(filepath.Join(dir, "part.md"), []byte("Included part\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	wd, err := os.Getwd()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := os.Chdir(dir); err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { os.Chdir(wd) })
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"),
+		WithEncoding(EncodingLatin1), WithIncludes(true), WithVars(true, ""))
+	var buf bytes.Buffer
+	content := []byte("---\nname: Caf\xe9\n---\n# Hello {{ .name }}\n\n<!-- include: part.md -->\n")
+	if err := s.WriteHTML(&buf, content); err != nil {
+		t.Fatal(err)
+	}
+	for _, want := range []string{"Hello Café", "Included part"} {
+		if !strings.Contains(buf.String(), want) {
+			t.Errorf("expected %q in:\n%s", want, buf.String())
+		}
+	}
+}
@@ -0,0 +1,113 @@
+package pkg
+
+import (
+	"io"
+	"net/http"
+	"path"
+	"sort"
+	"strings"
+	"sync"
+	"time"
+)
+
+// WithPager links the previous and next page below the pages of directories
+// without SUMMARY.md or mkdocs.yml, which have their own order. Pages are
+// ordered like a depth-first walk of the directory tree: the README or index
+// of a directory first, then its pages by the order field of their front
+// matter and file name, then its subdirectories by name.
+func WithPager(enabled bool) Option {
+	return func(s *Server) {
+		s.pager = enabled
+	}
+}
+
+// pageOrder caches the reading order of the served directory, which is read
+// again after a file changed.
+type pageOrder struct {
+	mu      sync.Mutex
+	book    *book
+	modTime time.Time
+}
+
+func (o *pageOrder) invalidate() {
+	o.mu.Lock()
+	o.book = nil
+	o.mu.Unlock()
+}
+
+// get returns the pages of dir in reading order and when it was read.
+func (o *pageOrder) get(s *Server, dir http.FileSystem) (*book, time.Time) {
+	o.mu.Lock()
+	defer o.mu.Unlock()
+	if o.book == nil {
+		o.book = &book{ordered: true}
+		s.orderPages(dir, "/", o.book)
+		o.modTime = time.Now()
+	}
+	return o.book, o.modTime
+}
+
+// orderPages appends the pages below the directory name to b in reading
+// order.
+func (s *Server) orderPages(dir http.FileSystem, name string, b *book) {
+	f, err := dir.Open(name)
+	if err != nil {
+		return
+	}
+	infos, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return
+	}
+
+	var pages []indexEntry
+	var dirs []string
+	for _, info := range infos {
+		p := path.Join(name, info.Name())
+		if info.IsDir() {
+			if info.Name() != "node_modules" {
+				dirs = append(dirs, p)
+			}
+			continue
+		}
+		if !s.IsMarkdown(p) {
+			continue
+		}
+		content, err := readFile(dir, p)
+		if err != nil {
+			continue
+		}
+		fm, _ := parseFrontMatter(content)
+		pages = append(pages, indexEntry{file: p, title: extractTitle(content, info.Name()), order: fm.Order})
+	}
+	sort.Slice(pages, func(i, j int) bool {
+		if a, b := indexPage(pages[i].file), indexPage(pages[j].file); a != b {
+			return a
+		}
+		return pages[i].less(pages[j])
+	})
+	sort.Strings(dirs)
+
+	for _, p := range pages {
+		b.chapters = append(b.chapters, bookChapter{title: p.title, path: p.file})
+	}
+	for _, d := range dirs {
+		s.orderPages(dir, d, b)
+	}
+}
+
+// indexPage reports whether name is the page shown for its directory.
+func indexPage(name string) bool {
+	base := strings.ToLower(path.Base(name))
+	base = strings.TrimSuffix(base, path.Ext(base))
+	return base == "readme" || base == "index"
+}
+
+func readFile(dir http.FileSystem, name string) ([]byte, error) {
+	f, err := dir.Open(name)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	return io.ReadAll(f)
+}
@@ -2,13 +2,16 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"encoding/json"
 	"fmt"
 	"html/template"
 	"io"
-	"log"
+	"log/slog"
 	"path"
 	"regexp"
 	"strings"
+	"time"
 
 	"github.com/alecthomas/chroma/v2"
 	chroma_html "github.com/alecthomas/chroma/v2/formatters/html"
@@ -24,80 +27,413 @@ import (
 var blockquotes = []string{"Note", "Tip", "Important", "Warning", "Caution", "BlockQuote"}
 
 type Parser struct {
-	theme string
+	theme         string
+	linkBase      string
+	imageBase     string
+	mapTiles      string
+	plantuml      *plantuml
+	graphvizDot   string
+	diagrams      *diagramCache
+	wikiRoot      string
+	repoPrefix    string
+	repo          string
+	repoURL       string
+	sourceLines   bool
+	smartypants   bool
+	hardWraps     bool
+	definitions   bool
+	attributes    bool
+	abbreviations bool
+	embeds        bool
+
+	footnoteMarkers  string
+	footnoteBacklink string
+	bibliography     *bibliography
+
+	detectLanguage bool
+	lexerAliases   map[string]string
+
+	transforms []SourceTransform
+	visitors   []ASTVisitor
+	filters    []HTMLFilter
+
+	// stats collects timings of a render, see WithRenderTimings
+	stats *renderStats
+	// ctx cancels the diagram commands and requests of a render, see
+	// WithRenderTimeout
+	ctx context.Context
 }
 
-func NewParser(theme string) *Parser {
-	return &Parser{
-		theme: theme,
+// ParserOption configures optional Parser behaviour.
+type ParserOption func(*Parser)
+
+// WithBaseURL resolves relative links and images against the given base URLs,
+// used when rendering documents that are not served from the local disk.
+func WithBaseURL(linkBase string, imageBase string) ParserOption {
+	return func(p *Parser) {
+		p.linkBase = linkBase
+		p.imageBase = imageBase
+	}
+}
+
+// WithSmartypants converts straight quotes, dashes and ellipses to their
+// typographic equivalents. GitHub doesn't do this, so it is off by default.
+func WithSmartypants(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.smartypants = enabled
+	}
+}
+
+// WithHardWraps renders single newlines in paragraphs as line breaks, like
+// GitHub does in issues and comments, instead of joining the lines like in
+// rendered files.
+func WithHardWraps(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.hardWraps = enabled
+	}
+}
+
+// WithDefinitionLists enables Python-Markdown and Kramdown style definition
+// lists, a term followed by lines starting with ": ". GitHub doesn't support
+// them.
+func WithDefinitionLists(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.definitions = enabled
+	}
+}
+
+// DefaultLexerAliases map code block languages chroma has no lexer for to
+// similar ones, see WithLexerAliases.
+var DefaultLexerAliases = map[string]string{
+	"jsonc": "json",
+	"json5": "json",
+}
+
+// WithLanguageDetection highlights code blocks without language with the
+// lexer guessed from their content, the default. GitHub doesn't highlight
+// them.
+func WithLanguageDetection(enabled bool) ParserOption {
+	return func(p *Parser) {
+		p.detectLanguage = enabled
 	}
 }
 
+// WithLexerAliases highlights code blocks in a language like the one it is
+// mapped to, e.g. "tf" to "terraform", in addition to DefaultLexerAliases.
+func WithLexerAliases(aliases map[string]string) ParserOption {
+	return func(p *Parser) {
+		p.lexerAliases = aliases
+	}
+}
+
+func NewParser(theme string, opts ...ParserOption) *Parser {
+	p := &Parser{
+		theme:       theme,
+		graphvizDot: installedGraphvizDot(),
+
+		detectLanguage: true,
+	}
+	for _, opt := range opts {
+		opt(p)
+	}
+	return p
+}
+
 func (m Parser) MdToHTML(bytes []byte) []byte {
-	extensions := parser.NoIntraEmphasis | parser.Tables | parser.FencedCode |
-		parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs |
-		parser.BackslashLineBreak | parser.MathJax | parser.OrderedListStart
-	p := parser.NewWithExtensions(extensions)
-	doc := p.Parse(bytes)
+	return m.MdToHTMLFile(bytes, "")
+}
 
+// MdToHTMLFile renders the markdown file at name, the slash separated path
+// of the file in the served directory, resolving its local links like GitHub
+// would, see WithRepoLinks.
+func (m Parser) MdToHTMLFile(bytes []byte, name string) []byte {
+	start := time.Now()
+	doc := m.parse(bytes)
+	if name != "" && m.linkBase == "" {
+		resolveLocalLinks(doc, m.repoPrefix, name)
+	}
+	if m.sourceLines {
+		addSourceLines(doc, bytes)
+	}
+	if m.stats == nil {
+		return m.render(doc)
+	}
+	m.stats.addParse(start)
+	headings := countHeadings(doc)
+	out := m.render(doc)
+	// set after rendering, which may render parts of the document too
+	m.stats.headings = headings
+	return out
+}
+
+// render renders a parsed document, or a part of it, to HTML.
+func (m Parser) render(node ast.Node) []byte {
 	htmlFlags := html.CommonFlags
 	opts := html.RendererOptions{Flags: htmlFlags, RenderNodeHook: m.renderHook}
 	renderer := html.NewRenderer(opts)
 
-	return markdown.Render(doc, renderer)
+	out := markdown.Render(node, renderer)
+	for _, f := range m.filters {
+		out = f(out)
+	}
+	return out
+}
+
+// parse parses markdown into the document rendered by MdToHTML.
+func (m Parser) parse(md []byte) ast.Node {
+	for _, t := range m.transforms {
+		md = t(md)
+	}
+	md = blankFrontMatter(md)
+	md = normalizeFences(md)
+	md = normalizeTables(md)
+	var abbrs map[string]string
+	if m.abbreviations {
+		md, abbrs = extractAbbreviations(md)
+	}
+	doc := m.parseBlocks(md)
+	fixTableCells(doc)
+	removeEmptyTableBodies(doc)
+	autolinkLiterals(doc)
+	// before @user mentions are linked
+	m.citeWorks(doc)
+	if m.repoURL != "" {
+		m.linkReferences(doc)
+	}
+	if m.attributes {
+		headingAttributes(doc)
+	}
+	addHeadingIDs(doc)
+	addAbbreviations(doc, abbrs)
+	m.renderFootnotes(doc)
+	rewriteRelativeURLs(doc, m.linkBase, m.imageBase)
+	for _, v := range m.visitors {
+		v(doc)
+	}
+	return doc
+}
+
+// parseBlocks parses markdown without the passes over the document, which
+// are run once by parse after the content of <details> blocks is parsed too.
+func (m Parser) parseBlocks(md []byte) ast.Node {
+	extensions := parser.NoIntraEmphasis | parser.Tables | parser.FencedCode |
+		parser.Autolink | parser.Strikethrough | parser.SpaceHeadings | parser.HeadingIDs |
+		parser.BackslashLineBreak | parser.MathJax | parser.OrderedListStart | parser.Footnotes
+	if m.hardWraps {
+		extensions |= parser.HardLineBreak
+	}
+	if m.definitions {
+		extensions |= parser.DefinitionLists
+	}
+	if m.attributes {
+		extensions |= parser.Attributes
+	}
+	p := parser.NewWithExtensions(extensions)
+	registerWikiLinks(p, md, m.wikiRoot)
+	doc := p.Parse(md)
+	expandDetails(doc, m.parseBlocks)
+	return doc
 }
 
 func (m Parser) renderHook(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
+	if m.ctx != nil && m.ctx.Err() != nil {
+		// the render timed out, nobody waits for the rest
+		return ast.Terminate, true
+	}
+	if status, ok := m.renderHookFootnotes(w, node, entering); ok {
+		return status, ok
+	}
 	switch node.(type) {
 	case *ast.BlockQuote:
-		return renderHookBlockQuote()
+		return renderHookBlockQuote(node)
 	case *ast.Paragraph:
 		return renderHookParagraph(w, node, entering)
 	case *ast.Text:
+		if m.smartypants {
+			return renderSmartypants(w, node)
+		}
 		return renderHookText(w, node)
 	case *ast.ListItem:
 		return renderHookListItem(w, node, entering)
 	case *ast.CodeBlock:
-		return renderHookCodeBlock(w, node, m.theme)
+		return m.renderHookCodeBlock(w, node)
+	case *ast.Image:
+		return renderHookImage(w, node, entering)
+	case *ast.Link:
+		return m.renderHookLink(w, node, entering)
 	}
 
 	return ast.GoToNext, false
 }
 
-func renderHookCodeBlock(w io.Writer, node ast.Node, theme string) (ast.WalkStatus, bool) {
-	block := node.(*ast.CodeBlock)
+func (m Parser) renderHookCodeBlock(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
+	line := sourceLine(node)
+	if line == "" {
+		return m.renderCodeBlock(w, node.(*ast.CodeBlock))
+	}
+	var buf bytes.Buffer
+	status, ok := m.renderCodeBlock(&buf, node.(*ast.CodeBlock))
+	if _, err := io.WriteString(w, withSourceLine(buf.String(), line)); err != nil {
+		slog.Error("failed to write HTML", "err", err)
+	}
+	return status, ok
+}
+
+// context returns the context of the render, see Parser.ctx.
+func (m Parser) context() context.Context {
+	if m.ctx == nil {
+		return context.Background()
+	}
+	return m.ctx
+}
 
-	if string(block.Info) == "mermaid" {
-		m, err := renderMermaid(string(block.Literal), theme)
+func (m Parser) renderCodeBlock(w io.Writer, block *ast.CodeBlock) (ast.WalkStatus, bool) {
+	lang, attrs := parseFenceInfo(string(block.Info))
+
+	if lang == "geojson" || lang == "topojson" {
+		geo, err := renderMap(string(block.Literal), lang, m.mapTiles)
+		if err == nil {
+			fmt.Fprint(w, geo)
+			return ast.GoToNext, true
+		}
+		// invalid geodata is shown as a code block, like GitHub does
+		slog.Warn("invalid map data", "err", err)
+	}
+
+	if m.plantuml != nil && (lang == "plantuml" || lang == "puml") {
+		diagram, err := m.plantuml.renderHTML(m.context(), string(block.Literal), m.diagrams)
+		if err == nil {
+			fmt.Fprint(w, diagram)
+			return ast.GoToNext, true
+		}
+		slog.Error("failed to render diagram", "err", err)
+		fmt.Fprintf(w, `<p class="grip-diagram-error">%s</p>`, template.HTMLEscapeString(err.Error()))
+	}
+
+	if m.graphvizDot != "" && (lang == "dot" || lang == "graphviz") {
+		diagram, err := renderGraphviz(m.context(), string(block.Literal), m.graphvizDot, m.diagrams)
+		if err == nil {
+			fmt.Fprint(w, diagram)
+			return ast.GoToNext, true
+		}
+		slog.Error("failed to render diagram", "err", err)
+		fmt.Fprintf(w, `<p class="grip-diagram-error">%s</p>`, template.HTMLEscapeString(err.Error()))
+	}
+
+	if lang == "mermaid" {
+		diagram, err := renderMermaid(string(block.Literal), m.theme)
 		if err != nil {
-			log.Println("Error:", err)
+			slog.Error("failed to render diagram", "err", err)
 		}
-		fmt.Fprint(w, m)
+		fmt.Fprint(w, diagram)
 		return ast.GoToNext, true
 	}
 
 	var lexer chroma.Lexer
-	if block.Info == nil {
-		lexer = lexers.Analyse(string(block.Literal))
-	} else {
-		lexer = lexers.Get(string(block.Info))
+	switch {
+	case lang == "" && attrs["file"] != "":
+		// embedded snippets are highlighted by the language of their file
+		lexer = lexers.Match(path.Base(attrs["file"]))
+	default:
+		lexer = m.lexer(lang, string(block.Literal))
 	}
 	// ensure lexer is never nil
 	if lexer == nil {
 		lexer = lexers.Get("plaintext")
 	}
 
+	// a title like ```go title="main.go" is shown in a bar above the code
+	if title := attrs["title"]; title != "" {
+		fmt.Fprintf(w, `<div class="grip-code"><div class="grip-code-title"><span>%s</span>`+
+			`<button class="grip-code-copy" type="button" title="Copy">Copy</button></div>`, template.HTMLEscapeString(title))
+		defer fmt.Fprint(w, "</div>")
+	}
+
+	if m.stats != nil {
+		defer m.stats.addHighlight(time.Now())
+	}
 	iterator, _ := lexer.Tokenise(nil, string(block.Literal))
 	formatter := chroma_html.New(chroma_html.WithClasses(true))
 	err := formatter.Format(w, styles.Fallback, iterator)
 	if err != nil {
-		log.Println("Error:", err)
+		slog.Error("failed to highlight code", "err", err)
 	}
 	return ast.GoToNext, true
 }
 
-func renderHookBlockQuote() (ast.WalkStatus, bool) {
-	return ast.GoToNext, true
+// lexer returns the lexer for a code block in lang, resolving aliases and
+// guessing the language of blocks without one if enabled, or nil.
+func (m Parser) lexer(lang string, source string) chroma.Lexer {
+	key := strings.ToLower(lang)
+	if alias, ok := m.lexerAliases[key]; ok {
+		lang = alias
+	} else if alias, ok := DefaultLexerAliases[key]; ok {
+		lang = alias
+	}
+	if lang == "" {
+		if !m.detectLanguage {
+			return nil
+		}
+		if lexer := lexers.Analyse(source); lexer != nil {
+			return lexer
+		}
+		// chroma can't tell JSON from other languages by its content
+		if trimmed := strings.TrimSpace(source); strings.HasPrefix(trimmed, "{") || strings.HasPrefix(trimmed, "[") {
+			if json.Valid([]byte(trimmed)) {
+				return lexers.Get("json")
+			}
+		}
+		return nil
+	}
+	return lexers.Get(lang)
+}
+
+// highlightCode highlights source with the lexer for lang like fenced code
+// blocks, reporting false if there is no such lexer.
+func highlightCode(source string, lang string) (string, bool) {
+	lexer := lexers.Get(lang)
+	if lexer == nil {
+		return "", false
+	}
+	iterator, err := lexer.Tokenise(nil, source)
+	if err != nil {
+		return "", false
+	}
+	var buf bytes.Buffer
+	formatter := chroma_html.New(chroma_html.WithClasses(true))
+	if err := formatter.Format(&buf, styles.Fallback, iterator); err != nil {
+		return "", false
+	}
+	return buf.String(), true
+}
+
+func renderHookBlockQuote(node ast.Node) (ast.WalkStatus, bool) {
+	// alerts are rendered by the paragraph hook, plain quotes by the default renderer
+	return ast.GoToNext, alertType(node) != ""
+}
+
+// alertType returns the alert type of a blockquote starting with e.g. [!NOTE],
+// or an empty string for plain blockquotes.
+func alertType(node ast.Node) string {
+	children := node.GetChildren()
+	if len(children) == 0 {
+		return ""
+	}
+	paragraph, ok := children[0].(*ast.Paragraph)
+	if !ok || len(paragraph.GetChildren()) == 0 {
+		return ""
+	}
+	t, ok := paragraph.GetChildren()[0].(*ast.Text)
+	if !ok {
+		return ""
+	}
+
+	for _, b := range blockquotes {
+		if strings.HasPrefix(string(t.Literal), fmt.Sprintf("[!%s]", strings.ToUpper(b))) {
+			return strings.ToLower(b)
+		}
+	}
+	return ""
 }
 
 func renderHookParagraph(w io.Writer, node ast.Node, entering bool) (ast.WalkStatus, bool) {
@@ -132,22 +468,33 @@ func renderHookParagraph(w io.Writer, node ast.Node, entering bool) (ast.WalkSta
 	if entering {
 		var s string
 		s, _ = createBlockquoteStart(alert)
-		_, err = io.WriteString(w, s)
+		_, err = io.WriteString(w, withSourceLine(s, sourceLine(paragraph.GetParent())))
 	} else {
 		_, err = io.WriteString(w, "</div>")
 	}
 	if err != nil {
-		log.Println("Error:", err)
+		slog.Error("failed to write HTML", "err", err)
 	}
 
 	return ast.GoToNext, true
 }
 
+// renderSmartypants renders text like renderHookText with smart punctuation:
+// "quotes" become curly, -- and --- en and em dashes and ... an ellipsis.
+func renderSmartypants(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
+	var buf bytes.Buffer
+	status, ok := renderHookText(&buf, node)
+	sp := html.NewSmartypantsRenderer(html.Smartypants | html.SmartypantsDashes | html.SmartypantsLatexDashes)
+	sp.Process(w, buf.Bytes())
+	return status, ok
+}
+
+var emojiShortcode = regexp.MustCompile(`(:\S+:)`)
+
 func renderHookText(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
 	block := node.(*ast.Text)
 
-	r := regexp.MustCompile(`(:\S+:)`)
-	withEmoji := r.ReplaceAllStringFunc(string(block.Literal), func(s string) string {
+	withEmoji := emojiShortcode.ReplaceAllStringFunc(string(block.Literal), func(s string) string {
 		val, ok := EmojiMap[s]
 		if !ok {
 			return s
@@ -164,7 +511,7 @@ func renderHookText(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
 	if !ok {
 		_, err := io.WriteString(w, withEmoji)
 		if err != nil {
-			log.Println("Error:", err)
+			slog.Error("failed to write HTML", "err", err)
 		}
 		return ast.GoToNext, true
 	}
@@ -177,7 +524,7 @@ func renderHookText(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
 			if found {
 				_, err := io.WriteString(w, content)
 				if err != nil {
-					log.Println("Error:", err)
+					slog.Error("failed to write HTML", "err", err)
 				}
 				return ast.GoToNext, true
 			}
@@ -191,7 +538,7 @@ func renderHookText(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
 		if found {
 			_, err := io.WriteString(w, content)
 			if err != nil {
-				log.Println("Error:", err)
+				slog.Error("failed to write HTML", "err", err)
 			}
 			return ast.GoToNext, true
 		}
@@ -201,14 +548,15 @@ func renderHookText(w io.Writer, node ast.Node) (ast.WalkStatus, bool) {
 		if found {
 			_, err := io.WriteString(w, content)
 			if err != nil {
-				log.Println("Error:", err)
+				slog.Error("failed to write HTML", "err", err)
 			}
+			return ast.GoToNext, true
 		}
 	}
 
 	_, err := io.WriteString(w, withEmoji)
 	if err != nil {
-		log.Println("Error:", err)
+		slog.Error("failed to write HTML", "err", err)
 	}
 	return ast.GoToNext, true
 }
@@ -233,26 +581,26 @@ func renderHookListItem(w io.Writer, node ast.Node, entering bool) (ast.WalkStat
 	if entering {
 		_, err := io.WriteString(w, "<li class=\"task-list-item\">")
 		if err != nil {
-			log.Println("Error:", err)
+			slog.Error("failed to write HTML", "err", err)
 		}
 	} else {
 		_, err := io.WriteString(w, "</li>")
 		if err != nil {
-			log.Println("Error:", err)
+			slog.Error("failed to write HTML", "err", err)
 		}
 	}
 
 	return ast.GoToNext, true
 }
 
+var (
+	alertTemplates  = template.Must(template.ParseFS(defaults.Templates, "templates/alert/*.html"))
+	mermaidTemplate = template.Must(template.New("mermaid.html").Funcs(assetFuncs).ParseFS(defaults.Templates, "templates/mermaid/mermaid.html"))
+)
+
 func createBlockquoteStart(alert string) (string, error) {
-	lp := path.Join("templates/alert", fmt.Sprintf("%s.html", alert))
-	tmpl, err := template.ParseFS(defaults.Templates, lp)
-	if err != nil {
-		return "", err
-	}
 	var tpl bytes.Buffer
-	if err := tmpl.Execute(&tpl, alert); err != nil {
+	if err := alertTemplates.ExecuteTemplate(&tpl, fmt.Sprintf("%s.html", alert), alert); err != nil {
 		return "", err
 	}
 	return tpl.String(), nil
@@ -268,13 +616,8 @@ func renderMermaid(content string, theme string) (string, error) {
 		Content: content,
 		Theme:   theme,
 	}
-	lp := path.Join("templates/mermaid/mermaid.html")
-	tmpl, err := template.ParseFS(defaults.Templates, lp)
-	if err != nil {
-		return "", err
-	}
 	var tpl bytes.Buffer
-	if err := tmpl.Execute(&tpl, m); err != nil {
+	if err := mermaidTemplate.Execute(&tpl, m); err != nil {
 		return "", err
 	}
 	return tpl.String(), nil
@@ -0,0 +1,731 @@
+package pkg
+
+import (
+	"bytes"
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+func TestMdToHTMLTables(t *testing.T) {
+	tests := []struct {
+		name  string
+		input string
+		want  []string
+	}{
+		{
+			name: "inline formatting",
+			input: "| Command | Description |\n" +
+				"| --- | --- |\n" +
+				"| `git status` | List all *new or modified* files |\n" +
+				"| `git diff` | Show file differences that **haven't been** staged |\n",
+			want: []string{
+				"<td><code>git status</code></td>",
+				"<td>List all <em>new or modified</em> files</td>",
+				"<td>Show file differences that <strong>haven't been</strong> staged</td>",
+			},
+		},
+		{
+			name: "escaped pipes",
+			input: "| Name     | Character |\n" +
+				"| ---      | ---       |\n" +
+				"| Backtick | `         |\n" +
+				"| Pipe     | \\|        |\n",
+			want: []string{
+				"<td>Backtick</td>",
+				"<td>|</td>",
+			},
+		},
+		{
+			name: "code span with escaped pipe",
+			input: "| Operator | Example |\n" +
+				"| --- | --- |\n" +
+				"| or | `a \\|\\| b` |\n",
+			want: []string{
+				"<td><code>a || b</code></td>",
+			},
+		},
+		{
+			name: "math",
+			input: "| Inline | Display |\n" +
+				"| --- | --- |\n" +
+				"| $a^2$ | $$\\sum x$$ |\n",
+			want: []string{
+				`<td><span class="math inline">\(a^2\)</span></td>`,
+				`<td><span class="math display">\[\sum x\]</span></td>`,
+			},
+		},
+		{
+			name: "images and links",
+			input: "| Logo | Link |\n" +
+				"| :---: | ---: |\n" +
+				"| ![logo](logo.png) | [docs](docs/README.md) |\n",
+			want: []string{
+				`<td align="center"><img src="logo.png" alt="logo" /></td>`,
+				`<td align="right"><a href="docs/README.md">docs</a></td>`,
+			},
+		},
+	}
+
+	p := NewParser("auto")
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(p.MdToHTML([]byte(tt.input)))
+			for _, want := range tt.want {
+				if !strings.Contains(got, want) {
+					t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+				}
+			}
+		})
+	}
+}
+
+func TestMdToHTMLWikiLinks(t *testing.T) {
+	root := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(root, "notes"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	for _, name := range []string{"Home.md", "notes/My-Page.md", "notes/Other.md"} {
+		if err := os.WriteFile(filepath.Join(root, name), []byte("# page"), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"page name", "[[My Page]]", `<a href="/notes/My-Page.md">My Page</a>`},
+		{"alias", "[[other|the other page]]", `<a href="/notes/Other.md">the other page</a>`},
+		{"heading", "[[Home#Getting Started|start]]", `<a href="/Home.md#getting-started">start</a>`},
+		{"missing page", "[[New Page]]", `<a class="grip-wikilink-missing" href="/New%20Page.md">New Page</a>`},
+		{"plain link", "[docs](docs.md)", `<a href="docs.md">docs</a>`},
+	}
+
+	p := NewParser("auto", WithWikiLinks(root))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(p.MdToHTML([]byte(tt.input)))
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("output does not contain %q\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+
+	if got := string(NewParser("auto").MdToHTML([]byte("[[My Page]]"))); strings.Contains(got, "<a") {
+		t.Errorf("wiki links are rendered without WithWikiLinks: %s", got)
+	}
+}
+
+func TestMdToHTMLRepoLinks(t *testing.T) {
+	repo := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(repo, ".git"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	docs := filepath.Join(repo, "docs")
+	if err := os.MkdirAll(docs, 0755); err != nil {
+		t.Fatal(err)
+	}
+
+	tests := []struct {
+		name  string
+		input string
+		want  string
+	}{
+		{"relative", "[usage](./usage.md#setup)", `<a href="./usage.md#setup">usage</a>`},
+		{"root anchored", "[guide](/docs/guide/x.md#top)", `<a href="/guide/x.md#top">guide</a>`},
+		{"root anchored outside", "[license](/LICENSE)", `<a class="grip-link-outside" title="Outside of the previewed directory" href="/LICENSE">license</a>`},
+		{"relative outside", "[main](../../main.go)", `<a class="grip-link-outside" title="Outside of the previewed directory" href="../../main.go">main</a>`},
+		{"outside repository", "[other](../../../other-repo/file.md)", `class="grip-link-outside"`},
+		{"image", "![logo](/docs/logo.png)", `<img src="/logo.png" alt="logo" />`},
+		{"external", "[site](https://example.com/x.md)", `<a href="https://example.com/x.md">site</a>`},
+	}
+
+	p := NewParser("auto", WithRepoLinks(docs))
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(p.MdToHTMLFile([]byte(tt.input), "/guide/index.md"))
+			if !strings.Contains(got, tt.want) {
+				t.Errorf("output does not contain %q\ngot:\n%s", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestMdToHTMLHeadingIDs(t *testing.T) {
+	input := "# Getting Started!\n\n## `go-grip` & Friends\n\n## Getting Started\n\n## Custom {#my-id}\n"
+	want := []string{
+		`<h1 id="getting-started">`,
+		`<h2 id="go-grip--friends">`,
+		`<h2 id="getting-started-1">`,
+		`<h2 id="my-id">`,
+	}
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLDetails(t *testing.T) {
+	input := "<details>\n<summary>More</summary>\n\n- *one*\n\n```\n</details>\n```\n\n<details>\n<summary>Nested</summary>\n\n**bold**\n\n</details>\n</details>\n\n<details><summary>Tight</summary>\n*raw*\n</details>\n"
+	want := []string{
+		"<details>\n<summary>More</summary>",
+		"<li><em>one</em></li>",
+		"<code><span class=\"line\"><span class=\"cl\">&lt;/details&gt;",
+		"<p><strong>bold</strong></p>",
+		"*raw*",
+	}
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+	if n := strings.Count(got, "</details>"); n != 3 {
+		t.Errorf("expected 3 closing tags, got %d:\n%s", n, got)
+	}
+}
+
+func TestMdToHTMLMedia(t *testing.T) {
+	input := "![Demo](demo.mp4)\n\nSee [demo.mov](demo.mov).\n\nhttps://example.com/talk.mp3\n\n![Logo](logo.png)\n"
+	want := []string{
+		`<video src="demo.mp4" controls preload="metadata" class="grip-video" title="Demo"></video>`,
+		`See <a href="demo.mov">demo.mov</a>.`,
+		`<audio src="https://example.com/talk.mp3" controls preload="metadata" class="grip-audio"></audio>`,
+		`<img src="logo.png" alt="Logo" />`,
+	}
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLEmbeds(t *testing.T) {
+	input := "https://www.youtube.com/watch?v=dQw4w9WgXcQ&t=42s\n\nhttps://gist.github.com/octocat/6cad326836d38bd3a7ae\n\nSee https://youtu.be/dQw4w9WgXcQ inline.\n"
+
+	if got := string(NewParser("auto").MdToHTML([]byte(input))); strings.Contains(got, "iframe") {
+		t.Errorf("expected no embeds by default, got:\n%s", got)
+	}
+
+	got := string(NewParser("auto", WithEmbeds(true)).MdToHTML([]byte(input)))
+	want := []string{
+		`<iframe src="https://www.youtube-nocookie.com/embed/dQw4w9WgXcQ?start=42"`,
+		`https://gist.github.com/octocat/6cad326836d38bd3a7ae.js`,
+		`sandbox="allow-scripts allow-popups"`,
+		`See <a href="https://youtu.be/dQw4w9WgXcQ">https://youtu.be/dQw4w9WgXcQ</a> inline.`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLLexers(t *testing.T) {
+	input := "```\n#!/bin/bash\necho hi\n```\n\n```\n[1, 2]\n```\n\n```jsonc\n{\"a\": 1}\n```\n\n```tf\nresource \"x\" \"y\" {}\n```\n"
+
+	got := string(NewParser("auto", WithLanguageDetection(false)).MdToHTML([]byte(input)))
+	if strings.Contains(got, `<span class="nb">echo</span>`) {
+		t.Errorf("expected no language detection, got:\n%s", got)
+	}
+
+	got = string(NewParser("auto", WithLexerAliases(map[string]string{"tf": "python"})).MdToHTML([]byte(input)))
+	want := []string{
+		`<span class="nb">echo</span>`,
+		`<span class="p">[</span><span class="mi">1</span>`,
+		`<span class="nt">&#34;a&#34;</span>`,
+		`<span class="n">resource</span>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLSmartypants(t *testing.T) {
+	input := "\"Quoted\" -- it's done... `\"code\"`\n"
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	if strings.Contains(got, "&ldquo;") {
+		t.Errorf("expected straight quotes without smartypants, got:\n%s", got)
+	}
+
+	got = string(NewParser("auto", WithSmartypants(true)).MdToHTML([]byte(input)))
+	want := "<p>&ldquo;Quoted&rdquo; &ndash; it&rsquo;s done&hellip; <code>&quot;code&quot;</code></p>"
+	if !strings.Contains(got, want) {
+		t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+	}
+}
+
+func TestMdToHTMLHardWraps(t *testing.T) {
+	input := "one\ntwo\n"
+
+	if got := string(NewParser("auto").MdToHTML([]byte(input))); !strings.Contains(got, "<p>one\ntwo</p>") {
+		t.Errorf("expected joined lines, got:\n%s", got)
+	}
+	if got := string(NewParser("auto", WithHardWraps(true)).MdToHTML([]byte(input))); !strings.Contains(got, "<p>one<br>\ntwo</p>") {
+		t.Errorf("expected a line break, got:\n%s", got)
+	}
+}
+
+func TestMdToHTMLDefinitionLists(t *testing.T) {
+	input := "Term\n: Definition\n"
+
+	if got := string(NewParser("auto").MdToHTML([]byte(input))); strings.Contains(got, "<dl>") {
+		t.Errorf("expected no definition list by default, got:\n%s", got)
+	}
+	got := string(NewParser("auto", WithDefinitionLists(true)).MdToHTML([]byte(input)))
+	for _, w := range []string{"<dt>Term</dt>", "<dd>Definition</dd>"} {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLAttributes(t *testing.T) {
+	input := "{#intro .lead}\nA paragraph\n\n{.wide}\n# Title\n\n## Usage {#use .big data-x=1}\n\n## Notes {.small}\n"
+	want := []string{
+		`<p id="intro" class="lead">A paragraph</p>`,
+		`<h1 id="title" class="wide">Title</h1>`,
+		`<h2 id="use" class="big" data-x="1">Usage</h2>`,
+		`<h2 id="notes" class="small">Notes</h2>`,
+	}
+
+	got := string(NewParser("auto", WithAttributes(true)).MdToHTML([]byte(input)))
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMdToHTMLAbbreviations(t *testing.T) {
+	input := "The HTML spec, not HTMLX or `HTML`.\n\n```\n*[CODE]: kept\n```\n\n*[HTML]: HyperText Markup Language\n"
+	want := []string{
+		`<p>The <abbr title="HyperText Markup Language">HTML</abbr> spec, not HTMLX or <code>HTML</code>.</p>`,
+		"*[CODE]: kept",
+	}
+
+	got := string(NewParser("auto", WithAbbreviations(true)).MdToHTML([]byte(input)))
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+	if strings.Contains(got, "*[HTML]") {
+		t.Errorf("expected the definition to be removed, got:\n%s", got)
+	}
+}
+
+func TestMdToHTMLAutolinks(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Visit www.commonmark.org/help for more.", `Visit <a href="http://www.commonmark.org/help">www.commonmark.org/help</a> for more.`},
+		{"Visit www.commonmark.org.", `Visit <a href="http://www.commonmark.org">www.commonmark.org</a>.`},
+		{"Visit www.commonmark.org/a.b.", `Visit <a href="http://www.commonmark.org/a.b">www.commonmark.org/a.b</a>.`},
+		{"www.google.com/search?q=Markup+(business)", `<a href="http://www.google.com/search?q=Markup+(business)">www.google.com/search?q=Markup+(business)</a>`},
+		{"www.google.com/search?q=Markup+(business)))", `<a href="http://www.google.com/search?q=Markup+(business)">www.google.com/search?q=Markup+(business)</a>))`},
+		{"(www.google.com/search?q=Markup+(business))", `(<a href="http://www.google.com/search?q=Markup+(business)">www.google.com/search?q=Markup+(business)</a>)`},
+		{"www.google.com/search?q=(business))+ok", `<a href="http://www.google.com/search?q=(business))+ok">www.google.com/search?q=(business))+ok</a>`},
+		{"www.google.com/search?q=commonmark&hl;", `<a href="http://www.google.com/search?q=commonmark">www.google.com/search?q=commonmark</a>&hl;`},
+		{"Invalid www.a_b.c_d and www.", "Invalid www.a_b.c_d and www."},
+		{"(Visit https://encrypted.google.com/search?q=Markup+(business))", `(Visit <a href="https://encrypted.google.com/search?q=Markup+(business)">https://encrypted.google.com/search?q=Markup+(business)</a>)`},
+		{"Mail foo@bar.baz.", `Mail <a href="mailto:foo@bar.baz">foo@bar.baz</a>.`},
+		{"hello@mail+xyz.example isn't valid, but hello+xyz@mail.example is.", `hello@mail+xyz.example isn't valid, but <a href="mailto:hello+xyz@mail.example">hello+xyz@mail.example</a> is.`},
+		{"a.b-c_d@a.b- and a.b-c_d@a.b", `a.b-c_d@a.b- and <a href="mailto:a.b-c_d@a.b">a.b-c_d@a.b</a>`},
+		{"`www.code.com` and [www.linked.com](https://linked.com)", `<code>www.code.com</code> and <a href="https://linked.com">www.linked.com</a>`},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := string(NewParser("auto").MdToHTML([]byte(tt.input)))
+			want := "<p>" + tt.want + "</p>"
+			if !strings.Contains(got, want) {
+				t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+			}
+		})
+	}
+}
+
+func TestMdToHTMLReferences(t *testing.T) {
+	tests := []struct {
+		input string
+		want  string
+	}{
+		{"Fixed in #12.", `Fixed in <a href="https://github.com/acme/web/issues/12">#12</a>.`},
+		{"See GH-3 and foo/bar.js#7", `See <a href="https://github.com/acme/web/issues/3">GH-3</a> and <a href="https://github.com/foo/bar.js/issues/7">foo/bar.js#7</a>`},
+		{"Thanks @octo-cat!", `Thanks <a href="https://github.com/octo-cat">@octo-cat</a>!`},
+		{"Mail a@b.com", `Mail <a href="mailto:a@b.com">a@b.com</a>`},
+		{"a#1, #12a, @org/team and `#9`", "a#1, #12a, @org/team and <code>#9</code>"},
+		{"[#12](#12)", `<a href="#12">#12</a>`},
+		{"Fixed by a5c3785ed8d6a35868bc169f07e40e889087fd2e.", `Fixed by <a href="https://github.com/acme/web/commit/a5c3785ed8d6a35868bc169f07e40e889087fd2e"><code>a5c3785</code></a>.`},
+		{"In e2f1c0a and foo/bar@e2f1c0a9", `In <a href="https://github.com/acme/web/commit/e2f1c0a"><code>e2f1c0a</code></a> and <a href="https://github.com/foo/bar/commit/e2f1c0a9">foo/bar@<code>e2f1c0a</code></a>`},
+		{"1234567 deadbeef e2f1c0 e2f1c0a_x v-e2f1c0a a5c3785ed8d6a35868bc169f07e40e889087fd2e0", "1234567 deadbeef e2f1c0 e2f1c0a_x v-e2f1c0a a5c3785ed8d6a35868bc169f07e40e889087fd2e0"},
+	}
+
+	parser := NewParser("auto", WithReferences("", "acme/web"))
+	for _, tt := range tests {
+		t.Run(tt.input, func(t *testing.T) {
+			got := string(parser.MdToHTML([]byte(tt.input)))
+			want := "<p>" + tt.want + "</p>"
+			if !strings.Contains(got, want) {
+				t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+			}
+		})
+	}
+
+	got := string(NewParser("auto", WithReferences("https://github.example.com/", "acme/web")).MdToHTML([]byte("#1")))
+	if !strings.Contains(got, `href="https://github.example.com/acme/web/issues/1"`) {
+		t.Errorf("expected a link to the GitHub instance, got:\n%s", got)
+	}
+}
+
+func TestOutline(t *testing.T) {
+	input := "Intro\n# One\n## Sub\n```\n# not a heading\n```\nSetext\n------\n# Two\n"
+
+	got := NewParser("auto").Outline([]byte(input))
+	if len(got) != 2 {
+		t.Fatalf("got %d top-level headings, want 2", len(got))
+	}
+	one, two := got[0], got[1]
+	if one.Slug != "one" || one.Offset != 6 || len(one.Children) != 2 {
+		t.Errorf("unexpected first heading %+v", one)
+	}
+	if setext := one.Children[1]; setext.Text != "Setext" || setext.Level != 2 || setext.Offset != strings.Index(input, "Setext") {
+		t.Errorf("unexpected setext heading %+v", setext)
+	}
+	if two.Slug != "two" || two.Offset != strings.Index(input, "# Two") {
+		t.Errorf("unexpected second heading %+v", two)
+	}
+}
+
+func TestLongFences(t *testing.T) {
+	input := "````md\n```\n# not a title\n```\n# not a heading\n````\n# Title\n"
+
+	if got := extractTitle([]byte(input), "file.md"); got != "Title" {
+		t.Errorf("extractTitle = %q, want Title", got)
+	}
+	if got := headingOffsets([]byte(input)); len(got) != 1 || got[0] != strings.Index(input, "# Title") {
+		t.Errorf("headingOffsets = %v, want only the title", got)
+	}
+	if got := splitSections([]byte(input), 1); len(got) != 2 || got[1].title != "Title" {
+		t.Errorf("splitSections = %+v, want the title section", got)
+	}
+}
+
+func TestParserHooks(t *testing.T) {
+	p := NewParser("auto",
+		WithSourceTransform(func(md []byte) []byte {
+			return bytes.ReplaceAll(md, []byte("{{version}}"), []byte("v1.2.3"))
+		}),
+		WithASTVisitor(func(doc ast.Node) {
+			ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+				if link, ok := node.(*ast.Link); ok && entering {
+					link.Destination = bytes.Replace(link.Destination, []byte("jira:"), []byte("https://jira.example.com/browse/"), 1)
+				}
+				return ast.GoToNext
+			})
+		}),
+	)
+	p.Use(WithHTMLFilter(func(html []byte) []byte {
+		return bytes.ReplaceAll(html, []byte("<table>"), []byte(`<table class="custom">`))
+	}))
+
+	got := string(p.MdToHTML([]byte("Release {{version}}, see [GRIP-1](jira:GRIP-1)\n\n| a |\n| - |\n| b |\n")))
+	for _, want := range []string{
+		"Release v1.2.3",
+		`<a href="https://jira.example.com/browse/GRIP-1">GRIP-1</a>`,
+		`<table class="custom">`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+		}
+	}
+}
+
+func TestDiffHTML(t *testing.T) {
+	old := "# Title\n\nkept\n\nchanged\n\nremoved\n"
+	new := "# Title\n\nkept\n\nchanged!\n\nadded\n"
+
+	got := string(NewParser("auto").DiffHTML([]byte(old), []byte(new)))
+	for _, want := range []string{
+		"<p>kept</p>\n<div class=\"grip-diff-removed\">",
+		`<div class="grip-diff-removed"><p>changed</p>`,
+		`<div class="grip-diff-added"><p>changed!</p>`,
+		`<div class="grip-diff-removed"><p>removed</p>`,
+		`<div class="grip-diff-added"><p>added</p>`,
+	} {
+		if !strings.Contains(got, want) {
+			t.Errorf("output does not contain %q\ngot:\n%s", want, got)
+		}
+	}
+	if strings.Contains(got, `grip-diff-added"><h1`) || strings.Contains(got, `grip-diff-removed"><h1`) {
+		t.Errorf("unchanged heading is marked as changed:\n%s", got)
+	}
+}
+
+func TestCountWords(t *testing.T) {
+	input := "# Two words\n\nOne *emphasized*\nline, `code` too.\n\n```\nnot counted\n```\n\n| a | b |\n|---|---|\n| c | d |\n"
+	if got := NewParser("auto").countWords([]byte(input)); got != 11 {
+		t.Errorf("expected 11 words, got %d", got)
+	}
+}
+
+func TestProseLint(t *testing.T) {
+	dir := t.TempDir()
+	files := map[string]string{
+		"doc.md":          "# Setup\n\nInstall teh tool, then\nthen run `teh` and\n[https://teh.example](https://teh.example).\n\nGrip and grip are fine.\n",
+		ProjectDictionary: "# words of the project\nGrip\n",
+	}
+	for name, content := range files {
+		if err := os.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	// grep stands in for a spell checker knowing neither word
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"),
+		WithProseLint(&ProseLint{SpellChecker: "grep -o -w -e teh -e Grip -e grip"}))
+	report, err := s.CheckLinks(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var got []string
+	for _, issue := range report.Prose {
+		got = append(got, issue.String())
+	}
+	want := []string{
+		`doc.md:3: possible spelling mistake "teh"`,
+		`doc.md:3: repeated word "then\nthen"`,
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got issues\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+}
+
+func TestMdToHTMLCodeTitle(t *testing.T) {
+	input := "```go title=\"cmd/main file.go\"\npackage main\n```\n\n```js:app.js\nlet a\n```\n"
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	want := []string{
+		`<div class="grip-code"><div class="grip-code-title"><span>cmd/main file.go</span>`,
+		`<span>app.js</span>`,
+		`<span class="kn">package</span>`,
+		`<span class="kd">let</span>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+}
+
+func TestMDXToMarkdown(t *testing.T) {
+	mdx := `import { Tabs, Tab } from "nextra/components"
+export const meta = {
+  title: "Guide",
+}
+
+# Guide
+
+{/* a comment */}
+<Tabs items={["npm", "yarn"]}>
+<Tab>
+
+Install with **npm**.
+
+</Tab>
+</Tabs>
+
+<Callout
+  type="warning"
+  emoji="⚠️" />
+
+` + "```jsx\nimport React from \"react\"\n<Button />\n```\n"
+	want := "\n\n\n\n\n# Guide\n\n\n\n\n\nInstall with **npm**.\n\n\n\n\n" +
+		"\n\n<span class=\"grip-mdx-component\" title=\"MDX component\">&lt;Callout /&gt;</span>\n\n" +
+		"```jsx\nimport React from \"react\"\n<Button />\n```\n"
+	got := string(MDXToMarkdown([]byte(mdx)))
+	if got != want {
+		t.Errorf("MDXToMarkdown() =\n%q\nwant\n%q", got, want)
+	}
+	if strings.Count(got, "\n") != strings.Count(mdx, "\n") {
+		t.Error("line numbers changed")
+	}
+}
+
+func TestEncodingDecode(t *testing.T) {
+	utf16le := []byte{0xff, 0xfe, '#', 0, ' ', 0, 0xe9, 0, 't', 0, 0xe9, 0}
+	utf16be := []byte{0, '#', 0, ' ', 0, 0xe9, 0, 't', 0, 0xe9}
+	for _, tt := range []struct {
+		content  []byte
+		encoding Encoding
+		want     string
+	}{
+		{[]byte("\xef\xbb\xbf# été"), EncodingAuto, "# été"},
+		{[]byte("# \xe9t\xe9"), EncodingAuto, "# été"},
+		{[]byte("\x93quoted\x94 \x80"), EncodingAuto, "“quoted” €"},
+		{[]byte("\xc3\xa9t\xc3\xa9 \x93"), EncodingAuto, "été \uFFFD"},
+		{[]byte("\x93quoted\x94"), EncodingLatin1, "\u0093quoted\u0094"},
+		{utf16le, EncodingAuto, "# été"},
+		{utf16be, EncodingAuto, "# été"},
+		{utf16be, EncodingUTF16BE, "# été"},
+	} {
+		if got := string(tt.encoding.Decode(tt.content)); got != tt.want {
+			t.Errorf("%s.Decode(%q) = %q, want %q", tt.encoding, tt.content, got, tt.want)
+		}
+	}
+}
+
+func TestTimedRenderer(t *testing.T) {
+	s := NewServer(nil, 0, "light", true, false, NewParser("light"))
+	stats := &renderStats{}
+	render, _ := s.renderer("a.md", nil)
+	if _, _, err := s.timedRenderer(context.Background(), render, "a.md", stats)([]byte("# A\n\n```go\nfunc main() {}\n```\n"), "a.md"); err != nil {
+		t.Fatal(err)
+	}
+	if stats.parse <= 0 || stats.highlight <= 0 {
+		t.Errorf("expected parse and highlight timings, got %+v", stats)
+	}
+
+	// markdown rendered by a renderer of WithRenderer is timed as a whole
+	s = NewServer(nil, 0, "light", true, false, NewParser("light"), WithRenderer(".md", func(content []byte, name string) ([]byte, string, error) {
+		return content, "", nil
+	}))
+	stats = &renderStats{}
+	render, _ = s.renderer("a.md", nil)
+	if out, _, err := s.timedRenderer(context.Background(), render, "a.md", stats)([]byte("# A"), "a.md"); err != nil || string(out) != "# A" {
+		t.Fatalf("expected the custom renderer, got %q: %v", out, err)
+	}
+	if *stats != (renderStats{}) {
+		t.Errorf("expected no timings for custom renderers, got %+v", stats)
+	}
+}
+
+func TestMdToHTMLFootnotes(t *testing.T) {
+	input := "One[^a], two[^b] and one again[^a].\n\n[^a]: First.\n[^b]: Second.\n"
+
+	got := string(NewParser("auto").MdToHTML([]byte(input)))
+	want := []string{
+		`<sup><a href="#fn-a" id="fnref-a" data-footnote-ref aria-describedby="footnote-label">1</a></sup>`,
+		`<a href="#fn-a" id="fnref-a-2" data-footnote-ref aria-describedby="footnote-label">1</a>`,
+		`<section data-footnotes class="footnotes"><h2 class="sr-only" id="footnote-label">Footnotes</h2>`,
+		`<li id="fn-a">First. <a href="#fnref-a" data-footnote-backref class="data-footnote-backref" aria-label="Back to reference a">↩</a>`,
+		`<a href="#fnref-a-2" data-footnote-backref class="data-footnote-backref" aria-label="Back to reference a-2">↩<sup>2</sup></a>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+
+	got = string(NewParser("auto", WithFootnoteStyle(FootnoteSymbols, "^")).MdToHTML([]byte(input)))
+	want = []string{
+		`class="grip-footnote-symbol" data-footnote-ref aria-describedby="footnote-label">*</a>`,
+		`data-footnote-ref aria-describedby="footnote-label">†</a>`,
+		`<ol class="grip-footnote-symbols">`,
+		`aria-label="Back to reference b">^</a>`,
+	}
+	for _, w := range want {
+		if !strings.Contains(got, w) {
+			t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+		}
+	}
+	if m := (Parser{footnoteMarkers: FootnoteSymbols}).footnoteMarker(8); m != "††" {
+		t.Errorf("footnoteMarker(8) = %q, want ††", m)
+	}
+}
+
+func TestMdToHTMLCitations(t *testing.T) {
+	dir := t.TempDir()
+	bib := filepath.Join(dir, "refs.bib")
+	err := os.WriteFile(bib, []byte(`@string{jot = "Journal of Things"}
+@article{doe2020,
+  author = {Doe, John and Roe, Richard A.},
+  title = {On M\"uller--Things},
+  journal = {Journal of Things},
+  year = 2020, volume = {12}, number = {3}, pages = {45--67},
+  doi = {10.1000/xyz}
+}
+@book{smith,
+  author = "Jane Smith and Bob Brown and {ACME Corp}",
+  title = {A Book},
+  publisher = {Pub \& Co},
+}
+`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	csl := filepath.Join(dir, "refs.json")
+	err = os.WriteFile(csl, []byte(`[
+  {"id": "doe2020", "type": "article-journal", "title": "On Müller–Things", "container-title": "Journal of Things",
+   "author": [{"family": "Doe", "given": "John"}, {"family": "Roe", "given": "Richard A."}],
+   "issued": {"date-parts": [[2020, 5]]}, "volume": 12, "issue": "3", "page": "45-67", "DOI": "10.1000/xyz"},
+  {"id": "smith", "type": "book", "title": "A Book", "publisher": "Pub & Co",
+   "author": [{"family": "Smith", "given": "Jane"}, {"family": "Brown", "given": "Bob"}, {"literal": "ACME Corp"}]}
+]`), 0644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	input := "See [@smith], [see @doe2020, p. 3; @smith] and [@missing]. Mail [me@example.com].\n\nNote[^1].\n\n[^1]: A note.\n"
+
+	tests := []struct {
+		name  string
+		file  string
+		style string
+		want  []string
+	}{
+		{
+			name:  "numeric bibtex",
+			file:  bib,
+			style: CitationNumeric,
+			want: []string{
+				`See [<a href="#ref-smith" class="grip-citation">1</a>], [see <a href="#ref-doe2020" class="grip-citation">2</a>, p. 3; <a href="#ref-smith" class="grip-citation">1</a>]`,
+				`<span class="grip-citation-missing" title="Unknown citation: missing">[@missing]</span>`,
+				`[<a href="mailto:me@example.com">me@example.com</a>]`,
+				"<ol class=\"grip-references\">\n<li id=\"ref-smith\">",
+				`Smith, J., Brown, B., &amp; ACME Corp (n.d.). <em>A Book</em>. Pub &amp; Co.</li>`,
+				`Doe, J., &amp; Roe, R. A. (2020). On Müller–Things. <em>Journal of Things</em>, 12(3), 45–67. <a href="https://doi.org/10.1000/xyz">`,
+			},
+		},
+		{
+			name:  "author-date csl-json",
+			file:  csl,
+			style: CitationAuthorDate,
+			want: []string{
+				`See (<a href="#ref-smith" class="grip-citation">Smith et al. n.d.</a>), (see <a href="#ref-doe2020" class="grip-citation">Doe and Roe 2020</a>, p. 3; `,
+				"<ul class=\"grip-references\">\n<li id=\"ref-doe2020\">",
+				`Doe, J., &amp; Roe, R. A. (2020). On Müller–Things. <em>Journal of Things</em>, 12(3), 45–67.`,
+			},
+		},
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := string(NewParser("auto", WithBibliography(tt.file, tt.style)).MdToHTML([]byte(input)))
+			for _, w := range tt.want {
+				if !strings.Contains(got, w) {
+					t.Errorf("output does not contain %q\ngot:\n%s", w, got)
+				}
+			}
+			// the references go before the footnotes
+			if strings.Index(got, `id="references"`) > strings.Index(got, "data-footnotes") {
+				t.Errorf("references are not before the footnotes\ngot:\n%s", got)
+			}
+		})
+	}
+}
@@ -0,0 +1,28 @@
+package pkg
+
+import (
+	"net/url"
+	"path/filepath"
+	"strings"
+)
+
+// fileURL converts an absolute file system path into a file:// URL. Windows
+// drive letters become file:///C:/... and UNC shares file://server/share/...
+func fileURL(p string) string {
+	p = filepath.ToSlash(p)
+	if strings.HasPrefix(p, "//") {
+		host, rest, _ := strings.Cut(p[2:], "/")
+		return (&url.URL{Scheme: "file", Host: host, Path: "/" + rest}).String()
+	}
+	if !strings.HasPrefix(p, "/") {
+		p = "/" + p
+	}
+	return (&url.URL{Scheme: "file", Path: p}).String()
+}
+
+// splitFile splits a file argument into its directory and the URL path of
+// the file relative to that directory, using the path rules of the current OS.
+func splitFile(file string) (string, string) {
+	file = filepath.Clean(file)
+	return filepath.Dir(file), filepath.ToSlash(filepath.Base(file))
+}
@@ -0,0 +1,37 @@
+package pkg
+
+import "testing"
+
+func TestFileURL(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{"/tmp/out/index.html", "file:///tmp/out/index.html"},
+		{"/tmp/my docs/index.html", "file:///tmp/my%20docs/index.html"},
+	}
+	for _, tt := range tests {
+		if got := fileURL(tt.path); got != tt.want {
+			t.Errorf("fileURL(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplitFile(t *testing.T) {
+	tests := []struct {
+		file     string
+		wantDir  string
+		wantName string
+	}{
+		{"README.md", ".", "README.md"},
+		{"docs/guide.md", "docs", "guide.md"},
+		{"./docs/../README.md", ".", "README.md"},
+		{"/srv/docs/README.md", "/srv/docs", "README.md"},
+	}
+	for _, tt := range tests {
+		dir, name := splitFile(tt.file)
+		if dir != tt.wantDir || name != tt.wantName {
+			t.Errorf("splitFile(%q) = %q, %q, want %q, %q", tt.file, dir, name, tt.wantDir, tt.wantName)
+		}
+	}
+}
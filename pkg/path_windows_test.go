@@ -0,0 +1,51 @@
+package pkg
+
+import (
+	"net/http"
+	"testing"
+)
+
+func TestFileURLWindows(t *testing.T) {
+	tests := []struct {
+		path string
+		want string
+	}{
+		{`C:\Users\me\out\index.html`, "file:///C:/Users/me/out/index.html"},
+		{`D:\my docs\index.html`, "file:///D:/my%20docs/index.html"},
+		{`\\server\share\docs\index.html`, "file://server/share/docs/index.html"},
+	}
+	for _, tt := range tests {
+		if got := fileURL(tt.path); got != tt.want {
+			t.Errorf("fileURL(%q) = %q, want %q", tt.path, got, tt.want)
+		}
+	}
+}
+
+func TestSplitFileWindows(t *testing.T) {
+	tests := []struct {
+		file     string
+		wantDir  string
+		wantName string
+	}{
+		{`docs\guide.md`, "docs", "guide.md"},
+		{`C:\repo\README.md`, `C:\repo`, "README.md"},
+		{`C:/repo/docs/README.md`, `C:\repo\docs`, "README.md"},
+		{`\\server\share\docs\README.md`, `\\server\share\docs`, "README.md"},
+		{`\\server\share\README.md`, `\\server\share\`, "README.md"},
+	}
+	for _, tt := range tests {
+		dir, name := splitFile(tt.file)
+		if dir != tt.wantDir || name != tt.wantName {
+			t.Errorf("splitFile(%q) = %q, %q, want %q, %q", tt.file, dir, name, tt.wantDir, tt.wantName)
+		}
+	}
+}
+
+func TestRootFSRejectsWindowsSeparators(t *testing.T) {
+	fsys := rootFS{root: http.Dir(t.TempDir())}
+	for _, name := range []string{`/..\..\Windows\win.ini`, `/C:/Windows/win.ini`, `/docs\..\..\secret`} {
+		if fsys.allowed(name) {
+			t.Errorf("allowed(%q) = true, want false", name)
+		}
+	}
+}
@@ -0,0 +1,118 @@
+package pkg
+
+import (
+	"fmt"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime"
+	"strings"
+)
+
+// chromeNames are the executables searched in PATH for headless printing.
+var chromeNames = []string{
+	"chromium", "chromium-browser", "google-chrome", "google-chrome-stable", "chrome", "microsoft-edge", "msedge",
+}
+
+// findChrome returns the path of a Chromium based browser.
+func findChrome() (string, error) {
+	for _, name := range chromeNames {
+		if p, err := exec.LookPath(name); err == nil {
+			return p, nil
+		}
+	}
+
+	var candidates []string
+	switch runtime.GOOS {
+	case "darwin":
+		candidates = []string{
+			"/Applications/Google Chrome.app/Contents/MacOS/Google Chrome",
+			"/Applications/Chromium.app/Contents/MacOS/Chromium",
+			"/Applications/Microsoft Edge.app/Contents/MacOS/Microsoft Edge",
+		}
+	case "windows":
+		for _, env := range []string{"ProgramFiles", "ProgramFiles(x86)", "LocalAppData"} {
+			if dir := os.Getenv(env); dir != "" {
+				candidates = append(candidates,
+					filepath.Join(dir, "Google", "Chrome", "Application", "chrome.exe"),
+					filepath.Join(dir, "Microsoft", "Edge", "Application", "msedge.exe"))
+			}
+		}
+	}
+	for _, p := range candidates {
+		if _, err := os.Stat(p); err == nil {
+			return p, nil
+		}
+	}
+
+	return "", fmt.Errorf("no Chromium based browser found, install Chromium or pass its path with --chrome")
+}
+
+// GeneratePDF renders a markdown file and prints it to outputPath with a
+// headless Chromium. chrome is the browser executable, searched for if empty.
+func (s *Server) GeneratePDF(filePath string, outputPath string, chrome string) error {
+	if chrome == "" {
+		var err error
+		chrome, err = findChrome()
+		if err != nil {
+			return err
+		}
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %v", filePath, err)
+	}
+	content, _ = s.embedFiles(http.Dir(filepath.Dir(filePath)), filepath.Base(filePath), content)
+
+	absOutputPath, err := filepath.Abs(outputPath)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	tmpDir, err := os.MkdirTemp("", "go-grip-pdf")
+	if err != nil {
+		return fmt.Errorf("failed to create temporary directory: %v", err)
+	}
+	defer os.RemoveAll(tmpDir)
+
+	staticDir := filepath.Join(tmpDir, "static")
+	if err := os.MkdirAll(staticDir, 0755); err != nil {
+		return fmt.Errorf("failed to create static directory: %v", err)
+	}
+	if err := copyStaticFiles(staticDir); err != nil {
+		return fmt.Errorf("failed to copy static files: %v", err)
+	}
+
+	htmlPath := filepath.Join(tmpDir, "index.html")
+	html := s.htmlPage(string(s.parser.MdToHTML(content)), extractTitle(content, filepath.Base(filePath)))
+	// there is nothing to click on paper
+	html.Lightbox = false
+	if err := s.writeHTMLFile(htmlPath, html); err != nil {
+		return fmt.Errorf("failed to write HTML file: %v", err)
+	}
+
+	args := []string{
+		"--headless",
+		"--disable-gpu",
+		"--no-pdf-header-footer",
+		// give scripts like mermaid time to render before printing
+		"--virtual-time-budget=10000",
+		"--print-to-pdf=" + absOutputPath,
+	}
+	if runtime.GOOS == "linux" && os.Geteuid() == 0 {
+		// chromium refuses to run as root with its sandbox enabled
+		args = append(args, "--no-sandbox")
+	}
+	args = append(args, fileURL(htmlPath))
+
+	out, err := exec.Command(chrome, args...).CombinedOutput()
+	if err != nil {
+		return fmt.Errorf("failed to print PDF with %s: %v: %s", chrome, err, strings.TrimSpace(string(out)))
+	}
+
+	slog.Info("generated PDF file", "path", absOutputPath)
+	return nil
+}
@@ -0,0 +1,127 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/flate"
+	"context"
+	"encoding/base64"
+	"fmt"
+	"io"
+	"net/http"
+	"os/exec"
+	"strings"
+	"time"
+)
+
+const plantumlTimeout = 30 * time.Second
+
+// plantuml renders PlantUML diagrams to SVG with a PlantUML server or a local
+// plantuml.jar.
+type plantuml struct {
+	server string
+	jar    string
+}
+
+// WithPlantUML renders ```plantuml code blocks using the PlantUML server at
+// serverURL, e.g. https://www.plantuml.com/plantuml, or if it is empty by
+// running the plantuml.jar at jar with java. Diagrams are cached, see
+// WithDiagramCache.
+func WithPlantUML(serverURL string, jar string) ParserOption {
+	return func(p *Parser) {
+		if serverURL == "" && jar == "" {
+			p.plantuml = nil
+			return
+		}
+		p.plantuml = &plantuml{
+			server: strings.TrimSuffix(serverURL, "/"),
+			jar:    jar,
+		}
+	}
+}
+
+// render returns the SVG of a diagram, from cache if possible. The request
+// or java is stopped when ctx is done.
+func (p *plantuml) render(ctx context.Context, source string, cache *diagramCache) ([]byte, error) {
+	if p.server != "" {
+		return cache.render("plantuml:"+p.server, source, func() ([]byte, error) {
+			return p.renderServer(ctx, source)
+		})
+	}
+	return cache.render("plantuml:jar:"+p.jar, source, func() ([]byte, error) {
+		return p.renderJar(ctx, source)
+	})
+}
+
+func (p *plantuml) renderServer(ctx context.Context, source string) ([]byte, error) {
+	encoded, err := plantumlEncode(source)
+	if err != nil {
+		return nil, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, p.server+"/svg/"+encoded, nil)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render plantuml diagram: %v", err)
+	}
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render plantuml diagram: %v", err)
+	}
+	defer resp.Body.Close()
+
+	svg, err := io.ReadAll(resp.Body)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render plantuml diagram: %v", err)
+	}
+	// the server answers syntax errors with 400 and an SVG showing the error
+	if resp.StatusCode != http.StatusOK && resp.StatusCode != http.StatusBadRequest {
+		return nil, fmt.Errorf("failed to render plantuml diagram: %s", resp.Status)
+	}
+	return svg, nil
+}
+
+func (p *plantuml) renderJar(ctx context.Context, source string) ([]byte, error) {
+	ctx, cancel := context.WithTimeout(ctx, plantumlTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, "java", "-Djava.awt.headless=true", "-jar", p.jar, "-tsvg", "-pipe")
+	cmd.Stdin = strings.NewReader(source)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	// plantuml exits with an error for syntax errors, but still prints an SVG
+	// showing it, unlike when it is killed
+	if err := cmd.Run(); err != nil && (stdout.Len() == 0 || ctx.Err() != nil) {
+		return nil, fmt.Errorf("failed to render plantuml diagram: %v: %s", err, strings.TrimSpace(stderr.String()))
+	}
+	return stdout.Bytes(), nil
+}
+
+// plantumlEncoding is the base64 variant of PlantUML's text encoding.
+var plantumlEncoding = base64.NewEncoding("0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz-_")
+
+// plantumlEncode encodes diagram source for PlantUML server URLs, see
+// https://plantuml.com/text-encoding.
+func plantumlEncode(source string) (string, error) {
+	var buf bytes.Buffer
+	w, err := flate.NewWriter(&buf, flate.BestCompression)
+	if err != nil {
+		return "", err
+	}
+	if _, err := w.Write([]byte(source)); err != nil {
+		return "", err
+	}
+	if err := w.Close(); err != nil {
+		return "", err
+	}
+	// PlantUML fills the last group with zero bits instead of padding it
+	return strings.ReplaceAll(plantumlEncoding.EncodeToString(buf.Bytes()), "=", "0"), nil
+}
+
+// renderHTML renders a diagram as an image.
+func (p *plantuml) renderHTML(ctx context.Context, source string, cache *diagramCache) (string, error) {
+	svg, err := p.render(ctx, source, cache)
+	if err != nil {
+		return "", err
+	}
+	return `<div class="grip-plantuml"><img alt="PlantUML diagram" src="data:image/svg+xml;base64,` +
+		base64.StdEncoding.EncodeToString(svg) + `"></div>`, nil
+}
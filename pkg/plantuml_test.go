@@ -0,0 +1,45 @@
+package pkg
+
+import (
+	"bytes"
+	"compress/flate"
+	"encoding/base64"
+	"io"
+	"strings"
+	"testing"
+)
+
+func TestPlantumlEncode(t *testing.T) {
+	decode := func(encoded string) string {
+		t.Helper()
+		data, err := plantumlEncoding.WithPadding(base64.NoPadding).DecodeString(encoded)
+		if err != nil {
+			t.Fatalf("decode %q: %v", encoded, err)
+		}
+		source, err := io.ReadAll(flate.NewReader(bytes.NewReader(data)))
+		if err != nil {
+			t.Fatalf("inflate %q: %v", encoded, err)
+		}
+		return string(source)
+	}
+
+	// the example of https://plantuml.com/text-encoding, as the server
+	// encodes it
+	const source = "Bob -> Alice : hello"
+	if got := decode("SyfFKj2rKt3CoKnELR1Io4ZDoSa70000"); got != source {
+		t.Fatalf("got %q from the server encoding, want %q", got, source)
+	}
+
+	// deflate output differs between implementations, so the encoding
+	// must decode like the server's does
+	encoded, err := plantumlEncode(source)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(encoded)%4 != 0 || strings.ContainsAny(encoded, "=+/") {
+		t.Errorf("got %q, want groups of four characters of the PlantUML alphabet", encoded)
+	}
+	if got := decode(encoded); got != source {
+		t.Errorf("got %q from %q, want %q", got, encoded, source)
+	}
+}
@@ -0,0 +1,106 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+)
+
+// DefaultMaxPreviewSize is the size of the largest file rendered as a page.
+const DefaultMaxPreviewSize = 10 << 20
+
+// sniffLen is the number of bytes checked for NUL bytes to tell binary files
+// from text, like git does.
+const sniffLen = 8000
+
+// selfLoadingFormats are the extensions of formats whose renderer loads the
+// file itself from its ?raw=1 URL, so the server never reads them.
+var selfLoadingFormats = map[string]bool{
+	".stl": true,
+}
+
+// WithMaxPreviewSize sets the size in bytes of the largest file rendered as a
+// page. Larger files, like binary ones, get a page linking the raw file
+// instead. 0 disables the limit.
+func WithMaxPreviewSize(n int64) Option {
+	return func(s *Server) {
+		s.maxPreviewSize = n
+	}
+}
+
+// previewError returns why the file f can't be rendered, or "" if it can,
+// with the status answering the request: 413 for files that are too large
+// and 415 for binary ones. The file is left at its start.
+func (s *Server) previewError(name string, f http.File) (string, int, error) {
+	if selfLoadingFormats[strings.ToLower(path.Ext(name))] {
+		return "", 0, nil
+	}
+	info, err := f.Stat()
+	if err != nil {
+		return "", 0, err
+	}
+	if s.maxPreviewSize > 0 && info.Size() > s.maxPreviewSize {
+		return s.msg("This file is too large to preview (%s).", formatSize(info.Size())), http.StatusRequestEntityTooLarge, nil
+	}
+
+	head, err := io.ReadAll(io.LimitReader(f, sniffLen))
+	if err != nil {
+		return "", 0, err
+	}
+	if _, err := f.Seek(0, io.SeekStart); err != nil {
+		return "", 0, err
+	}
+	if s.isBinary(head) {
+		return s.msg("This file is binary and can't be previewed."), http.StatusUnsupportedMediaType, nil
+	}
+	return "", 0, nil
+}
+
+// isBinary reports whether the start of a file contains NUL bytes that are
+// not part of UTF-16 text.
+func (s *Server) isBinary(head []byte) bool {
+	if bytes.IndexByte(head, 0) < 0 {
+		return false
+	}
+	enc := s.encoding
+	if enc == "" || enc == EncodingAuto {
+		enc = detectEncoding(head)
+	}
+	return enc != EncodingUTF16LE && enc != EncodingUTF16BE
+}
+
+// servePreviewError answers the request for a file that can't be rendered
+// with status and a page explaining why and linking the raw file, like
+// GitHub does.
+func (s *Server) servePreviewError(w http.ResponseWriter, name string, reason string, status int) {
+	raw := (&url.URL{Path: name, RawQuery: "raw=1"}).String()
+	content := `<div class="grip-preview-error"><p>` + html.EscapeString(reason) + `</p>` +
+		`<p><a href="` + html.EscapeString(raw) + `">` + html.EscapeString(s.msg("View raw")) + `</a></p></div>` + "\n"
+	page, err := s.layoutPage([]byte(content), path.Base(name), pageNav{})
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.WriteHeader(status)
+	page.WriteTo(w)
+}
+
+// formatSize formats a size in bytes for people.
+func formatSize(n int64) string {
+	const unit = 1024
+	if n < unit {
+		return fmt.Sprintf("%d B", n)
+	}
+	div, exp := int64(unit), 0
+	for m := n / unit; m >= unit; m /= unit {
+		div *= unit
+		exp++
+	}
+	return fmt.Sprintf("%.1f %cB", float64(n)/float64(div), "KMGTPE"[exp])
+}
@@ -0,0 +1,252 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"os"
+	"os/exec"
+	"path"
+	"path/filepath"
+	"regexp"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const spellCheckerTimeout = 30 * time.Second
+
+// DefaultSpellChecker is the command listing misspelled words used by the
+// prose linter.
+const DefaultSpellChecker = "aspell list"
+
+// ProjectDictionary is the file of words the prose linter accepts in the
+// root of a previewed or checked directory.
+const ProjectDictionary = ".grip-words"
+
+// ProseLint configures the prose linter of WithProseLint. Besides spelling
+// it finds repeated words, like "the the".
+type ProseLint struct {
+	// SpellChecker is the command printing the misspelled words of the text
+	// on its standard input one per line, like "aspell list" or
+	// "hunspell -l -d en_US". Empty to only find repeated words.
+	SpellChecker string
+	// Dictionaries are files of words accepted in addition to the
+	// ProjectDictionary, one per line. Lines starting with # are comments.
+	Dictionaries []string
+}
+
+// WithProseLint underlines possible spelling mistakes and repeated words of
+// markdown pages in the preview, with the issue as tooltip. The issues are
+// served at /api/prose?file=<path>.md. A nil lint turns it off.
+func WithProseLint(lint *ProseLint) Option {
+	return func(s *Server) {
+		s.prose = lint
+	}
+}
+
+// ProseIssue is a possible mistake in the text of a markdown file.
+type ProseIssue struct {
+	// File is the slash separated path of the markdown file in the checked
+	// directory.
+	File string `json:"-"`
+	// Line is the line of the issue in the file, 0 if it is unknown.
+	Line int `json:"line"`
+	// Kind is "spelling" or "repetition".
+	Kind string `json:"kind"`
+	// Text is the misspelled or repeated words.
+	Text string `json:"text"`
+}
+
+func (p ProseIssue) String() string {
+	loc := p.File
+	if p.Line > 0 {
+		loc = fmt.Sprintf("%s:%d", p.File, p.Line)
+	}
+	if p.Kind == "repetition" {
+		return fmt.Sprintf("%s: repeated word %q", loc, p.Text)
+	}
+	return fmt.Sprintf("%s: possible spelling mistake %q", loc, p.Text)
+}
+
+// proseWord matches the words of prose, with apostrophes inside them.
+var proseWord = regexp.MustCompile(`\p{L}+(?:['’]\p{L}+)*`)
+
+// dictionary returns the lowercase words of the ProjectDictionary of dir, if
+// there is one, and of the Dictionaries.
+func (l *ProseLint) dictionary(dir string) (map[string]bool, error) {
+	words := make(map[string]bool)
+	files := append([]string{filepath.Join(dir, ProjectDictionary)}, l.Dictionaries...)
+	for i, file := range files {
+		content, err := os.ReadFile(file)
+		if i == 0 && errors.Is(err, fs.ErrNotExist) {
+			continue
+		}
+		if err != nil {
+			return nil, fmt.Errorf("failed to read dictionary: %v", err)
+		}
+		for _, line := range strings.Split(string(content), "\n") {
+			line = strings.TrimSpace(line)
+			if line != "" && !strings.HasPrefix(line, "#") {
+				words[strings.ToLower(line)] = true
+			}
+		}
+	}
+	return words, nil
+}
+
+// lint returns the issues of the document doc parsed from the markdown
+// content, ignoring the words of dictionary. Code, HTML and math are not
+// prose and left alone.
+func (l *ProseLint) lint(doc ast.Node, content []byte, dictionary map[string]bool) ([]ProseIssue, error) {
+	blocks := proseBlocks(doc)
+	misspelled, err := l.spellCheck(strings.Join(blocks, "\n"))
+	if err != nil {
+		return nil, err
+	}
+
+	var issues []ProseIssue
+	offset := 0
+	add := func(kind string, text string) {
+		// the AST has no positions, so search the text in the source
+		line := 0
+		if i := strings.Index(string(content[offset:]), text); i >= 0 {
+			offset += i
+			line = bytes.Count(content[:offset], []byte("\n")) + 1
+		}
+		issues = append(issues, ProseIssue{Line: line, Kind: kind, Text: text})
+	}
+	for _, block := range blocks {
+		matches := proseWord.FindAllStringIndex(block, -1)
+		for i, m := range matches {
+			word := block[m[0]:m[1]]
+			if misspelled[word] && !dictionary[strings.ToLower(word)] {
+				add("spelling", word)
+			}
+			if i == 0 {
+				continue
+			}
+			prev := matches[i-1]
+			if strings.EqualFold(block[prev[0]:prev[1]], word) && strings.TrimSpace(block[prev[1]:m[0]]) == "" {
+				add("repetition", block[prev[0]:m[1]])
+			}
+		}
+	}
+	return issues, nil
+}
+
+// spellCheck returns the words of text the SpellChecker reports.
+func (l *ProseLint) spellCheck(text string) (map[string]bool, error) {
+	misspelled := make(map[string]bool)
+	args := strings.Fields(l.SpellChecker)
+	if len(args) == 0 || strings.TrimSpace(text) == "" {
+		return misspelled, nil
+	}
+	ctx, cancel := context.WithTimeout(context.Background(), spellCheckerTimeout)
+	defer cancel()
+
+	var stdout, stderr bytes.Buffer
+	cmd := exec.CommandContext(ctx, args[0], args[1:]...)
+	cmd.Stdin = strings.NewReader(text)
+	cmd.Stdout = &stdout
+	cmd.Stderr = &stderr
+	if err := cmd.Run(); err != nil {
+		if msg := strings.TrimSpace(stderr.String()); msg != "" {
+			return nil, fmt.Errorf("failed to run spell checker: %s", msg)
+		}
+		return nil, fmt.Errorf("failed to run spell checker: %v", err)
+	}
+	scanner := bufio.NewScanner(&stdout)
+	for scanner.Scan() {
+		if word := strings.TrimSpace(scanner.Text()); word != "" {
+			misspelled[word] = true
+		}
+	}
+	return misspelled, nil
+}
+
+// proseBlocks returns the text of the paragraphs, headings and table cells of
+// doc.
+func proseBlocks(doc ast.Node) []string {
+	var blocks []string
+	var sb strings.Builder
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.Paragraph, *ast.Heading, *ast.TableCell:
+			if !entering && sb.Len() > 0 {
+				blocks = append(blocks, sb.String())
+				sb.Reset()
+			}
+		case *ast.Code, *ast.CodeBlock, *ast.HTMLSpan, *ast.HTMLBlock, *ast.Math, *ast.MathBlock:
+			if entering {
+				// keep words around code apart
+				sb.WriteString(" ")
+			}
+			return ast.SkipChildren
+		case *ast.Softbreak, *ast.Hardbreak:
+			sb.WriteString(" ")
+		case *ast.Text:
+			// URLs linked as they are aren't prose
+			if _, ok := n.Parent.(*ast.Link); ok && bytes.Contains(n.Literal, []byte("://")) {
+				sb.WriteString(" ")
+			} else if entering {
+				sb.Write(n.Literal)
+			}
+		}
+		return ast.GoToNext
+	})
+	return blocks
+}
+
+// proseHandler serves the ProseIssues of the markdown file given by the file
+// query parameter as JSON.
+func (s *Server) proseHandler(directory string, dir http.FileSystem) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		name := path.Clean("/" + r.URL.Query().Get("file"))
+		if !s.IsMarkdown(name) {
+			http.Error(w, "file must be a markdown file", http.StatusBadRequest)
+			return
+		}
+
+		f, err := dir.Open(name)
+		if err != nil {
+			http.NotFound(w, r)
+			return
+		}
+		defer f.Close()
+		content, err := io.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		dictionary, err := s.prose.dictionary(directory)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, _ = s.embedFiles(dir, name, content)
+		issues, err := s.prose.lint(s.parser.parse(content), content, dictionary)
+		if err != nil {
+			slog.Warn("failed to lint prose", "path", name, "err", err)
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		if issues == nil {
+			issues = []ProseIssue{}
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Header().Set("Cache-Control", "no-store")
+		if err := json.NewEncoder(w).Encode(issues); err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+		}
+	})
+}
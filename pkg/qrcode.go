@@ -0,0 +1,463 @@
+package pkg
+
+import (
+	"errors"
+	"io"
+	"net"
+	"net/url"
+	"strings"
+)
+
+// WithQRCode prints a QR code of the preview URL to w when the server can be
+// reached from other devices, so the page can be opened on a phone to check
+// the mobile layout. The QR code is drawn with block characters for a
+// terminal.
+func WithQRCode(w io.Writer) Option {
+	return func(s *Server) {
+		s.qrCode = w
+	}
+}
+
+// lanURL returns the first of urls which is not on a loopback address, or an
+// empty string if there is none.
+func lanURL(urls []string) string {
+	for _, u := range urls {
+		parsed, err := url.Parse(u)
+		if err != nil || parsed.Hostname() == "localhost" {
+			continue
+		}
+		if ip := net.ParseIP(parsed.Hostname()); ip != nil && ip.IsLoopback() {
+			continue
+		}
+		return u
+	}
+	return ""
+}
+
+// writeQRCode draws a QR code of text with black on white block characters,
+// two modules per character.
+func writeQRCode(w io.Writer, text string) error {
+	modules, err := qrEncode([]byte(text))
+	if err != nil {
+		return err
+	}
+	const quiet = 2
+	size := len(modules)
+	dark := func(x, y int) bool {
+		x, y = x-quiet, y-quiet
+		return x >= 0 && y >= 0 && x < size && y < size && modules[y][x]
+	}
+
+	var sb strings.Builder
+	for y := 0; y < size+2*quiet; y += 2 {
+		sb.WriteString("\x1b[30;47m")
+		for x := 0; x < size+2*quiet; x++ {
+			top, bottom := dark(x, y), dark(x, y+1)
+			switch {
+			case top && bottom:
+				sb.WriteString("█")
+			case top:
+				sb.WriteString("▀")
+			case bottom:
+				sb.WriteString("▄")
+			default:
+				sb.WriteString(" ")
+			}
+		}
+		sb.WriteString("\x1b[0m\n")
+	}
+	_, err = io.WriteString(w, sb.String())
+	return err
+}
+
+// qrVersion describes the size and error correction blocks of a QR code
+// version at error correction level L.
+type qrVersion struct {
+	ecPerBlock int
+	blocks     []int // data codewords of each block
+	alignment  []int // alignment pattern positions
+}
+
+var qrVersions = []qrVersion{
+	1:  {7, []int{19}, nil},
+	2:  {10, []int{34}, []int{6, 18}},
+	3:  {15, []int{55}, []int{6, 22}},
+	4:  {20, []int{80}, []int{6, 26}},
+	5:  {26, []int{108}, []int{6, 30}},
+	6:  {18, []int{68, 68}, []int{6, 34}},
+	7:  {20, []int{78, 78}, []int{6, 22, 38}},
+	8:  {24, []int{97, 97}, []int{6, 24, 42}},
+	9:  {30, []int{116, 116}, []int{6, 26, 46}},
+	10: {18, []int{68, 68, 69, 69}, []int{6, 28, 50}},
+}
+
+func (v qrVersion) dataCodewords() int {
+	n := 0
+	for _, b := range v.blocks {
+		n += b
+	}
+	return n
+}
+
+// qrEncode encodes data in byte mode at error correction level L into the
+// modules of the smallest QR code it fits in, true being dark. Only versions
+// up to 10 are supported, which is plenty for URLs.
+func qrEncode(data []byte) ([][]bool, error) {
+	version := 0
+	for v := 1; v < len(qrVersions); v++ {
+		countBits := 8
+		if v >= 10 {
+			countBits = 16
+		}
+		if 4+countBits+8*len(data) <= 8*qrVersions[v].dataCodewords() {
+			version = v
+			break
+		}
+	}
+	if version == 0 {
+		return nil, errors.New("text too long for a QR code")
+	}
+	v := qrVersions[version]
+
+	// mode indicator, character count, data, terminator and padding
+	var bits qrBits
+	bits.append(0b0100, 4)
+	if version >= 10 {
+		bits.append(len(data), 16)
+	} else {
+		bits.append(len(data), 8)
+	}
+	for _, b := range data {
+		bits.append(int(b), 8)
+	}
+	capacity := 8 * v.dataCodewords()
+	bits.append(0, min(4, capacity-len(bits)))
+	bits.append(0, (8-len(bits)%8)%8)
+	for pad := 0xEC; len(bits) < capacity; pad ^= 0xEC ^ 0x11 {
+		bits.append(pad, 8)
+	}
+	codewords := bits.bytes()
+
+	// split into blocks with error correction and interleave them
+	divisor := rsDivisor(v.ecPerBlock)
+	var blocks, ecBlocks [][]byte
+	for _, n := range v.blocks {
+		blocks = append(blocks, codewords[:n])
+		ecBlocks = append(ecBlocks, rsRemainder(codewords[:n], divisor))
+		codewords = codewords[n:]
+	}
+	var interleaved []byte
+	for i := 0; i < v.blocks[len(v.blocks)-1]; i++ {
+		for _, b := range blocks {
+			if i < len(b) {
+				interleaved = append(interleaved, b[i])
+			}
+		}
+	}
+	for i := 0; i < v.ecPerBlock; i++ {
+		for _, b := range ecBlocks {
+			interleaved = append(interleaved, b[i])
+		}
+	}
+
+	q := newQRCode(version)
+	q.drawCodewords(interleaved)
+
+	// use the mask with the lowest penalty
+	best, bestPenalty := 0, -1
+	for mask := 0; mask < 8; mask++ {
+		q.applyMask(mask)
+		q.drawFormat(mask)
+		if p := q.penalty(); bestPenalty < 0 || p < bestPenalty {
+			best, bestPenalty = mask, p
+		}
+		q.applyMask(mask)
+	}
+	q.applyMask(best)
+	q.drawFormat(best)
+	return q.modules, nil
+}
+
+type qrBits []bool
+
+func (b *qrBits) append(value int, n int) {
+	for i := n - 1; i >= 0; i-- {
+		*b = append(*b, value>>i&1 != 0)
+	}
+}
+
+func (b qrBits) bytes() []byte {
+	out := make([]byte, len(b)/8)
+	for i, bit := range b {
+		if bit {
+			out[i/8] |= 1 << (7 - i%8)
+		}
+	}
+	return out
+}
+
+// qrCode is a QR code being drawn. Function modules are the finder, timing
+// and alignment patterns and the format and version information.
+type qrCode struct {
+	version    int
+	size       int
+	modules    [][]bool
+	isFunction [][]bool
+}
+
+func newQRCode(version int) *qrCode {
+	size := 17 + 4*version
+	q := &qrCode{version: version, size: size}
+	q.modules = make([][]bool, size)
+	q.isFunction = make([][]bool, size)
+	for i := range q.modules {
+		q.modules[i] = make([]bool, size)
+		q.isFunction[i] = make([]bool, size)
+	}
+
+	for i := 0; i < size; i++ {
+		q.set(6, i, i%2 == 0)
+		q.set(i, 6, i%2 == 0)
+	}
+	for _, c := range [][2]int{{3, 3}, {size - 4, 3}, {3, size - 4}} {
+		for dy := -4; dy <= 4; dy++ {
+			for dx := -4; dx <= 4; dx++ {
+				x, y := c[0]+dx, c[1]+dy
+				if x >= 0 && y >= 0 && x < size && y < size {
+					dist := max(abs(dx), abs(dy))
+					q.set(x, y, dist != 2 && dist != 4)
+				}
+			}
+		}
+	}
+	align := qrVersions[version].alignment
+	for i, ax := range align {
+		for j, ay := range align {
+			// the corners are taken by the finder patterns
+			last := len(align) - 1
+			if i == 0 && j == 0 || i == 0 && j == last || i == last && j == 0 {
+				continue
+			}
+			for dy := -2; dy <= 2; dy++ {
+				for dx := -2; dx <= 2; dx++ {
+					q.set(ax+dx, ay+dy, max(abs(dx), abs(dy)) != 1)
+				}
+			}
+		}
+	}
+
+	// reserve the format information, drawn once the mask is known
+	q.drawFormat(0)
+	if version >= 7 {
+		rem := version
+		for i := 0; i < 12; i++ {
+			rem = rem<<1 ^ (rem>>11)*0x1F25
+		}
+		bits := version<<12 | rem
+		for i := 0; i < 18; i++ {
+			a, b := size-11+i%3, i/3
+			q.set(a, b, bits>>i&1 != 0)
+			q.set(b, a, bits>>i&1 != 0)
+		}
+	}
+	return q
+}
+
+func (q *qrCode) set(x, y int, dark bool) {
+	q.modules[y][x] = dark
+	q.isFunction[y][x] = true
+}
+
+// drawFormat draws the error correction level and mask.
+func (q *qrCode) drawFormat(mask int) {
+	// level L is 01
+	data := 1<<3 | mask
+	rem := data
+	for i := 0; i < 10; i++ {
+		rem = rem<<1 ^ (rem>>9)*0x537
+	}
+	bits := (data<<10 | rem) ^ 0x5412
+	bit := func(i int) bool { return bits>>i&1 != 0 }
+
+	for i := 0; i <= 5; i++ {
+		q.set(8, i, bit(i))
+	}
+	q.set(8, 7, bit(6))
+	q.set(8, 8, bit(7))
+	q.set(7, 8, bit(8))
+	for i := 9; i < 15; i++ {
+		q.set(14-i, 8, bit(i))
+	}
+	for i := 0; i < 8; i++ {
+		q.set(q.size-1-i, 8, bit(i))
+	}
+	for i := 8; i < 15; i++ {
+		q.set(8, q.size-15+i, bit(i))
+	}
+	q.set(8, q.size-8, true)
+}
+
+// drawCodewords fills the data modules in the zigzag order of the standard.
+func (q *qrCode) drawCodewords(data []byte) {
+	i := 0
+	for right := q.size - 1; right >= 1; right -= 2 {
+		if right == 6 {
+			right = 5
+		}
+		for vert := 0; vert < q.size; vert++ {
+			for j := 0; j < 2; j++ {
+				x := right - j
+				y := vert
+				if (right+1)&2 == 0 {
+					y = q.size - 1 - vert
+				}
+				if !q.isFunction[y][x] && i < len(data)*8 {
+					q.modules[y][x] = data[i>>3]>>(7-i&7)&1 != 0
+					i++
+				}
+			}
+		}
+	}
+}
+
+// applyMask inverts the data modules selected by mask. Applying it twice
+// removes it again.
+func (q *qrCode) applyMask(mask int) {
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			var invert bool
+			switch mask {
+			case 0:
+				invert = (x+y)%2 == 0
+			case 1:
+				invert = y%2 == 0
+			case 2:
+				invert = x%3 == 0
+			case 3:
+				invert = (x+y)%3 == 0
+			case 4:
+				invert = (x/3+y/2)%2 == 0
+			case 5:
+				invert = x*y%2+x*y%3 == 0
+			case 6:
+				invert = (x*y%2+x*y%3)%2 == 0
+			case 7:
+				invert = ((x+y)%2+x*y%3)%2 == 0
+			}
+			if invert && !q.isFunction[y][x] {
+				q.modules[y][x] = !q.modules[y][x]
+			}
+		}
+	}
+}
+
+// penalty scores how hard the code is to scan, see ISO/IEC 18004 7.8.3.
+func (q *qrCode) penalty() int {
+	result := 0
+	finderLike := []string{"10111010000", "00001011101"}
+	for _, vertical := range []bool{false, true} {
+		for a := 0; a < q.size; a++ {
+			var line strings.Builder
+			run := 0
+			for b := 0; b < q.size; b++ {
+				x, y := b, a
+				if vertical {
+					x, y = a, b
+				}
+				dark := q.modules[y][x]
+				if dark {
+					line.WriteByte('1')
+				} else {
+					line.WriteByte('0')
+				}
+				if b > 0 && dark == q.modules[y-boolInt(vertical)][x-boolInt(!vertical)] {
+					run++
+				} else {
+					run = 1
+				}
+				if run == 5 {
+					result += 3
+				} else if run > 5 {
+					result++
+				}
+			}
+			for _, p := range finderLike {
+				result += 40 * strings.Count(line.String(), p)
+			}
+		}
+	}
+
+	dark := 0
+	for y := 0; y < q.size; y++ {
+		for x := 0; x < q.size; x++ {
+			if q.modules[y][x] {
+				dark++
+			}
+			if x > 0 && y > 0 {
+				c := q.modules[y][x]
+				if c == q.modules[y-1][x] && c == q.modules[y][x-1] && c == q.modules[y-1][x-1] {
+					result += 3
+				}
+			}
+		}
+	}
+	total := q.size * q.size
+	k := (abs(dark*20-total*10)+total-1)/total - 1
+	return result + k*10
+}
+
+// rsDivisor returns the generator polynomial of Reed-Solomon error
+// correction with degree codewords, without the leading coefficient.
+func rsDivisor(degree int) []byte {
+	result := make([]byte, degree)
+	result[degree-1] = 1
+	root := byte(1)
+	for i := 0; i < degree; i++ {
+		for j := range result {
+			result[j] = gfMultiply(result[j], root)
+			if j+1 < degree {
+				result[j] ^= result[j+1]
+			}
+		}
+		root = gfMultiply(root, 0x02)
+	}
+	return result
+}
+
+// rsRemainder returns the error correction codewords of data.
+func rsRemainder(data []byte, divisor []byte) []byte {
+	result := make([]byte, len(divisor))
+	for _, b := range data {
+		factor := b ^ result[0]
+		copy(result, result[1:])
+		result[len(result)-1] = 0
+		for i, d := range divisor {
+			result[i] ^= gfMultiply(d, factor)
+		}
+	}
+	return result
+}
+
+// gfMultiply multiplies in GF(2^8) modulo x^8 + x^4 + x^3 + x^2 + 1.
+func gfMultiply(x, y byte) byte {
+	z := 0
+	for i := 7; i >= 0; i-- {
+		z = z<<1 ^ (z>>7)*0x11D
+		z ^= int(y>>i&1) * int(x)
+	}
+	return byte(z)
+}
+
+func abs(n int) int {
+	if n < 0 {
+		return -n
+	}
+	return n
+}
+
+func boolInt(b bool) int {
+	if b {
+		return 1
+	}
+	return 0
+}
@@ -0,0 +1,27 @@
+package pkg
+
+import "testing"
+
+func TestQREncode(t *testing.T) {
+	modules, err := qrEncode([]byte("http://192.168.1.23:6419/README.md"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	// 34 bytes need version 3 at level L
+	if len(modules) != 29 {
+		t.Fatalf("expected 29 modules, got %d", len(modules))
+	}
+	// the finder pattern in the top left corner
+	for i, want := range []bool{true, true, true, true, true, true, true, false} {
+		if modules[0][i] != want || modules[i][0] != want {
+			t.Errorf("unexpected finder pattern module at %d", i)
+		}
+	}
+
+	if lanURL([]string{"http://127.0.0.1:6419/", "http://[::1]:6419/", "http://192.168.1.23:6419/"}) != "http://192.168.1.23:6419/" {
+		t.Errorf("expected the first non-loopback URL")
+	}
+	if _, err := qrEncode(make([]byte, 300)); err == nil {
+		t.Errorf("expected an error for text too long")
+	}
+}
@@ -0,0 +1,64 @@
+package pkg
+
+import (
+	"html"
+	"net/http"
+	"strings"
+)
+
+// pageQuery holds the query parameters overriding the server options for a
+// single page, e.g. ?theme=dark&toc=1 for an editor webview. ?raw=1 and
+// ?source=1 are handled by sourceRequested.
+type pageQuery struct {
+	theme string
+	toc   bool
+}
+
+func parsePageQuery(r *http.Request) pageQuery {
+	q := r.URL.Query()
+	var p pageQuery
+	if theme := q.Get("theme"); theme != "" && validTheme(theme) {
+		p.theme = theme
+	}
+	p.toc = q.Get("toc") == "1"
+	return p
+}
+
+// cacheKey returns the render cache key of the page at name with the query
+// applied, as every variant is cached on its own.
+func (p pageQuery) cacheKey(name string) string {
+	key := name
+	if p.theme != "" {
+		key += "?theme=" + p.theme
+	}
+	if p.toc {
+		key += "?toc=1"
+	}
+	return key
+}
+
+// tableOfContents renders the headings of a markdown document as nested
+// list of links to them.
+func (m Parser) tableOfContents(content []byte) string {
+	headings := m.Outline(content)
+	if len(headings) == 0 {
+		return ""
+	}
+	var sb strings.Builder
+	sb.WriteString(`<nav class="grip-toc">`)
+	writeTOC(&sb, headings)
+	sb.WriteString(`</nav>`)
+	return sb.String()
+}
+
+func writeTOC(sb *strings.Builder, headings []*OutlineHeading) {
+	sb.WriteString("<ul>")
+	for _, h := range headings {
+		sb.WriteString(`<li><a href="#` + html.EscapeString(h.Slug) + `">` + html.EscapeString(h.Text) + `</a>`)
+		if len(h.Children) > 0 {
+			writeTOC(sb, h.Children)
+		}
+		sb.WriteString("</li>")
+	}
+	sb.WriteString("</ul>")
+}
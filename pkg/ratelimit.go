@@ -0,0 +1,121 @@
+package pkg
+
+import (
+	"math"
+	"net"
+	"net/http"
+	"strconv"
+	"sync"
+	"time"
+)
+
+// DefaultMaxRequestSize is the default limit of request headers, bodies and
+// websocket messages, which go-grip itself keeps small.
+const DefaultMaxRequestSize = 1 << 20
+
+// WithRateLimit limits every client IP address to perSecond requests on
+// average, allowing bursts of up to burst requests, like loading a page with
+// all its images. Excess requests get 429 Too Many Requests. Behind a
+// reverse proxy all clients share the limit of the proxy.
+func WithRateLimit(perSecond float64, burst int) Option {
+	return func(s *Server) {
+		if perSecond <= 0 {
+			s.rateLimit = nil
+			return
+		}
+		s.rateLimit = &rateLimiter{
+			rate:    perSecond,
+			burst:   float64(max(burst, 1)),
+			clients: make(map[string]*tokenBucket),
+		}
+	}
+}
+
+// WithMaxRequestSize limits request headers, bodies and websocket messages
+// to n bytes instead of DefaultMaxRequestSize.
+func WithMaxRequestSize(n int64) Option {
+	return func(s *Server) {
+		s.maxRequestSize = n
+	}
+}
+
+// rateLimiter keeps a token bucket for every client.
+type rateLimiter struct {
+	rate  float64
+	burst float64
+
+	mu      sync.Mutex
+	clients map[string]*tokenBucket
+	swept   time.Time
+}
+
+type tokenBucket struct {
+	tokens float64
+	last   time.Time
+}
+
+// allow takes a token from the bucket of client, returning how long to wait
+// for the next one if the bucket is empty.
+func (l *rateLimiter) allow(client string, now time.Time) (bool, time.Duration) {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	l.sweep(now)
+	b, ok := l.clients[client]
+	if !ok {
+		b = &tokenBucket{tokens: l.burst, last: now}
+		l.clients[client] = b
+	}
+	b.tokens = math.Min(l.burst, b.tokens+now.Sub(b.last).Seconds()*l.rate)
+	b.last = now
+	if b.tokens < 1 {
+		return false, time.Duration((1 - b.tokens) / l.rate * float64(time.Second))
+	}
+	b.tokens--
+	return true, 0
+}
+
+// sweep forgets the clients whose buckets are full again, at most once a
+// minute.
+func (l *rateLimiter) sweep(now time.Time) {
+	if now.Sub(l.swept) < time.Minute {
+		return
+	}
+	l.swept = now
+	refill := time.Duration(l.burst / l.rate * float64(time.Second))
+	for client, b := range l.clients {
+		if now.Sub(b.last) > refill {
+			delete(l.clients, client)
+		}
+	}
+}
+
+// limitRequests enforces the rate limit per client IP and the maximum size
+// of request bodies.
+func (s *Server) limitRequests(next http.Handler) http.Handler {
+	maxSize := s.requestSizeLimit()
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if s.rateLimit != nil {
+			client, _, err := net.SplitHostPort(r.RemoteAddr)
+			if err != nil {
+				client = r.RemoteAddr
+			}
+			if ok, wait := s.rateLimit.allow(client, time.Now()); !ok {
+				w.Header().Set("Retry-After", strconv.Itoa(int(math.Ceil(wait.Seconds()))))
+				http.Error(w, "too many requests", http.StatusTooManyRequests)
+				return
+			}
+		}
+		if r.Body != nil {
+			r.Body = http.MaxBytesReader(w, r.Body, maxSize)
+		}
+		next.ServeHTTP(w, r)
+	})
+}
+
+func (s *Server) requestSizeLimit() int64 {
+	if s.maxRequestSize > 0 {
+		return s.maxRequestSize
+	}
+	return DefaultMaxRequestSize
+}
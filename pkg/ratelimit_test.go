@@ -0,0 +1,37 @@
+package pkg
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRateLimiter(t *testing.T) {
+	s := NewServer(nil, 0, "", false, false, nil, WithRateLimit(1, 2))
+	now := time.Now()
+	for i, want := range []bool{true, true, false} {
+		if ok, _ := s.rateLimit.allow("10.0.0.1", now); ok != want {
+			t.Errorf("request %d: allowed = %v, want %v", i, ok, want)
+		}
+	}
+	if ok, _ := s.rateLimit.allow("10.0.0.2", now); !ok {
+		t.Error("other client should not be limited")
+	}
+	if ok, _ := s.rateLimit.allow("10.0.0.1", now.Add(time.Second)); !ok {
+		t.Error("bucket should refill after a second")
+	}
+
+	handler := s.limitRequests(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.3:1234"
+	var code int
+	for range 3 {
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		code = rec.Code
+	}
+	if code != http.StatusTooManyRequests {
+		t.Errorf("status = %d, want 429", code)
+	}
+}
@@ -0,0 +1,126 @@
+package pkg
+
+import (
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// WithReferences links references like GitHub does in the files of the
+// repository repo, given as owner/name: #123 and GH-123 to its issues and
+// pull requests, owner/name#123 to those of other repositories, @user to
+// profiles and commit SHAs, shortened to 7 characters, to the commits, on
+// the GitHub instance at baseURL, github.com if empty. Without repo only the
+// references naming their repository and users are linked.
+func WithReferences(baseURL string, repo string) ParserOption {
+	return func(p *Parser) {
+		p.repo = repo
+		p.repoURL = NewGitHub(baseURL, "").BaseURL
+	}
+}
+
+// ValidRepository reports whether repo is an owner/name repository.
+func ValidRepository(repo string) bool {
+	return repositoryRegex.MatchString(repo)
+}
+
+var (
+	repositoryRegex = regexp.MustCompile(`^[A-Za-z0-9][A-Za-z0-9-]*/[A-Za-z0-9._-]+$`)
+	referenceRegex  = regexp.MustCompile(`([A-Za-z0-9][A-Za-z0-9-]*/[A-Za-z0-9._-]+)?#([0-9]+)|GH-([0-9]+)|@([A-Za-z0-9](?:-?[A-Za-z0-9]){0,38})|(?:([A-Za-z0-9][A-Za-z0-9-]*/[A-Za-z0-9._-]+)@)?([0-9a-f]{7,40})`)
+)
+
+// shortSHALen is the length commit SHAs are shortened to.
+const shortSHALen = 7
+
+// linkReferences links the references in the text of doc. Code, links and
+// email addresses are left alone.
+func (m Parser) linkReferences(doc ast.Node) {
+	for _, t := range linkableTexts(doc) {
+		var nodes []ast.Node
+		start := 0
+		for _, match := range referenceRegex.FindAllSubmatchIndex(t.Literal, -1) {
+			if !referenceBoundary(t.Literal, match[0]-1) || !wordBoundary(t.Literal, match[1]) {
+				continue
+			}
+			link := m.referenceLink(t.Literal, match)
+			if link == nil {
+				continue
+			}
+			if match[0] > start {
+				nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:match[0]]}})
+			}
+			nodes = append(nodes, link)
+			start = match[1]
+		}
+		if nodes == nil {
+			continue
+		}
+		if start < len(t.Literal) {
+			nodes = append(nodes, &ast.Text{Leaf: ast.Leaf{Literal: t.Literal[start:]}})
+		}
+		replaceNode(t, nodes)
+	}
+}
+
+// referenceLink returns the link for a match of referenceRegex in text, or
+// nil if the match is no reference.
+func (m Parser) referenceLink(text []byte, match []int) *ast.Link {
+	group := func(i int) string {
+		if match[2*i] < 0 {
+			return ""
+		}
+		return string(text[match[2*i]:match[2*i+1]])
+	}
+	link := func(dest string, children ...ast.Node) *ast.Link {
+		l := &ast.Link{Destination: []byte(dest)}
+		if children == nil {
+			children = []ast.Node{&ast.Text{Leaf: ast.Leaf{Literal: text[match[0]:match[1]]}}}
+		}
+		for _, c := range children {
+			ast.AppendChild(l, c)
+		}
+		return l
+	}
+
+	switch {
+	case group(6) != "":
+		// without both letters and digits it is more likely a hex word
+		// or a number
+		sha := group(6)
+		if !strings.ContainsAny(sha, "0123456789") || !strings.ContainsAny(sha, "abcdef") {
+			return nil
+		}
+		repo := m.repo
+		var children []ast.Node
+		if group(5) == "" && repo == "" {
+			return nil
+		}
+		if group(5) != "" {
+			repo = group(5)
+			children = append(children, &ast.Text{Leaf: ast.Leaf{Literal: []byte(repo + "@")}})
+		}
+		children = append(children, &ast.Code{Leaf: ast.Leaf{Literal: []byte(sha[:shortSHALen])}})
+		return link(m.repoURL+"/"+repo+"/commit/"+sha, children...)
+	case group(4) != "":
+		// a slash continues the reference, e.g. @org/team
+		if match[1] < len(text) && text[match[1]] == '/' {
+			return nil
+		}
+		return link(m.repoURL + "/" + group(4))
+	case group(1) != "":
+		return link(m.repoURL + "/" + group(1) + "/issues/" + group(2))
+	case m.repo == "":
+		return nil
+	case group(3) != "":
+		return link(m.repoURL + "/" + m.repo + "/issues/" + group(3))
+	default:
+		return link(m.repoURL + "/" + m.repo + "/issues/" + group(2))
+	}
+}
+
+// referenceBoundary reports whether a reference may start after the byte at
+// i, which may be out of range.
+func referenceBoundary(text []byte, i int) bool {
+	return i < 0 || wordBoundary(text, i) && strings.IndexByte("/@.-#&", text[i]) < 0
+}
@@ -0,0 +1,532 @@
+package pkg
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/fsnotify/fsnotify"
+	"github.com/gorilla/websocket"
+)
+
+const (
+	reloadEndpoint       = "/reload_ws"
+	reloadEventsEndpoint = "/reload_events"
+	watcherRetry         = 2 * time.Second
+	// eventsKeepAlive is how often an idle event stream sends a comment, so
+	// proxies don't close it
+	eventsKeepAlive = 30 * time.Second
+
+	// DefaultReloadDebounce is how long no further changes must happen before
+	// browsers are told to reload, so bursts of saves reload only once.
+	DefaultReloadDebounce = 100 * time.Millisecond
+
+	// a file changing within loopWindow after each of loopLimit reloads in
+	// a row is written by rendering the pages, and a file changing more
+	// than burstLimit times within loopWindow is written for every change,
+	// like a log of the server at debug level. They are ignored for
+	// loopExpiry, so they don't reload pages forever or keep them from
+	// reloading at all.
+	loopWindow = time.Second
+	loopLimit  = 5
+	burstLimit = 200
+	loopExpiry = time.Minute
+)
+
+// reloader watches a directory tree and notifies connected browsers when a
+// file changes. If the file watcher fails, clients are told that the page
+// may be stale and the watcher is restarted until it recovers.
+type reloader struct {
+	directory string
+	// roots are the directories below directory to watch, all of it if
+	// empty
+	roots    []string
+	upgrader websocket.Upgrader
+	// onReload is called for every reload sent to the clients
+	onReload  func()
+	debounce  time.Duration
+	readLimit int64
+	// exclude are the patterns of WithReloadExclude
+	exclude []string
+
+	// realDirectory is directory with symlinks resolved
+	realDirectory string
+	// added receives dependencies outside the directory to watch
+	added chan string
+
+	mu      sync.Mutex
+	clients map[chan string]struct{}
+	deps    map[string]struct{}
+	stale   bool
+	timer   *time.Timer
+	done    chan struct{}
+	// reloaded is when clients were last told to reload, echoes counts
+	// the reloads in a row files changed right after and loops are the
+	// files ignored for changing after every reload, with the time they
+	// were detected. Pages and the files they read are never ignored.
+	reloaded time.Time
+	echoes   map[string]echo
+	loops    map[string]time.Time
+	read     map[string]struct{}
+}
+
+// echo counts the reloads in a row a file changed right after, and its
+// changes since start.
+type echo struct {
+	reload  time.Time
+	count   int
+	start   time.Time
+	changes int
+}
+
+func newReloader(directory string) *reloader {
+	if abs, err := filepath.Abs(directory); err == nil {
+		directory = abs
+	}
+	realDirectory, err := filepath.EvalSymlinks(directory)
+	if err != nil {
+		realDirectory = directory
+	}
+	return &reloader{
+		directory:     directory,
+		realDirectory: realDirectory,
+		debounce:      DefaultReloadDebounce,
+		added:         make(chan string, 64),
+		clients:       make(map[chan string]struct{}),
+		deps:          make(map[string]struct{}),
+		done:          make(chan struct{}),
+		echoes:        make(map[string]echo),
+		loops:         make(map[string]time.Time),
+		read:          make(map[string]struct{}),
+	}
+}
+
+// run watches the directory until ctx is cancelled, restarting the watcher
+// whenever it fails. Connected clients are disconnected once run returns.
+func (r *reloader) run(ctx context.Context) {
+	defer close(r.done)
+
+	for {
+		err := r.watch(ctx)
+		if ctx.Err() != nil {
+			return
+		}
+		slog.Error("file watcher stopped", "err", err)
+		r.setStale(true)
+
+		select {
+		case <-ctx.Done():
+			return
+		case <-time.After(watcherRetry):
+		}
+	}
+}
+
+func (r *reloader) watch(ctx context.Context) error {
+	w, err := fsnotify.NewWatcher()
+	if err != nil {
+		return err
+	}
+	defer w.Close()
+
+	roots := r.roots
+	if len(roots) == 0 {
+		roots = []string{r.directory}
+	}
+	for _, root := range roots {
+		if err := addRecursive(w, root, r.excluded); err != nil {
+			return err
+		}
+	}
+	r.mu.Lock()
+	for file := range r.deps {
+		addDependency(w, file)
+	}
+	r.mu.Unlock()
+
+	// the watcher recovered after a failure, so changes may have been missed
+	if r.setStale(false) {
+		r.broadcast("reload")
+	}
+
+	for {
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case file := <-r.added:
+			addDependency(w, file)
+		case err, ok := <-w.Errors:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+			return err
+		case e, ok := <-w.Events:
+			if !ok {
+				return errors.New("watcher closed")
+			}
+
+			if e.Has(fsnotify.Create) {
+				if err := addRecursive(w, e.Name, r.excluded); err != nil && !errors.Is(err, fs.ErrNotExist) {
+					return err
+				}
+			}
+			if (e.Has(fsnotify.Remove) || e.Has(fsnotify.Rename)) && slices.Contains(roots, filepath.Clean(e.Name)) {
+				return errors.New("watched directory was removed")
+			}
+
+			if temporaryFile(e.Name) || !r.contains(e.Name) && !r.dependency(e.Name) || r.excluded(e.Name) || r.looping(e.Name) {
+				continue
+			}
+			slog.Debug("file changed", "path", e.Name, "op", e.Op.String())
+			r.scheduleReload()
+		}
+	}
+}
+
+// scheduleReload tells clients to reload once no further changes happened
+// for a short while.
+func (r *reloader) scheduleReload() {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if r.timer != nil {
+		r.timer.Stop()
+	}
+	r.timer = time.AfterFunc(r.debounce, func() {
+		if r.onReload != nil {
+			r.onReload()
+		}
+		r.mu.Lock()
+		r.reloaded = time.Now()
+		r.mu.Unlock()
+		r.broadcast("reload")
+	})
+}
+
+// excluded reports whether changes of file are ignored by the patterns of
+// WithReloadExclude.
+func (r *reloader) excluded(file string) bool {
+	return reloadExcluded(r.directory, r.exclude, file)
+}
+
+// looping reports whether file changed right after each of the last
+// loopLimit reloads, so rendering the reloaded pages writes it, or keeps
+// changing without pause, and ignores its changes for loopExpiry. Files
+// rendered pages read are edited by the user and never loop.
+func (r *reloader) looping(file string) bool {
+	file = filepath.Clean(file)
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	if _, ok := r.read[file]; ok {
+		return false
+	}
+	now := time.Now()
+	if detected, ok := r.loops[file]; ok {
+		if now.Sub(detected) < loopExpiry {
+			return true
+		}
+		delete(r.loops, file)
+	}
+	e := r.echoes[file]
+	if now.Sub(e.start) > loopWindow {
+		e.start, e.changes = now, 0
+	}
+	e.changes++
+	switch {
+	case r.reloaded.IsZero() || now.Sub(r.reloaded) > loopWindow:
+		e.count = 0
+	case !e.reload.Equal(r.reloaded):
+		// only the first change after every reload counts
+		e.reload = r.reloaded
+		e.count++
+	}
+	if e.count < loopLimit && e.changes <= burstLimit {
+		r.echoes[file] = e
+		return false
+	}
+	delete(r.echoes, file)
+	r.loops[file] = now
+	slog.Warn("reload loop detected, ignoring changes of file", "path", file,
+		"hint", "exclude generated files with --reload-exclude")
+	return true
+}
+
+// reloadExcluded reports whether file, an absolute path, matches one of
+// patterns, see WithReloadExclude, with relative patterns matched below dir.
+func reloadExcluded(dir string, patterns []string, file string) bool {
+	rel, err := filepath.Rel(dir, file)
+	inside := err == nil && rel != ".." && !strings.HasPrefix(rel, ".."+string(filepath.Separator))
+	rel = filepath.ToSlash(rel)
+	for _, pattern := range patterns {
+		switch {
+		case filepath.IsAbs(pattern):
+			if within(filepath.Clean(pattern), file) {
+				return true
+			}
+		case !inside:
+		case !strings.Contains(strings.Trim(pattern, "/"), "/"):
+			pattern = strings.Trim(pattern, "/")
+			for _, elem := range strings.Split(rel, "/") {
+				if ok, _ := path.Match(pattern, elem); ok {
+					return true
+				}
+			}
+		default:
+			pattern = strings.Trim(pattern, "/")
+			for name := rel; name != "." && name != "/"; name = path.Dir(name) {
+				if ok, _ := path.Match(pattern, name); ok {
+					return true
+				}
+			}
+		}
+	}
+	return false
+}
+
+// temporaryFile reports whether the file at name is a temporary file of an
+// editor, like vim swap and backup files or emacs lock files, whose changes
+// don't need a reload.
+func temporaryFile(name string) bool {
+	base := filepath.Base(name)
+	switch {
+	case strings.HasSuffix(base, "~"),
+		strings.HasPrefix(base, ".#"),
+		strings.HasPrefix(base, "#") && strings.HasSuffix(base, "#"),
+		base == "4913": // vim checks if it may write to the directory with it
+		return true
+	}
+	switch strings.ToLower(filepath.Ext(base)) {
+	case ".swp", ".swo", ".swx", ".tmp", ".temp", ".bak":
+		return true
+	}
+	return false
+}
+
+// reloadExcludes returns the patterns of WithReloadExclude and the cache
+// directories go-grip writes to while rendering, which would otherwise
+// reload pages in a loop when they are inside the served directory.
+func (s *Server) reloadExcludes() []string {
+	excludes := slices.Clone(s.reloadExclude)
+	var caches []string
+	if s.parser != nil && s.parser.diagrams != nil {
+		caches = append(caches, s.parser.diagrams.dir)
+	}
+	if s.imageProxy != nil {
+		caches = append(caches, s.imageProxy.cacheDir)
+	}
+	for _, dir := range caches {
+		if abs, err := filepath.Abs(dir); err == nil {
+			excludes = append(excludes, abs)
+		}
+	}
+	return excludes
+}
+
+// addRecursive adds path and all directories below it to the watcher, except
+// the directories skip reports, if it isn't nil.
+func addRecursive(w *fsnotify.Watcher, path string, skip func(dir string) bool) error {
+	return filepath.WalkDir(path, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return err
+		}
+		if !d.IsDir() {
+			return nil
+		}
+		if d.Name() == ".git" {
+			return filepath.SkipDir
+		}
+		abs, err := filepath.Abs(p)
+		if err != nil {
+			return err
+		}
+		if skip != nil && skip(abs) {
+			return filepath.SkipDir
+		}
+		return w.Add(abs)
+	})
+}
+
+// addDependency watches the directory of file, so replacing the file by
+// renaming a new one over it is noticed too.
+func addDependency(w *fsnotify.Watcher, file string) {
+	if err := w.Add(filepath.Dir(file)); err != nil {
+		slog.Debug("failed to watch dependency", "path", file, "err", err)
+	}
+}
+
+// setStale updates the stale state and notifies clients about changes. It
+// reports whether the state changed.
+func (r *reloader) setStale(stale bool) bool {
+	r.mu.Lock()
+	changed := r.stale != stale
+	r.stale = stale
+	r.mu.Unlock()
+
+	if changed && stale {
+		r.broadcast("stale")
+	}
+	return changed
+}
+
+func (r *reloader) broadcast(msg string) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+
+	for c := range r.clients {
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+// Reload transports of WithReloadTransport.
+const (
+	// ReloadAuto uses the websocket and falls back to server-sent events if
+	// it can't connect.
+	ReloadAuto = "auto"
+	// ReloadWebSocket notifies browsers over a websocket.
+	ReloadWebSocket = "websocket"
+	// ReloadSSE notifies browsers with server-sent events, which work through
+	// proxies blocking websockets.
+	ReloadSSE = "sse"
+)
+
+// handle serves the reload websocket and event stream and disables caching
+// for all other responses, so reloaded pages are always fresh.
+func (r *reloader) handle(next http.Handler) http.Handler {
+	next = noCache(next)
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		switch req.URL.Path {
+		case reloadEndpoint:
+			r.serveWS(w, req)
+			return
+		case reloadEventsEndpoint:
+			r.serveEvents(w, req)
+			return
+		}
+		next.ServeHTTP(w, req)
+	})
+}
+
+// noCache makes browsers revalidate every response of next, so changed files
+// show up on the next load.
+func noCache(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, req *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		next.ServeHTTP(w, req)
+	})
+}
+
+func (r *reloader) serveWS(w http.ResponseWriter, req *http.Request) {
+	conn, err := r.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		slog.Error("failed to open reload websocket", "err", err)
+		return
+	}
+	defer conn.Close()
+	if r.readLimit > 0 {
+		conn.SetReadLimit(r.readLimit)
+	}
+
+	c := r.subscribe()
+	defer r.unsubscribe(c)
+
+	// the client never sends anything, reading only detects disconnects
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			if _, _, err := conn.ReadMessage(); err != nil {
+				return
+			}
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-c:
+			if err := conn.WriteMessage(websocket.TextMessage, []byte(msg)); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-r.done:
+			return
+		}
+	}
+}
+
+// serveEvents sends the messages of the websocket as server-sent events.
+func (r *reloader) serveEvents(w http.ResponseWriter, req *http.Request) {
+	h := w.Header()
+	h.Set("Content-Type", "text/event-stream")
+	h.Set("Cache-Control", "no-cache")
+	// nginx buffers responses unless told otherwise
+	h.Set("X-Accel-Buffering", "no")
+	c := r.subscribe()
+	defer r.unsubscribe(c)
+
+	w.WriteHeader(http.StatusOK)
+	rc := http.NewResponseController(w)
+	// the stream stays open until the client leaves, beyond the timeouts
+	// of WithTimeouts
+	_ = rc.SetReadDeadline(time.Time{})
+	_ = rc.SetWriteDeadline(time.Time{})
+	if err := rc.Flush(); err != nil {
+		slog.Error("failed to open reload event stream", "err", err)
+		return
+	}
+
+	keepAlive := time.NewTicker(eventsKeepAlive)
+	defer keepAlive.Stop()
+	for {
+		var err error
+		select {
+		case msg := <-c:
+			_, err = fmt.Fprintf(w, "data: %s\n\n", msg)
+		case <-keepAlive.C:
+			_, err = io.WriteString(w, ": keep-alive\n\n")
+		case <-req.Context().Done():
+			return
+		case <-r.done:
+			return
+		}
+		if err == nil {
+			err = rc.Flush()
+		}
+		if err != nil {
+			return
+		}
+	}
+}
+
+// subscribe registers a client for reload messages. Clients connecting while
+// the watcher is down are told right away.
+func (r *reloader) subscribe() chan string {
+	c := make(chan string, 1)
+	r.mu.Lock()
+	r.clients[c] = struct{}{}
+	if r.stale {
+		c <- "stale"
+	}
+	r.mu.Unlock()
+	return c
+}
+
+func (r *reloader) unsubscribe(c chan string) {
+	r.mu.Lock()
+	delete(r.clients, c)
+	r.mu.Unlock()
+}
@@ -0,0 +1,135 @@
+package pkg
+
+import (
+	"bufio"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestTemporaryFile(t *testing.T) {
+	for name, want := range map[string]bool{
+		"docs/README.md":      false,
+		"docs/.README.md.swp": true,
+		"docs/README.md~":     true,
+		"docs/.#README.md":    true,
+		"docs/#README.md#":    true,
+		"docs/4913":           true,
+		"docs/upload.TMP":     true,
+		"docs/#1.md":          false,
+	} {
+		if got := temporaryFile(name); got != want {
+			t.Errorf("temporaryFile(%q) = %v, want %v", name, got, want)
+		}
+	}
+}
+
+func TestReloadEvents(t *testing.T) {
+	r := newReloader(t.TempDir())
+	srv := httptest.NewServer(r.handle(http.NotFoundHandler()))
+	defer srv.Close()
+
+	resp, err := http.Get(srv.URL + reloadEventsEndpoint)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer resp.Body.Close()
+	if ct := resp.Header.Get("Content-Type"); ct != "text/event-stream" {
+		t.Fatalf("Content-Type = %q", ct)
+	}
+
+	// the headers are flushed after the client is subscribed
+	r.broadcast("reload")
+	line, err := bufio.NewReader(resp.Body).ReadString('\n')
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.TrimSpace(line) != "data: reload" {
+		t.Errorf("event = %q, want data: reload", line)
+	}
+}
+
+func TestPageDependencies(t *testing.T) {
+	html := []byte(`<p><img src="img/a.png" alt=""> <a href="other.md">other</a></p>
+<link rel="stylesheet" href="/css/site.css?v=1"><video poster="https://example.com/p.png" src="../clip.mp4"></video>`)
+	got := strings.Join(pageDependencies("docs/page.md", html), " ")
+	want := "/docs/img/a.png /css/site.css /clip.mp4"
+	if got != want {
+		t.Errorf("pageDependencies = %q, want %q", got, want)
+	}
+}
+
+func TestReloadExcluded(t *testing.T) {
+	dir := t.TempDir()
+	patterns := []string{"*.log", "node_modules", "build/*.html", filepath.Join(dir, "site")}
+	for name, want := range map[string]bool{
+		"README.md":               false,
+		"docs/server.log":         true,
+		"web/node_modules/x/a.md": true,
+		"build/index.html":        true,
+		"build/sub/index.html":    false,
+		"build/notes.md":          false,
+		"docs/build/index.html":   false,
+		"site/index.html":         true,
+		"sitemap.md":              false,
+	} {
+		if got := reloadExcluded(dir, patterns, filepath.Join(dir, filepath.FromSlash(name))); got != want {
+			t.Errorf("reloadExcluded(%q) = %v, want %v", name, got, want)
+		}
+	}
+	if reloadExcluded(dir, patterns, filepath.Join(filepath.Dir(dir), "other.log")) {
+		t.Error("expected relative patterns to only match inside the directory")
+	}
+}
+
+func TestReloadLoop(t *testing.T) {
+	r := newReloader(t.TempDir())
+	file := filepath.Join(r.directory, "cache.json")
+	if r.looping(file) {
+		t.Fatal("expected a change without reload to be no loop")
+	}
+	for i := 1; i <= loopLimit; i++ {
+		r.reloaded = time.Now().Add(time.Duration(i) * time.Millisecond)
+		// only the first change after every reload counts
+		r.looping(file)
+		if got := r.looping(file); got != (i == loopLimit) {
+			t.Fatalf("looping after reload %d = %v", i, got)
+		}
+	}
+
+	other := filepath.Join(r.directory, "README.md")
+	r.reloaded = time.Now().Add(-2 * loopWindow)
+	if r.looping(other) || !r.looping(file) {
+		t.Error("expected only the looping file to be ignored")
+	}
+
+	log := filepath.Join(r.directory, "server.log")
+	for i := 0; i < burstLimit; i++ {
+		if r.looping(log) {
+			t.Fatalf("expected %d changes to be no loop", i+1)
+		}
+	}
+	if !r.looping(log) {
+		t.Error("expected a file changing without pause to be ignored")
+	}
+
+	r.loops[log] = time.Now().Add(-loopExpiry)
+	if r.looping(log) {
+		t.Error("expected the loop to expire")
+	}
+
+	page := filepath.Join(r.directory, "notes.md")
+	if err := os.WriteFile(page, []byte("# Notes"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	r.watchDependencies([]string{"/notes.md"})
+	for i := 0; i <= burstLimit; i++ {
+		if r.looping(page) {
+			t.Fatal("expected changes of a page to never be a loop")
+		}
+	}
+}
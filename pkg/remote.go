@@ -0,0 +1,165 @@
+package pkg
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"os"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+var (
+	repoShorthand = regexp.MustCompile(`^[\w.-]+/[\w.-]+$`)
+	httpClient    = &http.Client{Timeout: 30 * time.Second}
+)
+
+// RemoteSource is a markdown document fetched over HTTP. Relative links and
+// images in the document are resolved against LinkBase and ImageBase.
+type RemoteSource struct {
+	URL       string
+	LinkBase  string
+	ImageBase string
+
+	github *GitHub
+}
+
+// GitHub describes the GitHub instance used to look up repositories, either
+// github.com or a GitHub Enterprise Server.
+type GitHub struct {
+	BaseURL string
+	APIURL  string
+	Token   string
+}
+
+// NewGitHub returns the GitHub instance at baseURL, defaulting to github.com.
+// The token is sent with API requests and downloads from the instance.
+func NewGitHub(baseURL string, token string) *GitHub {
+	baseURL = strings.TrimSuffix(baseURL, "/")
+	if baseURL == "" || baseURL == "https://github.com" {
+		return &GitHub{BaseURL: "https://github.com", APIURL: "https://api.github.com", Token: token}
+	}
+	return &GitHub{BaseURL: baseURL, APIURL: baseURL + "/api/v3", Token: token}
+}
+
+// authorize adds the token to requests sent to the GitHub instance.
+func (gh *GitHub) authorize(req *http.Request) {
+	if gh.Token == "" {
+		return
+	}
+	base, err := url.Parse(gh.BaseURL)
+	if err != nil {
+		return
+	}
+	host := req.URL.Hostname()
+	if host == base.Hostname() || host == "api."+base.Hostname() || (host == "raw.githubusercontent.com" && base.Hostname() == "github.com") {
+		req.Header.Set("Authorization", "Bearer "+gh.Token)
+	}
+}
+
+// ResolveRemote checks whether arg refers to a remote document, either an
+// http(s) URL or an owner/repo shorthand for the README of a repository on
+// the given GitHub instance. It reports false for everything else, including
+// existing local paths that look like a shorthand.
+func ResolveRemote(arg string, gh *GitHub) (*RemoteSource, bool, error) {
+	if strings.HasPrefix(arg, "http://") || strings.HasPrefix(arg, "https://") {
+		u, err := url.Parse(arg)
+		if err != nil {
+			return nil, true, fmt.Errorf("invalid url %s: %v", arg, err)
+		}
+		base := baseURL(u)
+		return &RemoteSource{URL: arg, LinkBase: base, ImageBase: base, github: gh}, true, nil
+	}
+
+	if !repoShorthand.MatchString(arg) {
+		return nil, false, nil
+	}
+	if _, err := os.Stat(arg); err == nil {
+		return nil, false, nil
+	}
+
+	src, err := gh.fetchReadme(arg)
+	return src, true, err
+}
+
+// fetchReadme looks up the README of the default branch of a repository.
+func (gh *GitHub) fetchReadme(repo string) (*RemoteSource, error) {
+	req, err := http.NewRequest(http.MethodGet, fmt.Sprintf("%s/repos/%s/readme", gh.APIURL, repo), nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/vnd.github+json")
+	gh.authorize(req)
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch readme of %s: %v", repo, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch readme of %s: %s", repo, resp.Status)
+	}
+
+	var readme struct {
+		HTMLURL     string `json:"html_url"`
+		DownloadURL string `json:"download_url"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&readme); err != nil {
+		return nil, fmt.Errorf("failed to decode readme of %s: %v", repo, err)
+	}
+
+	src := &RemoteSource{URL: readme.DownloadURL, github: gh}
+	if u, err := url.Parse(readme.DownloadURL); err == nil {
+		src.ImageBase = baseURL(u)
+	}
+	if u, err := url.Parse(readme.HTMLURL); err == nil {
+		src.LinkBase = baseURL(u)
+	}
+	return src, nil
+}
+
+// Name returns the file name of the remote document.
+func (src *RemoteSource) Name() string {
+	u, err := url.Parse(src.URL)
+	if err != nil || path.Base(u.Path) == "/" || path.Base(u.Path) == "." {
+		return "README.md"
+	}
+	return path.Base(u.Path)
+}
+
+// Fetch downloads the current content of the remote document.
+func (src *RemoteSource) Fetch() ([]byte, error) {
+	req, err := http.NewRequest(http.MethodGet, src.URL, nil)
+	if err != nil {
+		return nil, err
+	}
+	if src.github != nil {
+		src.github.authorize(req)
+	}
+
+	resp, err := httpClient.Do(req)
+	if err != nil {
+		return nil, fmt.Errorf("failed to fetch %s: %v", src.URL, err)
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("failed to fetch %s: %s", src.URL, resp.Status)
+	}
+	return io.ReadAll(resp.Body)
+}
+
+// baseURL returns the directory URL of u, ending with a slash.
+func baseURL(u *url.URL) string {
+	b := *u
+	b.RawQuery = ""
+	b.Fragment = ""
+	b.Path = path.Dir(b.Path) + "/"
+	b.RawPath = ""
+	return b.String()
+}
@@ -0,0 +1,110 @@
+package pkg
+
+import (
+	"context"
+	"net/http"
+	"path"
+	"strings"
+)
+
+// Renderer converts the content of a file to HTML and returns it with the
+// page title, which may be empty to use the file name instead. The name is
+// the slash separated path of the file in the served directory.
+type Renderer func(content []byte, name string) ([]byte, string, error)
+
+// includingRenderer returns the Renderer of a format whose files include
+// other files, reading them from fsys, the served directory. Files can't
+// include files outside of it.
+type includingRenderer func(fsys http.FileSystem) Renderer
+
+// fixedRenderer is the includingRenderer of a format that includes no files.
+func fixedRenderer(r Renderer) includingRenderer {
+	return func(http.FileSystem) Renderer {
+		return r
+	}
+}
+
+// DefaultMarkdownExtensions are the file extensions rendered as markdown
+// unless configured otherwise with WithMarkdownExtensions.
+var DefaultMarkdownExtensions = []string{".md", ".markdown", ".mdown", ".mkdn", ".mdx"}
+
+// registerDefaultRenderers maps the extensions of all supported formats to
+// their renderer.
+func (s *Server) registerDefaultRenderers() {
+	s.renderers = make(map[string]includingRenderer)
+	s.setMarkdownExtensions(DefaultMarkdownExtensions)
+	for _, ext := range []string{".adoc", ".asciidoc", ".asc"} {
+		s.renderers[ext] = asciidocRenderer
+	}
+	for _, ext := range []string{".rst", ".rest"} {
+		s.renderers[ext] = fixedRenderer(renderRst)
+	}
+	s.renderers[".org"] = orgRenderer
+	s.renderers[".geojson"] = fixedRenderer(s.renderMapFile)
+	s.renderers[".topojson"] = fixedRenderer(s.renderMapFile)
+	s.renderers[".stl"] = fixedRenderer(renderSTL)
+	s.renderers[".csv"] = fixedRenderer(renderCSV)
+	s.renderers[".tsv"] = fixedRenderer(renderCSV)
+}
+
+func (s *Server) setMarkdownExtensions(exts []string) {
+	for _, ext := range s.markdownExtensions {
+		delete(s.renderers, ext)
+	}
+	s.markdownExtensions = nil
+	for _, ext := range exts {
+		ext = normalizeExtension(ext)
+		s.markdownExtensions = append(s.markdownExtensions, ext)
+		s.renderers[ext] = fixedRenderer(s.renderMarkdown)
+		delete(s.customRenderers, ext)
+	}
+}
+
+// normalizeExtension turns "MD" and ".md" into ".md".
+func normalizeExtension(ext string) string {
+	return "." + strings.ToLower(strings.TrimPrefix(ext, "."))
+}
+
+// renderer returns the renderer for the extension of the file p in fsys,
+// the served directory, which the files it includes are read from.
+func (s *Server) renderer(p string, fsys http.FileSystem) (Renderer, bool) {
+	r, ok := s.renderers[strings.ToLower(path.Ext(p))]
+	if !ok {
+		return nil, false
+	}
+	return r(fsys), true
+}
+
+// IsMarkdown reports whether the file name has one of the markdown extensions.
+func (s *Server) IsMarkdown(name string) bool {
+	ext := strings.ToLower(path.Ext(name))
+	for _, e := range s.markdownExtensions {
+		if e == ext {
+			return true
+		}
+	}
+	return false
+}
+
+func (s *Server) renderMarkdown(content []byte, name string) ([]byte, string, error) {
+	return s.renderMarkdownStats(context.Background(), content, name, nil)
+}
+
+// renderMarkdownStats renders markdown like renderMarkdown, stopping its
+// diagram commands when ctx is done and collecting the steps of the render
+// in stats if it isn't nil.
+func (s *Server) renderMarkdownStats(ctx context.Context, content []byte, name string, stats *renderStats) ([]byte, string, error) {
+	if s.slides {
+		return s.renderSlides(content, name), extractTitle(content, path.Base(name)), nil
+	}
+	if s.commentMode {
+		return s.renderComment(content, name)
+	}
+	p := *s.parser
+	p.stats, p.ctx = stats, ctx
+	return p.MdToHTMLFile(content, name), extractTitle(content, path.Base(name)), nil
+}
+
+func (s *Server) renderMapFile(content []byte, name string) ([]byte, string, error) {
+	return s.parser.renderMapFile(content, name)
+}
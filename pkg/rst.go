@@ -0,0 +1,62 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"fmt"
+	"html"
+	"regexp"
+	"strings"
+
+	rst "github.com/hhatto/gorst"
+)
+
+var (
+	rstCodeRegex   = regexp.MustCompile(`(?s)<pre class="([^"]+)"><code>(.*?)</code></pre>`)
+	rstHeaderRegex = regexp.MustCompile(`(?s)<h1[^>]*>(.*?)</h1>`)
+	htmlTagRegex   = regexp.MustCompile(`<[^>]*>`)
+)
+
+// rstToHTML converts reStructuredText source to HTML.
+func rstToHTML(content []byte) (out []byte, err error) {
+	// the experimental parser may panic on input it doesn't support
+	defer func() {
+		if r := recover(); r != nil {
+			err = fmt.Errorf("failed to convert reStructuredText: %v", r)
+		}
+	}()
+
+	var buf bytes.Buffer
+	w := bufio.NewWriter(&buf)
+	rst.NewParser(nil).ReStructuredText(bytes.NewReader(content), rst.ToHTML(w))
+	w.Flush()
+	return highlightCodeBlocks(buf.Bytes()), nil
+}
+
+// highlightCodeBlocks highlights the code-block directives of the converted
+// document like fenced code in markdown.
+func highlightCodeBlocks(doc []byte) []byte {
+	return rstCodeRegex.ReplaceAllFunc(doc, func(block []byte) []byte {
+		m := rstCodeRegex.FindSubmatch(block)
+		code, ok := highlightCode(html.UnescapeString(string(m[2])), string(m[1]))
+		if !ok {
+			return block
+		}
+		return []byte(code)
+	})
+}
+
+// renderRst converts reStructuredText source to HTML, taking the title from
+// its first H1 heading.
+func renderRst(content []byte, name string) ([]byte, string, error) {
+	out, err := rstToHTML(content)
+	if err != nil {
+		return nil, "", err
+	}
+
+	var title string
+	if m := rstHeaderRegex.FindSubmatch(out); m != nil {
+		title = html.UnescapeString(strings.TrimSpace(htmlTagRegex.ReplaceAllString(string(m[1]), "")))
+	}
+	return out, title, nil
+}
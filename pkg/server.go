@@ -2,21 +2,23 @@ package pkg
 
 import (
 	"bytes"
+	"context"
+	"errors"
 	"fmt"
+	"html"
 	"io"
 	"io/fs"
-	"log"
+	"log/slog"
 	"net/http"
 	"net/url"
 	"os"
 	"path"
 	"path/filepath"
-	"regexp"
 	"sort"
 	"strings"
-	"text/template"
+	"sync"
+	"time"
 
-	"github.com/aarol/reload"
 	chroma_html "github.com/alecthomas/chroma/v2/formatters/html"
 	"github.com/alecthomas/chroma/v2/styles"
 	"github.com/chrishrb/go-grip/defaults"
@@ -26,105 +28,818 @@ type Server struct {
 	parser      *Parser
 	theme       string
 	boundingBox bool
-	host        string
+	hosts       []string
 	port        int
+	portScan    int
+	listenAddrs []string
 	browser     bool
+
+	tlsCert       string
+	tlsKey        string
+	tlsSelfSigned bool
+	http2         bool
+
+	readTimeout  time.Duration
+	writeTimeout time.Duration
+	idleTimeout  time.Duration
+
+	authUser  string
+	authPass  string
+	authToken string
+
+	corsOrigins []string
+	corsMethods []string
+
+	remote    *RemoteSource
+	clipboard *clipboardSource
+	gitRef    string
+	gitTree   *gitFS
+
+	commentMode bool
+	commentAPI  *commentRenderer
+
+	startPage string
+	// mounts are the directories served by ServeRoots, relative to the
+	// served directory
+	mounts    []string
+	anchor    string
+	gitInfo   bool
+	slides    bool
+	lightbox  bool
+	wordCount bool
+	// breadcrumbs shows the path of pages below the served directory
+	breadcrumbs bool
+	pager       bool
+	warmup      bool
+	qrCode      io.Writer
+
+	browserCmd string
+
+	rateLimit      *rateLimiter
+	maxRequestSize int64
+
+	reload          bool
+	reloadDebounce  time.Duration
+	reloadExclude   []string
+	reloadTransport string
+
+	typography Typography
+	lang       string
+	basePath   string
+
+	renderers          map[string]includingRenderer
+	customRenderers    map[string]bool
+	markdownExtensions []string
+
+	compress bool
+	encoding Encoding
+	hidden   bool
+	symlinks bool
+	includes bool
+	drafts   bool
+	vars     bool
+	varsFile string
+	prose    *ProseLint
+
+	cache  *renderCache
+	layout *layout
+
+	renderSlots    chan struct{}
+	renderTimeout  time.Duration
+	maxPreviewSize int64
+	strictOffline  bool
+	renderTimings  bool
+	renderCode     bool
+
+	root       string
+	parserOpts []ParserOption
+	middleware []func(http.Handler) http.Handler
+	accessLog  *slog.Logger
+	metrics    *metrics
+	imageProxy *imageProxy
+
+	mu         sync.Mutex
+	httpServer *http.Server
+	stops      []context.CancelFunc
 }
 
-func NewServer(host string, port int, theme string, boundingBox bool, browser bool, parser *Parser) *Server {
-	return &Server{
-		host:        host,
+const (
+	shutdownTimeout  = 5 * time.Second
+	defaultCacheSize = 64 << 20
+)
+
+func NewServer(hosts []string, port int, theme string, boundingBox bool, browser bool, parser *Parser, opts ...Option) *Server {
+	s := &Server{
+		hosts:       hosts,
 		port:        port,
 		theme:       theme,
 		boundingBox: boundingBox,
 		browser:     browser,
 		parser:      parser,
+		cache:       newRenderCache(defaultCacheSize),
+		compress:    true,
+		reload:      true,
+		symlinks:    true,
+		http2:       true,
+
+		readTimeout:    DefaultReadTimeout,
+		idleTimeout:    DefaultIdleTimeout,
+		maxPreviewSize: DefaultMaxPreviewSize,
+	}
+	s.registerDefaultRenderers()
+	for _, opt := range opts {
+		opt(s)
+	}
+	if !validTheme(s.theme) {
+		slog.Warn("unknown theme, defaulting to auto", "theme", s.theme)
+		s.theme = "auto"
 	}
+	s.useImageProxy()
+	return s
 }
 
-func (s *Server) Serve(file string) error {
-	directory := path.Dir(file)
-	filename := path.Base(file)
+// New returns a server for embedding go-grip into other programs, configured
+// by options only. It previews the current directory in the auto theme unless
+// configured otherwise, e.g.
+//
+//	srv := grip.New(grip.WithTheme("dark"), grip.WithRoot("docs"))
+//	mux.Handle("/", srv.Handler())
+func New(opts ...Option) *Server {
+	s := NewServer([]string{"localhost"}, 6419, "auto", true, false, nil, opts...)
+	if s.parser == nil {
+		s.parser = NewParser(s.theme, s.parserOpts...)
+		s.useImageProxy()
+	}
+	return s
+}
 
-	reload := reload.New(directory)
-	reload.DebugLog = log.New(io.Discard, "", 0)
+// useImageProxy points remote images rendered by the parser to the image
+// proxy, if it is enabled.
+func (s *Server) useImageProxy() {
+	if s.imageProxy != nil && s.parser != nil {
+		s.parser.Use(WithHTMLFilter(s.imageProxy.rewrite))
+	}
+}
 
-	validThemes := map[string]bool{"light": true, "dark": true, "auto": true}
+// Handler returns the live preview of the root directory, see WithRoot, for
+// mounting at the root path of another server. Its file watchers run until
+// Close is called.
+func (s *Server) Handler() http.Handler {
+	ctx, cancel := context.WithCancel(context.Background())
+	s.mu.Lock()
+	s.stops = append(s.stops, cancel)
+	s.mu.Unlock()
+
+	root := s.root
+	if root == "" {
+		root = "."
+	}
+	handler, _ := s.handler(ctx, root, "", "")
+	return handler
+}
 
-	if !validThemes[s.theme] {
-		log.Println("Warning: Unknown theme ", s.theme, ", defaulting to 'auto'")
-		s.theme = "auto"
+// Use adds middleware around the preview, e.g. for logging or tracing. The
+// middleware added first sees requests first, all of it before the built-in
+// authentication, compression and reload handling. Use must be called before
+// Serve or Handler.
+func (s *Server) Use(middleware ...func(http.Handler) http.Handler) {
+	s.middleware = append(s.middleware, middleware...)
+}
+
+// Close stops the file watchers of all handlers returned by Handler.
+func (s *Server) Close() error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	for _, stop := range s.stops {
+		stop()
 	}
+	s.stops = nil
+	return nil
+}
 
-	dir := http.Dir(directory)
-	chttp := http.NewServeMux()
-	chttp.Handle("/static/", http.FileServer(http.FS(defaults.StaticFiles)))
-	chttp.Handle("/", http.FileServer(dir))
+// Render renders markdown to HTML without the page layout.
+func (s *Server) Render(md []byte) ([]byte, error) {
+	return s.limitRender(func(ctx context.Context) ([]byte, error) {
+		p := *s.parser
+		p.ctx = ctx
+		return p.MdToHTML(md), nil
+	})
+}
+
+// Render renders markdown to HTML without the page layout, with the default
+// options.
+func Render(md []byte) ([]byte, error) {
+	return New().Render(md)
+}
+
+// Serve renders and serves the markdown file and its directory until ctx is
+// cancelled or Shutdown is called.
+func (s *Server) Serve(ctx context.Context, file string) error {
+	directory, filename := splitFile(file)
+
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	if file == "-" {
+		directory = "."
+		filename = stdinPage
+	}
+	if s.remote != nil {
+		directory = "."
+		filename = s.remote.Name()
+	}
+	if s.clipboard != nil {
+		if err := s.clipboard.init(); err != nil {
+			return err
+		}
+		directory = "."
+		file = clipboardPage
+		filename = clipboardPage
+	}
+	return s.serve(ctx, directory, file, filename)
+}
+
+// serve serves directory, opening the page filename of the served file, or
+// the README of the directory if file is empty.
+func (s *Server) serve(ctx context.Context, directory string, file string, filename string) error {
+	if _, err := s.files(directory); err != nil {
+		return err
+	}
+	if s.imageProxy != nil {
+		if _, err := s.imageProxy.signingKey(); err != nil {
+			return err
+		}
+	}
+	handler, dir := s.handler(ctx, directory, file, filename)
+
+	listeners, err := s.listen()
+	if err != nil {
+		return err
+	}
+
+	var addrs []string
+	for _, l := range listeners {
+		if l.Addr().Network() == "unix" {
+			slog.Info("starting server", "socket", l.Addr().String())
+			continue
+		}
+		addrs = append(addrs, l.urls()...)
+	}
+
+	page := filename
+	if file == "" {
+		page = ""
+		for _, readme := range []string{"README.md", "README.adoc", "README.rst", "README.org"} {
+			f, err := dir.Open(readme)
+			if err == nil {
+				f.Close()
+				page = readme
+				break
+			}
+		}
+	}
+	var query string
+	if s.startPage != "" {
+		page, query, _ = strings.Cut(s.startPage, "?")
+	}
+	if s.authToken != "" {
+		if query != "" {
+			query += "&"
+		}
+		query += "token=" + url.QueryEscape(s.authToken)
+	}
+	var fragment string
+	if s.anchor != "" {
+		if file != "-" && s.remote == nil && s.clipboard == nil {
+			s.anchor = s.resolveAnchor(dir, "/"+page, s.anchor)
+		}
+		fragment = "#" + url.PathEscape(s.anchor)
+	}
+	for i := range addrs {
+		addrs[i], _ = url.JoinPath(addrs[i], s.basePath, page)
+		if query != "" {
+			addrs[i] += "?" + query
+		}
+		addrs[i] += fragment
+		slog.Info("starting server", "url", addrs[i])
+	}
+	if s.qrCode != nil {
+		if u := lanURL(addrs); u != "" {
+			if err := writeQRCode(s.qrCode, u); err != nil {
+				slog.Warn("failed to print QR code", "url", u, "err", err)
+			}
+		}
+	}
+
+	absFile := file
+	if file != "-" && s.remote == nil && s.clipboard == nil {
+		absFile, _ = filepath.Abs(file)
+	}
+	unregister, err := registerInstance(Instance{
+		ID:      os.Getpid(),
+		Port:    s.port,
+		File:    absFile,
+		URLs:    addrs,
+		Started: time.Now(),
+	})
+	if err != nil {
+		slog.Warn("failed to register instance", "err", err)
+	} else {
+		defer unregister()
+	}
+
+	if s.browser && len(addrs) > 0 {
+		err := s.open(addrs[0])
+		if err != nil {
+			slog.Error("failed to open browser", "err", err)
+		}
+	}
+
+	httpServer := s.newHTTPServer(handler)
+	s.mu.Lock()
+	s.httpServer = httpServer
+	s.mu.Unlock()
+
+	errs := make(chan error, len(listeners))
+	for _, l := range listeners {
+		go func(l listener) {
+			errs <- httpServer.Serve(l)
+		}(l)
+	}
+
+	select {
+	case err := <-errs:
+		httpServer.Close()
+		if errors.Is(err, http.ErrServerClosed) {
+			return nil
+		}
+		return err
+	case <-ctx.Done():
+		shutdownCtx, cancelShutdown := context.WithTimeout(context.Background(), shutdownTimeout)
+		defer cancelShutdown()
+		return s.Shutdown(shutdownCtx)
+	}
+}
+
+// site is the state of a handler serving one directory. It is kept apart
+// from the Server, which may serve several directories with handlers of
+// their own.
+type site struct {
+	*Server
+	directory string
+	// name is the name of the directory, the root of breadcrumbs
+	name string
+	// dirs are the absolute paths of the directory, as given and with
+	// symlinks resolved, which error pages don't show
+	dirs []string
+	// watchDeps watches the files pages depend on, nil without live reload
+	watchDeps func(names []string)
+	mkdocs    mkdocsNav
+	pages     pageOrder
+}
+
+func (s *Server) newSite(directory string) *site {
+	st := &site{Server: s, directory: directory}
+	if abs, err := filepath.Abs(directory); err == nil {
+		st.name = filepath.Base(abs)
+		st.dirs = []string{abs}
+		if real, err := filepath.EvalSymlinks(abs); err == nil && real != abs {
+			st.dirs = append(st.dirs, real)
+		}
+	}
+	st.mkdocs.find(directory)
+	return st
+}
 
-	// Regex for markdown
-	regex := regexp.MustCompile(`(?i)\.md$`)
+// handler returns the handler serving the directory, with file being the
+// argument of Serve. Its file watchers stop once ctx is cancelled.
+func (s *Server) handler(ctx context.Context, directory string, file string, filename string) (http.Handler, http.FileSystem) {
+	st := s.newSite(directory)
+	reloader := newReloader(directory)
+	for _, mnt := range s.mounts {
+		reloader.roots = append(reloader.roots, filepath.Join(reloader.directory, filepath.FromSlash(mnt)))
+	}
+	if s.reloadDebounce > 0 {
+		reloader.debounce = s.reloadDebounce
+	}
+	reloader.exclude = s.reloadExcludes()
+	reloader.onReload = func() {
+		s.metrics.reloaded()
+		st.pages.invalidate()
+		st.mkdocs.invalidate()
+	}
+	if s.reload {
+		go reloader.run(ctx)
+		st.watchDeps = reloader.watchDependencies
+		if s.layout != nil {
+			for _, file := range s.layout.files() {
+				reloader.watchFile(file)
+			}
+		}
+		if s.vars && s.varsFile != "" {
+			reloader.watchFile(s.varsFile)
+		}
+		if s.parser.bibliography != nil {
+			reloader.watchFile(s.parser.bibliography.file)
+		}
+	}
+
+	hub := newSyncHub(directory)
+	hub.readLimit = s.requestSizeLimit()
+	reloader.readLimit = s.requestSizeLimit()
+	go func() {
+		<-ctx.Done()
+		hub.close()
+	}()
+
+	var stdin stdinSource
+	if file == "-" {
+		go stdin.read(os.Stdin, reloader.scheduleReload)
+	}
+	if s.clipboard != nil {
+		go s.clipboard.watch(ctx, reloader.scheduleReload)
+	}
+
+	files, err := s.files(directory)
+	if err != nil {
+		slog.Error("failed to read git ref", "ref", s.gitRef, "err", err)
+		files = http.Dir(directory)
+	}
+	dir := rootFS{root: files, hidden: s.hidden}
+	chttp := http.NewServeMux()
+	chttp.Handle("/static/", staticHandler())
+	chttp.Handle("/", contentHandler(dir))
+	chttp.HandleFunc("/favicon.ico", func(w http.ResponseWriter, r *http.Request) {
+		// a favicon of the served directory takes precedence
+		if f, err := dir.Open(r.URL.Path); err == nil {
+			f.Close()
+			http.FileServer(dir).ServeHTTP(w, r)
+			return
+		}
+		http.ServeFileFS(w, r, defaults.StaticFiles, "static/images/favicon.ico")
+	})
 
 	// Serve website with rendered markdown
-	http.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+	mux := http.NewServeMux()
+	mux.Handle("/fragment/", s.fragmentHandler(dir))
+	mux.Handle("/api/outline", s.outlineHandler(dir))
+	mux.Handle("/diff", st.diffHandler(directory, dir))
+	if s.gitInfo {
+		mux.Handle("/api/git", s.gitInfoHandler(directory))
+	}
+	if s.prose != nil {
+		mux.Handle("/api/prose", s.proseHandler(directory, dir))
+	}
+	mux.Handle(syncEndpoint, hub)
+	if s.metrics != nil {
+		mux.Handle("/metrics", s.metrics)
+	}
+	if s.imageProxy != nil {
+		mux.Handle(imageProxyPath, s.imageProxy)
+	}
+	mux.HandleFunc("/", func(w http.ResponseWriter, r *http.Request) {
+		if len(s.mounts) > 0 && r.URL.Path == "/" && !sourceRequested(r) {
+			st.serveMountIndex(w, dir)
+			return
+		}
 		f, err := dir.Open(r.URL.Path)
 		if err == nil {
 			defer f.Close()
 		}
 
-		if err == nil && regex.MatchString(r.URL.Path) {
-			bytes, err := readToString(dir, r.URL.Path)
-			if err != nil {
-				log.Fatal(err)
+		if render, ok := s.pageRenderer(r, dir); err == nil && ok {
+			if sourceRequested(r) {
+				s.serveSourceFile(w, r, f)
 				return
 			}
-			htmlContent := s.parser.MdToHTML(bytes)
-
-			// Serve
-			err = serveTemplate(w, htmlStruct{
-				Content:      string(htmlContent),
-				Theme:        s.theme,
-				BoundingBox:  s.boundingBox,
-				CssCodeLight: getCssCode("github"),
-				CssCodeDark:  getCssCode("github-dark"),
-			})
+			reason, status, err := s.previewError(r.URL.Path, f)
+			switch {
+			case err != nil:
+				http.Error(w, err.Error(), http.StatusInternalServerError)
+			case reason != "":
+				s.servePreviewError(w, r.URL.Path, reason, status)
+			default:
+				st.serveRenderedFile(w, r, dir, f, render)
+			}
+		} else if file == "-" && r.URL.Path == "/"+stdinPage {
+			if sourceRequested(r) {
+				serveSource(w, r, s.encoding.Decode(stdin.bytes()), stdinPage, time.Time{})
+			} else {
+				content, _ := s.embedFiles(dir, stdinPage, stdin.bytes())
+				st.serveMarkdown(w, content, stdinPage)
+			}
+		} else if s.clipboard != nil && r.URL.Path == "/"+clipboardPage {
+			if sourceRequested(r) {
+				serveSource(w, r, s.clipboard.bytes(), clipboardPage, time.Time{})
+			} else {
+				st.serveMarkdown(w, s.clipboard.bytes(), clipboardPage)
+			}
+		} else if s.remote != nil && r.URL.Path == "/"+filename {
+			content, err := s.remote.Fetch()
 			if err != nil {
-				log.Fatal(err)
+				http.Error(w, err.Error(), http.StatusBadGateway)
 				return
 			}
+			if sourceRequested(r) {
+				serveSource(w, r, content, s.remote.Name(), time.Time{})
+			} else {
+				st.serveMarkdown(w, content, s.remote.Name())
+			}
+		} else if wantsErrorPage(r, err) {
+			s.serveNotFound(w, dir, r.URL.Path)
 		} else {
 			chttp.ServeHTTP(w, r)
 		}
 	})
 
-	addr := fmt.Sprintf("http://%s:%d/", s.host, s.port)
-	if file == "" {
-		readme := "README.md"
-		f, err := dir.Open(readme)
-		if err == nil {
-			defer f.Close()
+	if s.warmup && file != "-" && s.remote == nil && s.clipboard == nil {
+		go st.warmUp(ctx, dir)
+	}
+
+	var handler http.Handler = noCache(mux)
+	if s.reload {
+		handler = reloader.handle(mux)
+	}
+	if s.compress {
+		handler = compressHandler(handler)
+	}
+	handler = s.authHandler(handler)
+	if s.metrics != nil {
+		// health checks of proxies don't know the credentials
+		authenticated := handler
+		handler = http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if r.URL.Path == "/healthz" {
+				healthz(w, r)
+				return
+			}
+			authenticated.ServeHTTP(w, r)
+		})
+	}
+	for i := len(s.middleware) - 1; i >= 0; i-- {
+		handler = s.middleware[i](handler)
+	}
+	handler = s.corsHandler(handler)
+	handler = s.limitRequests(handler)
+	handler = s.stripBasePath(handler)
+	if s.accessLog != nil {
+		handler = accessLogHandler(s.accessLog, handler)
+	}
+	return handler, dir
+}
+
+// mediaTypes are the content types of image, video and audio formats the
+// mime package may not know, depending on the system.
+var mediaTypes = map[string]string{
+	".apng": "image/apng",
+	".avif": "image/avif",
+	".bmp":  "image/bmp",
+	".ico":  "image/x-icon",
+	".jxl":  "image/jxl",
+	".svg":  "image/svg+xml",
+	".tif":  "image/tiff",
+	".tiff": "image/tiff",
+	".webp": "image/webp",
+	".mp4":  "video/mp4",
+	".m4v":  "video/mp4",
+	".mov":  "video/quicktime",
+	".webm": "video/webm",
+	".ogv":  "video/ogg",
+	".mp3":  "audio/mpeg",
+	".m4a":  "audio/mp4",
+	".wav":  "audio/wav",
+	".ogg":  "audio/ogg",
+	".oga":  "audio/ogg",
+	".opus": "audio/ogg",
+	".flac": "audio/flac",
+}
+
+// contentHandler serves the files of the served directory, like images and
+// videos embedded in pages, with support for range requests to seek in
+// videos. Browsers revalidate the files on every load, so files changed on
+// disk show up when the page reloads.
+func contentHandler(dir http.FileSystem) http.Handler {
+	files := http.FileServer(dir)
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Cache-Control", "no-cache")
+		if t, ok := mediaTypes[strings.ToLower(path.Ext(r.URL.Path))]; ok {
+			w.Header().Set("Content-Type", t)
 		}
-		if err == nil {
-			addr, _ = url.JoinPath(addr, readme)
+		files.ServeHTTP(w, r)
+	})
+}
+
+// files returns the files served from directory, the working tree or the
+// tree of the git ref set with WithGitRef.
+func (s *Server) files(directory string) (http.FileSystem, error) {
+	if s.gitRef == "" {
+		if len(s.mounts) > 0 {
+			return mountFS{root: newSymlinkFS(directory, s.symlinks), mounts: s.mounts}, nil
 		}
-	} else {
-		addr, _ = url.JoinPath(addr, filename)
+		return newSymlinkFS(directory, s.symlinks), nil
 	}
+	if s.gitTree == nil || s.gitTree.dir != directory {
+		tree, err := newGitFS(directory, s.gitRef)
+		if err != nil {
+			return nil, err
+		}
+		slog.Info("serving git ref", "ref", s.gitRef, "commit", tree.commit[:min(len(tree.commit), 12)])
+		s.gitTree = tree
+	}
+	return s.gitTree, nil
+}
 
-	fmt.Printf("Starting server: %s\n", addr)
+// Shutdown gracefully stops a running server, waiting for active requests
+// to finish until ctx expires.
+func (s *Server) Shutdown(ctx context.Context) error {
+	s.mu.Lock()
+	httpServer := s.httpServer
+	s.mu.Unlock()
 
-	if s.browser {
-		err := Open(addr)
+	if httpServer == nil {
+		return nil
+	}
+	return httpServer.Shutdown(ctx)
+}
+
+// renderPage renders a file into the layout template, with the navigation
+// around it, and returns it with what the metadata headers tell about it.
+// The page title is the one returned by render, or name if there is none.
+func (s *Server) renderPage(render Renderer, content []byte, name string, nav pageNav) (page, pageMeta, error) {
+	stats := &renderStats{headings: -1}
+	start := time.Now()
+
+	var title string
+	htmlContent, err := s.limitRender(func(ctx context.Context) ([]byte, error) {
+		out, t, err := s.timedRenderer(ctx, render, name, stats)(content, name)
+		title = t
+		return out, err
+	})
+	if err != nil {
+		return nil, pageMeta{}, err
+	}
+	if title == "" {
+		base := path.Base(name)
+		title = strings.TrimSuffix(base, path.Ext(base))
+	}
+
+	rendered := time.Now()
+	p, err := s.layoutPage(htmlContent, title, nav)
+	if err != nil {
+		return nil, pageMeta{}, err
+	}
+	if s.renderTimings {
+		logRenderTimings(name, stats, rendered.Sub(start), time.Since(rendered), p.size(), time.Since(start))
+	}
+	meta := pageMeta{renderTime: time.Since(start), headings: stats.headings}
+	if meta.headings < 0 {
+		// not rendered by the parser, like slides
+		meta.headings = s.headingCount(name, content)
+	}
+	return p, meta, nil
+}
+
+// layoutPage puts rendered HTML into the layout template of served pages.
+// The layout is executed around a marker instead of the content, so large
+// documents are streamed to the client without copies.
+func (s *Server) layoutPage(htmlContent []byte, title string, nav pageNav) (page, error) {
+	html := s.htmlPage(contentMarker, title)
+	if nav.Theme != "" {
+		html.Theme = nav.Theme
+	}
+	html.BoundingBox = s.boundingBox && !s.slides
+	html.Slides = s.slides
+	html.Sidebar = nav.Sidebar
+	html.Pager = nav.Pager
+	html.Stats = nav.Stats
+	html.Breadcrumbs = nav.Breadcrumbs
+	html.TOC = nav.TOC
+	html.Reload = s.reload
+	html.Transport = s.reloadTransport
+	html.Source = true
+	html.GitInfo = s.gitInfo
+	html.Prose = s.prose != nil && nav.Revision != ""
+	html.Revision = nav.Revision
+	var buf bytes.Buffer
+	if err := s.executeTemplate(&buf, html); err != nil {
+		return nil, err
+	}
+	if s.strictOffline {
+		if err := checkOffline(htmlContent); err != nil {
+			return nil, err
+		}
+	}
+	return splitLayout(buf.Bytes(), s.prefixURLs(htmlContent)), nil
+}
+
+// serveMarkdown renders markdown source that has no backing file.
+func (s *site) serveMarkdown(w http.ResponseWriter, content []byte, name string) {
+	page, _, err := s.renderPage(s.renderMarkdown, content, name, pageNav{Revision: pageRevision(content)})
+	if err != nil {
+		s.serveRenderError(w, err)
+		return
+	}
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	page.WriteTo(w)
+}
+
+// serveRenderedFile renders a file into a page with render, using the render
+// cache and answering conditional requests with 304 Not Modified. Pages count
+// as modified when a file in dir they embed changes. HEAD requests missing the
+// cache are answered with the metadata headers without rendering the page.
+func (s *site) serveRenderedFile(w http.ResponseWriter, r *http.Request, dir http.FileSystem, f http.File, render Renderer) {
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	if _, err := s.currentLayout(); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	var content []byte
+	var embedded time.Time
+	var files []string
+	if !selfLoadingFormats[strings.ToLower(path.Ext(r.URL.Path))] {
+		content, err = io.ReadAll(f)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+		content, embedded, files = s.embed(dir, r.URL.Path, content)
+	}
+	modTime := info.ModTime()
+	if embedded.After(modTime) {
+		modTime = embedded
+	}
+
+	var nav pageNav
+	if s.IsMarkdown(r.URL.Path) {
+		if b, navMod := s.readBook(dir); b != nil {
+			nav = b.nav(r.URL.Path)
+			if navMod.After(modTime) {
+				modTime = navMod
+			}
+		}
+	}
+
+	query := parsePageQuery(r)
+	nav.Theme = query.theme
+	if content != nil {
+		nav.Revision = pageRevision(content)
+	}
+	// handlers of other directories share the cache
+	key := s.directory + "\x00" + query.cacheKey(r.URL.Path) + nav.cacheKey()
+	etag := sourceETag(key, modTime)
+	page, meta, ok := s.cache.get(key, modTime)
+	s.metrics.cacheLookup(ok)
+	if !ok && r.Method == http.MethodHead {
+		setMetaHeaders(w.Header(), modTime, pageMeta{headings: s.headingCount(r.URL.Path, content)})
+		w.Header().Set("Content-Type", "text/html; charset=utf-8")
+		serveHead(w, r, etag, modTime)
+		return
+	}
+	if !ok {
+		if s.breadcrumbs {
+			nav.Breadcrumbs = breadcrumbs(s.name, r.URL.Path)
+		}
+		if s.wordCount && s.IsMarkdown(r.URL.Path) {
+			nav.Stats = s.pageStats(content, info.ModTime())
+		}
+		if query.toc && s.IsMarkdown(r.URL.Path) && !s.commentMode {
+			nav.TOC = s.parser.tableOfContents(content)
+		}
+		page, meta, err = s.renderPage(render, content, r.URL.Path, nav)
 		if err != nil {
-			fmt.Println("Error opening browser:", err)
+			s.serveRenderError(w, err)
+			return
+		}
+		s.cache.put(key, modTime, page, meta)
+		if s.watchDeps != nil {
+			s.watchDeps(append(append(files, r.URL.Path), pageDependencies(r.URL.Path, page...)...))
 		}
 	}
 
-	handler := reload.Handle(http.DefaultServeMux)
-	return http.ListenAndServe(fmt.Sprintf(":%d", s.port), handler)
+	setMetaHeaders(w.Header(), modTime, meta)
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	w.Header().Set("ETag", etag)
+	http.ServeContent(w, r, "", modTime, page.reader())
+}
+
+// WriteHTML renders markdown source read from stdin as a complete HTML page
+// to w. Like files, it is converted to UTF-8 and its include directives and
+// variables are expanded, relative to the working directory.
+func (s *Server) WriteHTML(w io.Writer, content []byte) error {
+	content, _ = s.embedFiles(http.Dir("."), stdinPage, content)
+	htmlContent := s.parser.MdToHTML(content)
+
+	return s.executeTemplate(w, s.htmlPage(string(htmlContent), extractTitle(content, "")))
 }
 
 func (s *Server) GenerateStaticSite(file string, outputDir string) error {
-	fmt.Println("Warning: GenerateStaticSite is deprecated. Use GenerateSingleFile or GenerateDirectoryFiles instead.")
+	slog.Warn("GenerateStaticSite is deprecated, use GenerateSingleFile or GenerateDirectoryFiles instead")
 
 	absFilePath, err := filepath.Abs(file)
 	if err != nil {
@@ -140,7 +855,7 @@ func (s *Server) GenerateStaticSite(file string, outputDir string) error {
 		return fmt.Errorf("failed to create output directory: %v", err)
 	}
 
-	staticDir := path.Join(absOutputDir, "static")
+	staticDir := filepath.Join(absOutputDir, "static")
 	if err := os.MkdirAll(staticDir, 0755); err != nil {
 		return fmt.Errorf("failed to create static directory: %v", err)
 	}
@@ -149,7 +864,7 @@ func (s *Server) GenerateStaticSite(file string, outputDir string) error {
 		return fmt.Errorf("failed to copy static files: %v", err)
 	}
 
-	directory := path.Dir(absFilePath)
+	directory := filepath.Dir(absFilePath)
 	if file == "" {
 		directory = "."
 	}
@@ -161,48 +876,41 @@ func (s *Server) GenerateStaticSite(file string, outputDir string) error {
 
 	var indexFile string
 	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
-			content, err := os.ReadFile(path.Join(directory, entry.Name()))
+		if !entry.IsDir() && s.IsMarkdown(entry.Name()) {
+			content, err := os.ReadFile(filepath.Join(directory, entry.Name()))
 			if err != nil {
 				return fmt.Errorf("failed to read file %s: %v", entry.Name(), err)
 			}
 
 			htmlContent := s.parser.MdToHTML(content)
 
-			htmlFile := strings.TrimSuffix(entry.Name(), ".md") + ".html"
+			htmlFile := strings.TrimSuffix(entry.Name(), filepath.Ext(entry.Name())) + ".html"
 			if entry.Name() == "README.md" {
 				htmlFile = "index.html"
 				indexFile = htmlFile
 			}
 
-			html := htmlStruct{
-				Content:      string(htmlContent),
-				Theme:        s.theme,
-				BoundingBox:  s.boundingBox,
-				CssCodeLight: getCssCode("github"),
-				CssCodeDark:  getCssCode("github-dark"),
-			}
+			html := s.htmlPage(string(htmlContent), extractTitle(content, entry.Name()))
 
-			outputFilePath := path.Join(absOutputDir, htmlFile)
-			if err := writeHTMLFile(outputFilePath, html); err != nil {
+			outputFilePath := filepath.Join(absOutputDir, htmlFile)
+			if err := s.writeHTMLFile(outputFilePath, html); err != nil {
 				return fmt.Errorf("failed to write HTML file %s: %v", htmlFile, err)
 			}
 
-			fmt.Printf("Generated HTML file: %s\n", outputFilePath)
+			slog.Info("generated HTML file", "path", outputFilePath)
 		}
 	}
 
-	fmt.Printf("Output directory: %s\n", absOutputDir)
+	slog.Info("output directory", "path", absOutputDir)
 
 	if s.browser {
-		indexPath := path.Join(absOutputDir, indexFile)
+		indexPath := filepath.Join(absOutputDir, indexFile)
 		if indexFile == "" {
-			indexPath = path.Join(absOutputDir, "index.html")
+			indexPath = filepath.Join(absOutputDir, "index.html")
 		}
-		fileURL := "file://" + indexPath
-		err := Open(fileURL)
+		err := s.open(fileURL(indexPath))
 		if err != nil {
-			fmt.Println("Error opening browser:", err)
+			slog.Error("failed to open browser", "err", err)
 		}
 	}
 
@@ -237,129 +945,110 @@ func copyStaticFiles(staticDir string) error {
 
 		return nil
 	})
-
-	return err
-}
-
-func writeHTMLFile(path string, html htmlStruct) error {
-	tmpl, err := template.ParseFS(defaults.Templates, "templates/layout.html")
 	if err != nil {
-		return fmt.Errorf("failed to parse template: %v", err)
+		return err
 	}
 
+	for name, css := range syntaxStylesheets() {
+		outputPath := filepath.Join(staticDir, name)
+		if err := os.WriteFile(outputPath, css, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %v", outputPath, err)
+		}
+	}
+	// the pages link the fingerprinted copies
+	_, fingerprinted := assets()
+	for p, a := range fingerprinted {
+		outputPath := filepath.Join(staticDir, filepath.FromSlash(strings.TrimPrefix(p, "static/")))
+		if err := os.WriteFile(outputPath, a.content, 0644); err != nil {
+			return fmt.Errorf("failed to write file %s: %v", outputPath, err)
+		}
+	}
+	return nil
+}
+
+func (s *Server) writeHTMLFile(path string, html htmlStruct) error {
 	file, err := os.Create(path)
 	if err != nil {
 		return fmt.Errorf("failed to create file: %v", err)
 	}
 	defer file.Close()
 
-	if err := tmpl.Execute(file, html); err != nil {
+	if err := s.executeTemplate(file, html); err != nil {
 		return fmt.Errorf("failed to execute template: %v", err)
 	}
 
 	return nil
 }
 
-func readToString(dir http.Dir, filename string) ([]byte, error) {
-	f, err := dir.Open(filename)
-	if err != nil {
-		return nil, err
-	}
-	defer f.Close()
-
-	var buf bytes.Buffer
-	_, err = buf.ReadFrom(f)
-	if err != nil {
-		return nil, err
+// htmlPage returns the htmlStruct of a page with content and title, with the
+// settings of the server all pages share.
+func (s *Server) htmlPage(content string, title string) htmlStruct {
+	return htmlStruct{
+		Content:      content,
+		Title:        title,
+		Theme:        s.theme,
+		BoundingBox:  s.boundingBox,
+		CssCodeLight: getCssCode("github"),
+		CssCodeDark:  getCssCode("github-dark"),
+		Lightbox:     s.lightbox,
+		Typography:   s.typography,
 	}
-	return buf.Bytes(), nil
 }
 
 type htmlStruct struct {
 	Content      string
+	Title        string
 	Theme        string
 	BoundingBox  bool
 	CssCodeLight string
 	CssCodeDark  string
+	Reload       bool
+	Transport    string
+	Source       bool
+	GitInfo      bool
+	Prose        bool
+	Slides       bool
+	Lightbox     bool
+	Sidebar      string
+	Pager        string
+	Stats        string
+	Breadcrumbs  string
+	TOC          string
+	Typography   Typography
+	Lang         string
+	Messages     string
+	BasePath     string
+	Revision     string
 }
 
-func serveTemplate(w http.ResponseWriter, html htmlStruct) error {
-	w.Header().Set("Content-Type", "text/html")
-	tmpl, err := template.ParseFS(defaults.Templates, "templates/layout.html")
-	if err != nil {
-		return err
-	}
-	err = tmpl.Execute(w, html)
-	return err
-}
+// cssCodes caches the stylesheets of getCssCode by style name.
+var cssCodes sync.Map
 
 func getCssCode(style string) string {
+	if css, ok := cssCodes.Load(style); ok {
+		return css.(string)
+	}
 	buf := new(strings.Builder)
 	formatter := chroma_html.New(chroma_html.WithClasses(true))
 	s := styles.Get(style)
 	_ = formatter.WriteCSS(buf, s)
+	cssCodes.Store(style, buf.String())
 	return buf.String()
 }
 
 func (s *Server) GenerateSingleFile(filePath string, outputDir string) error {
-	absOutputDir, err := filepath.Abs(outputDir)
+	e, err := s.newSiteExport(filepath.Dir(filePath), outputDir, filepath.Base(filePath))
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
-	}
-
-	if err := os.MkdirAll(absOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
-	}
-
-	staticDir := path.Join(absOutputDir, "static")
-	if err := os.MkdirAll(staticDir, 0755); err != nil {
-		return fmt.Errorf("failed to create static directory: %v", err)
-	}
-
-	if err := copyStaticFiles(staticDir); err != nil {
-		return fmt.Errorf("failed to copy static files: %v", err)
-	}
-
-	absFilePath, err := filepath.Abs(filePath)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
-	}
-
-	content, err := os.ReadFile(absFilePath)
-	if err != nil {
-		return fmt.Errorf("failed to read file %s: %v", absFilePath, err)
-	}
-
-	htmlContent := s.parser.MdToHTML(content)
-
-	baseFileName := filepath.Base(absFilePath)
-	htmlFile := strings.TrimSuffix(baseFileName, ".md") + ".html"
-
-	if baseFileName == "README.md" {
-		htmlFile = "index.html"
-	}
-
-	outputFilePath := path.Join(absOutputDir, htmlFile)
-
-	html := htmlStruct{
-		Content:      string(htmlContent),
-		Theme:        s.theme,
-		BoundingBox:  s.boundingBox,
-		CssCodeLight: getCssCode("github"),
-		CssCodeDark:  getCssCode("github-dark"),
+		return err
 	}
-
-	if err := writeHTMLFile(outputFilePath, html); err != nil {
-		return fmt.Errorf("failed to write HTML file %s: %v", htmlFile, err)
+	if err := e.exportAll(); err != nil {
+		return err
 	}
 
-	fmt.Printf("Generated HTML file: %s\n", outputFilePath)
-
 	if s.browser {
-		fileURL := "file://" + outputFilePath
-		err := Open(fileURL)
+		err := s.open(fileURL(filepath.Join(e.output, exportFileName(e.single))))
 		if err != nil {
-			fmt.Println("Error opening browser:", err)
+			slog.Error("failed to open browser", "err", err)
 		}
 	}
 
@@ -367,148 +1056,103 @@ func (s *Server) GenerateSingleFile(filePath string, outputDir string) error {
 }
 
 func (s *Server) GenerateDirectoryFiles(dirPath string, outputDir string) error {
-	absDirPath, err := filepath.Abs(dirPath)
+	e, err := s.newSiteExport(dirPath, outputDir, "")
 	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
-	}
-
-	absOutputDir, err := filepath.Abs(outputDir)
-	if err != nil {
-		return fmt.Errorf("failed to get absolute path: %v", err)
-	}
-
-	if err := os.MkdirAll(absOutputDir, 0755); err != nil {
-		return fmt.Errorf("failed to create output directory: %v", err)
-	}
-
-	staticDir := path.Join(absOutputDir, "static")
-	if err := os.MkdirAll(staticDir, 0755); err != nil {
-		return fmt.Errorf("failed to create static directory: %v", err)
-	}
-
-	if err := copyStaticFiles(staticDir); err != nil {
-		return fmt.Errorf("failed to copy static files: %v", err)
-	}
-
-	entries, err := os.ReadDir(absDirPath)
-	if err != nil {
-		return fmt.Errorf("failed to read directory: %v", err)
-	}
-
-	foundMarkdown := false
-
-	var indexFile string
-	generatedFiles := make(map[string]string) // filename -> title
-
-	for _, entry := range entries {
-		if !entry.IsDir() && strings.HasSuffix(entry.Name(), ".md") {
-			foundMarkdown = true
-
-			mdFilePath := path.Join(absDirPath, entry.Name())
-			content, err := os.ReadFile(mdFilePath)
-			if err != nil {
-				return fmt.Errorf("failed to read file %s: %v", mdFilePath, err)
-			}
-
-			title := extractTitle(content, entry.Name())
-
-			htmlContent := s.parser.MdToHTML(content)
-
-			htmlFile := strings.TrimSuffix(entry.Name(), ".md") + ".html"
-
-			if entry.Name() == "README.md" {
-				htmlFile = "index.html"
-				indexFile = htmlFile
-			}
-
-			outputFilePath := path.Join(absOutputDir, htmlFile)
-
-			generatedFiles[htmlFile] = title
-
-			html := htmlStruct{
-				Content:      string(htmlContent),
-				Theme:        s.theme,
-				BoundingBox:  s.boundingBox,
-				CssCodeLight: getCssCode("github"),
-				CssCodeDark:  getCssCode("github-dark"),
-			}
-
-			if err := writeHTMLFile(outputFilePath, html); err != nil {
-				return fmt.Errorf("failed to write HTML file %s: %v", outputFilePath, err)
-			}
-
-			fmt.Printf("Generated HTML file: %s\n", outputFilePath)
-		}
-	}
-
-	if !foundMarkdown {
-		return fmt.Errorf("no markdown files found in directory %s", absDirPath)
+		return err
 	}
-
-	if indexFile == "" {
-		dirName := filepath.Base(absDirPath)
-		indexContent := generateDirectoryIndex(dirName, generatedFiles)
-
-		html := htmlStruct{
-			Content:      string(indexContent),
-			Theme:        s.theme,
-			BoundingBox:  s.boundingBox,
-			CssCodeLight: getCssCode("github"),
-			CssCodeDark:  getCssCode("github-dark"),
-		}
-
-		indexFile = "index.html"
-		indexPath := path.Join(absOutputDir, indexFile)
-
-		if err := writeHTMLFile(indexPath, html); err != nil {
-			return fmt.Errorf("failed to write index file: %v", err)
-		}
-
-		fmt.Printf("Generated index file: %s\n", indexPath)
+	if err := e.exportAll(); err != nil {
+		return err
 	}
 
-	fmt.Printf("Output directory: %s\n", absOutputDir)
+	slog.Info("output directory", "path", e.output)
 
 	if s.browser {
-		fileURL := "file://" + path.Join(absOutputDir, indexFile)
-		err := Open(fileURL)
+		err := s.open(fileURL(filepath.Join(e.output, "index.html")))
 		if err != nil {
-			fmt.Println("Error opening browser:", err)
+			slog.Error("failed to open browser", "err", err)
 		}
 	}
 
 	return nil
 }
 
+// extractTitle returns the title of the front matter, the text of the first
+// H1 heading outside of code blocks, or the filename without its extension if
+// there is neither.
 func extractTitle(content []byte, filename string) string {
+	fm, content := parseFrontMatter(content)
+	if fm.Title != "" {
+		return fm.Title
+	}
 	lines := strings.Split(string(content), "\n")
+	var fence string
 	for _, line := range lines {
-		if strings.HasPrefix(strings.TrimSpace(line), "# ") {
-			return strings.TrimSpace(strings.TrimPrefix(strings.TrimSpace(line), "# "))
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+			}
+			continue
+		}
+		if marker, _, ok := fenceOpening([]byte(trimmed)); ok {
+			fence = marker
+			continue
+		}
+		if strings.HasPrefix(trimmed, "# ") {
+			title := strings.TrimSpace(strings.TrimPrefix(trimmed, "# "))
+			// optional closing sequence, e.g. "# Title #"
+			if t := strings.TrimRight(title, "#"); t == "" || strings.HasSuffix(t, " ") {
+				title = strings.TrimSpace(t)
+			}
+			if title != "" {
+				return title
+			}
 		}
 	}
 
 	return strings.TrimSuffix(filename, filepath.Ext(filename))
 }
 
-func generateDirectoryIndex(dirName string, files map[string]string) string {
+// indexEntry is a page listed by generateDirectoryIndex.
+type indexEntry struct {
+	file  string
+	title string
+	order *int
+}
+
+// less orders pages by the order field of their front matter, pages without
+// last, then by file name.
+func (a indexEntry) less(b indexEntry) bool {
+	if (a.order == nil) != (b.order == nil) {
+		return a.order != nil
+	}
+	if a.order != nil && *a.order != *b.order {
+		return *a.order < *b.order
+	}
+	return a.file < b.file
+}
+
+// generateDirectoryIndex lists the pages ordered by the order field of their
+// front matter, then by file name.
+func (s *Server) generateDirectoryIndex(dirName string, files []indexEntry) string {
 	var sb strings.Builder
 
-	sb.WriteString("<h1>Directory: " + dirName + "</h1>\n")
-	sb.WriteString("<p>The following files were generated:</p>\n")
+	sb.WriteString("<h1>" + html.EscapeString(s.msg("Directory: %s", dirName)) + "</h1>\n")
+	sb.WriteString("<p>" + html.EscapeString(s.msg("The following files were generated:")) + "</p>\n")
 	sb.WriteString("<ul>\n")
 
-	var filenames []string
-	for filename := range files {
-		if filename != "index.html" {
-			filenames = append(filenames, filename)
+	var pages []indexEntry
+	for _, f := range files {
+		if f.file != "index.html" {
+			pages = append(pages, f)
 		}
 	}
-	sort.Strings(filenames)
+	sort.Slice(pages, func(i, j int) bool {
+		return pages[i].less(pages[j])
+	})
 
-	for _, filename := range filenames {
-		title := files[filename]
-		sb.WriteString(fmt.Sprintf("  <li><a href=\"%s\">%s</a></li>\n", filename, title))
+	for _, page := range pages {
+		sb.WriteString(fmt.Sprintf("  <li><a href=\"%s\">%s</a></li>\n", page.file, html.EscapeString(page.title)))
 	}
 
 	sb.WriteString("</ul>\n")
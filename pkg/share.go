@@ -0,0 +1,15 @@
+package pkg
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+)
+
+// pageRevision returns the revision of a page rendered from content, a short
+// hash the share button puts into links as ?rev=. Pages opened with another
+// revision show a banner telling that the file changed since the link was
+// shared.
+func pageRevision(content []byte) string {
+	sum := sha256.Sum256(content)
+	return hex.EncodeToString(sum[:6])
+}
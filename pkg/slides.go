@@ -0,0 +1,77 @@
+package pkg
+
+import (
+	"bytes"
+	"strings"
+)
+
+const slideComment = "<!-- slide -->"
+
+// WithSlides serves markdown files as presentations, one slide per section
+// separated by a --- line after a blank line or by <!-- slide -->.
+func WithSlides(enabled bool) Option {
+	return func(s *Server) {
+		s.slides = enabled
+	}
+}
+
+// renderSlides renders every slide of a markdown file on its own.
+func (s *Server) renderSlides(content []byte, name string) []byte {
+	// source lines would count from the start of each slide
+	p := *s.parser
+	p.sourceLines = false
+
+	var buf bytes.Buffer
+	buf.WriteString(`<div class="grip-slides">` + "\n")
+	for _, slide := range splitSlides(content) {
+		buf.WriteString(`<section class="grip-slide">` + "\n")
+		buf.Write(p.MdToHTMLFile(slide, name))
+		buf.WriteString("</section>\n")
+	}
+	buf.WriteString("</div>\n")
+	return buf.Bytes()
+}
+
+// splitSlides splits markdown into slides. Separators in code blocks, and
+// --- lines directly below text, which make the text a heading, don't count.
+func splitSlides(content []byte) [][]byte {
+	_, content = parseFrontMatter(content)
+
+	var slides [][]byte
+	var fence string
+	start := 0
+	prevBlank := true
+	for off := 0; off < len(content); {
+		end := bytes.IndexByte(content[off:], '\n')
+		next := len(content)
+		if end >= 0 {
+			next = off + end + 1
+		}
+		line := strings.TrimSpace(string(content[off:next]))
+		marker, _, isFence := fenceOpening([]byte(line))
+
+		switch {
+		case fence != "":
+			if isFenceClosing([]byte(line), fence) {
+				fence = ""
+			}
+		case isFence:
+			fence = marker
+		case line == slideComment || (line == "---" && prevBlank):
+			slides = append(slides, content[start:off])
+			start = next
+		}
+		prevBlank = line == ""
+		off = next
+	}
+	slides = append(slides, content[start:])
+
+	// drop empty slides, e.g. before a leading separator
+	out := slides[:0]
+	for _, slide := range slides {
+		if len(bytes.TrimSpace(slide)) > 0 {
+			out = append(out, slide)
+		}
+	}
+	return out
+}
@@ -0,0 +1,23 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestSplitSlides(t *testing.T) {
+	input := "---\ntitle: Deck\n---\n# One\n\n---\n\nHeading\n---\n\n```\n---\n<!-- slide -->\n```\n<!-- slide -->\n# Three\n"
+
+	var got []string
+	for _, slide := range splitSlides([]byte(input)) {
+		got = append(got, strings.TrimSpace(string(slide)))
+	}
+	want := []string{
+		"# One",
+		"Heading\n---\n\n```\n---\n<!-- slide -->\n```",
+		"# Three",
+	}
+	if strings.Join(got, "\n|\n") != strings.Join(want, "\n|\n") {
+		t.Errorf("got slides\n%s\nwant\n%s", strings.Join(got, "\n|\n"), strings.Join(want, "\n|\n"))
+	}
+}
@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+
+	xhtml "golang.org/x/net/html"
+)
+
+// voidElements are the HTML elements without end tag.
+var voidElements = map[string]bool{
+	"area": true, "base": true, "br": true, "col": true, "embed": true, "hr": true, "img": true,
+	"input": true, "link": true, "meta": true, "source": true, "track": true, "wbr": true,
+}
+
+// Snapshot renders the file like the preview does, without the page layout
+// around the document, and returns the HTML in the canonical form of
+// CanonicalHTML. The output only changes when the rendering of the document
+// does, so it can be compared with golden files in tests.
+func (s *Server) Snapshot(file string) ([]byte, error) {
+	dir := rootFS{root: http.Dir(filepath.Dir(file)), hidden: s.hidden}
+	render, ok := s.renderer(file, dir)
+	if !ok {
+		return nil, fmt.Errorf("unsupported file type: %s", file)
+	}
+	content, err := os.ReadFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("failed to read file %s: %v", file, err)
+	}
+	name := filepath.Base(file)
+	content, _ = s.embedFiles(dir, name, content)
+	out, _, err := render(content, name)
+	if err != nil {
+		return nil, fmt.Errorf("failed to render %s: %v", file, err)
+	}
+	return CanonicalHTML(out), nil
+}
+
+// CanonicalHTML returns html with every tag on its own line, indented by its
+// depth, attributes sorted by name, whitespace in text collapsed and entities
+// escaped the same way. Text of pre, textarea, script and style elements is
+// kept as is, since its whitespace matters.
+func CanonicalHTML(page []byte) []byte {
+	var out bytes.Buffer
+	depth, raw := 0, 0
+	// tag is the last opened element, script and style text isn't escaped
+	var tag string
+	line := func(s string) {
+		if raw > 0 {
+			out.WriteString(s)
+			return
+		}
+		out.WriteString(strings.Repeat("  ", depth))
+		out.WriteString(s)
+		out.WriteByte('\n')
+	}
+
+	z := xhtml.NewTokenizer(bytes.NewReader(page))
+	for {
+		tt := z.Next()
+		if tt == xhtml.ErrorToken {
+			return out.Bytes()
+		}
+		tok := z.Token()
+		switch tt {
+		case xhtml.StartTagToken, xhtml.SelfClosingTagToken:
+			sort.SliceStable(tok.Attr, func(i, j int) bool {
+				return tok.Attr[i].Key < tok.Attr[j].Key
+			})
+			if voidElements[tok.Data] {
+				// <br> and <br/> are the same element
+				tok.Type = xhtml.SelfClosingTagToken
+			}
+			line(tok.String())
+			if tok.Type == xhtml.StartTagToken {
+				depth++
+				if rawTextElement(tok.Data) {
+					raw++
+				}
+				tag = tok.Data
+			}
+		case xhtml.EndTagToken:
+			if voidElements[tok.Data] {
+				continue
+			}
+			if rawTextElement(tok.Data) && raw > 0 {
+				raw--
+				out.WriteByte('\n')
+			}
+			depth = max(depth-1, 0)
+			line(tok.String())
+		case xhtml.TextToken:
+			if raw > 0 && (tag == "script" || tag == "style") {
+				out.WriteString(tok.Data)
+			} else if raw > 0 {
+				out.WriteString(html.EscapeString(tok.Data))
+			} else if text := strings.Join(strings.Fields(tok.Data), " "); text != "" {
+				line(html.EscapeString(text))
+			}
+		case xhtml.CommentToken, xhtml.DoctypeToken:
+			line(tok.String())
+		}
+	}
+}
+
+func rawTextElement(tag string) bool {
+	return tag == "pre" || tag == "textarea" || tag == "script" || tag == "style"
+}
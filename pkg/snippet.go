@@ -0,0 +1,81 @@
+package pkg
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+var fenceInfoField = regexp.MustCompile(`([^\s=]+)=("[^"]*"|'[^']*'|\S*)|\S+`)
+
+// parseFenceInfo splits the info string of a fenced code block, e.g.
+// "go file=main.go lines=10-42", into the language and its attributes. Values
+// may be quoted to contain spaces. A filename after the language, as in
+// "go:main.go", is the title of the block.
+func parseFenceInfo(info string) (string, map[string]string) {
+	var lang string
+	attrs := make(map[string]string)
+	for i, m := range fenceInfoField.FindAllStringSubmatch(info, -1) {
+		if m[1] != "" {
+			attrs[m[1]] = strings.Trim(m[2], `"'`)
+		} else if i == 0 {
+			lang = m[0]
+		}
+	}
+	if l, title, ok := strings.Cut(lang, ":"); ok && title != "" {
+		lang = l
+		if _, ok := attrs["title"]; !ok {
+			attrs["title"] = title
+		}
+	}
+	return lang, attrs
+}
+
+// snippet returns the lines of a source file embedded with
+// ```go file=main.go lines=10-42, relative to the file on top of stack.
+func (e *embedder) snippet(stack []string, file string, lines string) (string, error) {
+	content, err := e.read(resolveEmbed(stack, file))
+	if err != nil {
+		return "", err
+	}
+	all := strings.SplitAfter(string(content), "\n")
+	if all[len(all)-1] == "" {
+		all = all[:len(all)-1]
+	}
+
+	start, end := 1, len(all)
+	if lines != "" {
+		if start, end, err = parseLineRange(lines, len(all)); err != nil {
+			return "", err
+		}
+	}
+	snippet := strings.Join(all[start-1:end], "")
+	if !strings.HasSuffix(snippet, "\n") {
+		snippet += "\n"
+	}
+	return snippet, nil
+}
+
+// parseLineRange parses "10-42", "10-" or "10" into 1-based inclusive line
+// numbers, limiting the end to the n lines of the file.
+func parseLineRange(lines string, n int) (int, int, error) {
+	from, to, isRange := strings.Cut(lines, "-")
+	start, err := strconv.Atoi(from)
+	if err != nil || start < 1 {
+		return 0, 0, fmt.Errorf("invalid line range %q", lines)
+	}
+	end := start
+	if isRange {
+		end = n
+		if to != "" {
+			if end, err = strconv.Atoi(to); err != nil || end < start {
+				return 0, 0, fmt.Errorf("invalid line range %q", lines)
+			}
+		}
+	}
+	if start > n {
+		return 0, 0, fmt.Errorf("line %d is beyond the end of the file", start)
+	}
+	return start, min(end, n), nil
+}
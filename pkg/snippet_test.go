@@ -0,0 +1,38 @@
+package pkg
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"testing"
+)
+
+func TestParseFenceInfo(t *testing.T) {
+	lang, attrs := parseFenceInfo(`go:main.go file="src/main.go" lines=10-42`)
+	if lang != "go" || attrs["title"] != "main.go" || attrs["file"] != "src/main.go" || attrs["lines"] != "10-42" {
+		t.Errorf("got %q %v", lang, attrs)
+	}
+}
+
+func TestSnippets(t *testing.T) {
+	dir := t.TempDir()
+	if err := os.MkdirAll(filepath.Join(dir, "src"), 0755); err != nil {
+		t.Fatal(err)
+	}
+	if err := os.WriteFile(filepath.Join(dir, "src", "main.go"), []byte("package main\n\nfunc main() {\n}\n"), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"))
+	for _, tt := range []struct{ doc, want string }{
+		{"```go file=src/main.go lines=3-4\nstale\n```\n", "```go file=src/main.go lines=3-4\nfunc main() {\n}\n```\n"},
+		{"```go file=src/main.go lines=3-\n```\n", "```go file=src/main.go lines=3-\nfunc main() {\n}\n```\n"},
+		{"```go file=src/main.go\n```\n", "```go file=src/main.go\npackage main\n\nfunc main() {\n}\n```\n"},
+		{"```go file=src/main.go lines=9\n```\n", "\n> [!CAUTION]\n> Failed to embed snippet `src/main.go`: line 9 is beyond the end of the file\n\n```go file=src/main.go lines=9\n```\n"},
+		{"```go file=missing.go\n```\n", "\n> [!CAUTION]\n> Failed to embed snippet `missing.go`: file not found\n\n```go file=missing.go\n```\n"},
+	} {
+		if got, _ := s.embedFiles(http.Dir(dir), "doc.md", []byte(tt.doc)); string(got) != tt.want {
+			t.Errorf("%q: got %q, want %q", tt.doc, got, tt.want)
+		}
+	}
+}
@@ -0,0 +1,87 @@
+package pkg
+
+import (
+	"bytes"
+	"io"
+	"net/http"
+	"path"
+	"strings"
+	"time"
+
+	chroma_html "github.com/alecthomas/chroma/v2/formatters/html"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// sourceRequested reports whether a rendered page was requested with ?raw=1
+// for the original source or ?source=1 for the highlighted source view.
+func sourceRequested(r *http.Request) bool {
+	q := r.URL.Query()
+	return q.Get("raw") == "1" || q.Get("source") == "1"
+}
+
+// serveSource answers a request for which sourceRequested is true. ?raw=1
+// returns the source as text/plain, ?source=1 an HTML fragment of the
+// source highlighted by the lexer matching name, loaded by the view source
+// toggle.
+func serveSource(w http.ResponseWriter, r *http.Request, content []byte, name string, modTime time.Time) {
+	if r.URL.Query().Get("raw") == "1" {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		http.ServeContent(w, r, "", modTime, bytes.NewReader(content))
+		return
+	}
+
+	lexer := lexers.Match(name)
+	if lexer == nil {
+		lexer = lexers.Get("markdown")
+	}
+	iterator, err := lexer.Tokenise(nil, string(content))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	var buf bytes.Buffer
+	formatter := chroma_html.New(chroma_html.WithClasses(true), chroma_html.WithLineNumbers(true))
+	if err := formatter.Format(&buf, styles.Fallback, iterator); err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+
+	w.Header().Set("Content-Type", "text/html; charset=utf-8")
+	http.ServeContent(w, r, "", modTime, bytes.NewReader(buf.Bytes()))
+}
+
+// serveSourceFile answers a request for which sourceRequested is true with
+// the source of f. Files that can't be previewed are streamed unchanged for
+// ?raw=1 instead of being read into memory.
+func (s *Server) serveSourceFile(w http.ResponseWriter, r *http.Request, f http.File) {
+	info, err := f.Stat()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	reason, status, err := s.previewError(r.URL.Path, f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	if reason != "" || selfLoadingFormats[strings.ToLower(path.Ext(r.URL.Path))] {
+		if r.URL.Query().Get("raw") != "1" {
+			if status == 0 {
+				// self-loading formats have no source to show
+				status = http.StatusUnprocessableEntity
+			}
+			http.Error(w, reason, status)
+			return
+		}
+		http.ServeContent(w, r, info.Name(), info.ModTime(), f)
+		return
+	}
+
+	content, err := io.ReadAll(f)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	serveSource(w, r, s.encoding.Decode(content), info.Name(), info.ModTime())
+}
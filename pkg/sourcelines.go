@@ -0,0 +1,114 @@
+package pkg
+
+import (
+	"bytes"
+	"strconv"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+const sourceLineAttr = "data-source-line"
+
+// WithSourceLines adds data-source-line attributes with the line in the
+// markdown source to the top-level blocks, used to synchronize scrolling
+// between an editor and the preview.
+func WithSourceLines() ParserOption {
+	return func(p *Parser) {
+		p.sourceLines = true
+	}
+}
+
+// addSourceLines annotates the top-level blocks of doc, parsed from md, with
+// the line they start at. The AST has no positions, so the line is the one of
+// the first text of a block: text that is still part of md is located by its
+// capacity, other text is searched after the previous block.
+func addSourceLines(doc ast.Node, md []byte) {
+	pos := 0
+	for _, block := range doc.GetChildren() {
+		text := firstText(block)
+		if len(text) == 0 {
+			continue
+		}
+		offset := cap(md) - cap(text)
+		if offset < pos || offset+len(text) > len(md) || !bytes.Equal(md[offset:offset+len(text)], text) {
+			// text of quotes and lists is copied without the markers, so
+			// only its first line is still found verbatim
+			text, _, _ = bytes.Cut(text, []byte("\n"))
+			i := bytes.Index(md[pos:], text)
+			if i < 0 {
+				continue
+			}
+			offset = pos + i
+		}
+		pos = offset + len(text)
+		setSourceLine(block, bytes.Count(md[:offset], []byte("\n"))+1)
+	}
+}
+
+// firstText returns the first literal text of a node or its children.
+func firstText(node ast.Node) []byte {
+	var text []byte
+	ast.WalkFunc(node, func(n ast.Node, entering bool) ast.WalkStatus {
+		if !entering || text != nil {
+			return ast.SkipChildren
+		}
+		if leaf := n.AsLeaf(); leaf != nil && len(bytes.TrimSpace(leaf.Literal)) > 0 {
+			text = bytes.TrimSpace(leaf.Literal)
+			return ast.Terminate
+		}
+		return ast.GoToNext
+	})
+	return text
+}
+
+func setSourceLine(node ast.Node, line int) {
+	attr := &ast.Attribute{}
+	if c := node.AsContainer(); c != nil {
+		if c.Attribute == nil {
+			c.Attribute = attr
+		}
+		attr = c.Attribute
+	} else if l := node.AsLeaf(); l != nil {
+		if l.Attribute == nil {
+			l.Attribute = attr
+		}
+		attr = l.Attribute
+	}
+	if attr.Attrs == nil {
+		attr.Attrs = make(map[string][]byte)
+	}
+	attr.Attrs[sourceLineAttr] = []byte(strconv.Itoa(line))
+}
+
+// sourceLine returns the source line annotated on a node, if any.
+func sourceLine(node ast.Node) string {
+	var attr *ast.Attribute
+	if c := node.AsContainer(); c != nil {
+		attr = c.Attribute
+	} else if l := node.AsLeaf(); l != nil {
+		attr = l.Attribute
+	}
+	if attr == nil {
+		return ""
+	}
+	return string(attr.Attrs[sourceLineAttr])
+}
+
+// withSourceLine adds the source line attribute to the first tag of HTML
+// rendered by a hook, which replaces the default rendering of the block.
+func withSourceLine(html string, line string) string {
+	if line == "" {
+		return html
+	}
+	start := strings.IndexByte(html, '<')
+	if start < 0 {
+		return html
+	}
+	end := strings.IndexAny(html[start:], " \t\n/>")
+	if end < 0 {
+		return html
+	}
+	end += start
+	return html[:end] + ` ` + sourceLineAttr + `="` + line + `"` + html[end:]
+}
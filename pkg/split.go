@@ -0,0 +1,260 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"html"
+	"log/slog"
+	"net/http"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+type section struct {
+	title   string
+	content []byte
+}
+
+// GenerateSplitFile renders a single markdown file as multiple HTML pages,
+// starting a new page at every heading up to the given level. The first page
+// is written as index.html and lists all other pages.
+func (s *Server) GenerateSplitFile(filePath string, outputDir string, level int) error {
+	if level < 1 || level > 6 {
+		return fmt.Errorf("invalid split level %d, must be between 1 and 6", level)
+	}
+
+	absOutputDir, err := filepath.Abs(outputDir)
+	if err != nil {
+		return fmt.Errorf("failed to get absolute path: %v", err)
+	}
+
+	staticDir := filepath.Join(absOutputDir, "static")
+	if err := os.MkdirAll(staticDir, 0755); err != nil {
+		return fmt.Errorf("failed to create static directory: %v", err)
+	}
+
+	if err := copyStaticFiles(staticDir); err != nil {
+		return fmt.Errorf("failed to copy static files: %v", err)
+	}
+
+	content, err := os.ReadFile(filePath)
+	if err != nil {
+		return fmt.Errorf("failed to read file %s: %v", filePath, err)
+	}
+	content, _ = s.embedFiles(http.Dir(filepath.Dir(filePath)), filepath.Base(filePath), content)
+
+	sections := splitSections(content, level)
+	if len(sections) > 0 && len(bytes.TrimSpace(sections[0].content)) == 0 {
+		sections = sections[1:]
+	}
+	if len(sections) == 0 {
+		return fmt.Errorf("file %s is empty", filePath)
+	}
+	if sections[0].title == "" {
+		sections[0].title = extractTitle(content, filepath.Base(filePath))
+	}
+
+	pages := make([]string, len(sections))
+	used := make(map[string]bool)
+	for i, sec := range sections {
+		if i == 0 {
+			pages[i] = "index.html"
+			continue
+		}
+		name := fmt.Sprintf("%02d-%s.html", i, slugify(sec.title))
+		for n := 2; used[name]; n++ {
+			name = fmt.Sprintf("%02d-%s-%d.html", i, slugify(sec.title), n)
+		}
+		used[name] = true
+		pages[i] = name
+	}
+
+	anchors := s.splitAnchors(content, sections)
+	for i, sec := range sections {
+		var body strings.Builder
+		body.Write(rewriteSplitLinks(s.parser.MdToHTML(sec.content), anchors, pages, i))
+		if i == 0 {
+			body.WriteString(s.splitContents(sections, pages))
+		}
+		body.WriteString(s.splitNav(sections, pages, i))
+
+		html := s.htmlPage(body.String(), sec.title)
+
+		outputFilePath := filepath.Join(absOutputDir, pages[i])
+		if err := s.writeHTMLFile(outputFilePath, html); err != nil {
+			return fmt.Errorf("failed to write HTML file %s: %v", pages[i], err)
+		}
+
+		slog.Info("generated HTML file", "path", outputFilePath)
+	}
+
+	slog.Info("output directory", "path", absOutputDir)
+
+	if s.browser {
+		err := s.open(fileURL(filepath.Join(absOutputDir, pages[0])))
+		if err != nil {
+			slog.Error("failed to open browser", "err", err)
+		}
+	}
+
+	return nil
+}
+
+// splitSections splits markdown source at ATX headings up to the given level.
+// Headings inside fenced code blocks are ignored. The first section holds the
+// content before the first matching heading and has an empty title.
+func splitSections(content []byte, level int) []section {
+	sections := []section{{}}
+	var fence string
+
+	for _, line := range bytes.SplitAfter(content, []byte("\n")) {
+		trimmed := strings.TrimSpace(string(line))
+		marker, _, isFence := fenceOpening([]byte(trimmed))
+
+		if fence != "" {
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+			}
+		} else if isFence {
+			fence = marker
+		} else if l, title := atxHeading(trimmed); l > 0 && l <= level {
+			sections = append(sections, section{title: title})
+		}
+
+		cur := &sections[len(sections)-1]
+		cur.content = append(cur.content, line...)
+	}
+
+	return sections
+}
+
+// atxHeading returns the level and text of an ATX heading line, or zero if
+// the line is not a heading.
+func atxHeading(line string) (int, string) {
+	level := 0
+	for level < len(line) && line[level] == '#' {
+		level++
+	}
+	if level == 0 || level > 6 || (level < len(line) && line[level] != ' ') {
+		return 0, ""
+	}
+	title := strings.TrimSpace(strings.TrimRight(line[level:], "#"))
+	return level, title
+}
+
+// splitAnchor is the page of a split document an anchor is on, with its id
+// there.
+type splitAnchor struct {
+	page int
+	id   string
+}
+
+// splitAnchors maps the anchors of the whole document to the pages of the
+// sections defining them. Duplicate headings are numbered per page, so the
+// second "Usage" of the document, usage-1, may be usage on its page.
+func (s *Server) splitAnchors(content []byte, sections []section) map[string]splitAnchor {
+	anchors := make(map[string]splitAnchor)
+	var headings []splitAnchor
+	for i, sec := range sections {
+		doc := s.parser.parse(sec.content)
+		for id := range documentAnchors(doc) {
+			if _, ok := anchors[id]; !ok {
+				anchors[id] = splitAnchor{page: i, id: id}
+			}
+		}
+		for _, id := range headingIDs(doc) {
+			headings = append(headings, splitAnchor{page: i, id: id})
+		}
+	}
+	// sections start at headings, so the headings of the document are those
+	// of the sections in order
+	if ids := headingIDs(s.parser.parse(content)); len(ids) == len(headings) {
+		for i, id := range ids {
+			anchors[id] = headings[i]
+		}
+	}
+	return anchors
+}
+
+// headingIDs returns the anchors of the headings of a parsed document in
+// order.
+func headingIDs(doc ast.Node) []string {
+	var ids []string
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if heading, ok := node.(*ast.Heading); ok && entering {
+			ids = append(ids, heading.HeadingID)
+			return ast.SkipChildren
+		}
+		return ast.GoToNext
+	})
+	return ids
+}
+
+// fragmentLinkRegex matches the href of a link to an anchor of the same page.
+var fragmentLinkRegex = regexp.MustCompile(`href="#([^"]+)"`)
+
+// rewriteSplitLinks points links to anchors in the HTML of page to the pages
+// the anchors are on.
+func rewriteSplitLinks(body []byte, anchors map[string]splitAnchor, pages []string, page int) []byte {
+	return fragmentLinkRegex.ReplaceAllFunc(body, func(m []byte) []byte {
+		anchor := html.UnescapeString(string(fragmentLinkRegex.FindSubmatch(m)[1]))
+		target, ok := anchors[anchor]
+		if !ok || (target.page == page && target.id == anchor) {
+			return m
+		}
+		href := "#" + target.id
+		if target.page != page {
+			href = pages[target.page] + href
+		}
+		return []byte(`href="` + html.EscapeString(href) + `"`)
+	})
+}
+
+func slugify(s string) string {
+	var sb strings.Builder
+	dash := false
+	for _, r := range strings.ToLower(s) {
+		switch {
+		case r >= 'a' && r <= 'z', r >= '0' && r <= '9':
+			sb.WriteRune(r)
+			dash = false
+		case !dash && sb.Len() > 0:
+			sb.WriteRune('-')
+			dash = true
+		}
+	}
+	slug := strings.TrimSuffix(sb.String(), "-")
+	if slug == "" {
+		return "section"
+	}
+	return slug
+}
+
+func (s *Server) splitContents(sections []section, pages []string) string {
+	var sb strings.Builder
+	sb.WriteString("<h2>" + html.EscapeString(s.msg("Contents")) + "</h2>\n<ul>\n")
+	for i := 1; i < len(sections); i++ {
+		sb.WriteString(fmt.Sprintf("  <li><a href=\"%s\">%s</a></li>\n", pages[i], html.EscapeString(sections[i].title)))
+	}
+	sb.WriteString("</ul>\n")
+	return sb.String()
+}
+
+func (s *Server) splitNav(sections []section, pages []string, i int) string {
+	var links []string
+	if i > 0 {
+		links = append(links, fmt.Sprintf("<a href=\"%s\">&larr; %s</a>", pages[i-1], html.EscapeString(sections[i-1].title)))
+		links = append(links, fmt.Sprintf("<a href=\"%s\">%s</a>", pages[0], html.EscapeString(s.msg("Contents"))))
+	}
+	if i < len(sections)-1 {
+		links = append(links, fmt.Sprintf("<a href=\"%s\">%s &rarr;</a>", pages[i+1], html.EscapeString(sections[i+1].title)))
+	}
+	if len(links) == 0 {
+		return ""
+	}
+	return "<hr>\n<nav class=\"split-nav\">" + strings.Join(links, " | ") + "</nav>\n"
+}
@@ -0,0 +1,75 @@
+package pkg
+
+import (
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestGenerateSplitFile(t *testing.T) {
+	dir := t.TempDir()
+	source := filepath.Join(dir, "book.md")
+	content := "# Book\n\nSee [usage](#usage), [the second usage](#usage-1) and [nothing](#missing).\n\n" +
+		"## Install\n\n```sh\n## not a heading\n```\n\n" +
+		"## Usage\n\nBack to [install](#install) or [the top](#book).\n\n" +
+		"## Usage\n\nAgain, see [above](#usage).\n"
+	if err := os.WriteFile(source, []byte(content), 0644); err != nil {
+		t.Fatal(err)
+	}
+
+	output := filepath.Join(dir, "out")
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"))
+	if err := s.GenerateSplitFile(source, output, 2); err != nil {
+		t.Fatal(err)
+	}
+	pages := make(map[string]string)
+	for _, name := range []string{"index.html", "01-install.html", "02-usage.html", "03-usage.html"} {
+		data, err := os.ReadFile(filepath.Join(output, name))
+		if err != nil {
+			t.Fatal(err)
+		}
+		pages[name] = string(data)
+	}
+	if matches, _ := filepath.Glob(filepath.Join(output, "*.html")); len(matches) != len(pages) {
+		t.Errorf("expected no page for the heading in a code block, got %v", matches)
+	}
+
+	for name, want := range map[string][]string{
+		"index.html": {
+			`href="02-usage.html#usage"`,
+			`href="03-usage.html#usage"`,
+			`href="#missing"`,
+			`<li><a href="01-install.html">Install</a></li>`,
+			`<a href="01-install.html">Install &rarr;</a>`,
+		},
+		"01-install.html": {
+			"## not a heading",
+			`<a href="index.html">&larr; Book</a>`,
+			`<a href="02-usage.html">Usage &rarr;</a>`,
+		},
+		"02-usage.html": {
+			`href="01-install.html#install"`,
+			`href="index.html#book"`,
+			`<a href="01-install.html">&larr; Install</a>`,
+			`<a href="index.html">Contents</a>`,
+			`<a href="03-usage.html">Usage &rarr;</a>`,
+		},
+		"03-usage.html": {
+			`href="02-usage.html#usage"`,
+			`<a href="02-usage.html">&larr; Usage</a>`,
+		},
+	} {
+		for _, w := range want {
+			if !strings.Contains(pages[name], w) {
+				t.Errorf("%s: expected %q in:\n%s", name, w, pages[name])
+			}
+		}
+	}
+	if strings.Contains(pages["index.html"], "&larr;") {
+		t.Error("expected no link to a previous page on the first page")
+	}
+	if strings.Contains(pages["03-usage.html"], "&rarr;") {
+		t.Error("expected no link to a next page on the last page")
+	}
+}
@@ -0,0 +1,43 @@
+package pkg
+
+import (
+	"io"
+	"log/slog"
+	"sync"
+)
+
+// stdinPage is the path under which markdown read from stdin is served.
+const stdinPage = "stdin.md"
+
+// stdinSource collects markdown read from stdin while it is being served.
+type stdinSource struct {
+	mu      sync.Mutex
+	content []byte
+}
+
+// read appends everything read from r to the content, calling onChange
+// whenever new input arrived.
+func (src *stdinSource) read(r io.Reader, onChange func()) {
+	buf := make([]byte, 32*1024)
+	for {
+		n, err := r.Read(buf)
+		if n > 0 {
+			src.mu.Lock()
+			src.content = append(src.content, buf[:n]...)
+			src.mu.Unlock()
+			onChange()
+		}
+		if err != nil {
+			if err != io.EOF {
+				slog.Error("failed to read stdin", "err", err)
+			}
+			return
+		}
+	}
+}
+
+func (src *stdinSource) bytes() []byte {
+	src.mu.Lock()
+	defer src.mu.Unlock()
+	return append([]byte(nil), src.content...)
+}
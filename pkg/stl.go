@@ -0,0 +1,15 @@
+package pkg
+
+import (
+	"html"
+	"net/url"
+)
+
+// renderSTL renders a 3D viewer for an STL file. The viewer loads the model
+// itself from the ?raw=1 URL of the file, so the content isn't embedded.
+func renderSTL(content []byte, name string) ([]byte, string, error) {
+	src := (&url.URL{Path: name, RawQuery: "raw=1"}).String()
+	out := `<div class="grip-stl" data-src="` + html.EscapeString(src) + `"></div>` + "\n" +
+		`<script src="` + assetPath("static/js/stl.js") + `"></script>` + "\n"
+	return []byte(out), "", nil
+}
@@ -0,0 +1,254 @@
+package pkg
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"html"
+	"io"
+	"net/http"
+	"net/url"
+	"path"
+	"regexp"
+	"strings"
+	"time"
+)
+
+const summaryFile = "/SUMMARY.md"
+
+var (
+	summaryChapter = regexp.MustCompile(`^(\s*)([-*+]\s+)?\[(.*)\]\((.*)\)\s*$`)
+	summaryPart    = regexp.MustCompile(`^#{1,6}\s+(.*?)\s*#*\s*$`)
+)
+
+// book is the table of contents of an mdBook style SUMMARY.md, see
+// https://rust-lang.github.io/mdBook/format/summary.html.
+type book struct {
+	chapters []bookChapter
+	// ordered books are the pages of the directory tree, which link only
+	// the previous and next page
+	ordered bool
+}
+
+// bookChapter is a chapter of a book, or the title of a part if part is set.
+// Draft chapters have no path, links to other sites a url instead.
+type bookChapter struct {
+	title  string
+	path   string
+	url    string
+	number string
+	depth  int
+	part   bool
+}
+
+// pageNav is the navigation and information rendered around a page.
+type pageNav struct {
+	Sidebar     string
+	Pager       string
+	Stats       string
+	Breadcrumbs string
+	TOC         string
+	// Theme overrides the theme of the server for the page.
+	Theme string
+	// Revision is the pageRevision of the page, for the share button.
+	Revision string
+}
+
+// cacheKey returns the part of the render cache key of a page for its
+// sidebar and pager, which change with the titles of other pages.
+func (n pageNav) cacheKey() string {
+	if n.Sidebar == "" && n.Pager == "" {
+		return ""
+	}
+	sum := sha256.Sum256([]byte(n.Sidebar + "\x00" + n.Pager))
+	return "\x00" + hex.EncodeToString(sum[:8])
+}
+
+// readBook reads the navigation of the served directory from its SUMMARY.md,
+// or else from the nav of its mkdocs.yml, or else from the directory tree
+// with WithPager, nil if there is none.
+func (s *site) readBook(dir http.FileSystem) (*book, time.Time) {
+	if b, modTime := readSummary(dir); b != nil {
+		return b, modTime
+	}
+	if b, modTime := s.mkdocs.get(dir); b != nil {
+		return b, modTime
+	}
+	if s.pager {
+		return s.pages.get(s.Server, dir)
+	}
+	return nil, time.Time{}
+}
+
+// readSummary reads the SUMMARY.md of dir, nil if there is none.
+func readSummary(dir http.FileSystem) (*book, time.Time) {
+	f, err := dir.Open(summaryFile)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	defer f.Close()
+	info, err := f.Stat()
+	if err != nil || info.IsDir() {
+		return nil, time.Time{}
+	}
+	content, err := io.ReadAll(f)
+	if err != nil {
+		return nil, time.Time{}
+	}
+	return parseSummary(content), info.ModTime()
+}
+
+// parseSummary parses the chapters of a SUMMARY.md: prefix and suffix
+// chapters as plain links, numbered chapters as nested list items and part
+// titles as headings. The first heading is the title of the summary itself.
+func parseSummary(content []byte) *book {
+	b := &book{}
+	var indents []int
+	var numbers []int
+	titled := false
+
+	scanner := bufio.NewScanner(bytes.NewReader(content))
+	for scanner.Scan() {
+		line := strings.ReplaceAll(scanner.Text(), "\t", "    ")
+		if m := summaryPart.FindStringSubmatch(line); m != nil {
+			if titled || len(b.chapters) > 0 {
+				b.chapters = append(b.chapters, bookChapter{title: m[1], part: true})
+			}
+			titled = true
+			continue
+		}
+		m := summaryChapter.FindStringSubmatch(line)
+		if m == nil {
+			continue
+		}
+
+		ch := bookChapter{title: m[3]}
+		if p, err := url.PathUnescape(strings.TrimSpace(m[4])); err == nil && p != "" {
+			ch.path = path.Clean("/" + p)
+		}
+		if m[2] != "" {
+			// numbered chapter, nested by indentation
+			indent := len(m[1])
+			for len(indents) > 0 && indents[len(indents)-1] > indent {
+				indents = indents[:len(indents)-1]
+			}
+			if len(indents) == 0 || indents[len(indents)-1] < indent {
+				indents = append(indents, indent)
+			}
+			ch.depth = len(indents) - 1
+			if len(numbers) > ch.depth+1 {
+				numbers = numbers[:ch.depth+1]
+			}
+			for len(numbers) < ch.depth+1 {
+				numbers = append(numbers, 0)
+			}
+			numbers[ch.depth]++
+			for _, n := range numbers {
+				ch.number += fmt.Sprintf("%d.", n)
+			}
+		} else {
+			indents, numbers = nil, nil
+		}
+		b.chapters = append(b.chapters, ch)
+	}
+	return b
+}
+
+// nav returns the sidebar and the links to the previous and next chapter of
+// the page at name.
+func (b *book) nav(name string) pageNav {
+	if b.ordered {
+		return pageNav{Pager: b.pager(name)}
+	}
+	return pageNav{Sidebar: b.sidebar(name), Pager: b.pager(name)}
+}
+
+func (b *book) sidebar(name string) string {
+	var sb strings.Builder
+	sb.WriteString(`<ol class="grip-book-chapters">`)
+	depth := 0
+	for i, ch := range b.chapters {
+		if i > 0 {
+			if ch.depth > depth {
+				for ; depth < ch.depth; depth++ {
+					sb.WriteString("<ol>")
+				}
+			} else {
+				sb.WriteString("</li>")
+				for ; depth > ch.depth; depth-- {
+					sb.WriteString("</ol></li>")
+				}
+			}
+		}
+
+		switch {
+		case ch.part:
+			sb.WriteString(`<li class="grip-book-part">` + html.EscapeString(ch.title))
+		case ch.path == "" && ch.url == "":
+			sb.WriteString(`<li><span class="grip-book-draft">` + chapterLabel(ch) + `</span>`)
+		default:
+			class := ""
+			if ch.path == name {
+				class = ` class="active" aria-current="page"`
+			}
+			sb.WriteString(`<li><a href="` + chapterHref(ch) + `"` + class + `>` + chapterLabel(ch) + `</a>`)
+		}
+	}
+	if len(b.chapters) > 0 {
+		sb.WriteString("</li>")
+	}
+	for ; depth > 0; depth-- {
+		sb.WriteString("</ol></li>")
+	}
+	sb.WriteString("</ol>")
+	return sb.String()
+}
+
+// pager links the chapters before and after the page at name, empty if the
+// page is not a chapter.
+func (b *book) pager(name string) string {
+	var pages []bookChapter
+	current := -1
+	for _, ch := range b.chapters {
+		if ch.part || ch.path == "" {
+			continue
+		}
+		if ch.path == name {
+			current = len(pages)
+		}
+		pages = append(pages, ch)
+	}
+	if current < 0 {
+		return ""
+	}
+
+	var sb strings.Builder
+	sb.WriteString(`<nav class="grip-pager">`)
+	if current > 0 {
+		prev := pages[current-1]
+		sb.WriteString(`<a class="grip-pager-prev" rel="prev" href="` + chapterHref(prev) + `">&larr; ` + html.EscapeString(prev.title) + `</a>`)
+	}
+	if current < len(pages)-1 {
+		next := pages[current+1]
+		sb.WriteString(`<a class="grip-pager-next" rel="next" href="` + chapterHref(next) + `">` + html.EscapeString(next.title) + ` &rarr;</a>`)
+	}
+	sb.WriteString(`</nav>`)
+	return sb.String()
+}
+
+func chapterHref(ch bookChapter) string {
+	if ch.url != "" {
+		return html.EscapeString(ch.url)
+	}
+	return html.EscapeString((&url.URL{Path: ch.path}).EscapedPath())
+}
+
+func chapterLabel(ch bookChapter) string {
+	label := html.EscapeString(ch.title)
+	if ch.number != "" {
+		label = "<strong>" + ch.number + "</strong> " + label
+	}
+	return label
+}
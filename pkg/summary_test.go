@@ -0,0 +1,158 @@
+package pkg
+
+import (
+	"net/http"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+)
+
+func TestParseSummary(t *testing.T) {
+	summary := `# Summary
+
+[Introduction](README.md)
+
+# User Guide
+
+- [Installation](guide/installation.md)
+    - [Nightly](guide/nightly%20builds.md)
+- [Reading Books](guide/reading.md)
+- [Draft]()
+
+---
+
+[Contributors](misc/contributors.md)
+`
+	b := parseSummary([]byte(summary))
+
+	var got []string
+	for _, ch := range b.chapters {
+		got = append(got, strings.TrimSpace(ch.number+" "+ch.title+" "+ch.path))
+	}
+	want := []string{
+		"Introduction /README.md",
+		"User Guide",
+		"1. Installation /guide/installation.md",
+		"1.1. Nightly /guide/nightly builds.md",
+		"2. Reading Books /guide/reading.md",
+		"3. Draft",
+		"Contributors /misc/contributors.md",
+	}
+	if strings.Join(got, "\n") != strings.Join(want, "\n") {
+		t.Errorf("got chapters\n%s\nwant\n%s", strings.Join(got, "\n"), strings.Join(want, "\n"))
+	}
+
+	pager := b.pager("/guide/nightly builds.md")
+	for _, link := range []string{`href="/guide/installation.md">&larr; Installation`, `href="/guide/reading.md">Reading Books &rarr;`} {
+		if !strings.Contains(pager, link) {
+			t.Errorf("pager does not contain %q\ngot: %s", link, pager)
+		}
+	}
+	if b.pager("/other.md") != "" {
+		t.Error("pages missing in the summary got a pager")
+	}
+}
+
+func TestMkDocsNav(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"mkdocs.yml":          "site_name: Test\nnav:\n  - Home: index.md\n  - Guide:\n    - guide/setup.md\n  - Source: https://example.com/repo\n",
+		"docs/index.md":       "# Home page",
+		"docs/guide/setup.md": "# Setting up",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	for _, tt := range []struct {
+		served string
+		prefix string
+	}{
+		{root, "/docs"},
+		{filepath.Join(root, "docs"), ""},
+	} {
+		config := findMkDocs(tt.served)
+		if config == nil {
+			t.Fatalf("no mkdocs.yml found for %s", tt.served)
+		}
+		b := config.read(http.Dir(tt.served))
+		if b == nil {
+			t.Fatal("no nav read")
+		}
+
+		var got []string
+		for _, ch := range b.chapters {
+			got = append(got, strings.TrimSpace(ch.title+" "+ch.path+ch.url))
+		}
+		want := []string{
+			"Home " + tt.prefix + "/index.md",
+			"Guide",
+			"Setting up " + tt.prefix + "/guide/setup.md",
+			"Source https://example.com/repo",
+		}
+		if strings.Join(got, "\n") != strings.Join(want, "\n") {
+			t.Errorf("serving %s got chapters\n%s\nwant\n%s", tt.served, strings.Join(got, "\n"), strings.Join(want, "\n"))
+		}
+	}
+
+	// the nav is read once until a file changes
+	var nav mkdocsNav
+	nav.find(root)
+	b, _ := nav.get(http.Dir(root))
+	if err := os.WriteFile(filepath.Join(root, "docs", "guide", "setup.md"), []byte("# Setup"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	if again, _ := nav.get(http.Dir(root)); again != b {
+		t.Error("expected the nav to be cached")
+	}
+	nav.invalidate()
+	if b, _ = nav.get(http.Dir(root)); b == nil || b.chapters[2].title != "Setup" {
+		t.Errorf("expected the nav to be read again, got %+v", b)
+	}
+}
+
+func TestPageOrder(t *testing.T) {
+	root := t.TempDir()
+	files := map[string]string{
+		"README.md":       "# Home",
+		"b.md":            "# B",
+		"c.md":            "---\norder: 1\n---\n# C",
+		"guide/setup.md":  "# Setup",
+		"guide/index.md":  "# Guide",
+		"notes.txt":       "not a page",
+		"api/overview.md": "# API",
+	}
+	for name, content := range files {
+		p := filepath.Join(root, filepath.FromSlash(name))
+		if err := os.MkdirAll(filepath.Dir(p), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := os.WriteFile(p, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	s := NewServer(nil, 0, "", false, false, nil, WithPager(true))
+	b, _ := s.newSite(root).readBook(http.Dir(root))
+	if b == nil {
+		t.Fatal("no page order read")
+	}
+	var got []string
+	for _, ch := range b.chapters {
+		got = append(got, ch.path)
+	}
+	want := "/README.md /c.md /b.md /api/overview.md /guide/index.md /guide/setup.md"
+	if strings.Join(got, " ") != want {
+		t.Errorf("got order %s, want %s", strings.Join(got, " "), want)
+	}
+	if nav := b.nav("/b.md"); nav.Sidebar != "" || !strings.Contains(nav.Pager, `href="/c.md"`) || !strings.Contains(nav.Pager, `href="/api/overview.md"`) {
+		t.Errorf("unexpected nav %+v", nav)
+	}
+}
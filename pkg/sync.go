@@ -0,0 +1,141 @@
+package pkg
+
+import (
+	"encoding/json"
+	"log/slog"
+	"net/http"
+	"path"
+	"path/filepath"
+	"strings"
+	"sync"
+
+	"github.com/gorilla/websocket"
+)
+
+const syncEndpoint = "/sync_ws"
+
+// syncMessage tells previews which source line an editor shows. Editors send
+// the file relative to the served directory or as absolute path, previews
+// receive it as URL path.
+type syncMessage struct {
+	File string `json:"file,omitempty"`
+	Path string `json:"path,omitempty"`
+	Line int    `json:"line"`
+}
+
+// syncHub relays the cursor position sent by editor plugins over the sync
+// websocket to all previews, which scroll to the rendered element of the line.
+type syncHub struct {
+	directory string
+	upgrader  websocket.Upgrader
+	readLimit int64
+
+	mu      sync.Mutex
+	clients map[chan []byte]struct{}
+	done    chan struct{}
+	once    sync.Once
+}
+
+func newSyncHub(directory string) *syncHub {
+	if abs, err := filepath.Abs(directory); err == nil {
+		directory = abs
+	}
+	return &syncHub{
+		directory: directory,
+		clients:   make(map[chan []byte]struct{}),
+		done:      make(chan struct{}),
+	}
+}
+
+// close disconnects all clients.
+func (h *syncHub) close() {
+	h.once.Do(func() { close(h.done) })
+}
+
+// urlPath turns the file of an editor into the URL path of its preview.
+func (h *syncHub) urlPath(file string) (string, bool) {
+	if filepath.IsAbs(file) {
+		rel, err := filepath.Rel(h.directory, file)
+		if err != nil || rel == ".." || strings.HasPrefix(rel, ".."+string(filepath.Separator)) {
+			return "", false
+		}
+		file = rel
+	}
+	return path.Clean("/" + filepath.ToSlash(file)), true
+}
+
+func (h *syncHub) broadcast(from chan []byte, msg []byte) {
+	h.mu.Lock()
+	defer h.mu.Unlock()
+
+	for c := range h.clients {
+		if c == from {
+			continue
+		}
+		// drop the position if the client is behind, a newer one follows
+		select {
+		case c <- msg:
+		default:
+		}
+	}
+}
+
+func (h *syncHub) ServeHTTP(w http.ResponseWriter, req *http.Request) {
+	conn, err := h.upgrader.Upgrade(w, req, nil)
+	if err != nil {
+		slog.Error("failed to open sync websocket", "err", err)
+		return
+	}
+	defer conn.Close()
+	if h.readLimit > 0 {
+		conn.SetReadLimit(h.readLimit)
+	}
+
+	c := make(chan []byte, 1)
+	h.mu.Lock()
+	h.clients[c] = struct{}{}
+	h.mu.Unlock()
+
+	defer func() {
+		h.mu.Lock()
+		delete(h.clients, c)
+		h.mu.Unlock()
+	}()
+
+	closed := make(chan struct{})
+	go func() {
+		defer close(closed)
+		for {
+			_, data, err := conn.ReadMessage()
+			if err != nil {
+				return
+			}
+			var msg syncMessage
+			if err := json.Unmarshal(data, &msg); err != nil {
+				continue
+			}
+			p, ok := h.urlPath(msg.File)
+			if !ok || msg.Line < 1 {
+				continue
+			}
+			out, err := json.Marshal(syncMessage{Path: p, Line: msg.Line})
+			if err != nil {
+				continue
+			}
+			h.broadcast(c, out)
+		}
+	}()
+
+	for {
+		select {
+		case msg := <-c:
+			if err := conn.WriteMessage(websocket.TextMessage, msg); err != nil {
+				return
+			}
+		case <-closed:
+			return
+		case <-h.done:
+			return
+		}
+	}
+}
@@ -0,0 +1,289 @@
+package pkg
+
+import (
+	"bytes"
+	"html"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// fixTableCells adjusts inline nodes inside table cells to match GitHub's
+// rendering. The table parser splits rows on unescaped pipes only, so escaped
+// pipes inside code spans keep their backslash, and display math written as
+// $$...$$ is parsed as inline math wrapped in stray dollar signs.
+func fixTableCells(doc ast.Node) {
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		cell, ok := node.(*ast.TableCell)
+		if !entering || !ok {
+			return ast.GoToNext
+		}
+
+		ast.WalkFunc(cell, func(node ast.Node, entering bool) ast.WalkStatus {
+			if code, ok := node.(*ast.Code); ok && entering {
+				code.Literal = bytes.ReplaceAll(code.Literal, []byte(`\|`), []byte("|"))
+			}
+			return ast.GoToNext
+		})
+
+		fixDisplayMath(cell)
+		return ast.SkipChildren
+	})
+}
+
+// fixDisplayMath replaces the sequence Text("...$"), Math, Text("$...") in the
+// children of a container with a single display math span.
+func fixDisplayMath(parent ast.Node) {
+	children := parent.GetChildren()
+	for i := 1; i+1 < len(children); i++ {
+		math, ok := children[i].(*ast.Math)
+		if !ok {
+			continue
+		}
+		before, ok1 := children[i-1].(*ast.Text)
+		after, ok2 := children[i+1].(*ast.Text)
+		if !ok1 || !ok2 || !bytes.HasSuffix(before.Literal, []byte("$")) || !bytes.HasPrefix(after.Literal, []byte("$")) {
+			continue
+		}
+
+		before.Literal = before.Literal[:len(before.Literal)-1]
+		after.Literal = after.Literal[1:]
+
+		span := &ast.HTMLSpan{}
+		span.Literal = []byte(`<span class="math display">\[` + html.EscapeString(string(math.Literal)) + `\]</span>`)
+		span.Parent = parent
+		children[i] = span
+	}
+	parent.SetChildren(children)
+}
+
+// removeEmptyTableBodies removes the bodies of tables without rows, which
+// GitHub leaves out.
+func removeEmptyTableBodies(doc ast.Node) {
+	var empty []ast.Node
+	ast.WalkFunc(doc, func(node ast.Node, entering bool) ast.WalkStatus {
+		if body, ok := node.(*ast.TableBody); ok && entering && len(body.GetChildren()) == 0 {
+			empty = append(empty, body)
+		}
+		return ast.GoToNext
+	})
+	for _, body := range empty {
+		ast.RemoveFromTree(body)
+	}
+}
+
+// normalizeTables rewrites the tables of md the way GitHub splits them into
+// cells, so the parser reads them the same way:
+//
+//   - rows are split at every unescaped pipe, also inside code spans, and
+//     backticks left without partner in their cell are escaped
+//   - empty cells are kept instead of spanning the previous column
+//   - lines without pipes continue the table until a blank line or another
+//     block, and cells beyond the columns of the header are dropped
+//   - lines looking like a delimiter row whose header has a different
+//     number of cells are no table, their pipes are escaped
+//
+// Tables in block quotes and indented more than three spaces are kept, and
+// md is returned as is if there are no tables.
+func normalizeTables(md []byte) []byte {
+	if !bytes.Contains(md, []byte("|")) || !bytes.Contains(md, []byte("-")) {
+		return md
+	}
+	lines := bytes.SplitAfter(md, []byte("\n"))
+	var out bytes.Buffer
+	changed := false
+	fence := ""
+
+	for i := 0; i < len(lines); i++ {
+		line := lines[i]
+		indent := indentation(line)
+		rest := bytes.TrimLeft(line, " \t")
+		switch {
+		case fence != "":
+			if indent <= 3 && isFenceClosing(rest, fence) {
+				fence = ""
+			}
+			out.Write(line)
+			continue
+		case indent <= 3:
+			if marker, _, ok := fenceOpening(rest); ok {
+				fence = marker
+				out.Write(line)
+				continue
+			}
+		}
+
+		if indent > 3 || i+1 >= len(lines) || !isDelimiterRow(lines[i+1]) {
+			if indent <= 3 && isDelimiterRow(line) && bytes.Contains(line, []byte("|")) {
+				// the delimiter row of no table
+				out.Write(escapePipes(line))
+				changed = true
+				continue
+			}
+			out.Write(line)
+			continue
+		}
+		header := tableCells(line)
+		delimiter := tableCells(lines[i+1])
+		if len(header) != len(delimiter) {
+			out.Write(line)
+			continue
+		}
+
+		prefix := line[:len(line)-len(rest)]
+		writeRow := func(cells [][]byte) {
+			out.Write(prefix)
+			out.WriteString("|")
+			for c := range header {
+				out.WriteString(" ")
+				if c < len(cells) {
+					out.Write(escapeUnpairedBackticks(cells[c]))
+				}
+				out.WriteString(" |")
+			}
+			out.WriteString("\n")
+		}
+		writeRow(header)
+		out.Write(prefix)
+		out.WriteString("| ")
+		out.Write(bytes.Join(delimiter, []byte(" | ")))
+		out.WriteString(" |\n")
+		i += 2
+		for ; i < len(lines) && !endsTable(lines[i]); i++ {
+			writeRow(tableCells(lines[i]))
+		}
+		i--
+		changed = true
+	}
+	if !changed {
+		return md
+	}
+	return out.Bytes()
+}
+
+// endsTable reports whether line ends a table: a blank line, or the start of
+// another block.
+func endsTable(line []byte) bool {
+	rest := bytes.TrimLeft(line, " \t")
+	if len(bytes.TrimSpace(rest)) == 0 || indentation(line) > 3 {
+		return true
+	}
+	if _, _, ok := fenceOpening(rest); ok {
+		return true
+	}
+	heading := bytes.TrimLeft(rest, "#")
+	if n := len(rest) - len(heading); n > 0 && n <= 6 && (len(bytes.TrimSpace(heading)) == 0 || heading[0] == ' ') {
+		return true
+	}
+	return rest[0] == '>' || isThematicBreak(rest) || listMarkerWidth(rest) > 0
+}
+
+// isDelimiterRow reports whether line is the delimiter row of a table, like
+// "| :-- | --: |" or "--|--".
+func isDelimiterRow(line []byte) bool {
+	if indentation(line) > 3 || !bytes.ContainsAny(line, "|:") {
+		return false
+	}
+	cells := tableCells(line)
+	if len(cells) == 0 {
+		return false
+	}
+	for _, c := range cells {
+		c = bytes.TrimPrefix(bytes.TrimSuffix(c, []byte(":")), []byte(":"))
+		if len(c) == 0 || len(bytes.Trim(c, "-")) != 0 {
+			return false
+		}
+	}
+	return true
+}
+
+// tableCells splits a table row into its trimmed cells at unescaped pipes,
+// ignoring the pipes at the start and end of the row.
+func tableCells(line []byte) [][]byte {
+	line = bytes.TrimSpace(line)
+	line = bytes.TrimPrefix(line, []byte("|"))
+	if n := len(line); n > 0 && line[n-1] == '|' && !escaped(line, n-1) {
+		line = line[:n-1]
+	}
+	var cells [][]byte
+	start := 0
+	for i := 0; i <= len(line); i++ {
+		if i == len(line) || (line[i] == '|' && !escaped(line, i)) {
+			cells = append(cells, bytes.TrimSpace(line[start:i]))
+			start = i + 1
+		}
+	}
+	return cells
+}
+
+// escaped reports whether the character at i is escaped with a backslash.
+func escaped(data []byte, i int) bool {
+	n := 0
+	for i-n-1 >= 0 && data[i-n-1] == '\\' {
+		n++
+	}
+	return n%2 == 1
+}
+
+// escapePipes escapes the unescaped pipes of line.
+func escapePipes(line []byte) []byte {
+	var out []byte
+	for i, c := range line {
+		if c == '|' && !escaped(line, i) {
+			out = append(out, '\\')
+		}
+		out = append(out, c)
+	}
+	return out
+}
+
+// escapeUnpairedBackticks escapes the backtick runs of a cell that don't
+// start or end a code span in it, so code spans don't continue in the next
+// cell.
+func escapeUnpairedBackticks(cell []byte) []byte {
+	if !bytes.Contains(cell, []byte("`")) {
+		return cell
+	}
+	var out []byte
+	for i := 0; i < len(cell); {
+		if cell[i] != '`' || escaped(cell, i) {
+			out = append(out, cell[i])
+			i++
+			continue
+		}
+		n := 0
+		for i+n < len(cell) && cell[i+n] == '`' {
+			n++
+		}
+		if end := closingBackticks(cell, i+n, n); end >= 0 {
+			// copy the code span as is
+			out = append(out, cell[i:end+n]...)
+			i = end + n
+			continue
+		}
+		for range n {
+			out = append(out, '\\', '`')
+		}
+		i += n
+	}
+	return out
+}
+
+// closingBackticks returns the position of the next run of exactly n
+// backticks in cell from start, or -1.
+func closingBackticks(cell []byte, start int, n int) int {
+	for i := start; i < len(cell); {
+		if cell[i] != '`' {
+			i++
+			continue
+		}
+		m := 0
+		for i+m < len(cell) && cell[i+m] == '`' {
+			m++
+		}
+		if m == n {
+			return i
+		}
+		i += m
+	}
+	return -1
+}
@@ -0,0 +1,354 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"regexp"
+	"strings"
+	"unicode/utf8"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/formatters"
+	"github.com/alecthomas/chroma/v2/lexers"
+	"github.com/alecthomas/chroma/v2/styles"
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// DefaultTermWidth is the width terminal output is wrapped at when the
+// terminal size is unknown.
+const DefaultTermWidth = 80
+
+var ansiEscape = regexp.MustCompile("\x1b\\[[0-9;]*m")
+
+// termRenderer renders a parsed document as text for a terminal, styled with
+// ANSI escape codes unless color is disabled.
+type termRenderer struct {
+	theme string
+	color bool
+	lexer func(lang string, source string) chroma.Lexer
+}
+
+// MdToTerm renders markdown for reading in a terminal, wrapping paragraphs at
+// width. Without color the output is plain text.
+func (m Parser) MdToTerm(md []byte, width int, color bool) []byte {
+	if width <= 0 {
+		width = DefaultTermWidth
+	}
+	r := termRenderer{theme: m.theme, color: color, lexer: m.lexer}
+	out := r.blocks(m.parse(md).GetChildren(), width, false)
+	return []byte(strings.TrimRight(out, "\n") + "\n")
+}
+
+func (r termRenderer) style(codes string, s string) string {
+	if !r.color || s == "" {
+		return s
+	}
+	return "\x1b[" + codes + "m" + s + "\x1b[0m"
+}
+
+// blocks renders block nodes separated by blank lines, or by line breaks in
+// tight lists.
+func (r termRenderer) blocks(nodes []ast.Node, width int, tight bool) string {
+	sep := "\n\n"
+	if tight {
+		sep = "\n"
+	}
+	var out []string
+	for _, n := range nodes {
+		if s := r.block(n, width); s != "" {
+			out = append(out, s)
+		}
+	}
+	return strings.Join(out, sep)
+}
+
+func (r termRenderer) block(node ast.Node, width int) string {
+	switch n := node.(type) {
+	case *ast.Heading:
+		text := strings.Repeat("#", n.Level) + " " + r.inline(n)
+		switch n.Level {
+		case 1:
+			return r.style("1;4;35", text)
+		case 2:
+			return r.style("1;35", text)
+		default:
+			return r.style("1", text)
+		}
+	case *ast.Paragraph:
+		return wrapText(r.inline(n), width)
+	case *ast.List:
+		return r.list(n, width)
+	case *ast.BlockQuote:
+		return r.blockQuote(n, width)
+	case *ast.CodeBlock:
+		lang, attrs := parseFenceInfo(string(n.Info))
+		code := indentLines(r.code(string(n.Literal), lang), "    ", "    ")
+		if title := attrs["title"]; title != "" {
+			return "    " + r.style("2", title) + "\n" + code
+		}
+		return code
+	case *ast.MathBlock:
+		return indentLines(r.style("36", strings.TrimRight(string(n.Literal), "\n")), "    ", "    ")
+	case *ast.HorizontalRule:
+		return r.style("2", strings.Repeat("─", width))
+	case *ast.Table:
+		return r.table(n)
+	case *ast.HTMLBlock:
+		return r.style("2", strings.TrimRight(string(n.Literal), "\n"))
+	default:
+		if len(node.GetChildren()) > 0 {
+			return r.blocks(node.GetChildren(), width, false)
+		}
+		return ""
+	}
+}
+
+func (r termRenderer) list(list *ast.List, width int) string {
+	var items []string
+	number := list.Start
+	if number == 0 {
+		number = 1
+	}
+	for _, child := range list.GetChildren() {
+		item, ok := child.(*ast.ListItem)
+		if !ok {
+			continue
+		}
+		marker := "• "
+		switch {
+		case item.ListFlags&ast.ListTypeTerm != 0:
+			items = append(items, r.style("1", r.blocks(item.GetChildren(), width, true)))
+			continue
+		case item.ListFlags&ast.ListTypeDefinition != 0:
+			marker = "  "
+		case list.ListFlags&ast.ListTypeOrdered != 0:
+			marker = fmt.Sprintf("%d. ", number)
+			number++
+		}
+		pad := strings.Repeat(" ", utf8.RuneCountInString(marker))
+		body := r.blocks(item.GetChildren(), width-len(pad), list.Tight)
+		body = r.taskMarker(body)
+		items = append(items, indentLines(body, r.style("2", marker), pad))
+	}
+	if list.Tight {
+		return strings.Join(items, "\n")
+	}
+	return strings.Join(items, "\n\n")
+}
+
+// taskMarker replaces the [ ] and [x] of task list items with check boxes.
+func (r termRenderer) taskMarker(body string) string {
+	switch {
+	case strings.HasPrefix(body, "[ ] "):
+		return "☐ " + body[len("[ ] "):]
+	case strings.HasPrefix(body, "[x] "):
+		return r.style("32", "☑") + " " + body[len("[x] "):]
+	}
+	return body
+}
+
+func (r termRenderer) blockQuote(quote *ast.BlockQuote, width int) string {
+	color := "2"
+	body := r.blocks(quote.GetChildren(), width-2, false)
+	if alert := alertType(quote); alert != "" {
+		color = map[string]string{
+			"note":      "34",
+			"tip":       "32",
+			"important": "35",
+			"warning":   "33",
+			"caution":   "31",
+		}[alert]
+		// the [!NOTE] marker is shown as a colored title instead
+		_, rest, _ := strings.Cut(body, "]")
+		body = r.style("1;"+color, strings.ToUpper(alert[:1])+alert[1:]) + "\n" + strings.TrimLeft(rest, " \n")
+	}
+	bar := r.style(color, "│") + " "
+	return indentLines(body, bar, bar)
+}
+
+// code highlights source for the terminal with the code style of the theme.
+func (r termRenderer) code(source string, lang string) string {
+	source = strings.TrimRight(source, "\n")
+	if !r.color {
+		return source
+	}
+	lexer := r.lexer(lang, source)
+	if lexer == nil {
+		lexer = lexers.Get("plaintext")
+	}
+	iterator, err := chroma.Coalesce(lexer).Tokenise(nil, source)
+	if err != nil {
+		return source
+	}
+	style := styles.Get("github-dark")
+	for _, mode := range colorModes {
+		if mode.Name == r.theme {
+			style = styles.Get(mode.CodeStyle)
+		}
+	}
+	var buf bytes.Buffer
+	if err := formatters.TTY256.Format(&buf, style, iterator); err != nil {
+		return source
+	}
+	return strings.TrimRight(buf.String(), "\n")
+}
+
+func (r termRenderer) table(table *ast.Table) string {
+	var rows [][]string
+	header := 0
+	ast.WalkFunc(table, func(node ast.Node, entering bool) ast.WalkStatus {
+		row, ok := node.(*ast.TableRow)
+		if !ok || !entering {
+			return ast.GoToNext
+		}
+		var cells []string
+		for _, c := range row.GetChildren() {
+			cells = append(cells, r.inline(c))
+		}
+		rows = append(rows, cells)
+		if _, ok := row.GetParent().(*ast.TableHeader); ok {
+			header = len(rows)
+		}
+		return ast.SkipChildren
+	})
+
+	var widths []int
+	for _, row := range rows {
+		for i, cell := range row {
+			if i >= len(widths) {
+				widths = append(widths, 0)
+			}
+			widths[i] = max(widths[i], visibleWidth(cell))
+		}
+	}
+
+	sep := r.style("2", " │ ")
+	var lines []string
+	for i, row := range rows {
+		cells := make([]string, len(widths))
+		for j := range widths {
+			cell := ""
+			if j < len(row) {
+				cell = row[j]
+			}
+			if i < header {
+				cell = r.style("1", cell)
+			}
+			cells[j] = cell + strings.Repeat(" ", widths[j]-visibleWidth(cell))
+		}
+		lines = append(lines, strings.TrimRight(strings.Join(cells, sep), " "))
+		if i == header-1 {
+			rules := make([]string, len(widths))
+			for j, w := range widths {
+				rules[j] = strings.Repeat("─", w)
+			}
+			lines = append(lines, r.style("2", strings.Join(rules, "─┼─")))
+		}
+	}
+	return strings.Join(lines, "\n")
+}
+
+// inline renders the inline content of a node on a single line, keeping
+// only explicit line breaks.
+func (r termRenderer) inline(node ast.Node) string {
+	var b strings.Builder
+	for _, child := range node.GetChildren() {
+		switch n := child.(type) {
+		case *ast.Text:
+			b.WriteString(r.emoji(string(n.Literal)))
+		case *ast.Softbreak:
+			b.WriteString(" ")
+		case *ast.Hardbreak:
+			b.WriteString("\n")
+		case *ast.NonBlockingSpace:
+			b.WriteString(" ")
+		case *ast.Emph:
+			b.WriteString(r.style("3", r.inline(n)))
+		case *ast.Strong:
+			b.WriteString(r.style("1", r.inline(n)))
+		case *ast.Del:
+			b.WriteString(r.style("9", r.inline(n)))
+		case *ast.Code:
+			if r.color {
+				b.WriteString(r.style("36", string(n.Literal)))
+			} else {
+				b.WriteString("`" + string(n.Literal) + "`")
+			}
+		case *ast.Math:
+			b.WriteString(r.style("36", string(n.Literal)))
+		case *ast.Link:
+			text := r.inline(n)
+			b.WriteString(r.style("4;34", text))
+			if dest := string(n.Destination); dest != "" && dest != ansiEscape.ReplaceAllString(text, "") && !strings.HasPrefix(dest, "#") {
+				b.WriteString(" " + r.style("2", "("+dest+")"))
+			}
+		case *ast.Image:
+			b.WriteString(r.style("2", "[image: "+r.inline(n)+"] ("+string(n.Destination)+")"))
+		case *ast.HTMLSpan:
+			if bytes.HasPrefix(n.Literal, []byte("<abbr")) || string(n.Literal) == "</abbr>" {
+				continue
+			}
+			b.WriteString(r.style("2", string(n.Literal)))
+		default:
+			b.WriteString(r.inline(child))
+		}
+	}
+	return b.String()
+}
+
+// emoji replaces emoji shortcodes with the emoji characters, keeping the ones
+// only GitHub has images for.
+func (r termRenderer) emoji(text string) string {
+	return emojiShortcode.ReplaceAllStringFunc(text, func(s string) string {
+		if val, ok := EmojiMap[s]; ok && !strings.HasPrefix(val, "/") {
+			return val
+		}
+		return s
+	})
+}
+
+// visibleWidth is the number of characters s takes up on the terminal.
+func visibleWidth(s string) int {
+	return utf8.RuneCountInString(ansiEscape.ReplaceAllString(s, ""))
+}
+
+// wrapText breaks text into lines of at most width characters at spaces,
+// keeping existing line breaks.
+func wrapText(text string, width int) string {
+	var lines []string
+	for _, line := range strings.Split(text, "\n") {
+		current, n := "", 0
+		for _, word := range strings.Fields(line) {
+			w := visibleWidth(word)
+			if n > 0 && n+1+w > width {
+				lines = append(lines, current)
+				current, n = "", 0
+			}
+			if n > 0 {
+				current += " "
+				n++
+			}
+			current += word
+			n += w
+		}
+		lines = append(lines, current)
+	}
+	return strings.Join(lines, "\n")
+}
+
+// indentLines prefixes the first line of s with first and the others with
+// rest.
+func indentLines(s string, first string, rest string) string {
+	lines := strings.Split(s, "\n")
+	for i, line := range lines {
+		prefix := rest
+		if i == 0 {
+			prefix = first
+		}
+		if line == "" {
+			prefix = strings.TrimRight(prefix, " ")
+		}
+		lines[i] = prefix + line
+	}
+	return strings.Join(lines, "\n")
+}
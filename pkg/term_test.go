@@ -0,0 +1,21 @@
+package pkg
+
+import (
+	"strings"
+	"testing"
+)
+
+func TestMdToTerm(t *testing.T) {
+	input := "# Title\n\nSome **bold** text and `code`.\n\n1. one\n2. [x] two\n\n> quoted\n"
+	want := "# Title\n\nSome bold text\nand `code`.\n\n1. one\n2. ☑ two\n\n│ quoted\n"
+
+	got := string(NewParser("light").MdToTerm([]byte(input), 16, false))
+	if got != want {
+		t.Errorf("got\n%s\nwant\n%s", got, want)
+	}
+
+	colored := string(NewParser("light").MdToTerm([]byte(input), 80, true))
+	if !strings.Contains(colored, "\x1b[1mbold\x1b[0m") {
+		t.Errorf("expected bold text to be styled, got %q", colored)
+	}
+}
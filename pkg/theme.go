@@ -0,0 +1,201 @@
+package pkg
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"fmt"
+	"net/http"
+	"regexp"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/alecthomas/chroma/v2"
+	"github.com/alecthomas/chroma/v2/styles"
+)
+
+// colorMode is one of GitHub's Primer color modes. Scheme is the
+// prefers-color-scheme value the mode belongs to; in the auto theme only the
+// base light and dark modes are used.
+type colorMode struct {
+	Name       string
+	Scheme     string
+	Stylesheet string
+	CodeStyle  string
+}
+
+// SyntaxStylesheet is the file name of the syntax highlighting stylesheet of
+// the mode in the static directory.
+func (m colorMode) SyntaxStylesheet() string {
+	return "syntax-" + strings.ReplaceAll(m.Name, "_", "-") + ".css"
+}
+
+var colorModes = []colorMode{
+	{Name: "light", Scheme: "light", Stylesheet: "github-markdown-light.css", CodeStyle: "github"},
+	{Name: "dark", Scheme: "dark", Stylesheet: "github-markdown-dark.css", CodeStyle: "github-dark"},
+	{Name: "dark_dimmed", Scheme: "dark", Stylesheet: "github-markdown-dark-dimmed.css", CodeStyle: "github-dark-dimmed"},
+	{Name: "dark_high_contrast", Scheme: "dark", Stylesheet: "github-markdown-dark-high-contrast.css", CodeStyle: "github-dark-high-contrast"},
+	{Name: "light_high_contrast", Scheme: "light", Stylesheet: "github-markdown-light-high-contrast.css", CodeStyle: "github-light-high-contrast"},
+}
+
+func validTheme(theme string) bool {
+	if theme == "auto" {
+		return true
+	}
+	for _, m := range colorModes {
+		if m.Name == theme {
+			return true
+		}
+	}
+	return false
+}
+
+// media returns the media query enabling the mode's stylesheets for a theme.
+func (m colorMode) media(theme string) string {
+	switch {
+	case theme == "auto" && m.Name == m.Scheme:
+		return "(prefers-color-scheme: " + m.Scheme + ")"
+	case theme == m.Name:
+		return "all"
+	default:
+		return "not all"
+	}
+}
+
+type colorModeStyle struct {
+	Name             string
+	Scheme           string
+	Stylesheet       string
+	SyntaxStylesheet string
+	Media            string
+	// CssCode is the content of SyntaxStylesheet, for custom layouts
+	// inlining it
+	CssCode string
+}
+
+// ColorModes returns the stylesheets of every color mode for the layout
+// template, with only the ones of the selected theme enabled.
+func (h htmlStruct) ColorModes() []colorModeStyle {
+	out := make([]colorModeStyle, 0, len(colorModes))
+	for _, m := range colorModes {
+		out = append(out, colorModeStyle{
+			Name:             m.Name,
+			Scheme:           m.Scheme,
+			Stylesheet:       m.Stylesheet,
+			SyntaxStylesheet: m.SyntaxStylesheet(),
+			Media:            m.media(h.Theme),
+			CssCode:          string(syntaxStylesheets()[m.SyntaxStylesheet()]),
+		})
+	}
+	return out
+}
+
+// cssRule matches the selector of a rule written by chroma's WriteCSS.
+var cssRule = regexp.MustCompile(`(?m)^(/\* \w+ \*/ )\.`)
+
+// syntaxStylesheets returns the syntax highlighting stylesheets of the color
+// modes by file name. They are generated once and scoped to the rendered
+// documents, without changing the specificity of chroma's selectors.
+var syntaxStylesheets = sync.OnceValue(func() map[string][]byte {
+	sheets := make(map[string][]byte, len(colorModes))
+	for _, m := range colorModes {
+		css := cssRule.ReplaceAllString(getCssCode(m.CodeStyle), "$1:where(.markdown-body) .")
+		sheets[m.SyntaxStylesheet()] = []byte(css)
+	}
+	return sheets
+})
+
+// serveSyntaxStylesheets serves the syntax highlighting stylesheets below
+// /static/ with a validator, so browsers load them once instead of with
+// every page.
+func serveSyntaxStylesheets(mux *http.ServeMux) {
+	for name, css := range syntaxStylesheets() {
+		etag := fmt.Sprintf(`"%x"`, sha256.Sum256(css))
+		mux.HandleFunc("/static/"+name, func(w http.ResponseWriter, r *http.Request) {
+			w.Header().Set("Content-Type", "text/css; charset=utf-8")
+			w.Header().Set("Cache-Control", "public, max-age=3600")
+			w.Header().Set("ETag", etag)
+			http.ServeContent(w, r, name, time.Time{}, bytes.NewReader(css))
+		})
+	}
+}
+
+// Syntax highlighting palettes of the Primer color modes chroma doesn't ship,
+// derived from chroma's github styles by swapping their colors.
+func init() {
+	registerCodeStyle("github-dark-dimmed", "github-dark", map[string]string{
+		"#0d1117": "#212830",
+		"#0f5323": "#1b4721",
+		"#490202": "#78191b",
+		"#56d364": "#8ddb8c",
+		"#6e7681": "#636e7b",
+		"#79c0ff": "#6cb6ff",
+		"#7ee787": "#8ddb8c",
+		"#8b949e": "#768390",
+		"#a5d6ff": "#96d0ff",
+		"#d2a8ff": "#dcbdfb",
+		"#e6edf3": "#adbac7",
+		"#f0883e": "#f69d50",
+		"#f85149": "#e5534b",
+		"#ff7b72": "#f47067",
+		"#ffa198": "#ffd8d3",
+		"#ffa657": "#f69d50",
+	})
+	registerCodeStyle("github-dark-high-contrast", "github-dark", map[string]string{
+		"#0d1117": "#0a0c10",
+		"#0f5323": "#006222",
+		"#490202": "#ad0116",
+		"#56d364": "#4ae168",
+		"#6e7681": "#9ea7b3",
+		"#79c0ff": "#91cbff",
+		"#7ee787": "#72f088",
+		"#8b949e": "#bdc4cc",
+		"#a5d6ff": "#addcff",
+		"#d2a8ff": "#dbb7ff",
+		"#e6edf3": "#f0f3f6",
+		"#f0883e": "#ffb757",
+		"#f85149": "#ff6a69",
+		"#ff7b72": "#ff9492",
+		"#ffa198": "#ffdcd7",
+		"#ffa657": "#ffb757",
+	})
+	registerCodeStyle("github-light-high-contrast", "github", map[string]string{
+		"#000080": "#023b95",
+		"#008080": "#024c1a",
+		"#0086b3": "#023b95",
+		"#009926": "#024c1a",
+		"#009999": "#023b95",
+		"#445588": "#622cbc",
+		"#555555": "#0e1116",
+		"#800080": "#622cbc",
+		"#888888": "#4b535d",
+		"#990000": "#86061d",
+		"#990073": "#622cbc",
+		"#999988": "#4b535d",
+		"#999999": "#4b535d",
+		"#aaaaaa": "#4b535d",
+		"#bbbbbb": "#4b535d",
+		"#dd1144": "#032563",
+	})
+}
+
+func registerCodeStyle(name string, base string, colors map[string]string) {
+	swap := func(c chroma.Colour) chroma.Colour {
+		if to, ok := colors[c.String()]; c.IsSet() && ok {
+			return chroma.MustParseColour(to)
+		}
+		return c
+	}
+	b := styles.Get(base).Builder().Transform(func(e chroma.StyleEntry) chroma.StyleEntry {
+		e.Colour = swap(e.Colour)
+		e.Background = swap(e.Background)
+		e.Border = swap(e.Border)
+		return e
+	})
+	style, err := b.Build()
+	if err != nil {
+		panic(err)
+	}
+	style.Name = name
+	styles.Register(style)
+}
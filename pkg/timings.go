@@ -0,0 +1,63 @@
+package pkg
+
+import (
+	"context"
+	"log/slog"
+	"path"
+	"strings"
+	"time"
+)
+
+// WithRenderTimings logs every render with the time spent parsing markdown,
+// highlighting code and executing the layout template and the size of the
+// page, to find out why huge or pathological documents render slowly. Pages
+// served from the render cache are not logged.
+func WithRenderTimings(enabled bool) Option {
+	return func(s *Server) {
+		s.renderTimings = enabled
+	}
+}
+
+// renderStats collects the time spent in the steps of rendering markdown
+// and the number of headings of the document, -1 until it is parsed. It is
+// set on the copy of the parser rendering one document, so concurrent
+// renders don't share it.
+type renderStats struct {
+	parse     time.Duration
+	highlight time.Duration
+	headings  int
+}
+
+func (st *renderStats) addParse(start time.Time) {
+	st.parse += time.Since(start)
+}
+
+func (st *renderStats) addHighlight(start time.Time) {
+	st.highlight += time.Since(start)
+}
+
+// timedRenderer returns the renderer of the file name rendering markdown
+// with ctx and collecting its steps in stats, see renderMarkdownStats, or
+// render itself for other formats and markdown rendered by a renderer of
+// WithRenderer.
+func (s *Server) timedRenderer(ctx context.Context, render Renderer, name string, stats *renderStats) Renderer {
+	if !s.IsMarkdown(name) || s.customRenderers[strings.ToLower(path.Ext(name))] {
+		return render
+	}
+	return func(content []byte, name string) ([]byte, string, error) {
+		return s.renderMarkdownStats(ctx, content, name, stats)
+	}
+}
+
+// logRenderTimings logs the timings of a render of name that took total.
+func logRenderTimings(name string, stats *renderStats, render time.Duration, layout time.Duration, size int64, total time.Duration) {
+	attrs := []any{"path", name}
+	if stats.parse > 0 {
+		// the rest of the render is turning the parsed document into HTML
+		attrs = append(attrs, "parse", stats.parse, "highlight", stats.highlight, "html", render-stats.parse-stats.highlight)
+	} else {
+		attrs = append(attrs, "render", render)
+	}
+	attrs = append(attrs, "template", layout, "bytes", size, "duration", total)
+	slog.Info("rendered page", attrs...)
+}
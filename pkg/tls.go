@@ -0,0 +1,105 @@
+package pkg
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/tls"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"fmt"
+	"math/big"
+	"net"
+	"net/http"
+	"time"
+)
+
+// tlsConfig returns the TLS configuration for the server, or nil if the
+// server should use plain HTTP.
+func (s *Server) tlsConfig() (*tls.Config, error) {
+	switch {
+	case s.tlsSelfSigned && (s.tlsCert != "" || s.tlsKey != ""):
+		return nil, fmt.Errorf("a TLS certificate and a self-signed certificate are mutually exclusive")
+	case s.tlsCert != "" || s.tlsKey != "":
+		if s.tlsCert == "" || s.tlsKey == "" {
+			return nil, fmt.Errorf("both a TLS certificate and key are required")
+		}
+		cert, err := tls.LoadX509KeyPair(s.tlsCert, s.tlsKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load TLS certificate: %v", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: s.nextProtos()}, nil
+	case s.tlsSelfSigned:
+		cert, err := selfSignedCert(s.hosts)
+		if err != nil {
+			return nil, fmt.Errorf("failed to generate TLS certificate: %v", err)
+		}
+		return &tls.Config{Certificates: []tls.Certificate{cert}, NextProtos: s.nextProtos()}, nil
+	}
+	return nil, nil
+}
+
+// nextProtos returns the protocols offered to clients in the TLS handshake.
+// http.Server.Serve speaks HTTP/2 on the TLS connections negotiating h2.
+func (s *Server) nextProtos() []string {
+	if s.http2 {
+		return []string{"h2", "http/1.1"}
+	}
+	return []string{"http/1.1"}
+}
+
+// newHTTPServer returns the HTTP server serving handler with the timeouts
+// of WithTimeouts.
+func (s *Server) newHTTPServer(handler http.Handler) *http.Server {
+	return &http.Server{
+		Handler:        handler,
+		MaxHeaderBytes: int(s.requestSizeLimit()),
+		ReadTimeout:    s.readTimeout,
+		WriteTimeout:   s.writeTimeout,
+		IdleTimeout:    s.idleTimeout,
+	}
+}
+
+// selfSignedCert generates a short-lived certificate valid for the given
+// hosts and the loopback addresses. Unspecified addresses like 0.0.0.0 only
+// tell the server to listen on every interface and are left out.
+func selfSignedCert(hosts []string) (tls.Certificate, error) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	serial, err := rand.Int(rand.Reader, new(big.Int).Lsh(big.NewInt(1), 128))
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	tmpl := x509.Certificate{
+		SerialNumber:          serial,
+		Subject:               pkix.Name{Organization: []string{"go-grip"}},
+		NotBefore:             time.Now().Add(-time.Hour),
+		NotAfter:              time.Now().Add(30 * 24 * time.Hour),
+		KeyUsage:              x509.KeyUsageDigitalSignature,
+		ExtKeyUsage:           []x509.ExtKeyUsage{x509.ExtKeyUsageServerAuth},
+		BasicConstraintsValid: true,
+		DNSNames:              []string{"localhost"},
+		IPAddresses:           []net.IP{net.IPv4(127, 0, 0, 1), net.IPv6loopback},
+	}
+	for _, h := range hosts {
+		if ip := net.ParseIP(h); ip != nil {
+			if ip.IsUnspecified() {
+				continue
+			}
+			tmpl.IPAddresses = append(tmpl.IPAddresses, ip)
+		} else if h != "localhost" {
+			tmpl.DNSNames = append(tmpl.DNSNames, h)
+		}
+	}
+
+	der, err := x509.CreateCertificate(rand.Reader, &tmpl, &tmpl, &key.PublicKey, key)
+	if err != nil {
+		return tls.Certificate{}, err
+	}
+
+	return tls.Certificate{Certificate: [][]byte{der}, PrivateKey: key}, nil
+}
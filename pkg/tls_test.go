@@ -0,0 +1,71 @@
+package pkg
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"slices"
+	"testing"
+	"time"
+)
+
+func TestHTTP2(t *testing.T) {
+	for _, enabled := range []bool{true, false} {
+		s := NewServer([]string{"127.0.0.1"}, 0, "light", false, false, NewParser("light"),
+			WithSelfSignedTLS(), WithHTTP2(enabled), WithTimeouts(time.Second, 0, time.Minute))
+		cfg, err := s.tlsConfig()
+		if err != nil {
+			t.Fatal(err)
+		}
+		l, err := net.Listen("tcp", "127.0.0.1:0")
+		if err != nil {
+			t.Fatal(err)
+		}
+		srv := s.newHTTPServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+		if srv.ReadTimeout != time.Second || srv.WriteTimeout != 0 || srv.IdleTimeout != time.Minute {
+			t.Errorf("unexpected timeouts %v, %v, %v", srv.ReadTimeout, srv.WriteTimeout, srv.IdleTimeout)
+		}
+		go srv.Serve(tls.NewListener(l, cfg))
+
+		client := &http.Client{Transport: &http.Transport{
+			TLSClientConfig:   &tls.Config{InsecureSkipVerify: true},
+			ForceAttemptHTTP2: true,
+		}}
+		resp, err := client.Get("https://" + l.Addr().String() + "/")
+		if err != nil {
+			t.Fatal(err)
+		}
+		resp.Body.Close()
+		srv.Close()
+		if want := map[bool]int{true: 2, false: 1}[enabled]; resp.ProtoMajor != want {
+			t.Errorf("WithHTTP2(%v): expected HTTP/%d, got %s", enabled, want, resp.Proto)
+		}
+	}
+}
+
+func TestSelfSignedCert(t *testing.T) {
+	cert, err := selfSignedCert([]string{"0.0.0.0", "::", "192.168.1.2", "example.local"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	parsed, err := x509.ParseCertificate(cert.Certificate[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	var ips []string
+	for _, ip := range parsed.IPAddresses {
+		ips = append(ips, ip.String())
+	}
+	if want := []string{"127.0.0.1", "::1", "192.168.1.2"}; !slices.Equal(ips, want) {
+		t.Errorf("got IP addresses %v, want %v", ips, want)
+	}
+	if want := []string{"localhost", "example.local"}; !slices.Equal(parsed.DNSNames, want) {
+		t.Errorf("got DNS names %v, want %v", parsed.DNSNames, want)
+	}
+
+	s := NewServer(nil, 0, "light", false, false, NewParser("light"), WithTLS("cert.pem", "key.pem"), WithSelfSignedTLS())
+	if _, err := s.tlsConfig(); err == nil {
+		t.Errorf("expected an error for a certificate and a self-signed certificate")
+	}
+}
@@ -0,0 +1,74 @@
+package pkg
+
+import (
+	"log/slog"
+	"strconv"
+	"strings"
+)
+
+// Typography customizes the width and text of pages. Empty fields keep the
+// defaults of the GitHub stylesheets.
+type Typography struct {
+	// MaxWidth is the width of the content on large screens, a CSS length,
+	// a number of pixels or "none" to use the full window width.
+	MaxWidth   string
+	FontFamily string
+	// FontSize is a CSS length or a number of pixels.
+	FontSize   string
+	LineHeight string
+}
+
+// WithTypography sets the width and font of pages, e.g. 980px like GitHub
+// instead of the default 896px. Values that could break out of the
+// stylesheet are ignored.
+func WithTypography(t Typography) Option {
+	return func(s *Server) {
+		for name, v := range map[string]*string{
+			"max-width":   &t.MaxWidth,
+			"font-family": &t.FontFamily,
+			"font-size":   &t.FontSize,
+			"line-height": &t.LineHeight,
+		} {
+			if strings.ContainsAny(*v, ";{}<>\\") {
+				slog.Warn("ignoring invalid typography value", "property", name, "value", *v)
+				*v = ""
+			}
+		}
+		s.typography = t
+	}
+}
+
+// CSS returns the stylesheet applying the typography, empty if nothing is
+// customized.
+func (t Typography) CSS() string {
+	var sb strings.Builder
+	if t.MaxWidth != "" {
+		width := pixels(t.MaxWidth)
+		if width == "full" {
+			width = "none"
+		}
+		sb.WriteString("@media (min-width: 940px) { .container { max-width: " + width + "; } }\n")
+	}
+	var body []string
+	if t.FontFamily != "" {
+		body = append(body, "font-family: "+t.FontFamily+";")
+	}
+	if t.FontSize != "" {
+		body = append(body, "font-size: "+pixels(t.FontSize)+";")
+	}
+	if t.LineHeight != "" {
+		body = append(body, "line-height: "+t.LineHeight+";")
+	}
+	if len(body) > 0 {
+		sb.WriteString(".markdown-body { " + strings.Join(body, " ") + " }\n")
+	}
+	return sb.String()
+}
+
+// pixels adds the px unit to plain numbers.
+func pixels(v string) string {
+	if _, err := strconv.ParseFloat(v, 64); err == nil {
+		return v + "px"
+	}
+	return v
+}
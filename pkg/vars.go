@@ -0,0 +1,230 @@
+package pkg
+
+import (
+	"bytes"
+	"fmt"
+	"os"
+	"regexp"
+	"strconv"
+	"strings"
+	"text/template"
+	"time"
+
+	"gopkg.in/yaml.v2"
+)
+
+// WithVars substitutes {{ .name }} placeholders in markdown documents, and
+// the files they include, with the fields of the front matter of the
+// document or else the variables of the YAML file, if file isn't empty, so
+// versions, product names and URLs are kept in one place. Documents are
+// executed as Go templates, placeholders failing to execute are shown as
+// caution alert above the unchanged document. Fenced code blocks, code spans
+// and code snippets embedded from files are not substituted.
+func WithVars(enabled bool, file string) Option {
+	return func(s *Server) {
+		s.vars = enabled
+		s.varsFile = file
+	}
+}
+
+// yamlVar decodes a variable of a YAML file or front matter. Numbers keep
+// the text they are written with, so version: 2.10 is substituted as 2.10
+// rather than 2.1.
+type yamlVar struct {
+	value any
+}
+
+func (v *yamlVar) UnmarshalYAML(unmarshal func(any) error) error {
+	if err := unmarshal(&v.value); err != nil {
+		return err
+	}
+	switch v.value.(type) {
+	case map[any]any:
+		var m map[string]yamlVar
+		if err := unmarshal(&m); err != nil {
+			return err
+		}
+		v.value = yamlVars(m)
+	case []any:
+		var l []yamlVar
+		if err := unmarshal(&l); err != nil {
+			return err
+		}
+		values := make([]any, len(l))
+		for i, e := range l {
+			values[i] = e.value
+		}
+		v.value = values
+	case int, int64, uint64, float64:
+		var s string
+		if err := unmarshal(&s); err != nil {
+			return err
+		}
+		v.value = s
+	}
+	return nil
+}
+
+// yamlVars returns the values of m, for templates.
+func yamlVars(m map[string]yamlVar) map[string]any {
+	values := make(map[string]any, len(m))
+	for k, v := range m {
+		values[k] = v.value
+	}
+	return values
+}
+
+// readVars returns the variables of the document content, the fields of its
+// front matter over the variables of the WithVars file, and the modification
+// time of the file.
+func (s *Server) readVars(content []byte) (map[string]any, time.Time, error) {
+	vars := make(map[string]any)
+	var modTime time.Time
+	if s.varsFile != "" {
+		info, err := os.Stat(s.varsFile)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to read variables: %v", err)
+		}
+		modTime = info.ModTime()
+		data, err := os.ReadFile(s.varsFile)
+		if err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to read variables: %v", err)
+		}
+		var file map[string]yamlVar
+		if err := yaml.Unmarshal(data, &file); err != nil {
+			return nil, time.Time{}, fmt.Errorf("failed to parse variables %s: %v", s.varsFile, err)
+		}
+		vars = yamlVars(file)
+	}
+	if _, data, _, ok := cutFrontMatter(content); ok {
+		var fm map[string]yamlVar
+		// invalid front matter is rendered as part of the document
+		if yaml.Unmarshal(data, &fm) == nil {
+			for k, v := range fm {
+				vars[k] = v.value
+			}
+		}
+	}
+	return vars, modTime, nil
+}
+
+// substituteVars executes the markdown file name as template with vars,
+// leaving its front matter and code as it is.
+func substituteVars(name string, content []byte, vars map[string]any) []byte {
+	block, _, body, _ := cutFrontMatter(content)
+	if !bytes.Contains(body, []byte("{{")) {
+		return content
+	}
+	text, code := hideCode(string(body))
+	var out bytes.Buffer
+	tmpl, err := template.New(name).Option("missingkey=error").Parse(text)
+	if err == nil {
+		err = tmpl.Execute(&out, vars)
+	}
+	var buf bytes.Buffer
+	buf.Write(block)
+	if err != nil {
+		writeVarsError(&buf, err)
+		buf.Write(body)
+		return buf.Bytes()
+	}
+	buf.WriteString(codePlaceholder.ReplaceAllStringFunc(out.String(), func(p string) string {
+		i, _ := strconv.Atoi(strings.Trim(p, "\x00"))
+		return code[i]
+	}))
+	return buf.Bytes()
+}
+
+// codePlaceholder matches the placeholders of hideCode.
+var codePlaceholder = regexp.MustCompile("\x00[0-9]+\x00")
+
+// hideCode replaces the fenced code blocks and code spans of markdown with
+// numbered placeholders, so templates leave them alone, and returns them by
+// number.
+func hideCode(md string) (string, []string) {
+	var out, text strings.Builder
+	var code []string
+	hide := func(c string) {
+		out.WriteString("\x00" + strconv.Itoa(len(code)) + "\x00")
+		code = append(code, c)
+	}
+	flush := func() {
+		hideCodeSpans(&out, text.String(), hide)
+		text.Reset()
+	}
+
+	var fence string
+	var block strings.Builder
+	for _, line := range strings.SplitAfter(md, "\n") {
+		trimmed := strings.TrimSpace(line)
+		if fence != "" {
+			block.WriteString(line)
+			if isFenceClosing([]byte(trimmed), fence) {
+				fence = ""
+				hide(block.String())
+				block.Reset()
+			}
+			continue
+		}
+		if marker, _, ok := fenceOpening([]byte(trimmed)); ok {
+			flush()
+			fence = marker
+			block.WriteString(line)
+			continue
+		}
+		text.WriteString(line)
+	}
+	if block.Len() > 0 {
+		// a fence left open runs to the end of the document
+		hide(block.String())
+	}
+	flush()
+	return out.String(), code
+}
+
+// hideCodeSpans writes text to out with its code spans, delimited by runs of
+// the same number of backticks, passed to hide instead.
+func hideCodeSpans(out *strings.Builder, text string, hide func(string)) {
+	backticks := func(i int) int {
+		n := 0
+		for i+n < len(text) && text[i+n] == '`' {
+			n++
+		}
+		return n
+	}
+	for i := 0; i < len(text); {
+		if text[i] != '`' {
+			out.WriteByte(text[i])
+			i++
+			continue
+		}
+		n := backticks(i)
+		end := -1
+		for j := i + n; j < len(text); {
+			k := strings.IndexByte(text[j:], '`')
+			if k < 0 {
+				break
+			}
+			k += j
+			m := backticks(k)
+			if m == n {
+				end = k + m
+				break
+			}
+			j = k + m
+		}
+		if end < 0 {
+			out.WriteString(text[i : i+n])
+			i += n
+			continue
+		}
+		hide(text[i:end])
+		i = end
+	}
+}
+
+// writeVarsError shows placeholders that failed to substitute as a caution
+// alert.
+func writeVarsError(buf *bytes.Buffer, err error) {
+	fmt.Fprintf(buf, "> [!CAUTION]\n> Failed to substitute variables: %v\n\n", err)
+}
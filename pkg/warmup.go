@@ -0,0 +1,132 @@
+package pkg
+
+import (
+	"context"
+	"log/slog"
+	"net/http"
+	"net/url"
+	"path"
+	"runtime"
+	"sync"
+	"time"
+)
+
+// WithWarmup renders all markdown files of the served directory at startup
+// in as many parallel workers as there are CPUs, so the render cache serves
+// the first visit of every page. It has no effect without render cache.
+func WithWarmup(enabled bool) Option {
+	return func(s *Server) {
+		s.warmup = enabled
+	}
+}
+
+// warmUp renders the markdown files in dir into the render cache until ctx is
+// cancelled.
+func (s *site) warmUp(ctx context.Context, dir http.FileSystem) {
+	if s.cache == nil {
+		return
+	}
+	start := time.Now()
+	var pages []string
+	walkFS(dir, "/", func(name string) {
+		if s.IsMarkdown(name) {
+			pages = append(pages, name)
+		}
+	})
+
+	jobs := make(chan string)
+	var wg sync.WaitGroup
+	for i := 0; i < runtime.GOMAXPROCS(0); i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			for name := range jobs {
+				s.warmPage(dir, name)
+			}
+		}()
+	}
+	rendered := 0
+loop:
+	for _, name := range pages {
+		select {
+		case jobs <- name:
+			rendered++
+		case <-ctx.Done():
+			break loop
+		}
+	}
+	close(jobs)
+	wg.Wait()
+	slog.Info("warmed up render cache", "pages", rendered, "duration", time.Since(start).Round(time.Millisecond))
+}
+
+// warmPage renders the page at name like a request for it would.
+func (s *site) warmPage(dir http.FileSystem, name string) {
+	render, ok := s.renderer(name, dir)
+	if !ok {
+		return
+	}
+	f, err := dir.Open(name)
+	if err != nil {
+		return
+	}
+	defer f.Close()
+	if reason, _, err := s.previewError(name, f); err != nil || reason != "" {
+		return
+	}
+
+	r := &http.Request{Method: http.MethodGet, URL: &url.URL{Path: name}, Header: make(http.Header)}
+	w := &discardResponse{header: make(http.Header)}
+	s.serveRenderedFile(w, r, dir, f, render)
+	if w.status >= http.StatusBadRequest {
+		slog.Debug("failed to warm up page", "page", name, "status", w.status)
+	}
+}
+
+// walkFS calls fn with the path of every file below dir in fsys, skipping
+// the files the file system hides.
+func walkFS(fsys http.FileSystem, dir string, fn func(name string)) {
+	f, err := fsys.Open(dir)
+	if err != nil {
+		return
+	}
+	infos, err := f.Readdir(-1)
+	f.Close()
+	if err != nil {
+		return
+	}
+	for _, info := range infos {
+		name := path.Join(dir, info.Name())
+		if info.IsDir() {
+			if info.Name() != "node_modules" {
+				walkFS(fsys, name, fn)
+			}
+			continue
+		}
+		fn(name)
+	}
+}
+
+// discardResponse is a response writer dropping the response, keeping only
+// its status.
+type discardResponse struct {
+	header http.Header
+	status int
+}
+
+func (w *discardResponse) Header() http.Header {
+	return w.header
+}
+
+func (w *discardResponse) Write(b []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	return len(b), nil
+}
+
+func (w *discardResponse) WriteHeader(status int) {
+	if w.status == 0 {
+		w.status = status
+	}
+}
@@ -0,0 +1,124 @@
+package pkg
+
+import (
+	"bytes"
+	"html"
+	"io/fs"
+	"net/url"
+	"path"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/gomarkdown/markdown/ast"
+	"github.com/gomarkdown/markdown/parser"
+)
+
+// WithWikiLinks enables Obsidian and GitHub wiki style [[Page Name]] and
+// [[Page Name|text]] links, resolved against the files below root.
+func WithWikiLinks(root string) ParserOption {
+	return func(p *Parser) {
+		p.wikiRoot = root
+	}
+}
+
+// registerWikiLinks makes p parse [[...]] as wiki links if the document
+// contains any, in which case the tree below root is indexed once.
+func registerWikiLinks(p *parser.Parser, md []byte, root string) {
+	if root == "" || !bytes.Contains(md, []byte("[[")) {
+		return
+	}
+	index := newWikiIndex(root)
+
+	var link parser.InlineParser
+	link = p.RegisterInline('[', func(p *parser.Parser, data []byte, offset int) (int, ast.Node) {
+		rest := data[offset:]
+		if !bytes.HasPrefix(rest, []byte("[[")) {
+			return link(p, data, offset)
+		}
+		end := bytes.Index(rest, []byte("]]"))
+		inner := string(rest[2:max(end, 2)])
+		if end < 0 || strings.TrimSpace(inner) == "" || strings.ContainsAny(inner, "[\n") {
+			return link(p, data, offset)
+		}
+		return end + 2, index.link(inner)
+	})
+}
+
+// wikiIndex maps normalized page names to the slash separated paths of the
+// files below the root, preferring markdown files and shallower paths.
+type wikiIndex map[string]string
+
+func newWikiIndex(root string) wikiIndex {
+	var files []string
+	_ = filepath.WalkDir(root, func(p string, d fs.DirEntry, err error) error {
+		if err != nil {
+			return nil
+		}
+		if d.IsDir() && p != root && strings.HasPrefix(d.Name(), ".") {
+			return filepath.SkipDir
+		}
+		if !d.IsDir() {
+			if rel, err := filepath.Rel(root, p); err == nil {
+				files = append(files, filepath.ToSlash(rel))
+			}
+		}
+		return nil
+	})
+
+	rank := func(f string) int {
+		r := strings.Count(f, "/")
+		if !slices.Contains(DefaultMarkdownExtensions, strings.ToLower(path.Ext(f))) {
+			r += 1000
+		}
+		return r
+	}
+	slices.SortStableFunc(files, func(a, b string) int { return rank(a) - rank(b) })
+
+	index := make(wikiIndex)
+	for _, f := range files {
+		trimmed := strings.TrimSuffix(f, path.Ext(f))
+		for _, name := range []string{f, trimmed, path.Base(f), path.Base(trimmed)} {
+			if _, ok := index[wikiKey(name)]; !ok {
+				index[wikiKey(name)] = f
+			}
+		}
+	}
+	return index
+}
+
+// wikiKey normalizes a page name, GitHub wikis store "Page Name" as Page-Name.md.
+func wikiKey(name string) string {
+	return strings.ToLower(strings.ReplaceAll(strings.TrimSpace(name), " ", "-"))
+}
+
+// link returns the link for the inner text of [[target#heading|text]]. Links
+// to missing pages point to where the page would be created.
+func (index wikiIndex) link(inner string) *ast.Link {
+	target, text, aliased := strings.Cut(inner, "|")
+	target, heading, _ := strings.Cut(strings.TrimSpace(target), "#")
+	if !aliased {
+		text = inner
+	}
+
+	var dest string
+	missing := false
+	if target != "" {
+		file, ok := index[wikiKey(target)]
+		if !ok {
+			file = strings.TrimPrefix(target, "/") + ".md"
+			missing = true
+		}
+		dest = (&url.URL{Path: "/" + file}).EscapedPath()
+	}
+	if heading != "" {
+		dest += "#" + url.PathEscape(wikiKey(heading))
+	}
+
+	link := &ast.Link{Destination: []byte(dest)}
+	if missing {
+		link.AdditionalAttributes = []string{`class="grip-wikilink-missing"`}
+	}
+	ast.AppendChild(link, &ast.Text{Leaf: ast.Leaf{Literal: []byte(html.EscapeString(strings.TrimSpace(text)))}})
+	return link
+}
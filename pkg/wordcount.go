@@ -0,0 +1,66 @@
+package pkg
+
+import (
+	"fmt"
+	"html"
+	"strings"
+	"time"
+
+	"github.com/gomarkdown/markdown/ast"
+)
+
+// wordsPerMinute is the reading speed the reading time is estimated with.
+const wordsPerMinute = 200
+
+// WithWordCount shows the number of words, the estimated reading time and the
+// last modification above served markdown pages.
+func WithWordCount(enabled bool) Option {
+	return func(s *Server) {
+		s.wordCount = enabled
+	}
+}
+
+// countWords counts the words of the text of a markdown document. Code
+// blocks and raw HTML are not counted.
+func (m Parser) countWords(md []byte) int {
+	var sb strings.Builder
+	ast.WalkFunc(m.parse(md), func(node ast.Node, entering bool) ast.WalkStatus {
+		switch n := node.(type) {
+		case *ast.CodeBlock, *ast.HTMLBlock, *ast.HTMLSpan:
+			return ast.SkipChildren
+		case *ast.Text:
+			sb.Write(n.Literal)
+		case *ast.Code:
+			sb.Write(n.Literal)
+		case *ast.Softbreak, *ast.Hardbreak:
+			sb.WriteString(" ")
+		case *ast.Paragraph, *ast.Heading, *ast.TableCell:
+			if !entering {
+				sb.WriteString("\n")
+			}
+		}
+		return ast.GoToNext
+	})
+	return len(strings.Fields(sb.String()))
+}
+
+// pageStats renders the word count, reading time and last modification of a
+// markdown page.
+func (s *Server) pageStats(content []byte, modTime time.Time) string {
+	words := s.parser.countWords(content)
+	minutes := max(1, (words+wordsPerMinute-1)/wordsPerMinute)
+
+	var sb strings.Builder
+	sb.WriteString(`<div class="grip-stats">`)
+	count := s.msg("%d words", words)
+	if words == 1 {
+		count = s.msg("%d word", words)
+	}
+	fmt.Fprintf(&sb, `<span>%s</span><span>%s</span>`, html.EscapeString(count), html.EscapeString(s.msg("%d min read", minutes)))
+	if !modTime.IsZero() {
+		fmt.Fprintf(&sb, `<span title="%s">%s</span>`,
+			html.EscapeString(modTime.Format(time.RFC3339)), html.EscapeString(s.msg("Modified %s", modTime.Format("2 Jan 2006 15:04"))))
+	}
+	sb.WriteString(`</div>`)
+	return sb.String()
+}